@@ -0,0 +1,149 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount of coins. It is stored as integer number of smallest units
+// to avoid rounding problems of float numbers
+type Amount int64
+
+// Number of digits after a point in a coins amount
+const AmountDecimals = 8
+
+// Number of smallest units in 1 coin
+const AmountUnit Amount = 100000000
+
+// Parse amount from a string in coins, like "10" or "0.5"
+func ParseAmount(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+
+	if s == "" {
+		return 0, errors.New("Amount is empty")
+	}
+
+	negative := false
+
+	if s[0] == '-' {
+		negative = true
+		s = s[1:]
+	}
+
+	parts := strings.SplitN(s, ".", 2)
+
+	// ParseInt would accept signs in parts, like "1.+5"
+	for _, part := range parts {
+		if !isDigits(part) {
+			return 0, errors.New(fmt.Sprintf("Wrong amount format: %s", s))
+		}
+	}
+
+	if s == "" || s == "." {
+		// no digits
+		return 0, errors.New(fmt.Sprintf("Wrong amount format: %s", s))
+	}
+
+	if parts[0] == "" {
+		parts[0] = "0"
+	}
+
+	coins, err := strconv.ParseInt(parts[0], 10, 64)
+
+	if err != nil || coins < 0 {
+		return 0, errors.New(fmt.Sprintf("Wrong amount format: %s", s))
+	}
+
+	if coins > math.MaxInt64/int64(AmountUnit) {
+		return 0, errors.New(fmt.Sprintf("Amount is too big: %s", s))
+	}
+
+	units := int64(0)
+
+	if len(parts) == 2 {
+		fraction := parts[1]
+
+		if len(fraction) > AmountDecimals {
+			return 0, errors.New(fmt.Sprintf("Amount can have max %d digits after a point: %s", AmountDecimals, s))
+		}
+
+		if fraction != "" {
+			fraction = fraction + strings.Repeat("0", AmountDecimals-len(fraction))
+
+			units, err = strconv.ParseInt(fraction, 10, 64)
+
+			if err != nil || units < 0 {
+				return 0, errors.New(fmt.Sprintf("Wrong amount format: %s", s))
+			}
+		}
+	}
+
+	amount := Amount(coins)*AmountUnit + Amount(units)
+
+	if negative {
+		amount = -amount
+	}
+
+	return amount, nil
+}
+
+// Returns true if a string has only ASCII digits. Empty string is allowed
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Converts float number of coins to amount. It is used to convert data of old format
+func AmountFromFloat(value float64) Amount {
+	return Amount(math.Round(value * float64(AmountUnit)))
+}
+
+// Format amount as coins with all digits after a point
+func (a Amount) String() string {
+	sign := ""
+	v := int64(a)
+
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	return fmt.Sprintf("%s%d.%08d", sign, v/int64(AmountUnit), v%int64(AmountUnit))
+}
+
+// Set amount from a string. With this Amount can be used as command line flag
+func (a *Amount) Set(s string) error {
+	v, err := ParseAmount(s)
+
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Checks if an amount can be a value of an output. It can not be more than all coins ever
+func (a Amount) IsValid() bool {
+	return a >= 0 && a <= MaxSupply
+}
+
+// Adds amounts of outputs. Fails if any of them is not valid or the sum is more than max supply,
+// so sums of values of a transaction can not overflow
+func AddAmounts(a, b Amount) (Amount, error) {
+	if !a.IsValid() || !b.IsValid() {
+		return 0, errors.New(fmt.Sprintf("Amount is out of range: %d + %d", int64(a), int64(b)))
+	}
+
+	if a > math.MaxInt64-b || a+b > MaxSupply {
+		return 0, errors.New("Sum of amounts is more than max supply")
+	}
+
+	return a + b, nil
+}
@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	cases := map[string]Amount{
+		"10":          10 * AmountUnit,
+		"0.5":         AmountUnit / 2,
+		".1":          AmountUnit / 10,
+		"3.":          3 * AmountUnit,
+		"1.00000001":  AmountUnit + 1,
+		"-2.25":       -(2*AmountUnit + AmountUnit/4),
+		"0.00000001":  1,
+		"12345678.99": 12345678*AmountUnit + 99*AmountUnit/100,
+	}
+	for input, expected := range cases {
+		result, err := ParseAmount(input)
+
+		if err != nil {
+			t.Fatalf("Error for %s: %s", input, err.Error())
+		}
+
+		if result != expected {
+			t.Fatalf("Got %d, expected %d for %s", result, expected, input)
+		}
+	}
+
+	for _, input := range []string{"", "abc", "1.000000001", "1.2.3", "--1", "1e5", "1.+5", "1.-5", "+1", ".", "-", "-.", "1. 5", "１"} {
+		_, err := ParseAmount(input)
+
+		if err == nil {
+			t.Fatalf("Expected error for %s", input)
+		}
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	cases := map[Amount]string{
+		10 * AmountUnit: "10.00000000",
+		1:               "0.00000001",
+		-AmountUnit / 2: "-0.50000000",
+		0:               "0.00000000",
+	}
+	for input, expected := range cases {
+		if result := input.String(); result != expected {
+			t.Fatalf("Got %s, expected %s", result, expected)
+		}
+	}
+
+	if AmountFromFloat(0.1+0.2) != 3*AmountUnit/10 {
+		t.Fatalf("Float conversion is wrong: %s", AmountFromFloat(0.1+0.2))
+	}
+}
+
+func TestAddAmounts(t *testing.T) {
+	sum, err := AddAmounts(AmountUnit, 2*AmountUnit)
+
+	if err != nil || sum != 3*AmountUnit {
+		t.Fatalf("Got %s, %v", sum, err)
+	}
+
+	if _, err = AddAmounts(MaxSupply, 1); err == nil {
+		t.Fatalf("Expected error for sum more than max supply")
+	}
+
+	if _, err = AddAmounts(0, math.MaxInt64); err == nil {
+		t.Fatalf("Expected error for huge amount")
+	}
+
+	if _, err = AddAmounts(-1, AmountUnit); err == nil {
+		t.Fatalf("Expected error for negative amount")
+	}
+}
@@ -0,0 +1,42 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Rules of block timestamps. A miner chooses a time of a block, so it can not be trusted alone.
+// A block time must be more than a median time of previous blocks and can not be far in the future.
+// Difficulty retarget and time locks of transactions depend on these rules
+
+// Median time past is calculated from this number of last blocks
+const MedianTimeSpan = 11
+
+// Max number of seconds a block time can be ahead of local time of a node
+const MaxFutureBlockTime = 2 * 60 * 60
+
+// Returns median of given times of blocks. Order of times doesn't matter
+func GetMedianTime(times []int64) int64 {
+	if len(times) == 0 {
+		return 0
+	}
+	sorted := make([]int64, len(times))
+	copy(sorted, times)
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return sorted[len(sorted)/2]
+}
+
+// Checks a time of a block. medianTime is a median time past of previous block, now is local time
+func CheckBlockTime(timestamp int64, medianTime int64, now int64) error {
+	if timestamp <= medianTime {
+		return errors.New(fmt.Sprintf("Block time %d is not more than median time of previous blocks %d", timestamp, medianTime))
+	}
+
+	if timestamp > now+MaxFutureBlockTime {
+		return errors.New(fmt.Sprintf("Block time %d is too far in the future", timestamp))
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestGetMedianTime(t *testing.T) {
+	tests := []struct {
+		times  []int64
+		result int64
+	}{
+		{[]int64{}, 0},
+		{[]int64{5}, 5},
+		{[]int64{3, 1, 2}, 2},
+		{[]int64{10, 20, 30, 40}, 30},
+		// a single time far in the future doesn't move the median
+		{[]int64{1, 2, 3, 4, 1000000}, 3},
+	}
+
+	for _, test := range tests {
+		m := GetMedianTime(test.times)
+
+		if m != test.result {
+			t.Fatalf("For %v got %d, expected %d", test.times, m, test.result)
+		}
+	}
+}
+
+func TestCheckBlockTime(t *testing.T) {
+	now := int64(1000000)
+
+	tests := []struct {
+		timestamp int64
+		median    int64
+		good      bool
+	}{
+		{now, now - 100, true},
+		{now - 99, now - 100, true},
+		// must be more than median
+		{now - 100, now - 100, false},
+		{now - 200, now - 100, false},
+		// some drift of clocks is fine
+		{now + MaxFutureBlockTime, now - 100, true},
+		{now + MaxFutureBlockTime + 1, now - 100, false},
+	}
+
+	for _, test := range tests {
+		err := CheckBlockTime(test.timestamp, test.median, now)
+
+		if (err == nil) != test.good {
+			t.Fatalf("Time %d with median %d: got error %v", test.timestamp, test.median, err)
+		}
+	}
+}
@@ -6,8 +6,19 @@ const ApplicationVersion = "0.1 beta"
 const Version = byte(0x00)
 const AddressChecksumLen = 4
 
-const PaymentForBlockMade = 10
+// Size of a hash of a public key in outputs and addresses
+const PubKeyHashLen = 20
+
+// Payment to a miner for a block before first halving
+const PaymentForBlockMade Amount = 10 * AmountUnit
+
+// Payment for a block is halved every this number of blocks
+const HalvingInterval = 210000
+
+// Max number of coins ever. Sum of all payments for blocks can not be more
+const MaxSupply Amount = 4200000 * AmountUnit
 
 const InitialNodesList = "http://librasdk.io/taincoin/initialnodes.json"
 
-const SmallestUnit = 0.00000001
+// Smallest amount that can be sent
+const SmallestUnit Amount = 1
@@ -0,0 +1,72 @@
+package lib
+
+import (
+	"math"
+)
+
+// Difficulty rules of proof of work chains. They are used by nodes to check blocks
+// and by light clients to check headers, so both must get same results
+
+// TargetBits is the difficulty of a genesis block and first blocks before any retarget
+const TargetBits = 16
+
+// Difficulty can not go out of this range
+const MinTargetBits = 8
+const MaxTargetBits = 48
+
+// Difficulty is recalculated every this number of blocks
+const DifficultyAdjustmentInterval = 20
+
+// Expected time between blocks, in seconds. Retarget tries to keep this speed
+const TargetBlockTime = 10
+
+// Max change of difficulty in one retarget. 2 bits means target can change 4 times max
+const MaxTargetBitsChange = 2
+
+// Check if difficulty is recalculated on a block with given height
+func IsRetargetHeight(height int) bool {
+	return height > 0 && height%DifficultyAdjustmentInterval == 0
+}
+
+// Returns difficulty a block with given height must have. prevBits is a difficulty of previous block.
+// timespan is time between a block at height-DifficultyAdjustmentInterval and previous block,
+// it is used only on retarget heights
+func GetNextTargetBits(height int, prevBits int, timespan int64) int {
+	if height == 0 {
+		// genesis block
+		return TargetBits
+	}
+
+	if !IsRetargetHeight(height) {
+		return prevBits
+	}
+	return CalculateNextTargetBits(prevBits, timespan)
+}
+
+// Calculates new difficulty from previous difficulty and time spent to make last interval of blocks
+// Every bit means the target is changed 2 times, so we add log2 of speed ratio
+func CalculateNextTargetBits(prevBits int, timespan int64) int {
+	expected := int64(DifficultyAdjustmentInterval-1) * TargetBlockTime
+
+	if timespan < 1 {
+		timespan = 1
+	}
+
+	change := int(math.Round(math.Log2(float64(expected) / float64(timespan))))
+
+	if change > MaxTargetBitsChange {
+		change = MaxTargetBitsChange
+	} else if change < -MaxTargetBitsChange {
+		change = -MaxTargetBitsChange
+	}
+
+	bits := prevBits + change
+
+	if bits < MinTargetBits {
+		bits = MinTargetBits
+	} else if bits > MaxTargetBits {
+		bits = MaxTargetBits
+	}
+
+	return bits
+}
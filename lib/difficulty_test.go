@@ -0,0 +1,37 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestCalculateNextTargetBits(t *testing.T) {
+	expected := int64(DifficultyAdjustmentInterval-1) * TargetBlockTime
+
+	tests := []struct {
+		prevBits int
+		timespan int64
+		result   int
+	}{
+		// blocks are made as expected. no change
+		{16, expected, 16},
+		// 2 times faster. one bit more
+		{16, expected / 2, 17},
+		// 2 times slower. one bit less
+		{16, expected * 2, 15},
+		// too fast. change is limited
+		{16, 0, 16 + MaxTargetBitsChange},
+		// too slow. change is limited
+		{16, expected * 1000, 16 - MaxTargetBitsChange},
+		// can not go out of range
+		{MinTargetBits, expected * 4, MinTargetBits},
+		{MaxTargetBits, 1, MaxTargetBits},
+	}
+
+	for _, test := range tests {
+		bits := CalculateNextTargetBits(test.prevBits, test.timespan)
+
+		if bits != test.result {
+			t.Fatalf("For %d bits and timespan %d got %d, expected %d", test.prevBits, test.timespan, bits, test.result)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package lib
+
+// Rules of emission of new coins. A miner gets a reward for every block.
+// The reward is halved every HalvingInterval blocks, and total of all rewards
+// can not be more than MaxSupply
+type EmissionSchedule struct {
+	InitialReward   Amount // reward for blocks before first halving
+	HalvingInterval int    // number of blocks between halvings. 0 means no halvings
+	MaxSupply       Amount // max number of coins ever. 0 means no limit
+}
+
+// Returns default emission schedule
+func DefaultEmissionSchedule() EmissionSchedule {
+	return EmissionSchedule{PaymentForBlockMade, HalvingInterval, MaxSupply}
+}
+
+// Check if a schedule is not set
+func (e EmissionSchedule) IsEmpty() bool {
+	return e.InitialReward == 0 && e.HalvingInterval == 0 && e.MaxSupply == 0
+}
+
+// Returns a reward for a block with given height without the supply limit
+func (e EmissionSchedule) getBaseReward(height int) Amount {
+	if height < 0 {
+		return 0
+	}
+	if e.HalvingInterval <= 0 {
+		return e.InitialReward
+	}
+
+	halvings := height / e.HalvingInterval
+
+	if halvings >= 63 {
+		return 0
+	}
+	return e.InitialReward >> uint(halvings)
+}
+
+// Returns a reward for a block with given height. Near the supply limit it is only what is left
+func (e EmissionSchedule) GetBlockReward(height int) Amount {
+	reward := e.getBaseReward(height)
+
+	if e.MaxSupply <= 0 || reward == 0 {
+		return reward
+	}
+
+	left := e.MaxSupply - e.GetSupply(height-1)
+
+	if reward > left {
+		return left
+	}
+	return reward
+}
+
+// Returns total of rewards of all blocks from genesis to a block with given height (including it)
+// This is number of coins in circulation after that block
+func (e EmissionSchedule) GetSupply(height int) Amount {
+	if height < 0 {
+		return 0
+	}
+
+	supply := Amount(0)
+
+	if e.HalvingInterval <= 0 {
+		supply = e.InitialReward * Amount(height+1)
+	} else {
+		// sum rewards period by period. reward is same inside a period
+		for start := 0; start <= height; start += e.HalvingInterval {
+			reward := e.getBaseReward(start)
+
+			if reward == 0 {
+				break
+			}
+
+			blocks := e.HalvingInterval
+
+			if height-start+1 < blocks {
+				blocks = height - start + 1
+			}
+
+			supply += reward * Amount(blocks)
+
+			if e.MaxSupply > 0 && supply >= e.MaxSupply {
+				break
+			}
+		}
+	}
+
+	if e.MaxSupply > 0 && supply > e.MaxSupply {
+		return e.MaxSupply
+	}
+	return supply
+}
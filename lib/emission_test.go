@@ -0,0 +1,52 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestEmissionSchedule(t *testing.T) {
+	e := EmissionSchedule{8 * AmountUnit, 10, 0}
+
+	cases := map[int]Amount{
+		0:   8 * AmountUnit,
+		9:   8 * AmountUnit,
+		10:  4 * AmountUnit,
+		25:  2 * AmountUnit,
+		400: 0,
+	}
+	for height, expected := range cases {
+		if result := e.GetBlockReward(height); result != expected {
+			t.Fatalf("Got reward %s, expected %s for height %d", result, expected, height)
+		}
+	}
+
+	if supply := e.GetSupply(14); supply != 100*AmountUnit {
+		t.Fatalf("Got supply %s, expected %s", supply, 100*AmountUnit)
+	}
+
+	// cap is reached inside third period
+	e.MaxSupply = 129 * AmountUnit
+
+	if reward := e.GetBlockReward(24); reward != AmountUnit {
+		t.Fatalf("Got last reward %s, expected %s", reward, AmountUnit)
+	}
+
+	if reward := e.GetBlockReward(25); reward != 0 {
+		t.Fatalf("Got reward %s after cap, expected 0", reward)
+	}
+
+	if supply := e.GetSupply(1000); supply != e.MaxSupply {
+		t.Fatalf("Got supply %s, expected %s", supply, e.MaxSupply)
+	}
+
+	// sum of all rewards is always equal to supply
+	total := Amount(0)
+
+	for h := 0; h < 40; h++ {
+		total += e.GetBlockReward(h)
+
+		if total != e.GetSupply(h) {
+			t.Fatalf("Sum of rewards %s is not equal to supply %s on height %d", total, e.GetSupply(h), h)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package nodeclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/taincoin/taincoin/lib"
+)
+
+// Checks a header of proof of work chain continues a chain of headers. prev are headers before it
+// in order of heights, the last one is the parent. It must have last DifficultyAdjustmentInterval
+// headers, or all headers from genesis, to check difficulty. now is local time.
+// Difficulty and time are checked with same rules as a node checks blocks, so a header can not
+// have easier proof of work than the chain requires
+func VerifyNextHeader(h ComBlockHeader, prev []ComBlockHeader, now int64) error {
+	prevBits := 0
+	timespan := int64(0)
+	medianTime := int64(0)
+
+	if len(prev) == 0 {
+		if h.Height != 0 || len(h.PrevBlockHash) > 0 {
+			return errors.New("First header must have no previous block")
+		}
+	} else {
+		last := prev[len(prev)-1]
+
+		if h.Height != last.Height+1 {
+			return errors.New(fmt.Sprintf("Header height %d is wrong. Expected %d", h.Height, last.Height+1))
+		}
+
+		if !bytes.Equal(h.PrevBlockHash, last.Hash) {
+			return errors.New(fmt.Sprintf("Header %d is not linked to previous header", h.Height))
+		}
+
+		if lib.IsRetargetHeight(h.Height) {
+			if len(prev) < lib.DifficultyAdjustmentInterval {
+				return errors.New(fmt.Sprintf("Not enough headers to check difficulty of header %d", h.Height))
+			}
+			timespan = last.Timestamp - prev[len(prev)-lib.DifficultyAdjustmentInterval].Timestamp
+		}
+		prevBits = last.Bits
+		medianTime = GetHeadersMedianTime(prev)
+	}
+
+	bits := lib.GetNextTargetBits(h.Height, prevBits, timespan)
+
+	if h.Bits != bits {
+		return errors.New(fmt.Sprintf("Header %d difficulty %d is wrong. Expected %d", h.Height, h.Bits, bits))
+	}
+
+	err := lib.CheckBlockTime(h.Timestamp, medianTime, now)
+
+	if err != nil {
+		return err
+	}
+
+	if !h.VerifyPoW() {
+		return errors.New(fmt.Sprintf("Proof of work of header %d is not valid", h.Height))
+	}
+	return nil
+}
+
+// Returns median time past of the last header in a list. A next header must have bigger time
+func GetHeadersMedianTime(headers []ComBlockHeader) int64 {
+	if len(headers) > lib.MedianTimeSpan {
+		headers = headers[len(headers)-lib.MedianTimeSpan:]
+	}
+
+	times := []int64{}
+
+	for _, h := range headers {
+		times = append(times, h.Timestamp)
+	}
+	return lib.GetMedianTime(times)
+}
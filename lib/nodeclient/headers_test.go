@@ -0,0 +1,81 @@
+package nodeclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+// Makes a header on top of prev and finds a nonce for it
+func mineHeader(prev []ComBlockHeader, timestamp int64, bits int) ComBlockHeader {
+	h := ComBlockHeader{MerkleRoot: []byte("root"), Timestamp: timestamp, Bits: bits}
+
+	if len(prev) > 0 {
+		h.PrevBlockHash = prev[len(prev)-1].Hash
+		h.Height = prev[len(prev)-1].Height + 1
+	}
+
+	for {
+		data := bytes.Join([][]byte{h.PrevBlockHash, h.MerkleRoot, utils.IntToHex(h.Timestamp),
+			utils.IntToHex(int64(h.Bits)), utils.IntToHex(int64(h.Nonce))}, []byte{})
+
+		hash := sha256.Sum256(data)
+		h.Hash = hash[:]
+
+		if h.VerifyPoW() {
+			return h
+		}
+		h.Nonce++
+	}
+}
+
+func TestVerifyNextHeader(t *testing.T) {
+	now := int64(1000000)
+
+	headers := []ComBlockHeader{}
+
+	for i := 0; i < 3; i++ {
+		h := mineHeader(headers, now-100+int64(i)*lib.TargetBlockTime, lib.TargetBits)
+
+		assert.Nil(t, VerifyNextHeader(h, headers, now), "Header is valid")
+
+		headers = append(headers, h)
+	}
+
+	easy := mineHeader(headers, now, lib.MinTargetBits)
+	assert.NotNil(t, VerifyNextHeader(easy, headers, now), "Header with easier difficulty is not valid")
+
+	old := mineHeader(headers, headers[1].Timestamp, lib.TargetBits)
+	assert.NotNil(t, VerifyNextHeader(old, headers, now), "Header older than median time is not valid")
+
+	future := mineHeader(headers, now+lib.MaxFutureBlockTime+1, lib.TargetBits)
+	assert.NotNil(t, VerifyNextHeader(future, headers, now), "Header far in the future is not valid")
+
+	other := mineHeader(headers[:2], now, lib.TargetBits)
+	assert.NotNil(t, VerifyNextHeader(other, headers, now), "Header of other branch is not valid")
+}
+
+func TestVerifyNextHeaderRetarget(t *testing.T) {
+	now := int64(1000000)
+
+	// headers of the interval are not checked, only times and difficulty are used
+	headers := []ComBlockHeader{}
+
+	for i := 0; i < lib.DifficultyAdjustmentInterval; i++ {
+		// blocks are made 2 times faster than expected
+		headers = append(headers, ComBlockHeader{Hash: []byte{byte(i)}, Height: i,
+			Timestamp: now - 1000 + int64(i)*lib.TargetBlockTime/2, Bits: lib.TargetBits})
+	}
+
+	h := mineHeader(headers, now, lib.TargetBits)
+	assert.NotNil(t, VerifyNextHeader(h, headers, now), "Difficulty must be changed on retarget")
+
+	h = mineHeader(headers, now, lib.TargetBits+1)
+	assert.Nil(t, VerifyNextHeader(h, headers, now), "Difficulty is increased")
+
+	assert.NotNil(t, VerifyNextHeader(h, headers[1:], now), "Not enough headers to check difficulty")
+}
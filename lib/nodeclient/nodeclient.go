@@ -15,6 +15,7 @@ import (
 	"io/ioutil"
 	"net"
 
+	"github.com/taincoin/taincoin/lib"
 	netlib "github.com/taincoin/taincoin/lib/net"
 	"github.com/taincoin/taincoin/lib/utils"
 )
@@ -54,9 +55,9 @@ type ComGetData struct {
 
 // Wallet Balance response
 type ComWalletBalance struct {
-	Total    float64
-	Approved float64
-	Pending  float64
+	Total    lib.Amount
+	Approved lib.Amount
+	Pending  lib.Amount
 }
 
 // Request for a wallet balance
@@ -83,8 +84,10 @@ type ComNewTransactionData struct {
 type ComRequestTransaction struct {
 	PubKey    []byte
 	To        string
-	Amount    float64
-	Signature []byte // to confirm request is from owner of PubKey (TODO)
+	Amount    lib.Amount
+	Fee       lib.Amount // what is left for a miner. Transactions with bigger fee are added to blocks first
+	Signature []byte     // to confirm request is from owner of PubKey (TODO)
+	Data      []byte     // data for a data output. To and Amount are not used if it is set
 }
 
 // Response on prepare transaction request. Returns transaction without signs
@@ -92,6 +95,7 @@ type ComRequestTransaction struct {
 type ComRequestTransactionData struct {
 	TX         []byte
 	DataToSign [][]byte
+	PrevTXs    [][]byte // transactions spent by inputs, without IDs. To check the transaction offline
 }
 
 // For request to get list of unspent transactions by wallet
@@ -104,7 +108,7 @@ type ComGetUnspentTransactions struct {
 type ComUnspentTransaction struct {
 	TXID   []byte
 	Vout   int
-	Amount float64
+	Amount lib.Amount
 	IsBase bool
 	From   string
 }
@@ -124,7 +128,7 @@ type ComGetHistoryTransactions struct {
 type ComHistoryTransaction struct {
 	IOType bool // In (false) or Out (true)
 	TXID   []byte
-	Amount float64
+	Amount lib.Amount
 	From   string
 	To     string
 }
@@ -154,6 +158,12 @@ type ComManageNode struct {
 	Node netlib.NodeAddr
 }
 
+// To unlock encrypted wallet of a node. Timeout is in seconds, 0 means until the node stops
+type ComUnlockWallet struct {
+	Passphrase string
+	Timeout    int
+}
+
 // To get node state
 type ComGetNodeState struct {
 	Host                  string
@@ -161,6 +171,117 @@ type ComGetNodeState struct {
 	ExpectingBlocksHeight int
 	TransactionsCached    int
 	UnspentOutputs        int
+	Syncing               bool   // headers first synchronization is in progress
+	SyncNode              string // node headers are loaded from
+	SyncHeadersHeight     int    // height of last verified header
+	SyncBlocksHeight      int    // height of last block added during synchronization
+	SyncNodes             int    // number of nodes bodies of blocks are loaded from
+}
+
+// Request for a supply of coins. Negative height means a top block
+type ComGetSupply struct {
+	Height int
+}
+
+// Supply of coins after a block
+type ComSupply struct {
+	Height      int
+	Supply      lib.Amount // coins in circulation after a block
+	BlockReward lib.Amount // payment for a block on this height
+	MaxSupply   lib.Amount // 0 means no limit
+}
+
+// Request for block headers of the main chain. Is used by light wallets and by nodes
+// for headers first synchronization
+type ComGetHeaders struct {
+	From  int // height of first block
+	Count int
+}
+
+// Block header. It has all data needed to check a block hash and proof of work
+type ComBlockHeader struct {
+	Hash          []byte
+	PrevBlockHash []byte
+	MerkleRoot    []byte
+	Timestamp     int64
+	Nonce         int
+	Height        int
+	Bits          int
+}
+
+// Request for a proof that a transaction is in a block of the main chain
+type ComGetMerkleProof struct {
+	TXID []byte
+}
+
+// Transaction output in a Merkle proof
+type ComTXOutput struct {
+	Value      lib.Amount
+	PubKeyHash []byte
+	Data       []byte
+}
+
+// Proof that a transaction is in a block. Parts of a transaction are included to build
+// same bytes as a node uses for a Merkle tree
+type ComMerkleProof struct {
+	BlockHash []byte
+	Proof     []utils.MerkleProofStep
+	TXID      []byte
+	Inputs    [][]byte // each input as bytes
+	Outputs   []ComTXOutput
+	Time      int64
+	LockTime  int64
+}
+
+// Request for a secret of hashed time-locked contracts
+type ComGetSecret struct {
+	SecretHash []byte
+}
+
+// Secret revealed in a transaction. Empty if it is not found
+type ComSecret struct {
+	Secret []byte
+	TXID   []byte
+}
+
+// Request for a block where data were anchored with a data output
+type ComGetAnchor struct {
+	Data []byte
+}
+
+// Transaction with a data output and its block. Empty if data are not found
+type ComAnchor struct {
+	TXID      []byte
+	BlockHash []byte
+	Height    int
+	Timestamp int64
+}
+
+// Request to timestamp hashes of documents. They are anchored with next batch
+type ComStamp struct {
+	Hashes [][]byte
+}
+
+// Request for a receipt of a timestamped hash
+type ComGetReceipt struct {
+	Hash []byte
+}
+
+// Receipt of a timestamped hash. Proof is a path from the hash to a root of a batch,
+// the root is a data output of a transaction proved by TXProof.
+// Root is empty while the batch is not in a block yet
+type ComStampReceipt struct {
+	Hash      []byte
+	Proof     []utils.MerkleProofStep
+	Root      []byte
+	TXProof   ComMerkleProof
+	Height    int   // not verified. Use headers to know time of a block
+	Timestamp int64 // not verified
+}
+
+// Request for a full block by a hash. Response is serialised block
+type ComGetBlock struct {
+	Hash []byte
 }
 
 // Check if node address looks fine
@@ -389,15 +510,48 @@ func (c *NodeClient) SendNewTransactionData(addr netlib.NodeAddr, from string, t
 // It returns a transaction without signature.
 // Wallet has to sign it and then use SendNewTransaction to send completed transaction
 func (c *NodeClient) SendRequestNewTransaction(addr netlib.NodeAddr,
-	PubKey []byte, to string, amount float64) ([]byte, [][]byte, error) {
+	PubKey []byte, to string, amount lib.Amount, fee lib.Amount) ([]byte, [][]byte, error) {
+
+	datapayload, err := c.SendRequestOfflineTransaction(addr, PubKey, to, amount, fee)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return datapayload.TX, datapayload.DataToSign, nil
+}
+
+// Same as SendRequestNewTransaction but returns also transactions spent by inputs.
+// A transaction is signed on other machine, it checks values of inputs with them
+func (c *NodeClient) SendRequestOfflineTransaction(addr netlib.NodeAddr,
+	PubKey []byte, to string, amount lib.Amount, fee lib.Amount) (ComRequestTransactionData, error) {
 
 	data := ComRequestTransaction{}
 	data.PubKey = PubKey
 	data.To = to
 	data.Amount = amount
+	data.Fee = fee
 
 	request, err := c.BuildCommandData("txrequest", &data)
 
+	if err != nil {
+		return ComRequestTransactionData{}, err
+	}
+
+	datapayload := ComRequestTransactionData{}
+
+	err = c.SendDataWaitResponse(addr, request, &datapayload)
+
+	return datapayload, err
+}
+
+// Request to prepare new transaction with a data output. Inputs cover only a fee.
+// Works same way as SendRequestNewTransaction
+func (c *NodeClient) SendRequestNewDataTransaction(addr netlib.NodeAddr,
+	PubKey []byte, data []byte, fee lib.Amount) ([]byte, [][]byte, error) {
+
+	request, err := c.BuildCommandData("txrequest", &ComRequestTransaction{PubKey: PubKey, Fee: fee, Data: data})
+
 	if err != nil {
 		return nil, nil, err
 	}
@@ -449,6 +603,144 @@ func (c *NodeClient) SendGetBalance(addr netlib.NodeAddr, address string) (ComWa
 	return datapayload, nil
 }
 
+// Request for a supply of coins after a block with given height
+func (c *NodeClient) SendGetSupply(addr netlib.NodeAddr, height int) (ComSupply, error) {
+	data := ComGetSupply{height}
+
+	request, err := c.BuildCommandData("getsupply", &data)
+
+	datapayload := ComSupply{}
+
+	err = c.SendDataWaitResponse(addr, request, &datapayload)
+
+	if err != nil {
+		return ComSupply{}, err
+	}
+
+	return datapayload, nil
+}
+
+// Request for block headers of the main chain starting from given height
+func (c *NodeClient) SendGetHeaders(addr netlib.NodeAddr, from int, count int) ([]ComBlockHeader, error) {
+	data := ComGetHeaders{from, count}
+
+	request, err := c.BuildCommandData("getheaders", &data)
+
+	datapayload := []ComBlockHeader{}
+
+	err = c.SendDataWaitResponse(addr, request, &datapayload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return datapayload, nil
+}
+
+// Request for a proof that a transaction is in a block of the main chain
+func (c *NodeClient) SendGetMerkleProof(addr netlib.NodeAddr, txID []byte) (ComMerkleProof, error) {
+	data := ComGetMerkleProof{txID}
+
+	request, err := c.BuildCommandData("getproof", &data)
+
+	datapayload := ComMerkleProof{}
+
+	err = c.SendDataWaitResponse(addr, request, &datapayload)
+
+	if err != nil {
+		return ComMerkleProof{}, err
+	}
+
+	return datapayload, nil
+}
+
+// Request for a secret of a hashed time-locked contract revealed in the blockchain
+func (c *NodeClient) SendGetSecret(addr netlib.NodeAddr, secretHash []byte) (ComSecret, error) {
+	data := ComGetSecret{secretHash}
+
+	request, err := c.BuildCommandData("getsecret", &data)
+
+	if err != nil {
+		return ComSecret{}, err
+	}
+
+	datapayload := ComSecret{}
+
+	err = c.SendDataWaitResponse(addr, request, &datapayload)
+
+	if err != nil {
+		return ComSecret{}, err
+	}
+
+	return datapayload, nil
+}
+
+// Request for a block where data were anchored
+func (c *NodeClient) SendGetAnchor(addr netlib.NodeAddr, data []byte) (ComAnchor, error) {
+	request, err := c.BuildCommandData("getanchor", &ComGetAnchor{data})
+
+	if err != nil {
+		return ComAnchor{}, err
+	}
+
+	datapayload := ComAnchor{}
+
+	err = c.SendDataWaitResponse(addr, request, &datapayload)
+
+	if err != nil {
+		return ComAnchor{}, err
+	}
+
+	return datapayload, nil
+}
+
+// Sends hashes to the timestamping service of a node. It is allowed only with auth of a local node
+func (c *NodeClient) SendStamp(addr netlib.NodeAddr, hashes [][]byte) error {
+	request, err := c.BuildCommandDataWithAuth("stamp", &ComStamp{hashes})
+
+	if err != nil {
+		return err
+	}
+
+	return c.SendDataWaitResponse(addr, request, nil)
+}
+
+// Request for a receipt of a timestamped hash
+func (c *NodeClient) SendGetReceipt(addr netlib.NodeAddr, hash []byte) (ComStampReceipt, error) {
+	request, err := c.BuildCommandData("getreceipt", &ComGetReceipt{hash})
+
+	if err != nil {
+		return ComStampReceipt{}, err
+	}
+
+	datapayload := ComStampReceipt{}
+
+	err = c.SendDataWaitResponse(addr, request, &datapayload)
+
+	if err != nil {
+		return ComStampReceipt{}, err
+	}
+
+	return datapayload, nil
+}
+
+// Request for a full block. Returns serialised block
+func (c *NodeClient) SendGetBlock(addr netlib.NodeAddr, hash []byte) ([]byte, error) {
+	data := ComGetBlock{hash}
+
+	request, err := c.BuildCommandData("getblock", &data)
+
+	datapayload := []byte{}
+
+	err = c.SendDataWaitResponse(addr, request, &datapayload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return datapayload, nil
+}
+
 // Request for list of nodes in contacts
 func (c *NodeClient) SendGetNodes() ([]netlib.NodeAddr, error) {
 	request, err := c.BuildCommandData("getnodes", nil)
@@ -492,6 +784,33 @@ func (c *NodeClient) SendRemoveNode(node netlib.NodeAddr) error {
 	return nil
 }
 
+// Request to keep wallet of a node unlocked. It is used to make blocks and send from RPC
+func (c *NodeClient) SendUnlockWallet(passphrase string, timeout int) error {
+	data := ComUnlockWallet{passphrase, timeout}
+	request, err := c.BuildCommandDataWithAuth("unlockwallet", &data)
+
+	err = c.SendDataWaitResponse(c.NodeAddress, request, nil)
+
+	if err != nil {
+		return errors.New(fmt.Sprintf("Unlock Wallet Response Error: %s", err.Error()))
+	}
+
+	return nil
+}
+
+// Request to remove wallet keys from memory of a node
+func (c *NodeClient) SendLockWallet() error {
+	request, err := c.BuildCommandDataWithAuth("lockwallet", nil)
+
+	err = c.SendDataWaitResponse(c.NodeAddress, request, nil)
+
+	if err != nil {
+		return errors.New(fmt.Sprintf("Lock Wallet Response Error: %s", err.Error()))
+	}
+
+	return nil
+}
+
 // Request to remove a node from contacts
 func (c *NodeClient) SendGetState() (ComGetNodeState, error) {
 	request, err := c.BuildCommandDataWithAuth("getstate", nil)
@@ -0,0 +1,108 @@
+package nodeclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+// Max number of headers a node returns for one request
+const MaxHeadersPerRequest = 1000
+
+// Size of a hash of a document for timestamping. It is SHA256 of a document
+const StampHashSize = 32
+
+// Checks a hash of a header and proof of work
+func (h ComBlockHeader) VerifyPoW() bool {
+	return utils.VerifyPoWHeader(h.PrevBlockHash, h.MerkleRoot, h.Timestamp, h.Bits, h.Nonce, h.Hash)
+}
+
+// Builds bytes of a transaction from parts. It is same data that a node uses in a Merkle tree.
+// Every part has a length before, so a node can not split same bytes to other outputs
+func (p ComMerkleProof) GetTransactionBytes() []byte {
+	buff := new(bytes.Buffer)
+
+	utils.WriteWithLength(buff, p.TXID)
+
+	binary.Write(buff, binary.BigEndian, uint32(len(p.Inputs)))
+
+	for _, in := range p.Inputs {
+		utils.WriteWithLength(buff, in)
+	}
+
+	binary.Write(buff, binary.BigEndian, uint32(len(p.Outputs)))
+
+	for _, out := range p.Outputs {
+		binary.Write(buff, binary.BigEndian, int64(out.Value))
+		utils.WriteWithLength(buff, out.PubKeyHash)
+		utils.WriteWithLength(buff, out.Data)
+	}
+
+	binary.Write(buff, binary.BigEndian, p.Time)
+	binary.Write(buff, binary.BigEndian, p.LockTime)
+
+	return buff.Bytes()
+}
+
+// Checks outputs have same form as a node accepts. Data can be only in an output with no value
+// and no key. Other outputs have a key hash of standard size
+func (p ComMerkleProof) CheckOutputs() error {
+	for _, out := range p.Outputs {
+		if len(out.Data) > 0 {
+			if out.Value != 0 || len(out.PubKeyHash) > 0 {
+				return errors.New("Data output can not have a value or a key")
+			}
+			continue
+		}
+
+		if len(out.PubKeyHash) != lib.PubKeyHashLen {
+			return errors.New("Key hash size of an output is wrong")
+		}
+	}
+	return nil
+}
+
+// Checks a transaction is in a block with given Merkle root
+func (p ComMerkleProof) Verify(merkleRoot []byte) bool {
+	if p.CheckOutputs() != nil {
+		return false
+	}
+	return utils.VerifyMerkleProof(p.GetTransactionBytes(), p.Proof, merkleRoot)
+}
+
+// Checks a receipt with Merkle root of the block from TXProof. The hash must lead to the root
+// of a batch and the transaction with the root in a data output must be in the block
+func (r ComStampReceipt) Verify(merkleRoot []byte) error {
+	if len(r.Root) == 0 {
+		return errors.New("Hash is not anchored yet")
+	}
+
+	if len(r.Hash) != StampHashSize {
+		return errors.New("Hash size is wrong")
+	}
+
+	if !utils.VerifyMerkleProof(r.Hash, r.Proof, r.Root) {
+		return errors.New("Hash is not in the batch")
+	}
+
+	anchored := false
+
+	for _, out := range r.TXProof.Outputs {
+		if out.Value == 0 && len(out.PubKeyHash) == 0 && bytes.Equal(out.Data, r.Root) {
+			anchored = true
+			break
+		}
+	}
+
+	if !anchored {
+		return errors.New("Transaction doesn't anchor the batch")
+	}
+
+	if !r.TXProof.Verify(merkleRoot) {
+		return errors.New("Transaction is not in the block")
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package nodeclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+func TestStampReceipt(t *testing.T) {
+	hashes := [][]byte{}
+
+	for _, doc := range []string{"doc1", "doc2", "doc3"} {
+		hash := sha256.Sum256([]byte(doc))
+		hashes = append(hashes, hash[:])
+	}
+
+	batchRoot := utils.NewMerkleTree(hashes).RootNode.Data
+
+	tx := ComMerkleProof{}
+	tx.TXID = []byte("txid")
+	tx.Inputs = [][]byte{[]byte("input")}
+	tx.Outputs = []ComTXOutput{{Value: 0, Data: batchRoot}, {Value: 5, PubKeyHash: bytes.Repeat([]byte{1}, lib.PubKeyHashLen)}}
+	tx.Time = 100
+
+	block := [][]byte{[]byte("coinbase"), tx.GetTransactionBytes()}
+	blockRoot := utils.NewMerkleTree(block).RootNode.Data
+
+	var err error
+
+	tx.Proof, err = utils.GetMerkleProof(block, 1)
+	assert.Nil(t, err)
+
+	receipt := ComStampReceipt{Hash: hashes[1], Root: batchRoot, TXProof: tx}
+
+	receipt.Proof, err = utils.GetMerkleProof(hashes, 1)
+	assert.Nil(t, err)
+
+	assert.Nil(t, receipt.Verify(blockRoot), "Receipt is valid")
+	assert.NotNil(t, receipt.Verify([]byte("other root")), "Receipt of other block is not valid")
+
+	other := receipt
+	other.Hash = []byte("doc4")
+	assert.NotNil(t, other.Verify(blockRoot), "Receipt of other hash is not valid")
+
+	// root of the batch is a node of the tree, it is not a stamped hash
+	other = receipt
+	other.Hash = batchRoot
+	other.Proof = nil
+	assert.NotNil(t, other.Verify(blockRoot), "Receipt of an inner node is not valid")
+
+	other = receipt
+	other.Root = hashes[0]
+	assert.NotNil(t, other.Verify(blockRoot), "Receipt with other root is not valid")
+
+	other = receipt
+	other.Root = []byte{}
+	assert.NotNil(t, other.Verify(blockRoot), "Pending receipt is not valid")
+}
+
+func TestMerkleProofOutputs(t *testing.T) {
+	victim := bytes.Repeat([]byte{7}, lib.PubKeyHashLen)
+
+	// data output of an attacker has bytes of a value and a key hash
+	data := new(bytes.Buffer)
+	binary.Write(data, binary.BigEndian, int64(5*lib.AmountUnit))
+	data.Write(victim)
+
+	tx := ComMerkleProof{}
+	tx.TXID = []byte("txid")
+	tx.Inputs = [][]byte{[]byte("input")}
+	tx.Outputs = []ComTXOutput{{Value: 0, Data: data.Bytes()}}
+	tx.Time = 100
+
+	block := [][]byte{[]byte("coinbase"), tx.GetTransactionBytes()}
+	blockRoot := utils.NewMerkleTree(block).RootNode.Data
+
+	var err error
+
+	tx.Proof, err = utils.GetMerkleProof(block, 1)
+	assert.Nil(t, err)
+
+	assert.True(t, tx.Verify(blockRoot), "Proof is valid")
+
+	tests := []struct {
+		name    string
+		outputs []ComTXOutput
+	}{
+		{"data split to a value and a key", []ComTXOutput{{Value: 0}, {Value: 5 * lib.AmountUnit, PubKeyHash: victim}}},
+		{"data as a key", []ComTXOutput{{Value: 0, PubKeyHash: data.Bytes()}}},
+		{"data output with a value", []ComTXOutput{{Value: 5, Data: data.Bytes()}}},
+		{"data output with a key", []ComTXOutput{{PubKeyHash: victim, Data: data.Bytes()}}},
+		{"short key", []ComTXOutput{{Value: 5, PubKeyHash: victim[1:]}}},
+	}
+
+	for _, test := range tests {
+		forged := tx
+		forged.Outputs = test.outputs
+
+		assert.False(t, forged.Verify(blockRoot), test.name)
+	}
+}
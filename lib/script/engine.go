@@ -0,0 +1,405 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+// Checks conditions that depend on a transaction. It is implemented by a node for every input
+type Checker interface {
+	// checks a signature of the input data
+	CheckSignature(signature, pubKey []byte) bool
+	// checks if a lock time of the transaction is same kind and not less than lockTime
+	CheckLockTime(lockTime int64) error
+	// checks if the spent output is old enough
+	CheckSequence(sequence int64) error
+}
+
+// Runs an unlocking script and then a locking script with same stack.
+// The unlocking script can only push data. Returns nil if the output can be spent
+func Execute(unlock, lock []byte, checker Checker) error {
+	if !IsPushOnly(unlock) {
+		return errors.New("Unlocking script must only push data")
+	}
+
+	e := engine{checker: checker}
+
+	err := e.run(unlock)
+
+	if err != nil {
+		return err
+	}
+
+	err = e.run(lock)
+
+	if err != nil {
+		return err
+	}
+
+	if len(e.stack) == 0 || !isTrue(e.stack[len(e.stack)-1]) {
+		return errors.New("Script result is false")
+	}
+	return nil
+}
+
+type engine struct {
+	stack   [][]byte
+	checker Checker
+	ops     int
+}
+
+func (e *engine) push(data []byte) error {
+	if len(e.stack) >= MaxStackSize {
+		return errors.New("Stack is too big")
+	}
+	e.stack = append(e.stack, data)
+	return nil
+}
+
+func (e *engine) pop() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, errors.New("Stack is empty")
+	}
+	data := e.stack[len(e.stack)-1]
+	e.stack = e.stack[:len(e.stack)-1]
+	return data, nil
+}
+
+func (e *engine) popNum() (int64, error) {
+	data, err := e.pop()
+
+	if err != nil {
+		return 0, err
+	}
+	return decodeNum(data)
+}
+
+func (e *engine) pushBool(v bool) error {
+	if v {
+		return e.push([]byte{1})
+	}
+	return e.push([]byte{})
+}
+
+func (e *engine) run(script []byte) error {
+	instructions, err := parse(script)
+
+	if err != nil {
+		return err
+	}
+
+	// state of every IF. Operations are executed only if all are true
+	conditions := []bool{}
+
+	executing := func() bool {
+		for _, c := range conditions {
+			if !c {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, in := range instructions {
+		if in.op > OP_16 {
+			e.ops++
+
+			if e.ops > MaxOps {
+				return errors.New("Too many operations in script")
+			}
+		}
+
+		if len(in.data) > MaxPushSize {
+			return errors.New("Pushed data are too big")
+		}
+
+		switch in.op {
+		case OP_IF, OP_NOTIF:
+			v := false
+
+			if executing() {
+				data, err := e.pop()
+
+				if err != nil {
+					return err
+				}
+				v = isTrue(data) == (in.op == OP_IF)
+			}
+			conditions = append(conditions, v)
+			continue
+		case OP_ELSE:
+			if len(conditions) == 0 {
+				return errors.New("ELSE without IF")
+			}
+			conditions[len(conditions)-1] = !conditions[len(conditions)-1]
+			continue
+		case OP_ENDIF:
+			if len(conditions) == 0 {
+				return errors.New("ENDIF without IF")
+			}
+			conditions = conditions[:len(conditions)-1]
+			continue
+		}
+
+		if !executing() {
+			continue
+		}
+
+		err = e.execute(in)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(conditions) > 0 {
+		return errors.New("IF without ENDIF")
+	}
+	return nil
+}
+
+func (e *engine) execute(in instruction) error {
+	switch {
+	case in.op == OP_0:
+		return e.push([]byte{})
+	case in.op <= OP_PUSHDATA2:
+		return e.push(in.data)
+	case in.op == OP_1NEGATE:
+		return e.push(encodeNum(-1))
+	case in.op >= OP_1 && in.op <= OP_16:
+		return e.push(encodeNum(int64(in.op - OP_1 + 1)))
+	}
+
+	switch in.op {
+	case OP_VERIFY:
+		return e.verify()
+
+	case OP_RETURN:
+		return errors.New("Script is unspendable")
+
+	case OP_DROP:
+		_, err := e.pop()
+		return err
+
+	case OP_DUP:
+		data, err := e.pop()
+
+		if err != nil {
+			return err
+		}
+		e.push(data)
+		return e.push(data)
+
+	case OP_SWAP:
+		a, err := e.pop()
+
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+
+		if err != nil {
+			return err
+		}
+		e.push(a)
+		return e.push(b)
+
+	case OP_SIZE:
+		if len(e.stack) == 0 {
+			return errors.New("Stack is empty")
+		}
+		return e.push(encodeNum(int64(len(e.stack[len(e.stack)-1]))))
+
+	case OP_EQUAL, OP_EQUALVERIFY:
+		a, err := e.pop()
+
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+
+		if err != nil {
+			return err
+		}
+		e.pushBool(bytes.Equal(a, b))
+
+		if in.op == OP_EQUALVERIFY {
+			return e.verify()
+		}
+		return nil
+
+	case OP_NOT:
+		n, err := e.popNum()
+
+		if err != nil {
+			return err
+		}
+		return e.pushBool(n == 0)
+
+	case OP_SHA256:
+		data, err := e.pop()
+
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(data)
+		return e.push(hash[:])
+
+	case OP_HASH160:
+		data, err := e.pop()
+
+		if err != nil {
+			return err
+		}
+		hash, err := utils.HashPubKey(data)
+
+		if err != nil {
+			return err
+		}
+		return e.push(hash)
+
+	case OP_CHECKSIG, OP_CHECKSIGVERIFY:
+		pubKey, err := e.pop()
+
+		if err != nil {
+			return err
+		}
+		signature, err := e.pop()
+
+		if err != nil {
+			return err
+		}
+		e.pushBool(len(signature) > 0 && len(pubKey) > 0 && e.checker.CheckSignature(signature, pubKey))
+
+		if in.op == OP_CHECKSIGVERIFY {
+			return e.verify()
+		}
+		return nil
+
+	case OP_CHECKMULTISIG, OP_CHECKMULTISIGVERIFY:
+		err := e.checkMultiSig()
+
+		if err != nil {
+			return err
+		}
+
+		if in.op == OP_CHECKMULTISIGVERIFY {
+			return e.verify()
+		}
+		return nil
+
+	case OP_CHECKLOCKTIMEVERIFY, OP_CHECKSEQUENCEVERIFY:
+		// the value stays on the stack. Usually it is dropped next
+		if len(e.stack) == 0 {
+			return errors.New("Stack is empty")
+		}
+
+		n, err := decodeNum(e.stack[len(e.stack)-1])
+
+		if err != nil {
+			return err
+		}
+
+		if n < 0 {
+			return errors.New("Lock time can not be negative")
+		}
+
+		if in.op == OP_CHECKLOCKTIMEVERIFY {
+			return e.checker.CheckLockTime(n)
+		}
+		return e.checker.CheckSequence(n)
+	}
+
+	return errors.New(fmt.Sprintf("Operation 0x%02x is not supported", in.op))
+}
+
+// Removes top value and fails if it is false
+func (e *engine) verify() error {
+	data, err := e.pop()
+
+	if err != nil {
+		return err
+	}
+
+	if !isTrue(data) {
+		return errors.New("Script verification failed")
+	}
+	return nil
+}
+
+// Stack: signatures, number of signatures, keys, number of keys.
+// Signatures must be in same order as keys. Every key can be used once
+func (e *engine) checkMultiSig() error {
+	keysCount, err := e.popNum()
+
+	if err != nil {
+		return err
+	}
+
+	if keysCount < 0 || keysCount > utils.MultiSigMaxKeys {
+		return errors.New("Wrong number of multisig keys")
+	}
+
+	keys := make([][]byte, keysCount)
+
+	for i := int(keysCount) - 1; i >= 0; i-- {
+		keys[i], err = e.pop()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	sigsCount, err := e.popNum()
+
+	if err != nil {
+		return err
+	}
+
+	if sigsCount < 0 || sigsCount > keysCount {
+		return errors.New("Wrong number of multisig signatures")
+	}
+
+	signatures := make([][]byte, sigsCount)
+
+	for i := int(sigsCount) - 1; i >= 0; i-- {
+		signatures[i], err = e.pop()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	keyIndex := 0
+
+	for _, signature := range signatures {
+		for keyIndex < len(keys) && !(len(signature) > 0 && e.checker.CheckSignature(signature, keys[keyIndex])) {
+			keyIndex++
+		}
+
+		if keyIndex == len(keys) {
+			return e.pushBool(false)
+		}
+		keyIndex++
+	}
+
+	return e.pushBool(true)
+}
+
+// Empty data, zeros and negative zero are false
+func isTrue(data []byte) bool {
+	for i, b := range data {
+		if b != 0 {
+			// negative zero
+			if i == len(data)-1 && b == 0x80 {
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
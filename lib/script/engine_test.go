@@ -0,0 +1,172 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math"
+	"testing"
+
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+// Signature is valid if it is same as the key
+type testChecker struct{}
+
+func (c testChecker) CheckSignature(signature, pubKey []byte) bool {
+	return bytes.Equal(signature, pubKey)
+}
+
+func (c testChecker) CheckLockTime(lockTime int64) error {
+	return nil
+}
+
+func (c testChecker) CheckSequence(sequence int64) error {
+	return nil
+}
+
+func TestAssemble(t *testing.T) {
+	text := "OP_IF OP_SHA256 0x0102 OP_EQUALVERIFY OP_ELSE 1000 OP_CHECKLOCKTIMEVERIFY OP_DROP OP_ENDIF OP_2 OP_DUP OP_CHECKSIG"
+
+	s, err := Assemble(text)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Disassemble(s)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// numbers are data pushes
+	if result != "OP_IF OP_SHA256 0x0102 OP_EQUALVERIFY OP_ELSE 0xe803 OP_CHECKLOCKTIMEVERIFY OP_DROP OP_ENDIF OP_2 OP_DUP OP_CHECKSIG" {
+		t.Fatalf("Got %s", result)
+	}
+
+	if _, err = Assemble("OP_DUP OP_UNKNOWN"); err == nil {
+		t.Fatal("Expected error for unknown operation")
+	}
+
+	for _, n := range []int64{0, 1, -1, 127, 128, -128, 255, 1 << 40, math.MaxInt64, math.MinInt64 + 1} {
+		v, err := decodeNum(encodeNum(n))
+
+		if err != nil || v != n {
+			t.Fatalf("Number %d decoded as %d", n, v)
+		}
+	}
+
+	// the smallest number needs 9 bytes. It must not panic and the engine must not read it
+	if len(encodeNum(math.MinInt64)) != 9 {
+		t.Fatalf("Wrong encoding of the smallest number %x", encodeNum(math.MinInt64))
+	}
+
+	if _, err = Assemble("-9223372036854775808 OP_DROP"); err == nil {
+		t.Fatal("Expected error for the smallest number")
+	}
+
+	if _, err = Assemble("-9223372036854775807 9223372036854775807"); err != nil {
+		t.Fatalf("Error for big numbers: %s", err.Error())
+	}
+}
+
+func TestExecute(t *testing.T) {
+	key := []byte("key1")
+	keyHash, _ := utils.HashPubKey(key)
+	secret := []byte("secret")
+	secretHash := sha256.Sum256(secret)
+
+	htlc := NewBuilder().AddOp(OP_IF).AddOp(OP_SHA256).AddData(secretHash[:]).AddOp(OP_EQUALVERIFY).
+		AddOp(OP_ELSE).AddInt(100).AddOp(OP_CHECKLOCKTIMEVERIFY).AddOp(OP_DROP).AddOp(OP_ENDIF).
+		AddData(key).AddOp(OP_CHECKSIG).Script()
+
+	multisig := MultiSig(2, [][]byte{[]byte("key1"), []byte("key2"), []byte("key3")})
+
+	tests := []struct {
+		name   string
+		unlock []byte
+		lock   []byte
+		valid  bool
+	}{
+		{"p2pkh", PayToPubKeyHashUnlock(key, key), PayToPubKeyHash(keyHash), true},
+		{"p2pkh wrong key", PayToPubKeyHashUnlock([]byte("key2"), []byte("key2")), PayToPubKeyHash(keyHash), false},
+		{"p2pkh wrong signature", PayToPubKeyHashUnlock([]byte("key2"), key), PayToPubKeyHash(keyHash), false},
+		{"hashlock", NewBuilder().AddData(key).AddData(secret).AddInt(1).Script(), htlc, true},
+		{"hashlock wrong secret", NewBuilder().AddData(key).AddData(key).AddInt(1).Script(), htlc, false},
+		{"timelock", NewBuilder().AddData(key).AddInt(0).Script(), htlc, true},
+		{"multisig", NewBuilder().AddInt(0).AddData([]byte("key1")).AddData([]byte("key3")).Script(), multisig, true},
+		{"multisig wrong order", NewBuilder().AddInt(0).AddData([]byte("key3")).AddData([]byte("key1")).Script(), multisig, false},
+		{"multisig same key", NewBuilder().AddInt(0).AddData([]byte("key1")).AddData([]byte("key1")).Script(), multisig, false},
+		{"not push only", NewBuilder().AddData(key).AddOp(OP_DUP).Script(), NewBuilder().AddOp(OP_EQUAL).Script(), false},
+		{"unspendable", []byte{}, NewBuilder().AddOp(OP_RETURN).AddData(key).Script(), false},
+		{"if without endif", NewBuilder().AddInt(1).Script(), NewBuilder().AddOp(OP_IF).AddInt(1).Script(), false},
+	}
+
+	for _, test := range tests {
+		err := Execute(test.unlock, test.lock, testChecker{})
+
+		if test.valid && err != nil {
+			t.Errorf("%s: %s", test.name, err.Error())
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+}
+
+func TestHTLC(t *testing.T) {
+	receiver := []byte("receiver")
+	receiverHash, _ := utils.HashPubKey(receiver)
+	sender := []byte("sender")
+	senderHash, _ := utils.HashPubKey(sender)
+
+	secret := bytes.Repeat([]byte{7}, HTLCSecretSize)
+	secretHash := sha256.Sum256(secret)
+
+	h := HTLC{secretHash[:], receiverHash, senderHash, 1000}
+
+	parsed, err := ParseHTLC(h.Script())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(parsed.Script(), h.Script()) || parsed.LockTime != 1000 {
+		t.Fatal("Parsed contract is different")
+	}
+
+	if _, err = ParseHTLC(PayToPubKeyHash(receiverHash)); err == nil {
+		t.Fatal("Expected error for other script")
+	}
+
+	redeem := HTLCRedeemUnlock(receiver, receiver, secret)
+
+	if err = Execute(redeem, h.Script(), testChecker{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(ExtractHTLCSecret(redeem, secretHash[:]), secret) {
+		t.Fatal("Secret is not found")
+	}
+
+	if err = Execute(HTLCRedeemUnlock(sender, sender, secret), h.Script(), testChecker{}); err == nil {
+		t.Fatal("Expected error for redeem by the sender")
+	}
+
+	if err = Execute(HTLCRefundUnlock(sender, sender), h.Script(), testChecker{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Execute(HTLCRefundUnlock(receiver, receiver), h.Script(), testChecker{}); err == nil {
+		t.Fatal("Expected error for refund by the receiver")
+	}
+
+	// preimage of other size has other hash on other chains
+	short := []byte("short")
+	shortHash := sha256.Sum256(short)
+	h.SecretHash = shortHash[:]
+
+	if err = Execute(HTLCRedeemUnlock(receiver, receiver, short), h.Script(), testChecker{}); err == nil {
+		t.Fatal("Expected error for secret of wrong size")
+	}
+}
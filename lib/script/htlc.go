@@ -0,0 +1,115 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+// Size of a secret of hashed time-locked contracts. Same size on other chains makes swaps safe
+const HTLCSecretSize = 32
+
+// Hashed time-locked contract. Coins can be taken by the receiver with the secret or returned
+// to the sender after the lock time. Addresses are pubkey hashes. The hash is SHA256, same as
+// on most other chains, so one secret unlocks contracts on both chains of a swap
+type HTLC struct {
+	SecretHash []byte
+	Receiver   []byte
+	Sender     []byte
+	LockTime   int64
+}
+
+// Returns the locking script of the contract
+func (h HTLC) Script() []byte {
+	return NewBuilder().
+		AddOp(OP_IF).
+		AddOp(OP_SIZE).AddInt(HTLCSecretSize).AddOp(OP_EQUALVERIFY).
+		AddOp(OP_SHA256).AddData(h.SecretHash).AddOp(OP_EQUALVERIFY).
+		AddOp(OP_DUP).AddOp(OP_HASH160).AddData(h.Receiver).
+		AddOp(OP_ELSE).
+		AddInt(h.LockTime).AddOp(OP_CHECKLOCKTIMEVERIFY).AddOp(OP_DROP).
+		AddOp(OP_DUP).AddOp(OP_HASH160).AddData(h.Sender).
+		AddOp(OP_ENDIF).
+		AddOp(OP_EQUALVERIFY).AddOp(OP_CHECKSIG).
+		Script()
+}
+
+// Returns address to send coins to the contract
+func (h HTLC) Address() (string, error) {
+	return Address(h.Script())
+}
+
+// Reads a contract from a script. Error if the script is not HTLC
+func ParseHTLC(script []byte) (*HTLC, error) {
+	instructions, err := parse(script)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(instructions) != 20 {
+		return nil, errors.New("Script is not HTLC")
+	}
+
+	h := &HTLC{}
+	h.SecretHash = instructions[5].data
+	h.Receiver = instructions[9].data
+	h.Sender = instructions[16].data
+
+	h.LockTime, err = instructionNum(instructions[11])
+
+	if err != nil {
+		return nil, err
+	}
+
+	// all other operations must be same
+	if !bytes.Equal(h.Script(), script) {
+		return nil, errors.New("Script is not HTLC")
+	}
+	return h, nil
+}
+
+// Script for an input to take coins with the secret
+func HTLCRedeemUnlock(signature, pubKey, secret []byte) []byte {
+	return NewBuilder().AddData(signature).AddData(pubKey).AddData(secret).AddInt(1).Script()
+}
+
+// Script for an input to return coins to the sender after the lock time
+func HTLCRefundUnlock(signature, pubKey []byte) []byte {
+	return NewBuilder().AddData(signature).AddData(pubKey).AddInt(0).Script()
+}
+
+// Looks for a secret in an unlocking script. Returns nil if there is no data with the hash
+func ExtractHTLCSecret(unlock []byte, secretHash []byte) []byte {
+	instructions, err := parse(unlock)
+
+	if err != nil {
+		return nil
+	}
+
+	for _, in := range instructions {
+		if len(in.data) != HTLCSecretSize {
+			continue
+		}
+
+		hash := sha256.Sum256(in.data)
+
+		if bytes.Equal(hash[:], secretHash) {
+			return in.data
+		}
+	}
+	return nil
+}
+
+// Returns a number pushed by an instruction
+func instructionNum(in instruction) (int64, error) {
+	switch {
+	case in.op == OP_1NEGATE:
+		return -1, nil
+	case in.op >= OP_1 && in.op <= OP_16:
+		return int64(in.op - OP_1 + 1), nil
+	case in.op <= OP_PUSHDATA2:
+		return decodeNum(in.data)
+	}
+	return 0, errors.New("Instruction is not a number")
+}
@@ -0,0 +1,84 @@
+package script
+
+import "fmt"
+
+// Operation codes. Values are same as in Bitcoin scripts where an operation exists there.
+// There are no loops and jumps back, so every script ends after its last operation
+const (
+	OP_0         = byte(0x00)
+	OP_PUSHDATA1 = byte(0x4c)
+	OP_PUSHDATA2 = byte(0x4d)
+	OP_1NEGATE   = byte(0x4f)
+	OP_1         = byte(0x51)
+	OP_16        = byte(0x60)
+
+	OP_IF     = byte(0x63)
+	OP_NOTIF  = byte(0x64)
+	OP_ELSE   = byte(0x67)
+	OP_ENDIF  = byte(0x68)
+	OP_VERIFY = byte(0x69)
+	OP_RETURN = byte(0x6a)
+
+	OP_DROP = byte(0x75)
+	OP_DUP  = byte(0x76)
+	OP_SWAP = byte(0x7c)
+	OP_SIZE = byte(0x82)
+
+	OP_EQUAL       = byte(0x87)
+	OP_EQUALVERIFY = byte(0x88)
+	OP_NOT         = byte(0x91)
+
+	OP_SHA256  = byte(0xa8)
+	OP_HASH160 = byte(0xa9)
+
+	OP_CHECKSIG            = byte(0xac)
+	OP_CHECKSIGVERIFY      = byte(0xad)
+	OP_CHECKMULTISIG       = byte(0xae)
+	OP_CHECKMULTISIGVERIFY = byte(0xaf)
+
+	OP_CHECKLOCKTIMEVERIFY = byte(0xb1)
+	OP_CHECKSEQUENCEVERIFY = byte(0xb2)
+)
+
+var opNames = map[byte]string{
+	OP_0:                   "OP_0",
+	OP_PUSHDATA1:           "OP_PUSHDATA1",
+	OP_PUSHDATA2:           "OP_PUSHDATA2",
+	OP_1NEGATE:             "OP_1NEGATE",
+	OP_IF:                  "OP_IF",
+	OP_NOTIF:               "OP_NOTIF",
+	OP_ELSE:                "OP_ELSE",
+	OP_ENDIF:               "OP_ENDIF",
+	OP_VERIFY:              "OP_VERIFY",
+	OP_RETURN:              "OP_RETURN",
+	OP_DROP:                "OP_DROP",
+	OP_DUP:                 "OP_DUP",
+	OP_SWAP:                "OP_SWAP",
+	OP_SIZE:                "OP_SIZE",
+	OP_EQUAL:               "OP_EQUAL",
+	OP_EQUALVERIFY:         "OP_EQUALVERIFY",
+	OP_NOT:                 "OP_NOT",
+	OP_SHA256:              "OP_SHA256",
+	OP_HASH160:             "OP_HASH160",
+	OP_CHECKSIG:            "OP_CHECKSIG",
+	OP_CHECKSIGVERIFY:      "OP_CHECKSIGVERIFY",
+	OP_CHECKMULTISIG:       "OP_CHECKMULTISIG",
+	OP_CHECKMULTISIGVERIFY: "OP_CHECKMULTISIGVERIFY",
+	OP_CHECKLOCKTIMEVERIFY: "OP_CHECKLOCKTIMEVERIFY",
+	OP_CHECKSEQUENCEVERIFY: "OP_CHECKSEQUENCEVERIFY",
+}
+
+var opCodes = map[string]byte{}
+
+func init() {
+	for code, name := range opNames {
+		opCodes[name] = code
+	}
+	for n := byte(1); n <= 16; n++ {
+		name := fmt.Sprintf("OP_%d", n)
+		opNames[OP_1+n-1] = name
+		opCodes[name] = OP_1 + n - 1
+	}
+	opCodes["OP_FALSE"] = OP_0
+	opCodes["OP_TRUE"] = OP_1
+}
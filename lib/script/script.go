@@ -0,0 +1,321 @@
+package script
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+// Limits keep execution of any script small
+const (
+	MaxScriptSize = 10000
+	MaxPushSize   = 520
+	MaxStackSize  = 1000
+	MaxOps        = 201
+	maxNumSize    = 8
+)
+
+// A script output is locked to a hash of the script same way as to a hash of a public key.
+// An input spending it has the script with this prefix in PubKey field and an unlocking
+// script in Signature field
+var scriptKeyMarker = []byte("SCRP")
+
+// One operation of a script. Data is set for push operations
+type instruction struct {
+	op   byte
+	data []byte
+}
+
+// Splits a script to operations
+func parse(script []byte) ([]instruction, error) {
+	if len(script) > MaxScriptSize {
+		return nil, errors.New("Script is too long")
+	}
+
+	result := []instruction{}
+
+	for i := 0; i < len(script); {
+		op := script[i]
+		i++
+
+		size := 0
+
+		switch {
+		case op > OP_0 && op < OP_PUSHDATA1:
+			size = int(op)
+		case op == OP_PUSHDATA1:
+			if i+1 > len(script) {
+				return nil, errors.New("Script is not complete")
+			}
+			size = int(script[i])
+			i++
+		case op == OP_PUSHDATA2:
+			if i+2 > len(script) {
+				return nil, errors.New("Script is not complete")
+			}
+			size = int(binary.LittleEndian.Uint16(script[i:]))
+			i += 2
+		default:
+			if _, ok := opNames[op]; !ok {
+				return nil, errors.New(fmt.Sprintf("Unknown operation 0x%02x", op))
+			}
+			result = append(result, instruction{op, nil})
+			continue
+		}
+
+		if i+size > len(script) {
+			return nil, errors.New("Script is not complete")
+		}
+		result = append(result, instruction{op, script[i : i+size]})
+		i += size
+	}
+	return result, nil
+}
+
+// Returns true if a script only pushes data. Unlocking scripts must be such
+func IsPushOnly(script []byte) bool {
+	instructions, err := parse(script)
+
+	if err != nil {
+		return false
+	}
+
+	for _, in := range instructions {
+		if in.op > OP_16 {
+			return false
+		}
+	}
+	return true
+}
+
+// Builds scripts
+type Builder struct {
+	script []byte
+}
+
+func NewBuilder() *Builder {
+	return &Builder{[]byte{}}
+}
+
+func (b *Builder) AddOp(op byte) *Builder {
+	b.script = append(b.script, op)
+	return b
+}
+
+// Adds data push with smallest push operation
+func (b *Builder) AddData(data []byte) *Builder {
+	switch {
+	case len(data) == 0:
+		b.script = append(b.script, OP_0)
+	case len(data) < int(OP_PUSHDATA1):
+		b.script = append(b.script, byte(len(data)))
+	case len(data) <= 0xff:
+		b.script = append(b.script, OP_PUSHDATA1, byte(len(data)))
+	default:
+		b.script = append(b.script, OP_PUSHDATA2, byte(len(data)), byte(len(data)>>8))
+	}
+	b.script = append(b.script, data...)
+	return b
+}
+
+// Adds a number. Small numbers are single operations
+func (b *Builder) AddInt(n int64) *Builder {
+	if n == 0 {
+		return b.AddOp(OP_0)
+	}
+	if n == -1 {
+		return b.AddOp(OP_1NEGATE)
+	}
+	if n > 0 && n <= 16 {
+		return b.AddOp(OP_1 + byte(n) - 1)
+	}
+	return b.AddData(encodeNum(n))
+}
+
+func (b *Builder) Script() []byte {
+	return b.script
+}
+
+// Numbers are little endian with a sign in the highest bit, same as in Bitcoin
+func encodeNum(n int64) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+
+	negative := n < 0
+
+	// unsigned, -n overflows for the smallest int64
+	abs := uint64(n)
+
+	if negative {
+		abs = uint64(-(n + 1)) + 1
+	}
+
+	result := []byte{}
+
+	for abs > 0 {
+		result = append(result, byte(abs&0xff))
+		abs >>= 8
+	}
+
+	if result[len(result)-1]&0x80 != 0 {
+		extra := byte(0)
+
+		if negative {
+			extra = 0x80
+		}
+		result = append(result, extra)
+	} else if negative {
+		result[len(result)-1] |= 0x80
+	}
+	return result
+}
+
+func decodeNum(data []byte) (int64, error) {
+	if len(data) > maxNumSize {
+		return 0, errors.New("Number is too long")
+	}
+
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	result := int64(0)
+
+	for i, b := range data {
+		result |= int64(b) << uint(8*i)
+	}
+
+	if data[len(data)-1]&0x80 != 0 {
+		result &= ^(int64(0x80) << uint(8*(len(data)-1)))
+		return -result, nil
+	}
+	return result, nil
+}
+
+// Makes a script from text like "OP_DUP OP_HASH160 0x0102.. OP_EQUALVERIFY OP_CHECKSIG".
+// Data are hex with 0x prefix, numbers are decimal
+func Assemble(text string) ([]byte, error) {
+	b := NewBuilder()
+
+	for _, token := range strings.Fields(text) {
+		if strings.HasPrefix(token, "0x") {
+			data, err := hex.DecodeString(token[2:])
+
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Wrong data %s", token))
+			}
+			b.AddData(data)
+			continue
+		}
+
+		if n, err := strconv.ParseInt(token, 10, 64); err == nil {
+			if n == math.MinInt64 {
+				// it is 9 bytes with a sign, the engine can not read such number
+				return nil, errors.New(fmt.Sprintf("Number %s is out of range", token))
+			}
+			b.AddInt(n)
+			continue
+		}
+
+		op, ok := opCodes[strings.ToUpper(token)]
+
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Unknown operation %s", token))
+		}
+		b.AddOp(op)
+	}
+
+	script := b.Script()
+
+	if len(script) > MaxScriptSize {
+		return nil, errors.New("Script is too long")
+	}
+	return script, nil
+}
+
+// Returns text of a script
+func Disassemble(script []byte) (string, error) {
+	instructions, err := parse(script)
+
+	if err != nil {
+		return "", err
+	}
+
+	tokens := []string{}
+
+	for _, in := range instructions {
+		if in.op > OP_0 && in.op <= OP_PUSHDATA2 {
+			tokens = append(tokens, "0x"+hex.EncodeToString(in.data))
+		} else {
+			tokens = append(tokens, opNames[in.op])
+		}
+	}
+	return strings.Join(tokens, " "), nil
+}
+
+// Standard script of outputs locked to a hash of a public key. All old outputs are such
+func PayToPubKeyHash(pubKeyHash []byte) []byte {
+	return NewBuilder().AddOp(OP_DUP).AddOp(OP_HASH160).AddData(pubKeyHash).
+		AddOp(OP_EQUALVERIFY).AddOp(OP_CHECKSIG).Script()
+}
+
+// Standard multisig script. Keys must be sorted
+func MultiSig(required int, pubKeys [][]byte) []byte {
+	b := NewBuilder().AddInt(int64(required))
+
+	for _, key := range pubKeys {
+		b.AddData(key)
+	}
+	return b.AddInt(int64(len(pubKeys))).AddOp(OP_CHECKMULTISIG).Script()
+}
+
+// Script of outputs that only carry data. It fails on execution, so such outputs can not be spent
+func DataOutput(data []byte) []byte {
+	return NewBuilder().AddOp(OP_RETURN).AddData(data).Script()
+}
+
+// Script to unlock P2PKH output
+func PayToPubKeyHashUnlock(signature, pubKey []byte) []byte {
+	return NewBuilder().AddData(signature).AddData(pubKey).Script()
+}
+
+// Script to unlock multisig. Signatures must be in same order as keys
+func MultiSigUnlock(signatures [][]byte) []byte {
+	b := NewBuilder()
+
+	for _, signature := range signatures {
+		b.AddData(signature)
+	}
+	return b.Script()
+}
+
+// Returns value to put in PubKey field of an input that spends output locked with a script
+func ScriptKey(script []byte) []byte {
+	return append(utils.CopyBytes(scriptKeyMarker), script...)
+}
+
+// Checks if PubKey field of an input has a script
+func IsScriptKey(pubKey []byte) bool {
+	return bytes.HasPrefix(pubKey, scriptKeyMarker)
+}
+
+// Returns a script from PubKey field of an input
+func ParseScriptKey(pubKey []byte) ([]byte, error) {
+	if !IsScriptKey(pubKey) {
+		return nil, errors.New("Not a script")
+	}
+	return pubKey[len(scriptKeyMarker):], nil
+}
+
+// Returns address to send coins locked with a script
+func Address(script []byte) (string, error) {
+	return utils.PubKeyToAddres(ScriptKey(script))
+}
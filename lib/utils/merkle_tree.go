@@ -1,7 +1,16 @@
 package utils
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"errors"
+)
+
+// Leaves and inner nodes are hashed with different prefixes, so an inner node can not be given
+// as a leaf. A last node of a level without a pair goes up as is, so a tree has log2 levels
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
 )
 
 // MerkleTree represent a Merkle tree
@@ -18,34 +27,27 @@ type MerkleNode struct {
 
 // NewMerkleTree creates a new Merkle tree from a sequence of data
 func NewMerkleTree(data [][]byte) *MerkleTree {
-	var nodes []MerkleNode
-
-	if len(data)%2 != 0 {
-
-		data = append(data, data[len(data)-1])
-
-	}
+	var nodes []*MerkleNode
 
 	for _, datum := range data {
-		node := NewMerkleNode(nil, nil, datum)
-		nodes = append(nodes, *node)
+		nodes = append(nodes, NewMerkleNode(nil, nil, datum))
 	}
 
-	for i := 0; i < len(data)/2; i++ {
-		var newLevel []MerkleNode
+	for len(nodes) > 1 {
+		var newLevel []*MerkleNode
 
 		for j := 0; j < len(nodes); j += 2 {
-			node := NewMerkleNode(&nodes[j], &nodes[j+1], nil)
-			newLevel = append(newLevel, *node)
-		}
-		if len(newLevel)%2 != 0 {
-			newLevel = append(newLevel, newLevel[len(newLevel)-1])
+			if j+1 == len(nodes) {
+				newLevel = append(newLevel, nodes[j])
+			} else {
+				newLevel = append(newLevel, NewMerkleNode(nodes[j], nodes[j+1], nil))
+			}
 		}
 
 		nodes = newLevel
 	}
 
-	mTree := MerkleTree{&nodes[0]}
+	mTree := MerkleTree{nodes[0]}
 
 	return &mTree
 }
@@ -55,12 +57,9 @@ func NewMerkleNode(left, right *MerkleNode, data []byte) *MerkleNode {
 	mNode := MerkleNode{}
 
 	if left == nil && right == nil {
-		hash := sha256.Sum256(data)
-		mNode.Data = hash[:]
+		mNode.Data = merkleLeafHash(data)
 	} else {
-		prevHashes := append(left.Data, right.Data...)
-		hash := sha256.Sum256(prevHashes)
-		mNode.Data = hash[:]
+		mNode.Data = merkleNodeHash(left.Data, right.Data)
 	}
 
 	mNode.Left = left
@@ -68,3 +67,76 @@ func NewMerkleNode(left, right *MerkleNode, data []byte) *MerkleNode {
 
 	return &mNode
 }
+
+func merkleLeafHash(data []byte) []byte {
+	hash := sha256.Sum256(append([]byte{merkleLeafPrefix}, data...))
+	return hash[:]
+}
+
+func merkleNodeHash(left, right []byte) []byte {
+	data := append([]byte{merkleNodePrefix}, left...)
+	hash := sha256.Sum256(append(data, right...))
+	return hash[:]
+}
+
+// One step of a Merkle proof. A hash of a sibling node and its side
+type MerkleProofStep struct {
+	Hash []byte
+	Left bool // sibling is on the left side
+}
+
+// Builds a proof that an element with given index is in the data. Levels of a tree
+// are built same way as in NewMerkleTree, so the proof leads to the same root
+func GetMerkleProof(data [][]byte, index int) ([]MerkleProofStep, error) {
+	if index < 0 || index >= len(data) {
+		return nil, errors.New("Index is out of range of data")
+	}
+
+	level := [][]byte{}
+
+	for _, datum := range data {
+		level = append(level, merkleLeafHash(datum))
+	}
+
+	proof := []MerkleProofStep{}
+
+	for len(level) > 1 {
+		sibling := index ^ 1
+
+		// a node without a pair goes up as is, there is no step for it
+		if sibling < len(level) {
+			proof = append(proof, MerkleProofStep{level[sibling], sibling < index})
+		}
+
+		newLevel := [][]byte{}
+
+		for j := 0; j < len(level); j += 2 {
+			if j+1 == len(level) {
+				newLevel = append(newLevel, level[j])
+			} else {
+				newLevel = append(newLevel, merkleNodeHash(level[j], level[j+1]))
+			}
+		}
+
+		level = newLevel
+		index = index / 2
+	}
+
+	return proof, nil
+}
+
+// Checks data is included in a Merkle tree with given root. Only the proof and the root are needed,
+// so it can be checked having only a block header
+func VerifyMerkleProof(data []byte, proof []MerkleProofStep, root []byte) bool {
+	current := merkleLeafHash(data)
+
+	for _, step := range proof {
+		if step.Left {
+			current = merkleNodeHash(step.Hash, current)
+		} else {
+			current = merkleNodeHash(current, step.Hash)
+		}
+	}
+
+	return bytes.Equal(current, root)
+}
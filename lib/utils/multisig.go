@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// M-of-N multisig. An output is locked to a hash of the multisig keys same way as to a hash
+// of a public key, so a multisig address looks like any other address. An input spending it
+// has serialized keys in PubKey field and M signatures in Signature field
+const MultiSigMaxKeys = 15
+
+// Serialized keys start with this. Normal public keys are X and Y numbers
+var multiSigMarker = []byte("MSIG")
+
+type MultiSig struct {
+	Required int
+	PubKeys  [][]byte // sorted
+}
+
+// Makes multisig keys. Keys are sorted, so same keys in any order give same address
+func NewMultiSig(required int, pubKeys [][]byte) (*MultiSig, error) {
+	if len(pubKeys) < 1 || len(pubKeys) > MultiSigMaxKeys {
+		return nil, errors.New(fmt.Sprintf("Multisig must have 1-%d keys", MultiSigMaxKeys))
+	}
+
+	if required < 1 || required > len(pubKeys) {
+		return nil, errors.New("Number of required signatures must be from 1 to number of keys")
+	}
+
+	keys := [][]byte{}
+
+	for _, key := range pubKeys {
+		if len(key) == 0 || len(key) > 255 {
+			return nil, errors.New("Public key is not valid")
+		}
+		keys = append(keys, CopyBytes(key))
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+
+	for i := 1; i < len(keys); i++ {
+		if bytes.Equal(keys[i-1], keys[i]) {
+			return nil, errors.New("Multisig keys must be different")
+		}
+	}
+
+	return &MultiSig{required, keys}, nil
+}
+
+// Checks if a public key of an input is serialized multisig keys
+func IsMultiSig(pubKey []byte) bool {
+	return bytes.HasPrefix(pubKey, multiSigMarker)
+}
+
+// Parses serialized multisig keys
+func ParseMultiSig(data []byte) (*MultiSig, error) {
+	if !IsMultiSig(data) || len(data) < len(multiSigMarker)+2 {
+		return nil, errors.New("Data are not multisig keys")
+	}
+
+	serialized := data
+	data = data[len(multiSigMarker):]
+
+	required := int(data[0])
+	count := int(data[1])
+	data = data[2:]
+
+	keys := [][]byte{}
+
+	for i := 0; i < count; i++ {
+		if len(data) < 1 || len(data) < int(data[0])+1 {
+			return nil, errors.New("Multisig keys are not complete")
+		}
+		keys = append(keys, data[1:int(data[0])+1])
+		data = data[int(data[0])+1:]
+	}
+
+	if len(data) > 0 {
+		return nil, errors.New("Extra data after multisig keys")
+	}
+
+	ms, err := NewMultiSig(required, keys)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// keys must be sorted already. Other order would give other hash for same keys
+	if !bytes.Equal(ms.Serialize(), serialized) {
+		return nil, errors.New("Multisig keys are not sorted")
+	}
+
+	return ms, nil
+}
+
+func concatKeys(keys [][]byte) []byte {
+	result := []byte{}
+
+	for _, key := range keys {
+		result = append(result, byte(len(key)))
+		result = append(result, key...)
+	}
+	return result
+}
+
+// Returns keys in the format used in inputs
+func (ms MultiSig) Serialize() []byte {
+	data := CopyBytes(multiSigMarker)
+	data = append(data, byte(ms.Required), byte(len(ms.PubKeys)))
+
+	return append(data, concatKeys(ms.PubKeys)...)
+}
+
+// Returns address to send coins to. Spending them needs Required signatures
+func (ms MultiSig) Address() (string, error) {
+	return PubKeyToAddres(ms.Serialize())
+}
+
+// Returns index of a key or -1 if it is not in the multisig
+func (ms MultiSig) KeyIndex(pubKey []byte) int {
+	for i, key := range ms.PubKeys {
+		if bytes.Equal(key, pubKey) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Joins signatures of keys to put in an input. Keys are indexes of ms.PubKeys.
+// Only Required signatures are used
+func (ms MultiSig) JoinSignatures(signatures map[int][]byte) ([]byte, error) {
+	indexes := []int{}
+
+	for index := range signatures {
+		if index < 0 || index >= len(ms.PubKeys) {
+			return nil, errors.New("Signature key is not in the multisig")
+		}
+		indexes = append(indexes, index)
+	}
+
+	if len(indexes) < ms.Required {
+		return nil, errors.New(fmt.Sprintf("Not enough signatures: %d of %d", len(indexes), ms.Required))
+	}
+
+	sort.Ints(indexes)
+
+	result := []byte{}
+
+	for _, index := range indexes[:ms.Required] {
+		signature := signatures[index]
+
+		if len(signature) == 0 || len(signature) > 255 {
+			return nil, errors.New("Signature is not valid")
+		}
+		result = append(result, byte(index), byte(len(signature)))
+		result = append(result, signature...)
+	}
+	return result, nil
+}
+
+// Parses joined signatures. Returns key indexes and signatures in order of keys
+func (ms MultiSig) parseSignatures(signatures []byte) ([]int, [][]byte, error) {
+	indexes := []int{}
+	result := [][]byte{}
+	lastIndex := -1
+
+	for len(signatures) > 0 {
+		if len(signatures) < 2 || len(signatures) < int(signatures[1])+2 {
+			return nil, nil, errors.New("Multisig signatures are not complete")
+		}
+
+		index := int(signatures[0])
+		signature := signatures[2 : int(signatures[1])+2]
+		signatures = signatures[int(signatures[1])+2:]
+
+		// indexes must grow, so one key can not sign twice
+		if index <= lastIndex || index >= len(ms.PubKeys) || len(signature) == 0 {
+			return nil, nil, errors.New("Multisig signatures are not valid")
+		}
+		lastIndex = index
+
+		indexes = append(indexes, index)
+		result = append(result, signature)
+	}
+	return indexes, result, nil
+}
+
+// Returns joined signatures in order of keys, without indexes. Exactly Required signatures must be joined
+func (ms MultiSig) SplitSignatures(signatures []byte) ([][]byte, error) {
+	_, result, err := ms.parseSignatures(signatures)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) != ms.Required {
+		return nil, errors.New(fmt.Sprintf("Expected %d multisig signatures, got %d", ms.Required, len(result)))
+	}
+	return result, nil
+}
+
+// Verifies joined signatures. Exactly Required signatures of different keys must be valid
+func (ms MultiSig) Verify(signatures []byte, message []byte) (bool, error) {
+	indexes, result, err := ms.parseSignatures(signatures)
+
+	if err != nil {
+		return false, err
+	}
+
+	for i, signature := range result {
+		v, err := VerifySignature(signature, message, ms.PubKeys[indexes[i]])
+
+		if err != nil || !v {
+			return false, err
+		}
+	}
+
+	return len(result) == ms.Required, nil
+}
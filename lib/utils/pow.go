@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/big"
+)
+
+// Checks proof of work of a block having only header fields. It is used by light clients.
+// Data for a hash must be same as in the node PoW consensus engine
+func VerifyPoWHeader(prevBlockHash, merkleRoot []byte, timestamp int64, bits int, nonce int, hash []byte) bool {
+	if bits <= 0 || bits >= 256 {
+		return false
+	}
+
+	data := bytes.Join(
+		[][]byte{
+			prevBlockHash,
+			merkleRoot,
+			IntToHex(timestamp),
+			IntToHex(int64(bits)),
+			IntToHex(int64(nonce)),
+		},
+		[]byte{},
+	)
+
+	h := sha256.Sum256(data)
+
+	if !bytes.Equal(h[:], hash) {
+		return false
+	}
+
+	target := big.NewInt(1)
+	target.Lsh(target, uint(256-bits))
+
+	var hashInt big.Int
+	hashInt.SetBytes(h[:])
+
+	return hashInt.Cmp(target) == -1
+}
+
+// Returns amount of work needed to make a hash with given difficulty. It is 2^bits
+// Light clients choose a chain with most work
+func GetWorkForBits(bits int) *big.Int {
+	work := big.NewInt(1)
+
+	if bits > 0 {
+		work.Lsh(work, uint(bits))
+	}
+	return work
+}
@@ -157,6 +157,13 @@ func IntToHex(num int64) []byte {
 	return buff.Bytes()
 }
 
+// Writes data with its length before. Parts of joined data can not be moved
+// from one to another then
+func WriteWithLength(buff *bytes.Buffer, data []byte) {
+	binary.Write(buff, binary.BigEndian, uint32(len(data)))
+	buff.Write(data)
+}
+
 // ReverseBytes reverses a byte array
 func ReverseBytes(data []byte) {
 	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
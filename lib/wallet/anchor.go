@@ -0,0 +1,105 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/taincoin/taincoin/lib/nodeclient"
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+// Data are anchored with an unspendable output. A transaction has only this output and a change,
+// a sender pays only a fee. Time of a block with the transaction proves the data existed then
+
+func (wc *WalletCLI) parseData() ([]byte, error) {
+	data, err := hex.DecodeString(wc.Input.Data)
+
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("Data must be a hex string")
+	}
+	return data, nil
+}
+
+// Sends a transaction with a data output
+func (wc *WalletCLI) commandAnchor() error {
+	data, err := wc.parseData()
+
+	if err != nil {
+		return err
+	}
+
+	if wc.Input.Fee < 0 {
+		return errors.New("The fee of transaction can not be negative")
+	}
+
+	if wc.WalletsObj.IsWatchOnly(wc.Input.Address) {
+		return ErrWatchOnly
+	}
+
+	err = wc.UnlockIfNeeded()
+
+	if err != nil {
+		return err
+	}
+
+	walletobj, err := wc.WalletsObj.GetWallet(wc.Input.Address)
+
+	if err != nil {
+		return err
+	}
+
+	TXBytes, DataToSign, err := wc.NodeCLI.SendRequestNewDataTransaction(wc.Node,
+		walletobj.GetPublicKey(), data, wc.Input.Fee)
+
+	if err != nil {
+		return err
+	}
+
+	signatures, err := utils.SignDataSet(walletobj.GetPublicKey(), walletobj.GetPrivateKey(), DataToSign)
+
+	if err != nil {
+		return err
+	}
+
+	NewTXID, err := wc.NodeCLI.SendNewTransactionData(wc.Node, wc.Input.Address, TXBytes, signatures)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Success. New transaction: %x\n", NewTXID)
+
+	return nil
+}
+
+// Shows a block where data were anchored
+func (wc *WalletCLI) commandFindAnchor() error {
+	data, err := wc.parseData()
+
+	if err != nil {
+		return err
+	}
+
+	anchor, err := wc.NodeCLI.SendGetAnchor(wc.Node, data)
+
+	if err != nil {
+		return err
+	}
+
+	if len(anchor.TXID) == 0 {
+		return errors.New("Data are not found in the blockchain")
+	}
+
+	PrintAnchor(anchor)
+
+	return nil
+}
+
+func PrintAnchor(anchor nodeclient.ComAnchor) {
+	fmt.Printf("Transaction: %x\n", anchor.TXID)
+	fmt.Printf("Block:       %x\n", anchor.BlockHash)
+	fmt.Printf("Height:      %d\n", anchor.Height)
+	fmt.Printf("Time:        %d (%s)\n", anchor.Timestamp, time.Unix(anchor.Timestamp, 0).UTC())
+}
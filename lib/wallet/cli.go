@@ -1,28 +1,60 @@
 package wallet
 
 import (
+	"bufio"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/taincoin/taincoin/lib"
 	"github.com/taincoin/taincoin/lib/net"
 	"github.com/taincoin/taincoin/lib/nodeclient"
+	"github.com/taincoin/taincoin/lib/script"
 	"github.com/taincoin/taincoin/lib/utils"
+	"golang.org/x/term"
 )
 
 const walletFile = "wallet.dat"
 
+// All prompts read from one reader. A new reader could take buffered lines of next prompts
+var stdinReader = bufio.NewReader(os.Stdin)
+
 type AppInput struct {
 	Command   string
 	Address   string
 	ToAddress string
-	Amount    float64
+	Amount    lib.Amount
+	Fee       lib.Amount
+	Height    int
+	Light     bool // light client mode. Data from nodes are verified with block headers and Merkle proofs
 	NodePort  int
 	NodeHost  string
 	DataDir   string
 	Nodes     []net.NodeAddr
 	LogDest   string
+
+	HD       bool   // create HD wallet, addresses are derived from a seed
+	Mnemonic string // mnemonic to restore HD wallet. It is read from stdin if not set
+
+	PubKey string // public key in hex to import as watch-only
+
+	File  string // file of a transaction for offline signing
+	Files string // list of files of a multisig transaction signed by other keys
+
+	Required int    // number of signatures required for multisig
+	PubKeys  string // list of multisig public keys in hex or addresses
+
+	Script string // locking script in text form
+
+	LockTime int64 // block height or unix time before which a transaction can not be added to a block
+
+	Contract   string // script of a swap contract in hex
+	Secret     string // secret of a swap contract in hex
+	SecretHash string // SHA256 of a secret in hex
+
+	Data string // data in hex to anchor in the blockchain
 }
 
 type WalletCLI struct {
@@ -34,6 +66,13 @@ type WalletCLI struct {
 	WalletsObj *Wallets
 	NodeMode   bool
 	Logger     *utils.LoggerMan
+
+	// checks if an address has transactions. It is used by HD wallets rescan.
+	// By default a node is requested for address history
+	IsAddressUsed func(address string) (bool, error)
+
+	// operations with a node for atomic swaps. By default a node is requested over network
+	SwapNode *SwapNode
 }
 
 // Init wallet client object. This will manage execution
@@ -42,6 +81,7 @@ func (wc *WalletCLI) Init(logger *utils.LoggerMan, input AppInput) {
 	wc.Logger = logger
 	wc.Input = input
 	wc.DataDir = input.DataDir
+	wc.Nodes = input.Nodes
 
 	wc.initNodeClient()
 	wc.initWallets()
@@ -103,7 +143,15 @@ func (wc *WalletCLI) ExecuteCommand() error {
 	wc.initNodeClient()
 
 	if wc.Input.Command != "createwallet" &&
-		wc.Input.Command != "listaddresses" {
+		wc.Input.Command != "listaddresses" &&
+		wc.Input.Command != "changepassphrase" &&
+		wc.Input.Command != "importaddress" &&
+		wc.Input.Command != "importpubkey" &&
+		wc.Input.Command != "signtx" &&
+		wc.Input.Command != "createmultisig" &&
+		wc.Input.Command != "combinetx" &&
+		wc.Input.Command != "createscript" &&
+		wc.Input.Command != "verifyreceipt" {
 		wc.checkNodeAddress()
 	}
 
@@ -129,6 +177,70 @@ func (wc *WalletCLI) ExecuteCommand() error {
 	} else if wc.Input.Command == "showhistory" {
 		return wc.commandShowHistory()
 
+	} else if wc.Input.Command == "getsupply" {
+		return wc.commandGetSupply()
+
+	} else if wc.Input.Command == "changepassphrase" {
+		return wc.commandChangePassphrase()
+
+	} else if wc.Input.Command == "restorewallet" {
+		return wc.commandRestoreWallet()
+
+	} else if wc.Input.Command == "rescanwallet" {
+		return wc.commandRescanWallet()
+
+	} else if wc.Input.Command == "importaddress" ||
+		wc.Input.Command == "importpubkey" {
+		return wc.commandImportWatchOnly()
+
+	} else if wc.Input.Command == "preparetx" {
+		return wc.commandPrepareTransaction()
+
+	} else if wc.Input.Command == "signtx" {
+		return wc.commandSignTransaction()
+
+	} else if wc.Input.Command == "broadcasttx" {
+		return wc.commandBroadcastTransaction()
+
+	} else if wc.Input.Command == "createmultisig" {
+		return wc.commandCreateMultiSig()
+
+	} else if wc.Input.Command == "combinetx" {
+		return wc.commandCombineTransaction()
+
+	} else if wc.Input.Command == "createscript" {
+		return wc.commandCreateScript()
+
+	} else if wc.Input.Command == "initiateswap" {
+		return wc.commandInitiateSwap()
+
+	} else if wc.Input.Command == "redeemswap" {
+		return wc.commandRedeemSwap()
+
+	} else if wc.Input.Command == "refundswap" {
+		return wc.commandRefundSwap()
+
+	} else if wc.Input.Command == "extractsecret" {
+		return wc.commandExtractSecret()
+
+	} else if wc.Input.Command == "showsecret" {
+		return wc.commandShowSecret()
+
+	} else if wc.Input.Command == "anchor" {
+		return wc.commandAnchor()
+
+	} else if wc.Input.Command == "findanchor" {
+		return wc.commandFindAnchor()
+
+	} else if wc.Input.Command == "stamp" {
+		return wc.commandStamp()
+
+	} else if wc.Input.Command == "getreceipt" {
+		return wc.commandGetReceipt()
+
+	} else if wc.Input.Command == "verifyreceipt" {
+		return wc.commandVerifyReceipt()
+
 	}
 
 	return errors.New("Unknown wallets command")
@@ -137,6 +249,32 @@ func (wc *WalletCLI) ExecuteCommand() error {
 // Creates new wallet and saves it in a wallets file
 // Wallet is a pare of keys
 func (wc *WalletCLI) commandCreatewallet() error {
+	err := wc.unlockOrEncrypt()
+
+	if err != nil {
+		return err
+	}
+
+	if wc.Input.HD && wc.WalletsObj.HD == nil {
+		mnemonic, err := NewMnemonic(MnemonicEntropyBits)
+
+		if err != nil {
+			return err
+		}
+
+		err = wc.WalletsObj.InitHD(mnemonic)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("HD wallet is created. Write down these words and keep them in a safe place.")
+		fmt.Println("All addresses of this wallet can be restored with them:")
+		fmt.Println()
+		fmt.Println(mnemonic)
+		fmt.Println()
+	}
+
 	address, err := wc.WalletsObj.CreateWallet()
 
 	if err != nil {
@@ -148,6 +286,81 @@ func (wc *WalletCLI) commandCreatewallet() error {
 	return nil
 }
 
+// Restores HD wallet from a mnemonic and finds used addresses
+func (wc *WalletCLI) commandRestoreWallet() error {
+	if wc.Input.Mnemonic == "" {
+		// not from arguments, they are visible in a list of processes
+		mnemonic, err := ReadMnemonic("Enter mnemonic words: ")
+
+		if err != nil {
+			return err
+		}
+		wc.Input.Mnemonic = mnemonic
+	}
+
+	err := ValidateMnemonic(wc.Input.Mnemonic)
+
+	if err != nil {
+		return err
+	}
+
+	err = wc.unlockOrEncrypt()
+
+	if err != nil {
+		return err
+	}
+
+	err = wc.WalletsObj.InitHD(wc.Input.Mnemonic)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("HD wallet is restored")
+
+	return wc.rescanHD()
+}
+
+// Finds used addresses of HD wallet
+func (wc *WalletCLI) commandRescanWallet() error {
+	err := wc.UnlockIfNeeded()
+
+	if err != nil {
+		return err
+	}
+
+	return wc.rescanHD()
+}
+
+func (wc *WalletCLI) rescanHD() error {
+	isUsed := wc.IsAddressUsed
+
+	if isUsed == nil {
+		isUsed = func(address string) (bool, error) {
+			list, err := wc.NodeCLI.SendGetHistory(wc.Node, address)
+
+			if err != nil {
+				return false, err
+			}
+			return len(list) > 0, nil
+		}
+	}
+
+	added, err := wc.WalletsObj.RescanHD(isUsed, HDGapLimit)
+
+	if err != nil {
+		return err
+	}
+
+	for _, address := range added {
+		fmt.Printf("Found address: %s\n", address)
+	}
+
+	fmt.Printf("Found %d new addresses. Next address index is %d\n", len(added), wc.WalletsObj.HD.NextIndex)
+
+	return nil
+}
+
 // List addresses (wallets) stored in the wallets file
 func (wc *WalletCLI) commandListAddresses() error {
 	fmt.Println("Wallets (addresses):")
@@ -158,12 +371,62 @@ func (wc *WalletCLI) commandListAddresses() error {
 		fmt.Println(address)
 	}
 
+	watched := wc.WalletsObj.GetWatchOnlyAddresses()
+
+	if len(watched) > 0 {
+		fmt.Println()
+		fmt.Println("Watch-only addresses (can not be spent):")
+
+		for _, address := range watched {
+			fmt.Println(address)
+		}
+	}
+
+	return nil
+}
+
+// Imports watch-only address or public key
+func (wc *WalletCLI) commandImportWatchOnly() error {
+	var pubKey []byte
+
+	if wc.Input.Command == "importpubkey" {
+		if wc.Input.PubKey == "" {
+			return errors.New("Public key is not provided")
+		}
+
+		var err error
+		pubKey, err = hex.DecodeString(wc.Input.PubKey)
+
+		if err != nil {
+			return errors.New("Public key must be in hex format")
+		}
+	} else if wc.Input.Address == "" {
+		return errors.New("Address is not provided")
+	}
+
+	err := wc.WalletsObj.ImportWatchOnly(wc.Input.Address, pubKey)
+
+	if err != nil {
+		return err
+	}
+
+	if len(pubKey) > 0 {
+		address, _ := utils.PubKeyToAddres(pubKey)
+		fmt.Printf("Watch-only address is added: %s\n", address)
+	} else {
+		fmt.Printf("Watch-only address is added: %s\n", wc.Input.Address)
+	}
+
 	return nil
 }
 
-// Lists wallets and balance for each wallet
+// Lists wallets and balance for each wallet. Watch-only addresses are marked
 func (wc *WalletCLI) commandListAddressesExt() error {
-	addresses := wc.WalletsObj.GetAddresses()
+	addresses := append(wc.WalletsObj.GetAddresses(), wc.WalletsObj.GetWatchOnlyAddresses()...)
+
+	if wc.Input.Light {
+		return wc.commandLightBalance(addresses)
+	}
 
 	fmt.Println("Balance for all addresses:")
 	fmt.Println()
@@ -175,7 +438,8 @@ func (wc *WalletCLI) commandListAddressesExt() error {
 			return err
 		}
 
-		fmt.Printf("%s: %.8f (Approved - %.8f, Pending - %.8f)\n", address, balance.Total, balance.Approved, balance.Pending)
+		fmt.Printf("%s%s: %s (Approved - %s, Pending - %s)\n", address, wc.WalletsObj.WatchOnlyMark(address),
+			balance.Total, balance.Approved, balance.Pending)
 	}
 
 	return nil
@@ -196,13 +460,13 @@ func (wc *WalletCLI) commandShowHistory() error {
 		return err
 	}
 
-	fmt.Println("History of transactions:")
+	fmt.Printf("History of transactions%s:\n", wc.WalletsObj.WatchOnlyMark(wc.Input.Address))
 
 	for _, rec := range list {
 		if rec.IOType {
-			fmt.Printf("%f\t In from\t%s\n", rec.Amount, rec.From)
+			fmt.Printf("%s\t In from\t%s\n", rec.Amount, rec.From)
 		} else {
-			fmt.Printf("%f\t Out To  \t%s\n", rec.Amount, rec.To)
+			fmt.Printf("%s\t Out To  \t%s\n", rec.Amount, rec.To)
 		}
 
 	}
@@ -218,22 +482,29 @@ func (wc *WalletCLI) commandUnspentTransactions() error {
 		return errors.New("Address is not valid")
 	}
 
-	// the wallet has to connect to node to execute this operation
-	list, err := wc.NodeCLI.SendGetUnspent(wc.Node, wc.Input.Address, []byte{})
+	var list nodeclient.ComUnspentTransactions
+	var err error
+
+	if wc.Input.Light {
+		list.Transactions, err = wc.getLightUnspent(wc.Input.Address)
+	} else {
+		// the wallet has to connect to node to execute this operation
+		list, err = wc.NodeCLI.SendGetUnspent(wc.Node, wc.Input.Address, []byte{})
+	}
 
 	if err != nil {
 		return err
 	}
 
-	balance := float64(0)
+	balance := lib.Amount(0)
 
 	for _, tx := range list.Transactions {
 
-		fmt.Printf("%f\t from\t%s in transaction %s output #%d\n", tx.Amount, tx.From, hex.EncodeToString(tx.TXID), tx.Vout)
+		fmt.Printf("%s\t from\t%s in transaction %s output #%d\n", tx.Amount, tx.From, hex.EncodeToString(tx.TXID), tx.Vout)
 		balance += tx.Amount
 	}
 
-	fmt.Printf("\nBalance - %f\n", balance)
+	fmt.Printf("\nBalance - %s%s\n", balance, wc.WalletsObj.WatchOnlyMark(wc.Input.Address))
 
 	return nil
 }
@@ -246,19 +517,85 @@ func (wc *WalletCLI) commandGetBalance() error {
 		return errors.New("Address is not valid")
 	}
 
+	if wc.Input.Light {
+		return wc.commandLightBalance([]string{wc.Input.Address})
+	}
+
 	balance, err := wc.NodeCLI.SendGetBalance(wc.Node, wc.Input.Address)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Balance of '%s': \nTotal - %.8f\n", wc.Input.Address, balance.Total)
-	fmt.Printf("Approved - %.8f\n", balance.Approved)
-	fmt.Printf("Pending - %.8f\n", balance.Pending)
+	fmt.Printf("Balance of '%s'%s: \nTotal - %s\n", wc.Input.Address, wc.WalletsObj.WatchOnlyMark(wc.Input.Address), balance.Total)
+	fmt.Printf("Approved - %s\n", balance.Approved)
+	fmt.Printf("Pending - %s\n", balance.Pending)
+
+	return nil
+}
+
+// Requests a node for supply of coins and displays it
+func (wc *WalletCLI) commandGetSupply() error {
+	supply, err := wc.NodeCLI.SendGetSupply(wc.Node, wc.Input.Height)
+
+	if err != nil {
+		return err
+	}
+
+	PrintSupply(supply)
+
+	return nil
+}
+
+// Displays supply of coins. It is used by node and wallet commands
+func PrintSupply(supply nodeclient.ComSupply) {
+	fmt.Printf("Supply after block %d - %s\n", supply.Height, supply.Supply)
+	fmt.Printf("Reward for block - %s\n", supply.BlockReward)
+
+	if supply.MaxSupply > 0 {
+		fmt.Printf("Max supply - %s\n", supply.MaxSupply)
+	}
+}
+
+// Shows balance of addresses in light client mode. Only verified outputs are counted
+// Pending transactions are not shown, they can not be verified
+func (wc *WalletCLI) commandLightBalance(addresses []string) error {
+	lc := newLightClient(wc)
+
+	err := lc.syncHeaders()
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Headers are verified up to block %d\n", len(lc.Headers)-1)
+
+	for _, address := range addresses {
+		balance, err := lc.getVerifiedBalance(address)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s%s: %s (verified)\n", address, wc.WalletsObj.WatchOnlyMark(address), balance)
+	}
 
 	return nil
 }
 
+// Returns verified unspent outputs in light client mode
+func (wc *WalletCLI) getLightUnspent(address string) ([]nodeclient.ComUnspentTransaction, error) {
+	lc := newLightClient(wc)
+
+	err := lc.syncHeaders()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return lc.getVerifiedUnspent(address)
+}
+
 // Send money command. Connects to a node to do this operation
 func (wc *WalletCLI) commandSend() error {
 	w := Wallet{}
@@ -274,8 +611,26 @@ func (wc *WalletCLI) commandSend() error {
 		return errors.New("The amount of transaction must be more 0")
 	}
 
+	if wc.Input.Fee < 0 {
+		return errors.New("The fee of transaction can not be negative")
+	}
+
+	if wc.WalletsObj.IsMultiSig(wc.Input.Address) {
+		return errors.New("Address is multisig. Use preparetx, signtx and broadcasttx to send from it")
+	}
+
+	if wc.WalletsObj.IsWatchOnly(wc.Input.Address) {
+		return ErrWatchOnly
+	}
+
 	wc.Logger.Trace.Printf("Prepare wallet %s to send data to node %s", wc.Input.Address, wc.Node.NodeAddrToString())
 
+	err := wc.UnlockIfNeeded()
+
+	if err != nil {
+		return err
+	}
+
 	// load wallet object for this address
 	walletobj, err := wc.WalletsObj.GetWallet(wc.Input.Address)
 
@@ -286,7 +641,7 @@ func (wc *WalletCLI) commandSend() error {
 	// Prepares new transaction without signatures
 	// This is just request to a node and it returns prepared transaction
 	TXBytes, DataToSign, err := wc.NodeCLI.SendRequestNewTransaction(wc.Node,
-		walletobj.GetPublicKey(), wc.Input.ToAddress, wc.Input.Amount)
+		walletobj.GetPublicKey(), wc.Input.ToAddress, wc.Input.Amount, wc.Input.Fee)
 
 	if err != nil {
 		return err
@@ -304,3 +659,478 @@ func (wc *WalletCLI) commandSend() error {
 
 	return nil
 }
+
+// Asks for a passphrase if the wallet is encrypted and decrypts keys
+func (wc *WalletCLI) UnlockIfNeeded() error {
+	if !wc.WalletsObj.IsLocked() {
+		return nil
+	}
+
+	passphrase, err := ReadPassphrase("Wallet passphrase: ")
+
+	if err != nil {
+		return err
+	}
+
+	return wc.WalletsObj.Unlock(passphrase)
+}
+
+// Unlocks the wallet or asks for a new passphrase and encrypts it if the wallet is plain.
+// Used before new keys are saved, so they are never written to a file unencrypted
+func (wc *WalletCLI) unlockOrEncrypt() error {
+	if wc.WalletsObj.Encrypted {
+		return wc.UnlockIfNeeded()
+	}
+
+	fmt.Println("Wallet file will be encrypted. Keep the passphrase, keys can not be recovered without it")
+
+	passphrase, err := ReadNewPassphrase("New passphrase: ", "Repeat new passphrase: ")
+
+	if err != nil {
+		return err
+	}
+
+	return wc.WalletsObj.Encrypt(passphrase)
+}
+
+// Changes passphrase of encrypted wallets file
+func (wc *WalletCLI) commandChangePassphrase() error {
+	if !wc.WalletsObj.Encrypted {
+		return errors.New("Wallet is not encrypted. Use unlock command to set a passphrase")
+	}
+
+	oldPassphrase, err := ReadPassphrase("Current passphrase: ")
+
+	if err != nil {
+		return err
+	}
+
+	newPassphrase, err := ReadNewPassphrase("New passphrase: ", "Repeat new passphrase: ")
+
+	if err != nil {
+		return err
+	}
+
+	err = wc.WalletsObj.ChangePassphrase(oldPassphrase, newPassphrase)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Passphrase is changed")
+
+	return nil
+}
+
+// Reads a passphrase from stdin. It is not shown on the screen if stdin is a terminal
+func ReadPassphrase(prompt string) (string, error) {
+	fmt.Print(prompt)
+
+	var passphrase string
+
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		line, err := term.ReadPassword(fd)
+
+		fmt.Println()
+
+		if err != nil {
+			return "", err
+		}
+		passphrase = string(line)
+	} else {
+		line, err := stdinReader.ReadString('\n')
+
+		if err != nil && line == "" {
+			return "", errors.New("Passphrase is not provided")
+		}
+
+		passphrase = strings.TrimRight(line, "\r\n")
+	}
+
+	if passphrase == "" {
+		return "", ErrEmptyPassphrase
+	}
+	return passphrase, nil
+}
+
+// Reads a new passphrase twice. A typo in a passphrase used to encrypt keys would lock them forever
+func ReadNewPassphrase(prompt, repeatPrompt string) (string, error) {
+	passphrase, err := ReadPassphrase(prompt)
+
+	if err != nil {
+		return "", err
+	}
+
+	repeat, err := ReadPassphrase(repeatPrompt)
+
+	if err != nil {
+		return "", err
+	}
+
+	if repeat != passphrase {
+		return "", errors.New("Passphrases don't match")
+	}
+	return passphrase, nil
+}
+
+// Reads mnemonic words from stdin. All words are on one line
+func ReadMnemonic(prompt string) (string, error) {
+	fmt.Print(prompt)
+
+	line, _ := stdinReader.ReadString('\n')
+
+	words := strings.Fields(line)
+
+	if len(words) == 0 {
+		return "", errors.New("Mnemonic is not provided")
+	}
+	return strings.Join(words, " "), nil
+}
+
+// Requests a node to prepare a transaction and saves it to a file to sign on other machine.
+// Only a public key of the sender is needed, it can be watch-only address
+func (wc *WalletCLI) commandPrepareTransaction() error {
+	w := Wallet{}
+
+	if !w.ValidateAddress(wc.Input.Address) {
+		return errors.New("From Address is not valid")
+	}
+	if !w.ValidateAddress(wc.Input.ToAddress) {
+		return errors.New("To Address is not valid")
+	}
+
+	if wc.Input.Amount <= 0 {
+		return errors.New("The amount of transaction must be more 0")
+	}
+
+	if wc.Input.Fee < 0 {
+		return errors.New("The fee of transaction can not be negative")
+	}
+
+	if wc.Input.File == "" {
+		return errors.New("File is not provided")
+	}
+
+	pubKey, err := wc.WalletsObj.GetPublicKey(wc.Input.Address)
+
+	if err != nil {
+		return err
+	}
+
+	prepared, err := wc.NodeCLI.SendRequestOfflineTransaction(wc.Node,
+		pubKey, wc.Input.ToAddress, wc.Input.Amount, wc.Input.Fee)
+
+	if err != nil {
+		return err
+	}
+
+	ot, err := NewOfflineTransaction(wc.Input.Address, wc.Input.ToAddress, wc.Input.Amount, wc.Input.Fee,
+		pubKey, prepared.TX, prepared.DataToSign, prepared.PrevTXs)
+
+	if err != nil {
+		return err
+	}
+
+	if wc.Input.LockTime != 0 {
+		err = ot.SetLockTime(wc.Input.LockTime)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return SaveOfflineTransaction(ot, wc.Input.File)
+}
+
+// Saves unsigned transaction and shows what to do next. It is used by node and wallet commands
+func SaveOfflineTransaction(ot *OfflineTransaction, file string) error {
+	err := ot.SaveToFile(file)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Unsigned transaction is saved to %s\n", file)
+	fmt.Println("Sign it with signtx on a machine with the keys, then send with broadcasttx")
+
+	return nil
+}
+
+// Signs a transaction from a file. It doesn't need a connection to a node.
+// Signatures are saved to same file
+func (wc *WalletCLI) commandSignTransaction() error {
+	if wc.Input.File == "" {
+		return errors.New("File is not provided")
+	}
+
+	ot, err := LoadOfflineTransaction(wc.Input.File)
+
+	if err != nil {
+		return err
+	}
+
+	// the fee is calculated from values of inputs, not taken from the file
+	fee, err := ot.GetFee()
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Send %s from %s to %s with fee %s\n", ot.Amount, ot.From, ot.To, fee)
+
+	for _, in := range ot.Inputs {
+		fmt.Printf("  Input %s from %s:%d\n", in.Amount, in.TXID, in.Vout)
+	}
+
+	for _, out := range ot.Outputs {
+		if out.Data != "" {
+			fmt.Printf("  Data output %s\n", out.Data)
+			continue
+		}
+		fmt.Printf("  Output %s to %s\n", out.Amount, out.Address)
+	}
+
+	err = wc.UnlockIfNeeded()
+
+	if err != nil {
+		return err
+	}
+
+	ms, err := ot.GetMultiSig()
+
+	if err != nil {
+		return err
+	}
+
+	if ms != nil {
+		return wc.signMultiSigTransaction(ot, ms)
+	}
+
+	walletobj, err := wc.WalletsObj.GetWallet(ot.From)
+
+	if err != nil {
+		return err
+	}
+
+	err = ot.Sign(walletobj)
+
+	if err != nil {
+		return err
+	}
+
+	err = ot.SaveToFile(wc.Input.File)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Transaction is signed and saved to %s\n", wc.Input.File)
+
+	return nil
+}
+
+// Signs with all keys of this wallet that are in the multisig
+func (wc *WalletCLI) signMultiSigTransaction(ot *OfflineTransaction, ms *utils.MultiSig) error {
+	signed := 0
+
+	for _, w := range wc.WalletsObj.Wallets {
+		if ms.KeyIndex(w.PublicKey) < 0 {
+			continue
+		}
+
+		err := ot.Sign(*w)
+
+		if err != nil {
+			return err
+		}
+		signed++
+	}
+
+	if signed == 0 {
+		return errors.New("This wallet has no keys of the multisig")
+	}
+
+	err := ot.SaveToFile(wc.Input.File)
+
+	if err != nil {
+		return err
+	}
+
+	wc.printMultiSigState(ot, ms)
+
+	return nil
+}
+
+func (wc *WalletCLI) printMultiSigState(ot *OfflineTransaction, ms *utils.MultiSig) {
+	if ot.IsSigned() {
+		fmt.Printf("Transaction has %d of %d signatures and is saved to %s. It can be sent now\n",
+			len(ot.PartialSignatures), ms.Required, wc.Input.File)
+	} else {
+		fmt.Printf("Transaction has %d of %d signatures and is saved to %s. Pass it to other signers\n",
+			len(ot.PartialSignatures), ms.Required, wc.Input.File)
+	}
+}
+
+// Makes multisig address from public keys. Keys can be given as addresses of this wallet too.
+// The address is added as watch-only, it is used to prepare transactions
+func (wc *WalletCLI) commandCreateMultiSig() error {
+	if wc.Input.PubKeys == "" {
+		return errors.New("Public keys are not provided")
+	}
+
+	pubKeys := [][]byte{}
+
+	for _, key := range strings.Split(wc.Input.PubKeys, ",") {
+		key = strings.TrimSpace(key)
+
+		pubKey, err := wc.WalletsObj.GetPublicKey(key)
+
+		if err != nil {
+			pubKey, err = hex.DecodeString(key)
+
+			if err != nil {
+				return errors.New(fmt.Sprintf("%s is not a public key or an address of this wallet", key))
+			}
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	ms, err := utils.NewMultiSig(wc.Input.Required, pubKeys)
+
+	if err != nil {
+		return err
+	}
+
+	address, err := ms.Address()
+
+	if err != nil {
+		return err
+	}
+
+	if !wc.WalletsObj.IsWatchOnly(address) {
+		err = wc.WalletsObj.ImportWatchOnly(address, ms.Serialize())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Multisig address (%d of %d): %s\n", ms.Required, len(ms.PubKeys), address)
+	fmt.Printf("Multisig keys: %x\n", ms.Serialize())
+	fmt.Println("Other signers can add the address to their wallets with importpubkey -pubkey KEYS")
+
+	return nil
+}
+
+// Makes address of outputs locked with a script. The address is added as watch-only
+func (wc *WalletCLI) commandCreateScript() error {
+	if wc.Input.Script == "" {
+		return errors.New("Script is not provided")
+	}
+
+	s, err := script.Assemble(wc.Input.Script)
+
+	if err != nil {
+		return err
+	}
+
+	address, err := script.Address(s)
+
+	if err != nil {
+		return err
+	}
+
+	if !wc.WalletsObj.IsWatchOnly(address) {
+		err = wc.WalletsObj.ImportWatchOnly(address, script.ScriptKey(s))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	text, _ := script.Disassemble(s)
+
+	fmt.Printf("Script address: %s\n", address)
+	fmt.Printf("Script: %s\n", text)
+	fmt.Printf("Script in hex: %x\n", s)
+
+	return nil
+}
+
+// Adds signatures from other files of same multisig transaction
+func (wc *WalletCLI) commandCombineTransaction() error {
+	if wc.Input.File == "" || wc.Input.Files == "" {
+		return errors.New("Files are not provided")
+	}
+
+	ot, err := LoadOfflineTransaction(wc.Input.File)
+
+	if err != nil {
+		return err
+	}
+
+	for _, file := range strings.Split(wc.Input.Files, ",") {
+		other, err := LoadOfflineTransaction(strings.TrimSpace(file))
+
+		if err != nil {
+			return err
+		}
+
+		err = ot.Combine(other)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	ms, err := ot.GetMultiSig()
+
+	if err != nil {
+		return err
+	}
+
+	err = ot.SaveToFile(wc.Input.File)
+
+	if err != nil {
+		return err
+	}
+
+	wc.printMultiSigState(ot, ms)
+
+	return nil
+}
+
+// Sends signed transaction from a file to a node
+func (wc *WalletCLI) commandBroadcastTransaction() error {
+	if wc.Input.File == "" {
+		return errors.New("File is not provided")
+	}
+
+	ot, err := LoadOfflineTransaction(wc.Input.File)
+
+	if err != nil {
+		return err
+	}
+
+	TXBytes, err := ot.GetTransaction()
+
+	if err != nil {
+		return err
+	}
+
+	signatures, err := ot.GetSignatures()
+
+	if err != nil {
+		return err
+	}
+
+	NewTXID, err := wc.NodeCLI.SendNewTransactionData(wc.Node, ot.From, TXBytes, signatures)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Success. New transaction: %x\n", NewTXID)
+
+	return nil
+}
@@ -0,0 +1,311 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/gob"
+	"errors"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted wallets file starts with this. Old files are plain gob data
+var encryptedWalletsMagic = []byte("TAINWENC")
+
+// scrypt parameters for new files. They are saved in a file, so can be changed later
+const (
+	walletKDFN      = 1 << 15
+	walletKDFR      = 8
+	walletKDFP      = 1
+	walletKeyLength = 32
+	walletSaltSize  = 16
+)
+
+var (
+	ErrWalletLocked     = errors.New("Wallet is locked. Unlock it first")
+	ErrWrongPassphrase  = errors.New("Wrong passphrase")
+	ErrEmptyPassphrase  = errors.New("Passphrase can not be empty")
+	ErrWalletNotCrypted = errors.New("Wallet is not encrypted")
+)
+
+// Content of encrypted wallets file. Public keys are not encrypted, so addresses can be listed
+// and balances can be checked without a passphrase. Private keys are encrypted with AES-256-GCM,
+// the key is derived from a passphrase with scrypt
+type encryptedWalletsFile struct {
+	Version    int
+	Salt       []byte
+	N          int
+	R          int
+	P          int
+	Nonce      []byte
+	PublicKeys map[string][]byte
+	Data       []byte
+	// watch-only addresses. They have no private keys, so they are not encrypted
+	Watched map[string][]byte
+}
+
+// Encrypted data after keys in files of version 2. Files without swap secrets are saved as
+// version 1, there is only a seed after keys, so they can be read by old versions
+type encryptedWalletsExtra struct {
+	HD          *HDWallet
+	SwapSecrets map[string][]byte
+}
+
+// Keys of one wallet in encrypted data. Only numbers are saved, the curve is always P256
+type encryptedWalletKeys struct {
+	PrivateKey []byte
+	PublicKey  []byte
+}
+
+// Encryption key and parameters it was derived with
+type walletsKey struct {
+	key  []byte
+	salt []byte
+	n    int
+	r    int
+	p    int
+}
+
+// Derives new key from a passphrase with a random salt
+func newWalletsKey(passphrase string) (*walletsKey, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+
+	salt := make([]byte, walletSaltSize)
+
+	_, err := rand.Read(salt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return deriveWalletsKey(passphrase, salt, walletKDFN, walletKDFR, walletKDFP)
+}
+
+func deriveWalletsKey(passphrase string, salt []byte, n, r, p int) (*walletsKey, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, n, r, p, walletKeyLength)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &walletsKey{key, salt, n, r, p}, nil
+}
+
+// Encrypts wallets, a seed of HD wallets and secrets of swaps
+func (k *walletsKey) encrypt(wallets map[string]*Wallet, hd *HDWallet, secrets map[string][]byte) (*encryptedWalletsFile, error) {
+	var plain bytes.Buffer
+
+	keys := map[string]encryptedWalletKeys{}
+
+	for address, w := range wallets {
+		keys[address] = encryptedWalletKeys{w.PrivateKey.D.Bytes(), w.PublicKey}
+	}
+
+	encoder := gob.NewEncoder(&plain)
+
+	err := encoder.Encode(keys)
+
+	if err != nil {
+		return nil, err
+	}
+
+	version := 1
+
+	if len(secrets) > 0 {
+		version = 2
+
+		err = encoder.Encode(encryptedWalletsExtra{hd, secrets})
+
+		if err != nil {
+			return nil, err
+		}
+	} else if hd != nil {
+		// the seed goes after keys. files without HD wallets don't have it
+		err = encoder.Encode(hd)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	aead, err := k.getAEAD()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ef := encryptedWalletsFile{Version: version, Salt: k.salt, N: k.n, R: k.r, P: k.p}
+
+	ef.Nonce = make([]byte, aead.NonceSize())
+
+	_, err = rand.Read(ef.Nonce)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ef.Data = aead.Seal(nil, ef.Nonce, plain.Bytes(), encryptedWalletsMagic)
+
+	ef.PublicKeys = map[string][]byte{}
+
+	for address, w := range wallets {
+		ef.PublicKeys[address] = w.PublicKey
+	}
+
+	return &ef, nil
+}
+
+// Returns file contents
+func (ef *encryptedWalletsFile) serialize() ([]byte, error) {
+	content := bytes.NewBuffer(append([]byte{}, encryptedWalletsMagic...))
+
+	err := gob.NewEncoder(content).Encode(ef)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return content.Bytes(), nil
+}
+
+func (k *walletsKey) getAEAD() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Checks if file contents are encrypted wallets
+func isEncryptedWallets(content []byte) bool {
+	return bytes.HasPrefix(content, encryptedWalletsMagic)
+}
+
+func parseEncryptedWallets(content []byte) (*encryptedWalletsFile, error) {
+	ef := encryptedWalletsFile{}
+
+	err := gob.NewDecoder(bytes.NewReader(content[len(encryptedWalletsMagic):])).Decode(&ef)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &ef, nil
+}
+
+// Returns wallets with public keys only
+func (ef *encryptedWalletsFile) getPublicWallets() map[string]*Wallet {
+	wallets := map[string]*Wallet{}
+
+	for address, pubKey := range ef.PublicKeys {
+		wallets[address] = &Wallet{PublicKey: pubKey}
+	}
+	return wallets
+}
+
+// Derives a key from a passphrase and decrypts wallets. Returns the key to save changes later
+func (ef *encryptedWalletsFile) decrypt(passphrase string) (map[string]*Wallet, *HDWallet, map[string][]byte, *walletsKey, error) {
+	key, err := deriveWalletsKey(passphrase, ef.Salt, ef.N, ef.R, ef.P)
+
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	wallets, hd, secrets, err := ef.decryptWithKey(key)
+
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return wallets, hd, secrets, key, nil
+}
+
+// Decrypts wallets with a key derived before
+func (ef *encryptedWalletsFile) decryptWithKey(key *walletsKey) (map[string]*Wallet, *HDWallet, map[string][]byte, error) {
+	aead, err := key.getAEAD()
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	plain, err := aead.Open(nil, ef.Nonce, ef.Data, encryptedWalletsMagic)
+
+	if err != nil {
+		// authentication failed. it is wrong passphrase or changed file
+		return nil, nil, nil, ErrWrongPassphrase
+	}
+
+	keys := map[string]encryptedWalletKeys{}
+
+	decoder := gob.NewDecoder(bytes.NewReader(plain))
+
+	err = decoder.Decode(&keys)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var hd *HDWallet
+	var secrets map[string][]byte
+
+	if ef.Version >= 2 {
+		extra := encryptedWalletsExtra{}
+
+		err = decoder.Decode(&extra)
+
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		hd = extra.HD
+		secrets = extra.SwapSecrets
+	} else {
+		hdData := HDWallet{}
+
+		err = decoder.Decode(&hdData)
+
+		if err == nil {
+			hd = &hdData
+		} else if err != io.EOF {
+			return nil, nil, nil, err
+		}
+	}
+
+	// public part is not authenticated. it must be same as encrypted
+	if len(keys) != len(ef.PublicKeys) {
+		return nil, nil, nil, errors.New("Wallets file is damaged. Public keys don't match encrypted keys")
+	}
+
+	wallets := map[string]*Wallet{}
+
+	for address, k := range keys {
+		w := &Wallet{PrivateKey: makePrivateKey(k.PrivateKey), PublicKey: k.PublicKey}
+
+		pubKey := append(w.PrivateKey.PublicKey.X.Bytes(), w.PrivateKey.PublicKey.Y.Bytes()...)
+
+		if !bytes.Equal(ef.PublicKeys[address], w.PublicKey) || !bytes.Equal(pubKey, w.PublicKey) {
+			return nil, nil, nil, errors.New("Wallets file is damaged. Public keys don't match encrypted keys")
+		}
+		wallets[address] = w
+	}
+
+	return wallets, hd, secrets, nil
+}
+
+// Restores P256 private key from its number
+func makePrivateKey(d []byte) ecdsa.PrivateKey {
+	private := ecdsa.PrivateKey{}
+	private.Curve = elliptic.P256()
+	private.D = new(big.Int).SetBytes(d)
+	private.PublicKey.X, private.PublicKey.Y = private.Curve.ScalarBaseMult(d)
+
+	return private
+}
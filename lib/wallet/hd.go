@@ -0,0 +1,263 @@
+package wallet
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Keys of HD wallets are derived with SLIP-0010, it is BIP32 for the NIST P-256 curve.
+// Addresses are on path m/44'/HDCoinType'/0'/0/i
+const (
+	HDCoinType     = 5489 // not registered in SLIP-44
+	HDGapLimit     = 20   // number of unused addresses in a row to stop a rescan
+	hdHardened     = uint32(0x80000000)
+	hdMasterSecret = "Nist256p1 seed"
+)
+
+// Seed of HD wallets and index of next address
+type HDWallet struct {
+	Seed      []byte
+	NextIndex uint32
+}
+
+// Extended private key
+type hdKey struct {
+	key       *big.Int
+	chainCode []byte
+}
+
+// Returns path of an address key
+func HDAddressPath(index uint32) string {
+	return fmt.Sprintf("m/44'/%d'/0'/0/%d", HDCoinType, index)
+}
+
+func newHDMasterKey(seed []byte) *hdKey {
+	n := elliptic.P256().Params().N
+	data := seed
+
+	for {
+		mac := hmac.New(sha512.New, []byte(hdMasterSecret))
+		mac.Write(data)
+		I := mac.Sum(nil)
+
+		key := new(big.Int).SetBytes(I[:32])
+
+		if key.Sign() > 0 && key.Cmp(n) < 0 {
+			return &hdKey{key, I[32:]}
+		}
+		data = I
+	}
+}
+
+// Derives a child private key
+func (k *hdKey) child(index uint32) *hdKey {
+	curve := elliptic.P256()
+	n := curve.Params().N
+
+	data := []byte{}
+
+	if index >= hdHardened {
+		data = append([]byte{0}, ser256(k.key)...)
+	} else {
+		x, y := curve.ScalarBaseMult(ser256(k.key))
+		data = elliptic.MarshalCompressed(curve, x, y)
+	}
+	data = append(data, ser32(index)...)
+
+	for {
+		mac := hmac.New(sha512.New, k.chainCode)
+		mac.Write(data)
+		I := mac.Sum(nil)
+
+		il := new(big.Int).SetBytes(I[:32])
+
+		if il.Cmp(n) < 0 {
+			key := new(big.Int).Add(il, k.key)
+			key.Mod(key, n)
+
+			if key.Sign() > 0 {
+				return &hdKey{key, I[32:]}
+			}
+		}
+		// invalid key. SLIP-0010 continues with other data
+		data = append([]byte{1}, I[32:]...)
+		data = append(data, ser32(index)...)
+	}
+}
+
+// Derives a key for a path like m/44'/0'/0'/0/1
+func (k *hdKey) derivePath(path string) (*hdKey, error) {
+	parts := strings.Split(path, "/")
+
+	if parts[0] != "m" {
+		return nil, errors.New("Path must start with m")
+	}
+
+	key := k
+
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'")
+
+		index, err := strconv.ParseUint(strings.TrimSuffix(part, "'"), 10, 31)
+
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Wrong path element %s", part))
+		}
+
+		if hardened {
+			index += uint64(hdHardened)
+		}
+		key = key.child(uint32(index))
+	}
+	return key, nil
+}
+
+// Makes a wallet for an address index. Returns nil wallet if the key can not be used.
+// Public key of a wallet is X and Y without padding, verification of signatures needs
+// 32 bytes for each of them. Such keys are skipped same way on every derivation
+func (hd *HDWallet) deriveWallet(index uint32) (*Wallet, error) {
+	key, err := newHDMasterKey(hd.Seed).derivePath(HDAddressPath(index))
+
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Wallet{}
+	w.PrivateKey = makePrivateKey(ser256(key.key))
+	w.PublicKey = append(w.PrivateKey.PublicKey.X.Bytes(), w.PrivateKey.PublicKey.Y.Bytes()...)
+
+	if len(w.PublicKey) != 64 {
+		return nil, nil
+	}
+	return w, nil
+}
+
+// Derives wallet for next index. Returns the wallet and its address
+func (hd *HDWallet) nextWallet() (*Wallet, string, error) {
+	for {
+		index := hd.NextIndex
+		hd.NextIndex++
+
+		w, err := hd.deriveWallet(index)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		if w != nil {
+			return w, string(w.GetAddress()), nil
+		}
+	}
+}
+
+func ser256(v *big.Int) []byte {
+	b := make([]byte, 32)
+	return v.FillBytes(b)
+}
+
+func ser32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+// Sets a seed of HD wallets from a mnemonic. New addresses will be derived from it
+func (ws *Wallets) InitHD(mnemonic string) error {
+	if ws.IsLocked() {
+		return ErrWalletLocked
+	}
+
+	if ws.HD != nil {
+		return errors.New("Wallet already has HD seed")
+	}
+
+	seed, err := MnemonicToSeed(mnemonic)
+
+	if err != nil {
+		return err
+	}
+
+	ws.HD = &HDWallet{Seed: seed}
+
+	return ws.SaveToFile()
+}
+
+func (ws *Wallets) createHDWallet() (string, error) {
+	w, address, err := ws.HD.nextWallet()
+
+	if err != nil {
+		return "", err
+	}
+
+	ws.Wallets[address] = w
+
+	err = ws.SaveToFile()
+
+	if err != nil {
+		return "", err
+	}
+
+	return address, nil
+}
+
+// Derives addresses one by one and checks if they were used. Stops after gapLimit unused
+// addresses in a row. Used addresses are added to wallets. Returns added addresses
+func (ws *Wallets) RescanHD(isUsed func(address string) (bool, error), gapLimit int) ([]string, error) {
+	if ws.IsLocked() {
+		return nil, ErrWalletLocked
+	}
+
+	if ws.HD == nil {
+		return nil, errors.New("Wallet is not HD")
+	}
+
+	added := []string{}
+	unused := 0
+	next := ws.HD.NextIndex
+
+	for index := uint32(0); unused < gapLimit; index++ {
+		w, err := ws.HD.deriveWallet(index)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if w == nil {
+			continue
+		}
+
+		address := string(w.GetAddress())
+
+		used, err := isUsed(address)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !used {
+			unused++
+			continue
+		}
+		unused = 0
+
+		if index >= next {
+			next = index + 1
+		}
+
+		if _, ok := ws.Wallets[address]; !ok {
+			ws.Wallets[address] = w
+			added = append(added, address)
+		}
+	}
+
+	ws.HD.NextIndex = next
+
+	return added, ws.SaveToFile()
+}
@@ -0,0 +1,137 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestMnemonic(t *testing.T) {
+	mnemonic := entropyToMnemonic(make([]byte, 16))
+
+	if mnemonic != "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about" {
+		t.Fatalf("Wrong mnemonic %s", mnemonic)
+	}
+
+	seed, err := MnemonicToSeed(mnemonic)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hex.EncodeToString(seed) != "5eb00bbddcf069084889a8ab9155568165f5c453ccb85e70811aaed6f6da5fc19a5ac40b389cd370d086206dec8aa6c43daea6690f20ad3d8d48b2d2ce9e38e4" {
+		t.Fatalf("Wrong seed %x", seed)
+	}
+
+	if ValidateMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon") == nil {
+		t.Fatal("Expected checksum error")
+	}
+}
+
+func TestHDDerivation(t *testing.T) {
+	// SLIP-0010 test vector 1 for nist256p1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+
+	master := newHDMasterKey(seed)
+
+	if hex.EncodeToString(ser256(master.key)) != "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2" {
+		t.Fatalf("Wrong master key %x", ser256(master.key))
+	}
+
+	key, err := master.derivePath("m/0'")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hex.EncodeToString(ser256(key.key)) != "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c" {
+		t.Fatalf("Wrong child key %x", ser256(key.key))
+	}
+}
+
+func TestHDRescan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallets")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mnemonic, err := NewMnemonic(MnemonicEntropyBits)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ws := Wallets{DataDir: dir + "/", Wallets: map[string]*Wallet{}}
+
+	if err = ws.Encrypt("secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ws.InitHD(mnemonic); err != nil {
+		t.Fatal(err)
+	}
+
+	used := map[string]bool{}
+
+	for i := 0; i < 3; i++ {
+		address, err := ws.CreateWallet()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		// only first and last addresses have transactions
+		used[address] = i != 1
+	}
+
+	// restore from same words to other directory
+	dir2, err := ioutil.TempDir("", "wallets")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir2)
+
+	ws2 := Wallets{DataDir: dir2 + "/", Wallets: map[string]*Wallet{}}
+	ws2.Encrypt("secret")
+
+	if err = ws2.InitHD(mnemonic); err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := ws2.RescanHD(func(address string) (bool, error) {
+		return used[address], nil
+	}, HDGapLimit)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(added) != 2 || ws2.HD.NextIndex != 3 {
+		t.Fatalf("Expected 2 addresses and next index 3, got %d and %d", len(added), ws2.HD.NextIndex)
+	}
+
+	// seed is encrypted with keys
+	ws3 := Wallets{DataDir: dir2 + "/"}
+	ws3.LoadFromFile()
+
+	if ws3.HD != nil {
+		t.Fatal("Seed must not be available in locked wallet")
+	}
+
+	if err = ws3.Unlock("secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	address, err := ws3.CreateWallet()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := used[address]; ok {
+		t.Fatal("Expected new address with index 3")
+	}
+}
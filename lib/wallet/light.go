@@ -0,0 +1,334 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/net"
+	"github.com/taincoin/taincoin/lib/nodeclient"
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+const headersFile = "headers.dat"
+
+// Light client (SPV). It doesn't trust a node. It keeps only block headers and checks
+// proof of work and difficulty of them. Headers are requested from all known nodes and the chain with most work is used.
+// Outputs of a wallet are accepted only if some node gives a Merkle proof that a transaction
+// is in a block from the headers chain.
+// NOTE. A light client can not prove an output is not spent. If all nodes hide a spending then a balance
+// will be shown bigger. Use more nodes to decrease this risk
+// It works only with proof of work consensus.
+type lightClient struct {
+	wc      *WalletCLI
+	Headers []nodeclient.ComBlockHeader
+	index   map[string]int // block hash to position in Headers
+}
+
+func newLightClient(wc *WalletCLI) *lightClient {
+	return &lightClient{wc, []nodeclient.ComBlockHeader{}, map[string]int{}}
+}
+
+// Returns list of nodes to ask. Every answer is verified, so more nodes is better
+func (lc *lightClient) getNodes() []net.NodeAddr {
+	nodes := []net.NodeAddr{}
+
+	add := func(node net.NodeAddr) {
+		if node.Host == "" {
+			return
+		}
+		for _, n := range nodes {
+			if n.CompareToAddress(node) {
+				return
+			}
+		}
+		nodes = append(nodes, node)
+	}
+
+	add(lc.wc.Node)
+
+	for _, node := range lc.wc.Nodes {
+		add(node)
+	}
+
+	if len(nodes) == 0 {
+		lc.wc.NodeCLI.NodeNet.LoadInitialNodes(nil)
+
+		for _, node := range lc.wc.NodeCLI.NodeNet.Nodes {
+			add(node)
+		}
+	}
+
+	return nodes
+}
+
+// Loads headers saved before
+func (lc *lightClient) loadHeaders() error {
+	fileContent, err := ioutil.ReadFile(lc.wc.DataDir + headersFile)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	headers := []nodeclient.ComBlockHeader{}
+
+	err = gob.NewDecoder(bytes.NewReader(fileContent)).Decode(&headers)
+
+	if err != nil {
+		return err
+	}
+
+	// saved headers are checked again. the file could be changed
+	if lc.verifyHeaders(headers, 0) != nil {
+		lc.wc.Logger.Trace.Println("Saved headers are not valid. Loading again")
+		return nil
+	}
+
+	lc.setHeaders(headers)
+
+	return nil
+}
+
+// Saves headers to a file. Next time only new headers are loaded
+func (lc *lightClient) saveHeaders() error {
+	var content bytes.Buffer
+
+	err := gob.NewEncoder(&content).Encode(lc.Headers)
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(lc.wc.DataDir+headersFile, content.Bytes(), 0644)
+}
+
+func (lc *lightClient) setHeaders(headers []nodeclient.ComBlockHeader) {
+	lc.Headers = headers
+	lc.index = map[string]int{}
+
+	for i, h := range headers {
+		lc.index[string(h.Hash)] = i
+	}
+}
+
+// Checks headers make a chain and every header has correct proof of work. Difficulty of a header
+// must be what the retarget rules give for its place in the chain, so a node can not give a chain
+// of cheap headers. Headers before "from" are checked already
+func (lc *lightClient) verifyHeaders(headers []nodeclient.ComBlockHeader, from int) error {
+	now := time.Now().Unix()
+
+	for i := from; i < len(headers); i++ {
+		err := nodeclient.VerifyNextHeader(headers[i], headers[:i], now)
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Calculates total work of a headers chain
+func (lc *lightClient) getChainWork(headers []nodeclient.ComBlockHeader) *big.Int {
+	work := big.NewInt(0)
+
+	for _, h := range headers {
+		work.Add(work, utils.GetWorkForBits(h.Bits))
+	}
+	return work
+}
+
+// Loads headers from a node. Continues known headers if the node has same chain
+func (lc *lightClient) loadHeadersFromNode(node net.NodeAddr) ([]nodeclient.ComBlockHeader, error) {
+	headers := append([]nodeclient.ComBlockHeader{}, lc.Headers...)
+
+	for {
+		list, err := lc.wc.NodeCLI.SendGetHeaders(node, len(headers), nodeclient.MaxHeadersPerRequest)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(list) == 0 {
+			break
+		}
+
+		if len(headers) > 0 && !bytes.Equal(list[0].PrevBlockHash, headers[len(headers)-1].Hash) {
+			if len(headers) == len(lc.Headers) && len(lc.Headers) > 0 {
+				// the node has other branch. load all from the start
+				headers = []nodeclient.ComBlockHeader{}
+				continue
+			}
+			return nil, errors.New("Node returned headers not linked to previous")
+		}
+
+		start := len(headers)
+		headers = append(headers, list...)
+
+		err = lc.verifyHeaders(headers, start)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(list) < nodeclient.MaxHeadersPerRequest {
+			break
+		}
+	}
+	return headers, nil
+}
+
+// Syncs headers with all nodes and keeps the chain with most work
+func (lc *lightClient) syncHeaders() error {
+	err := lc.loadHeaders()
+
+	if err != nil {
+		return err
+	}
+
+	best := lc.Headers
+	bestWork := lc.getChainWork(best)
+
+	for _, node := range lc.getNodes() {
+		headers, err := lc.loadHeadersFromNode(node)
+
+		if err != nil {
+			lc.wc.Logger.Trace.Printf("Headers from %s are not accepted: %s", node.NodeAddrToString(), err.Error())
+			continue
+		}
+
+		work := lc.getChainWork(headers)
+
+		if work.Cmp(bestWork) > 0 {
+			best = headers
+			bestWork = work
+		}
+	}
+
+	if len(best) == 0 {
+		return errors.New("No valid headers received from nodes")
+	}
+
+	lc.setHeaders(best)
+
+	return lc.saveHeaders()
+}
+
+// Checks an output with a Merkle proof. Returns verified value of the output
+func (lc *lightClient) verifyOutput(proof nodeclient.ComMerkleProof, txID []byte, vout int, pubKeyHash []byte) (lib.Amount, error) {
+	if !bytes.Equal(proof.TXID, txID) {
+		return 0, errors.New("Proof is for other transaction")
+	}
+
+	pos, ok := lc.index[string(proof.BlockHash)]
+
+	if !ok {
+		return 0, errors.New("Block of a transaction is not in the headers chain")
+	}
+
+	if !proof.Verify(lc.Headers[pos].MerkleRoot) {
+		return 0, errors.New("Merkle proof is not valid")
+	}
+
+	if vout < 0 || vout >= len(proof.Outputs) {
+		return 0, errors.New("Output index is wrong")
+	}
+
+	if !bytes.Equal(proof.Outputs[vout].PubKeyHash, pubKeyHash) {
+		return 0, errors.New("Output is not for this address")
+	}
+
+	return proof.Outputs[vout].Value, nil
+}
+
+// Returns unspent outputs of an address that are proved to be in the headers chain
+// Outputs are collected from all nodes. Values are taken from proofs, not from a node answer
+func (lc *lightClient) getVerifiedUnspent(address string) ([]nodeclient.ComUnspentTransaction, error) {
+	pubKeyHash, err := utils.AddresToPubKeyHash(address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := lc.getNodes()
+
+	candidates := []nodeclient.ComUnspentTransaction{}
+	known := map[string]bool{}
+
+	for _, node := range nodes {
+		list, err := lc.wc.NodeCLI.SendGetUnspent(node, address, []byte{})
+
+		if err != nil {
+			lc.wc.Logger.Trace.Printf("Unspent from %s error: %s", node.NodeAddrToString(), err.Error())
+			continue
+		}
+
+		for _, ut := range list.Transactions {
+			key := fmt.Sprintf("%x:%d", ut.TXID, ut.Vout)
+
+			if !known[key] {
+				known[key] = true
+				candidates = append(candidates, ut)
+			}
+		}
+	}
+
+	result := []nodeclient.ComUnspentTransaction{}
+
+	for _, ut := range candidates {
+		verified := false
+
+		for _, node := range nodes {
+			proof, err := lc.wc.NodeCLI.SendGetMerkleProof(node, ut.TXID)
+
+			if err != nil {
+				continue
+			}
+
+			value, err := lc.verifyOutput(proof, ut.TXID, ut.Vout, pubKeyHash)
+
+			if err != nil {
+				lc.wc.Logger.Trace.Printf("Proof from %s is not accepted: %s", node.NodeAddrToString(), err.Error())
+				continue
+			}
+
+			ut.Amount = value
+			verified = true
+			break
+		}
+
+		if verified {
+			result = append(result, ut)
+		} else {
+			lc.wc.Logger.Trace.Printf("Output %s:%d is not verified", hex.EncodeToString(ut.TXID), ut.Vout)
+		}
+	}
+
+	return result, nil
+}
+
+// Returns balance of an address from verified outputs
+func (lc *lightClient) getVerifiedBalance(address string) (lib.Amount, error) {
+	list, err := lc.getVerifiedUnspent(address)
+
+	if err != nil {
+		return 0, err
+	}
+
+	balance := lib.Amount(0)
+
+	for _, ut := range list {
+		balance += ut.Amount
+	}
+	return balance, nil
+}
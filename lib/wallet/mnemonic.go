@@ -0,0 +1,118 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Mnemonic backup of a seed. It is same as BIP39 with the English word list.
+// 128 bits of entropy give 12 words, 256 bits give 24 words
+const (
+	MnemonicEntropyBits = 128
+	mnemonicSeedRounds  = 2048
+	mnemonicSeedLength  = 64
+)
+
+var mnemonicIndexes map[string]int
+
+func init() {
+	mnemonicIndexes = make(map[string]int, len(mnemonicWords))
+
+	for i, w := range mnemonicWords {
+		mnemonicIndexes[w] = i
+	}
+}
+
+// Generates new random mnemonic
+func NewMnemonic(bits int) (string, error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", errors.New("Entropy must be 128-256 bits, multiple of 32")
+	}
+
+	entropy := make([]byte, bits/8)
+
+	_, err := rand.Read(entropy)
+
+	if err != nil {
+		return "", err
+	}
+
+	return entropyToMnemonic(entropy), nil
+}
+
+// Entropy and a checksum are split to 11 bits numbers. Every number is a word index
+func entropyToMnemonic(entropy []byte) string {
+	checksumBits := uint(len(entropy) * 8 / 32)
+	hash := sha256.Sum256(entropy)
+
+	data := new(big.Int).SetBytes(entropy)
+	data.Lsh(data, checksumBits)
+	data.Or(data, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	count := (len(entropy)*8 + int(checksumBits)) / 11
+	words := make([]string, count)
+	mask := big.NewInt(2047)
+
+	for i := count - 1; i >= 0; i-- {
+		words[i] = mnemonicWords[new(big.Int).And(data, mask).Int64()]
+		data.Rsh(data, 11)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// Checks words and a checksum of a mnemonic
+func ValidateMnemonic(mnemonic string) error {
+	words := strings.Fields(mnemonic)
+
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return errors.New("Mnemonic must have 12, 15, 18, 21 or 24 words")
+	}
+
+	data := big.NewInt(0)
+
+	for _, w := range words {
+		index, ok := mnemonicIndexes[strings.ToLower(w)]
+
+		if !ok {
+			return errors.New(fmt.Sprintf("Unknown mnemonic word %s", w))
+		}
+		data.Lsh(data, 11)
+		data.Or(data, big.NewInt(int64(index)))
+	}
+
+	checksumBits := uint(len(words) * 11 / 33)
+
+	checksum := new(big.Int).And(data, big.NewInt(int64(1<<checksumBits-1)))
+	data.Rsh(data, checksumBits)
+
+	entropy := make([]byte, len(words)*11*32/33/8)
+	data.FillBytes(entropy)
+
+	hash := sha256.Sum256(entropy)
+
+	if int64(hash[0]>>(8-checksumBits)) != checksum.Int64() {
+		return errors.New("Mnemonic checksum is wrong. Check words and their order")
+	}
+	return nil
+}
+
+// Returns a seed for HD keys. Same as BIP39, the password is empty
+func MnemonicToSeed(mnemonic string) ([]byte, error) {
+	err := ValidateMnemonic(mnemonic)
+
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := strings.ToLower(strings.Join(strings.Fields(mnemonic), " "))
+
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"), mnemonicSeedRounds, mnemonicSeedLength, sha512.New), nil
+}
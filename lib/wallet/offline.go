@@ -0,0 +1,663 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+// Version of unsigned transaction files. Increase it if the format changes
+const OfflineTransactionVersion = 2
+
+// Transaction prepared on an online machine to be signed on other machine where keys are.
+// It is saved as JSON, so it can be checked before signing. Inputs and outputs are decoded
+// from the transaction for information only, the signer decodes the transaction again
+type OfflineTransaction struct {
+	Version     int
+	From        string
+	To          string
+	Amount      string
+	Fee         string
+	PublicKey   string
+	Inputs      []OfflineTXInput
+	Outputs     []OfflineTXOutput
+	Transaction string // serialized transaction in hex
+	// transactions spent by inputs, serialized without IDs, in hex. Hashes of them are checked
+	// with inputs, so values of inputs and the fee are known offline
+	PrevTransactions []string
+	DataToSign       []string // data to sign for every input, in hex
+	Signatures       []string // signatures for every input, in hex. Empty until signed
+
+	// block height or unix time before which the transaction can not be added to a block
+	LockTime int64 `json:",omitempty"`
+
+	// signatures of multisig keys. Key in hex is mapped to signatures for every input.
+	// Signatures are joined when there are enough of them
+	PartialSignatures map[string][]string `json:",omitempty"`
+}
+
+type OfflineTXInput struct {
+	TXID   string
+	Vout   int
+	Amount string `json:",omitempty"` // value of a spent output, for information
+}
+
+type OfflineTXOutput struct {
+	Address string
+	Amount  string
+	Data    string `json:",omitempty"` // data output in hex. It has no address and amount
+}
+
+// Same fields as node transaction structures. A wallet doesn't depend on node packages,
+// gob decodes a transaction to these by field names
+type offlineTX struct {
+	ID   []byte
+	Vin  []offlineTXInput
+	Vout []offlineTXOutput
+	Time int64
+
+	LockTime int64
+}
+
+type offlineTXInput struct {
+	Txid      []byte
+	Vout      int
+	Signature []byte
+	PubKey    []byte
+
+	RelativeLock int64
+}
+
+type offlineTXOutput struct {
+	Value      lib.Amount
+	PubKeyHash []byte
+
+	Data []byte
+}
+
+// Makes unsigned transaction file contents from a transaction prepared by a node.
+// prevTXs are transactions spent by inputs, see PrevTransactions
+func NewOfflineTransaction(from, to string, amount, fee lib.Amount, pubKey []byte,
+	txBytes []byte, dataToSign [][]byte, prevTXs [][]byte) (*OfflineTransaction, error) {
+
+	ot := &OfflineTransaction{}
+	ot.Version = OfflineTransactionVersion
+	ot.From = from
+	ot.To = to
+	ot.Amount = amount.String()
+	ot.Fee = fee.String()
+	ot.PublicKey = hex.EncodeToString(pubKey)
+	ot.Transaction = hex.EncodeToString(txBytes)
+	ot.Signatures = []string{}
+
+	for _, data := range dataToSign {
+		ot.DataToSign = append(ot.DataToSign, hex.EncodeToString(data))
+	}
+
+	ot.PrevTransactions = []string{}
+
+	for _, prevTX := range prevTXs {
+		ot.PrevTransactions = append(ot.PrevTransactions, hex.EncodeToString(prevTX))
+	}
+
+	tx, err := ot.decodeTransaction()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// values are shown if previous transactions are fine. Sign checks them anyway
+	values, _ := ot.getInputValues(tx)
+
+	for i, vin := range tx.Vin {
+		input := OfflineTXInput{TXID: hex.EncodeToString(vin.Txid), Vout: vin.Vout}
+
+		if values != nil {
+			input.Amount = values[i].String()
+		}
+		ot.Inputs = append(ot.Inputs, input)
+	}
+	ot.LockTime = tx.LockTime
+
+	for _, vout := range tx.Vout {
+		if len(vout.Data) > 0 {
+			ot.Outputs = append(ot.Outputs, OfflineTXOutput{Amount: vout.Value.String(), Data: hex.EncodeToString(vout.Data)})
+			continue
+		}
+		address, _ := utils.PubKeyHashToAddres(vout.PubKeyHash)
+		ot.Outputs = append(ot.Outputs, OfflineTXOutput{Address: address, Amount: vout.Value.String()})
+	}
+
+	return ot, nil
+}
+
+// Loads transaction from a file
+func LoadOfflineTransaction(file string) (*OfflineTransaction, error) {
+	content, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ot := &OfflineTransaction{}
+
+	err = json.Unmarshal(content, ot)
+
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Transaction file is not valid: %s", err.Error()))
+	}
+
+	if ot.Version != OfflineTransactionVersion {
+		return nil, errors.New(fmt.Sprintf("Transaction file version %d is not supported", ot.Version))
+	}
+
+	return ot, nil
+}
+
+// Saves transaction to a file
+func (ot *OfflineTransaction) SaveToFile(file string) error {
+	content, err := json.MarshalIndent(ot, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, append(content, '\n'), 0600)
+}
+
+// Sets lock time of the transaction. A node doesn't add it to a block before the height
+// or the unix time. Data to sign are made again, so it must be done before signing
+func (ot *OfflineTransaction) SetLockTime(lockTime int64) error {
+	if len(ot.Signatures) > 0 || len(ot.PartialSignatures) > 0 {
+		return errors.New("Transaction is already signed")
+	}
+
+	if lockTime < 0 {
+		return errors.New("Lock time can not be negative")
+	}
+
+	tx, err := ot.decodeTransaction()
+
+	if err != nil {
+		return err
+	}
+
+	tx.LockTime = lockTime
+
+	var encoded bytes.Buffer
+
+	err = gob.NewEncoder(&encoded).Encode(tx)
+
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := hex.DecodeString(ot.PublicKey)
+
+	if err != nil {
+		return err
+	}
+
+	pubKeyHash, _ := utils.HashPubKey(pubKey)
+
+	ot.DataToSign = []string{}
+
+	for _, data := range tx.prepareSignData(pubKeyHash) {
+		ot.DataToSign = append(ot.DataToSign, hex.EncodeToString(data))
+	}
+
+	ot.Transaction = hex.EncodeToString(encoded.Bytes())
+	ot.LockTime = lockTime
+
+	return nil
+}
+
+// Returns values of inputs of the transaction. They are taken from previous transactions in the file.
+// A hash of a previous transaction must be ID an input spends and the output must be of the sender
+func (ot *OfflineTransaction) getInputValues(tx *offlineTX) ([]lib.Amount, error) {
+	pubKey, err := hex.DecodeString(ot.PublicKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pubKeyHash, _ := utils.HashPubKey(pubKey)
+
+	prevTXs := map[string]*offlineTX{}
+
+	for _, s := range ot.PrevTransactions {
+		prevBytes, err := hex.DecodeString(s)
+
+		if err != nil {
+			return nil, err
+		}
+
+		prevTX := &offlineTX{}
+
+		err = gob.NewDecoder(bytes.NewReader(prevBytes)).Decode(prevTX)
+
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Previous transaction can not be decoded: %s", err.Error()))
+		}
+
+		hash := sha256.Sum256(prevBytes)
+		prevTXs[string(hash[:])] = prevTX
+	}
+
+	values := []lib.Amount{}
+
+	for i, vin := range tx.Vin {
+		prevTX, ok := prevTXs[string(vin.Txid)]
+
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Previous transaction of input %d is not in the file", i))
+		}
+
+		if vin.Vout < 0 || vin.Vout >= len(prevTX.Vout) {
+			return nil, errors.New(fmt.Sprintf("Input %d spends output %d that doesn't exist", i, vin.Vout))
+		}
+
+		out := prevTX.Vout[vin.Vout]
+
+		if len(out.Data) > 0 || !bytes.Equal(out.PubKeyHash, pubKeyHash) {
+			return nil, errors.New(fmt.Sprintf("Input %d spends output of other address", i))
+		}
+
+		values = append(values, out.Value)
+	}
+
+	return values, nil
+}
+
+// Returns a fee the transaction pays. It is a difference of inputs and outputs,
+// values of inputs are checked with previous transactions in the file
+func (ot *OfflineTransaction) GetFee() (lib.Amount, error) {
+	tx, err := ot.decodeTransaction()
+
+	if err != nil {
+		return 0, err
+	}
+
+	values, err := ot.getInputValues(tx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	inputs := lib.Amount(0)
+
+	for _, value := range values {
+		inputs, err = lib.AddAmounts(inputs, value)
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	outputs := lib.Amount(0)
+
+	for _, vout := range tx.Vout {
+		outputs, err = lib.AddAmounts(outputs, vout.Value)
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if outputs > inputs {
+		return 0, errors.New(fmt.Sprintf("Outputs %s are more than inputs %s", outputs, inputs))
+	}
+
+	return inputs - outputs, nil
+}
+
+// Returns serialized transaction
+func (ot *OfflineTransaction) GetTransaction() ([]byte, error) {
+	return hex.DecodeString(ot.Transaction)
+}
+
+// Returns signatures. Error if the transaction is not signed
+func (ot *OfflineTransaction) GetSignatures() ([][]byte, error) {
+	if !ot.IsSigned() {
+		return nil, errors.New("Transaction is not signed")
+	}
+
+	signatures := [][]byte{}
+
+	for _, s := range ot.Signatures {
+		signature, err := hex.DecodeString(s)
+
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, signature)
+	}
+	return signatures, nil
+}
+
+// Returns multisig keys if the sender is multisig address
+func (ot *OfflineTransaction) GetMultiSig() (*utils.MultiSig, error) {
+	pubKey, err := hex.DecodeString(ot.PublicKey)
+
+	if err != nil || !utils.IsMultiSig(pubKey) {
+		return nil, err
+	}
+	return utils.ParseMultiSig(pubKey)
+}
+
+// Returns true if all signatures are there
+func (ot *OfflineTransaction) IsSigned() bool {
+	return len(ot.Signatures) > 0 && len(ot.Signatures) == len(ot.DataToSign)
+}
+
+// Checks the transaction and signs it. Data to sign are built again from the transaction
+// and must be same as in the file, so an online machine can not ask to sign other data.
+// Outputs must send the amount to the recipient and the rest back to the sender.
+// Values of inputs are taken from previous transactions in the file, so the fee must be same as expected.
+// For multisig sender the wallet key must be one of multisig keys
+func (ot *OfflineTransaction) Sign(w Wallet) error {
+	ms, err := ot.GetMultiSig()
+
+	if err != nil {
+		return err
+	}
+
+	if ms != nil {
+		if ms.KeyIndex(w.PublicKey) < 0 {
+			return errors.New("Wallet key is not one of multisig keys of the sender")
+		}
+	} else if hex.EncodeToString(w.PublicKey) != ot.PublicKey || string(w.GetAddress()) != ot.From {
+		return errors.New("Wallet doesn't match the sender of the transaction")
+	}
+
+	pubKey, _ := hex.DecodeString(ot.PublicKey)
+
+	amount, err := lib.ParseAmount(ot.Amount)
+
+	if err != nil {
+		return err
+	}
+
+	tx, err := ot.decodeTransaction()
+
+	if err != nil {
+		return err
+	}
+
+	pubKeyHash, _ := utils.HashPubKey(pubKey)
+
+	toAmount := lib.Amount(0)
+
+	for _, vout := range tx.Vout {
+		address, _ := utils.PubKeyHashToAddres(vout.PubKeyHash)
+
+		if address == ot.To {
+			toAmount += vout.Value
+		} else if !bytes.Equal(vout.PubKeyHash, pubKeyHash) {
+			return errors.New(fmt.Sprintf("Transaction has output to unexpected address %s", address))
+		}
+	}
+
+	if toAmount != amount {
+		return errors.New(fmt.Sprintf("Transaction sends %s to the recipient, expected %s", toAmount, amount))
+	}
+
+	if tx.LockTime != ot.LockTime {
+		return errors.New(fmt.Sprintf("Transaction has lock time %d, expected %d", tx.LockTime, ot.LockTime))
+	}
+
+	expectedFee, err := lib.ParseAmount(ot.Fee)
+
+	if err != nil {
+		return err
+	}
+
+	// an online machine could spend more than the amount and the change. It would go to a miner
+	fee, err := ot.GetFee()
+
+	if err != nil {
+		return err
+	}
+
+	if fee != expectedFee {
+		return errors.New(fmt.Sprintf("Transaction pays fee %s, expected %s", fee, expectedFee))
+	}
+
+	dataToSign := tx.prepareSignData(pubKeyHash)
+
+	if len(dataToSign) != len(ot.DataToSign) {
+		return errors.New("Data to sign don't match the transaction")
+	}
+
+	for i, data := range dataToSign {
+		if hex.EncodeToString(data) != ot.DataToSign[i] {
+			return errors.New("Data to sign don't match the transaction")
+		}
+	}
+
+	signatures, err := utils.SignDataSet(w.GetPublicKey(), w.GetPrivateKey(), dataToSign)
+
+	if err != nil {
+		return err
+	}
+
+	hexSignatures := []string{}
+
+	for _, signature := range signatures {
+		hexSignatures = append(hexSignatures, hex.EncodeToString(signature))
+	}
+
+	if ms == nil {
+		ot.Signatures = hexSignatures
+		return nil
+	}
+
+	if ot.PartialSignatures == nil {
+		ot.PartialSignatures = map[string][]string{}
+	}
+	ot.PartialSignatures[hex.EncodeToString(w.PublicKey)] = hexSignatures
+
+	return ot.joinSignatures(ms)
+}
+
+// Adds partial signatures from same transaction signed by other keys of multisig
+func (ot *OfflineTransaction) Combine(other *OfflineTransaction) error {
+	ms, err := ot.GetMultiSig()
+
+	if err != nil {
+		return err
+	}
+
+	if ms == nil {
+		return errors.New("Transaction is not from multisig address")
+	}
+
+	if other.Transaction != ot.Transaction || strings.Join(other.DataToSign, ",") != strings.Join(ot.DataToSign, ",") {
+		return errors.New("Transactions are different")
+	}
+
+	if ot.PartialSignatures == nil {
+		ot.PartialSignatures = map[string][]string{}
+	}
+
+	for key, signatures := range other.PartialSignatures {
+		pubKey, err := hex.DecodeString(key)
+
+		if err != nil || ms.KeyIndex(pubKey) < 0 {
+			return errors.New("Signature key is not one of multisig keys")
+		}
+
+		if len(signatures) != len(ot.DataToSign) {
+			return errors.New("Number of signatures doesn't match inputs")
+		}
+
+		// partial signatures are checked. A wrong one would make the transaction invalid
+		for i, s := range signatures {
+			signature, err := hex.DecodeString(s)
+
+			if err != nil {
+				return err
+			}
+
+			data, _ := hex.DecodeString(ot.DataToSign[i])
+
+			v, err := utils.VerifySignature(signature, data, pubKey)
+
+			if err != nil || !v {
+				return errors.New(fmt.Sprintf("Signature of key %s is not valid", key))
+			}
+		}
+		ot.PartialSignatures[key] = signatures
+	}
+
+	return ot.joinSignatures(ms)
+}
+
+// Makes input signatures when there are enough partial signatures
+func (ot *OfflineTransaction) joinSignatures(ms *utils.MultiSig) error {
+	if len(ot.PartialSignatures) < ms.Required {
+		return nil
+	}
+
+	signatures := []string{}
+
+	for i := range ot.DataToSign {
+		keysSignatures := map[int][]byte{}
+
+		for key, s := range ot.PartialSignatures {
+			pubKey, _ := hex.DecodeString(key)
+			signature, err := hex.DecodeString(s[i])
+
+			if err != nil {
+				return err
+			}
+			keysSignatures[ms.KeyIndex(pubKey)] = signature
+		}
+
+		joined, err := ms.JoinSignatures(keysSignatures)
+
+		if err != nil {
+			return err
+		}
+		signatures = append(signatures, hex.EncodeToString(joined))
+	}
+
+	ot.Signatures = signatures
+
+	return nil
+}
+
+func (ot *OfflineTransaction) decodeTransaction() (*offlineTX, error) {
+	txBytes, err := ot.GetTransaction()
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &offlineTX{}
+
+	err = gob.NewDecoder(bytes.NewReader(txBytes)).Decode(tx)
+
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Transaction can not be decoded: %s", err.Error()))
+	}
+
+	if len(tx.Vin) == 0 {
+		return nil, errors.New("Transaction has no inputs")
+	}
+
+	return tx, nil
+}
+
+// Same as String of a node transaction. Data to sign are made from this text
+func (tx offlineTX) String() string {
+	var lines []string
+	from, _ := utils.PubKeyToAddres(tx.Vin[0].PubKey)
+	fromhash, _ := utils.HashPubKey(tx.Vin[0].PubKey)
+	to := ""
+	amount := lib.Amount(0)
+
+	for _, output := range tx.Vout {
+		if len(output.Data) > 0 {
+			continue
+		}
+		if bytes.Compare(fromhash, output.PubKeyHash) != 0 {
+			to, _ = utils.PubKeyHashToAddres(output.PubKeyHash)
+			amount = output.Value
+			break
+		}
+	}
+
+	lines = append(lines, fmt.Sprintf("--- Transaction %x:", tx.ID))
+	lines = append(lines, fmt.Sprintf("    FROM %s TO %s VALUE %s", from, to, amount))
+	// in UTC, an offline machine can be in other time zone
+	lines = append(lines, fmt.Sprintf("    Time %d (%s)", tx.Time, time.Unix(0, tx.Time).UTC()))
+
+	if tx.LockTime != 0 {
+		lines = append(lines, fmt.Sprintf("    LockTime %d", tx.LockTime))
+	}
+
+	for i, input := range tx.Vin {
+		address, _ := utils.PubKeyToAddres(input.PubKey)
+		lines = append(lines, fmt.Sprintf("     Input %d:", i))
+		lines = append(lines, fmt.Sprintf("       TXID:      %x", input.Txid))
+		lines = append(lines, fmt.Sprintf("       Out:       %d", input.Vout))
+		lines = append(lines, fmt.Sprintf("       Signature: %x", input.Signature))
+		lines = append(lines, fmt.Sprintf("       PubKey:    %x", input.PubKey))
+		lines = append(lines, fmt.Sprintf("       Address:   %s", address))
+
+		if input.RelativeLock != 0 {
+			lines = append(lines, fmt.Sprintf("       RelativeLock: %d", input.RelativeLock))
+		}
+	}
+
+	for i, output := range tx.Vout {
+		lines = append(lines, fmt.Sprintf("     Output %d:", i))
+		lines = append(lines, fmt.Sprintf("       Value:  %s", output.Value))
+		lines = append(lines, fmt.Sprintf("       Script: %x", output.PubKeyHash))
+
+		if len(output.Data) > 0 {
+			lines = append(lines, fmt.Sprintf("       Data:   %x", output.Data))
+			continue
+		}
+		address, _ := utils.PubKeyHashToAddres(output.PubKeyHash)
+		lines = append(lines, fmt.Sprintf("       Address: %s", address))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Same as PrepareSignData of a node transaction. All inputs spend outputs of the sender
+func (tx offlineTX) prepareSignData(pubKeyHash []byte) [][]byte {
+	txCopy := offlineTX{[]byte{}, nil, nil, tx.Time, tx.LockTime}
+
+	for _, vin := range tx.Vin {
+		txCopy.Vin = append(txCopy.Vin, offlineTXInput{vin.Txid, vin.Vout, nil, nil, vin.RelativeLock})
+	}
+
+	for _, vout := range tx.Vout {
+		txCopy.Vout = append(txCopy.Vout, offlineTXOutput{vout.Value, utils.CopyBytes(vout.PubKeyHash), utils.CopyBytes(vout.Data)})
+	}
+
+	signdata := make([][]byte, len(txCopy.Vin))
+
+	for inID := range txCopy.Vin {
+		txCopy.Vin[inID].PubKey = pubKeyHash
+
+		signdata[inID] = []byte(fmt.Sprintf("%x\n", txCopy))
+
+		txCopy.Vin[inID].PubKey = nil
+	}
+
+	return signdata
+}
@@ -0,0 +1,296 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+// Makes a previous transaction with one output. Returns its bytes and ID
+func makeTestPrevTX(value lib.Amount, pubKeyHash []byte) ([]byte, []byte) {
+	prevTX := offlineTX{nil, []offlineTXInput{{[]byte{1, 2, 3}, 0, nil, nil, 0}}, []offlineTXOutput{{value, pubKeyHash, nil}}, 500, 0}
+
+	var prevBytes bytes.Buffer
+	gob.NewEncoder(&prevBytes).Encode(prevTX)
+
+	hash := sha256.Sum256(prevBytes.Bytes())
+
+	return prevBytes.Bytes(), hash[:]
+}
+
+func TestOfflineTransaction(t *testing.T) {
+	from := Wallet{}
+	from.MakeWallet()
+	to := Wallet{}
+	to.MakeWallet()
+	other := Wallet{}
+	other.MakeWallet()
+
+	fromHash, _ := utils.HashPubKey(from.PublicKey)
+	toHash, _ := utils.HashPubKey(to.PublicKey)
+	otherHash, _ := utils.HashPubKey(other.PublicKey)
+
+	// input value more than outputs. It is a fee
+	extraInput := lib.Amount(0)
+	prevOwner := fromHash
+
+	makeFile := func(outputs []offlineTXOutput) *OfflineTransaction {
+		inputValue := extraInput
+
+		for _, out := range outputs {
+			inputValue += out.Value
+		}
+
+		prevBytes, prevID := makeTestPrevTX(inputValue, prevOwner)
+
+		tx := offlineTX{nil, []offlineTXInput{{prevID, 0, nil, from.PublicKey, 0}}, outputs, 1000, 0}
+
+		var txBytes bytes.Buffer
+		gob.NewEncoder(&txBytes).Encode(tx)
+
+		// a node prepares data with all outputs it made
+		ot, err := NewOfflineTransaction(string(from.GetAddress()), string(to.GetAddress()),
+			lib.AmountUnit, 0, from.PublicKey, txBytes.Bytes(), tx.prepareSignData(fromHash), [][]byte{prevBytes})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ot
+	}
+
+	ot := makeFile([]offlineTXOutput{{lib.AmountUnit, toHash, nil}, {lib.AmountUnit, fromHash, nil}})
+
+	file, _ := ioutil.TempFile("", "tx")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	if err := ot.SaveToFile(file.Name()); err != nil {
+		t.Fatal(err)
+	}
+
+	ot, err := LoadOfflineTransaction(file.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ot.GetSignatures(); err == nil {
+		t.Fatal("Expected error for not signed transaction")
+	}
+
+	if err = ot.Sign(from); err != nil {
+		t.Fatal(err)
+	}
+
+	signatures, _ := ot.GetSignatures()
+	data := ot.DataToSign[0]
+
+	dataBytes, _ := hex.DecodeString(data)
+
+	if v, _ := utils.VerifySignature(signatures[0], dataBytes, from.PublicKey); !v {
+		t.Fatal("Signature is not valid")
+	}
+
+	// the change goes to other address
+	ot = makeFile([]offlineTXOutput{{lib.AmountUnit, toHash, nil}, {lib.AmountUnit, otherHash, nil}})
+
+	if err = ot.Sign(from); err == nil {
+		t.Fatal("Expected error for output to other address")
+	}
+
+	// data to sign are not for the transaction
+	ot = makeFile([]offlineTXOutput{{lib.AmountUnit, toHash, nil}})
+	ot.DataToSign[0] = "00"
+
+	if err = ot.Sign(from); err == nil {
+		t.Fatal("Expected error for wrong data to sign")
+	}
+
+	// lock time changes data to sign
+	ot = makeFile([]offlineTXOutput{{lib.AmountUnit, toHash, nil}})
+	oldData := ot.DataToSign[0]
+
+	if err = ot.SetLockTime(100); err != nil {
+		t.Fatal(err)
+	}
+
+	if ot.DataToSign[0] == oldData {
+		t.Fatal("Data to sign are same after setting lock time")
+	}
+
+	ot.LockTime = 50
+
+	if err = ot.Sign(from); err == nil {
+		t.Fatal("Expected error for wrong lock time")
+	}
+
+	ot.LockTime = 100
+
+	if err = ot.Sign(from); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ot.SetLockTime(200); err == nil {
+		t.Fatal("Expected error for lock time of signed transaction")
+	}
+
+	// an online machine spends more and the rest goes to a miner
+	extraInput = 10 * lib.AmountUnit
+	ot = makeFile([]offlineTXOutput{{lib.AmountUnit, toHash, nil}})
+
+	if fee, err := ot.GetFee(); err != nil || fee != extraInput {
+		t.Fatalf("Got fee %s, expected %s", fee, extraInput)
+	}
+
+	if ot.Inputs[0].Amount != (11 * lib.AmountUnit).String() {
+		t.Fatalf("Got input amount %s", ot.Inputs[0].Amount)
+	}
+
+	if err = ot.Sign(from); err == nil {
+		t.Fatal("Expected error for a fee more than expected")
+	}
+
+	extraInput = 0
+
+	// previous transaction is changed, its hash is not the input TXID
+	ot = makeFile([]offlineTXOutput{{lib.AmountUnit, toHash, nil}})
+	otherBytes, _ := makeTestPrevTX(10*lib.AmountUnit, fromHash)
+	ot.PrevTransactions = []string{hex.EncodeToString(otherBytes)}
+
+	if err = ot.Sign(from); err == nil {
+		t.Fatal("Expected error for wrong previous transaction")
+	}
+
+	ot.PrevTransactions = []string{}
+
+	if err = ot.Sign(from); err == nil {
+		t.Fatal("Expected error for missed previous transaction")
+	}
+
+	// input spends an output of other address
+	prevOwner = otherHash
+	ot = makeFile([]offlineTXOutput{{lib.AmountUnit, toHash, nil}})
+
+	if err = ot.Sign(from); err == nil {
+		t.Fatal("Expected error for input of other address")
+	}
+}
+
+func TestOfflineTransactionTimeZone(t *testing.T) {
+	from := Wallet{}
+	from.MakeWallet()
+	to := Wallet{}
+	to.MakeWallet()
+
+	fromHash, _ := utils.HashPubKey(from.PublicKey)
+	toHash, _ := utils.HashPubKey(to.PublicKey)
+
+	local := time.Local
+	defer func() { time.Local = local }()
+
+	// a node is in one time zone
+	time.Local = time.FixedZone("node", 5*3600)
+
+	prevBytes, prevID := makeTestPrevTX(lib.AmountUnit, fromHash)
+
+	tx := offlineTX{nil, []offlineTXInput{{prevID, 0, nil, from.PublicKey, 0}}, []offlineTXOutput{{lib.AmountUnit, toHash, nil}}, time.Now().UnixNano(), 0}
+
+	var txBytes bytes.Buffer
+	gob.NewEncoder(&txBytes).Encode(tx)
+
+	ot, err := NewOfflineTransaction(string(from.GetAddress()), string(to.GetAddress()),
+		lib.AmountUnit, 0, from.PublicKey, txBytes.Bytes(), tx.prepareSignData(fromHash), [][]byte{prevBytes})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an offline machine is in other
+	time.Local = time.FixedZone("offline", -8*3600)
+
+	if err = ot.Sign(from); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOfflineMultiSig(t *testing.T) {
+	wallets := []Wallet{}
+	pubKeys := [][]byte{}
+
+	for i := 0; i < 3; i++ {
+		w := Wallet{}
+		w.MakeWallet()
+		wallets = append(wallets, w)
+		pubKeys = append(pubKeys, w.PublicKey)
+	}
+
+	ms, _ := utils.NewMultiSig(2, pubKeys)
+	from, _ := ms.Address()
+	msHash, _ := utils.HashPubKey(ms.Serialize())
+
+	to := Wallet{}
+	to.MakeWallet()
+	toHash, _ := utils.HashPubKey(to.PublicKey)
+
+	prevBytes, prevID := makeTestPrevTX(2*lib.AmountUnit, msHash)
+
+	tx := offlineTX{nil, []offlineTXInput{{prevID, 0, nil, ms.Serialize(), 0}},
+		[]offlineTXOutput{{lib.AmountUnit, toHash, nil}, {lib.AmountUnit, msHash, nil}}, 1000, 0}
+
+	var txBytes bytes.Buffer
+	gob.NewEncoder(&txBytes).Encode(tx)
+
+	makeFile := func() *OfflineTransaction {
+		ot, err := NewOfflineTransaction(from, string(to.GetAddress()), lib.AmountUnit, 0, ms.Serialize(),
+			txBytes.Bytes(), tx.prepareSignData(msHash), [][]byte{prevBytes})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ot
+	}
+
+	// signers sign own copies of the file
+	ot1 := makeFile()
+	ot2 := makeFile()
+
+	if err := ot1.Sign(wallets[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ot2.Sign(wallets[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	if ot1.IsSigned() {
+		t.Fatal("Transaction must not be complete with 1 signature")
+	}
+
+	if err := ot1.Combine(ot2); err != nil {
+		t.Fatal(err)
+	}
+
+	signatures, err := ot1.GetSignatures()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := hex.DecodeString(ot1.DataToSign[0])
+
+	if v, err := ms.Verify(signatures[0], data); !v || err != nil {
+		t.Fatalf("Multisig signatures are not valid: %v", err)
+	}
+
+	if err = makeFile().Sign(to); err == nil {
+		t.Fatal("Expected error for a key not in multisig")
+	}
+}
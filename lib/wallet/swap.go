@@ -0,0 +1,463 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/script"
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+// Operations with a node used by atomic swaps. By default a node is requested over network,
+// node commands set functions working with a local DB when a node is not running
+type SwapNode struct {
+	GetBalance         func(address string) (lib.Amount, error)
+	RequestTransaction func(pubKey []byte, to string, amount, fee lib.Amount) ([]byte, [][]byte, error)
+	SendTransaction    func(from string, txBytes []byte, signatures [][]byte) ([]byte, error)
+	FindSecret         func(secretHash []byte) ([]byte, []byte, error)
+}
+
+func (wc *WalletCLI) getSwapNode() *SwapNode {
+	if wc.SwapNode != nil {
+		return wc.SwapNode
+	}
+
+	return &SwapNode{
+		GetBalance: func(address string) (lib.Amount, error) {
+			balance, err := wc.NodeCLI.SendGetBalance(wc.Node, address)
+			return balance.Approved, err
+		},
+		RequestTransaction: func(pubKey []byte, to string, amount, fee lib.Amount) ([]byte, [][]byte, error) {
+			return wc.NodeCLI.SendRequestNewTransaction(wc.Node, pubKey, to, amount, fee)
+		},
+		SendTransaction: func(from string, txBytes []byte, signatures [][]byte) ([]byte, error) {
+			return wc.NodeCLI.SendNewTransactionData(wc.Node, from, txBytes, signatures)
+		},
+		FindSecret: func(secretHash []byte) ([]byte, []byte, error) {
+			result, err := wc.NodeCLI.SendGetSecret(wc.Node, secretHash)
+			return result.Secret, result.TXID, err
+		},
+	}
+}
+
+// Makes a random secret for a swap
+func NewSwapSecret() ([]byte, error) {
+	secret := make([]byte, script.HTLCSecretSize)
+
+	_, err := rand.Read(secret)
+
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// Saves a secret of a started swap. It is encrypted same as keys, so coins of other side's
+// contract can be redeemed even if the secret printed by initiateswap is lost
+func (ws *Wallets) AddSwapSecret(secret []byte) error {
+	if ws.IsLocked() {
+		return ErrWalletLocked
+	}
+
+	hash := sha256.Sum256(secret)
+	key := hex.EncodeToString(hash[:])
+
+	if ws.SwapSecrets == nil {
+		ws.SwapSecrets = map[string][]byte{}
+	}
+	ws.SwapSecrets[key] = utils.CopyBytes(secret)
+
+	err := ws.SaveToFile()
+
+	if err != nil {
+		delete(ws.SwapSecrets, key)
+		return err
+	}
+	return nil
+}
+
+// Returns a saved secret with the SHA256 hash. nil if there is no such secret
+func (ws Wallets) GetSwapSecret(secretHash []byte) ([]byte, error) {
+	if ws.IsLocked() {
+		return nil, ErrWalletLocked
+	}
+	return ws.SwapSecrets[hex.EncodeToString(secretHash)], nil
+}
+
+// Returns a secret hash from -contract or -secrethash
+func (wc *WalletCLI) getSecretHashArg() ([]byte, error) {
+	if wc.Input.Contract != "" {
+		h, err := parseContract(wc.Input.Contract)
+
+		if err != nil {
+			return nil, err
+		}
+		return h.SecretHash, nil
+	}
+
+	secretHash, err := hex.DecodeString(wc.Input.SecretHash)
+
+	if err != nil || len(secretHash) != sha256.Size {
+		return nil, errors.New("Secret hash must be SHA256 in hex")
+	}
+	return secretHash, nil
+}
+
+// Reads a contract given in hex
+func parseContract(contract string) (*script.HTLC, error) {
+	s, err := hex.DecodeString(contract)
+
+	if err != nil {
+		return nil, errors.New("Contract must be a script in hex")
+	}
+
+	return script.ParseHTLC(s)
+}
+
+// Sends coins to a new contract. The receiver takes them with the secret, or the sender gets
+// them back after the lock time. The initiator of a swap doesn't give a hash, a new secret is made.
+// Other side uses the hash of the initiator's contract
+func (wc *WalletCLI) commandInitiateSwap() error {
+	w := Wallet{}
+
+	if !w.ValidateAddress(wc.Input.Address) {
+		return errors.New("From Address is not valid")
+	}
+	if !w.ValidateAddress(wc.Input.ToAddress) {
+		return errors.New("To Address is not valid")
+	}
+
+	if wc.Input.Amount <= 0 {
+		return errors.New("The amount of transaction must be more 0")
+	}
+
+	if wc.Input.Fee < 0 {
+		return errors.New("The fee of transaction can not be negative")
+	}
+
+	if wc.Input.LockTime <= 0 {
+		return errors.New("Lock time is not provided")
+	}
+
+	err := wc.UnlockIfNeeded()
+
+	if err != nil {
+		return err
+	}
+
+	walletobj, err := wc.WalletsObj.GetWallet(wc.Input.Address)
+
+	if err != nil {
+		return err
+	}
+
+	var secret []byte
+	var secretHash []byte
+
+	if wc.Input.SecretHash == "" {
+		secret, err = NewSwapSecret()
+
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(secret)
+		secretHash = hash[:]
+	} else {
+		secretHash, err = hex.DecodeString(wc.Input.SecretHash)
+
+		if err != nil || len(secretHash) != sha256.Size {
+			return errors.New("Secret hash must be SHA256 in hex")
+		}
+	}
+
+	h := script.HTLC{}
+	h.SecretHash = secretHash
+	h.Receiver, _ = utils.AddresToPubKeyHash(wc.Input.ToAddress)
+	h.Sender, _ = utils.HashPubKey(walletobj.GetPublicKey())
+	h.LockTime = wc.Input.LockTime
+
+	address, err := h.Address()
+
+	if err != nil {
+		return err
+	}
+
+	// the contract is kept in the wallet to see its balance
+	if !wc.WalletsObj.IsWatchOnly(address) {
+		err = wc.WalletsObj.ImportWatchOnly(address, script.ScriptKey(h.Script()))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if secret != nil {
+		// before coins are sent. Without the secret they can only be refunded after the lock time
+		err = wc.WalletsObj.AddSwapSecret(secret)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	node := wc.getSwapNode()
+
+	txBytes, dataToSign, err := node.RequestTransaction(walletobj.GetPublicKey(), address, wc.Input.Amount, wc.Input.Fee)
+
+	if err != nil {
+		return err
+	}
+
+	signatures, err := utils.SignDataSet(walletobj.GetPublicKey(), walletobj.GetPrivateKey(), dataToSign)
+
+	if err != nil {
+		return err
+	}
+
+	txID, err := node.SendTransaction(wc.Input.Address, txBytes, signatures)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Contract address: %s\n", address)
+	fmt.Printf("Contract: %x\n", h.Script())
+	fmt.Printf("Secret hash: %x\n", secretHash)
+
+	if secret != nil {
+		fmt.Printf("Secret: %x\n", secret)
+		fmt.Println("Keep the secret private until the other side makes its contract with same hash")
+		fmt.Println("The secret is saved in the wallet, showsecret prints it again")
+	}
+	fmt.Printf("Contract transaction: %x\n", txID)
+
+	return nil
+}
+
+// Takes coins from a contract with the secret. The wallet must have the receiver's key.
+// If the secret is not given, a secret saved by initiateswap is used
+func (wc *WalletCLI) commandRedeemSwap() error {
+	h, err := parseContract(wc.Input.Contract)
+
+	if err != nil {
+		return err
+	}
+
+	var secret []byte
+
+	if wc.Input.Secret == "" {
+		secret, err = wc.getSavedSecret(h.SecretHash)
+	} else {
+		secret, err = hex.DecodeString(wc.Input.Secret)
+
+		if err != nil {
+			err = errors.New("Secret must be in hex")
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(secret)
+
+	if len(secret) != script.HTLCSecretSize || !bytes.Equal(hash[:], h.SecretHash) {
+		return errors.New("Secret doesn't match the contract")
+	}
+
+	return wc.spendContract(h, h.Receiver, 0, func(signature, pubKey []byte) []byte {
+		return script.HTLCRedeemUnlock(signature, pubKey, secret)
+	})
+}
+
+// Returns coins from a contract to the sender. A node adds the transaction to a block
+// after the lock time of the contract
+func (wc *WalletCLI) commandRefundSwap() error {
+	h, err := parseContract(wc.Input.Contract)
+
+	if err != nil {
+		return err
+	}
+
+	return wc.spendContract(h, h.Sender, h.LockTime, script.HTLCRefundUnlock)
+}
+
+// Sends all coins of a contract to the wallet address of a key with the pubKeyHash,
+// or to -to address if it is set
+func (wc *WalletCLI) spendContract(h *script.HTLC, pubKeyHash []byte, lockTime int64,
+	unlock func(signature, pubKey []byte) []byte) error {
+
+	if wc.Input.Fee < 0 {
+		return errors.New("The fee of transaction can not be negative")
+	}
+
+	owner, err := utils.PubKeyHashToAddres(pubKeyHash)
+
+	if err != nil {
+		return err
+	}
+
+	to := owner
+
+	if wc.Input.ToAddress != "" {
+		w := Wallet{}
+
+		if !w.ValidateAddress(wc.Input.ToAddress) {
+			return errors.New("To Address is not valid")
+		}
+		to = wc.Input.ToAddress
+	}
+
+	err = wc.UnlockIfNeeded()
+
+	if err != nil {
+		return err
+	}
+
+	walletobj, err := wc.WalletsObj.GetWallet(owner)
+
+	if err != nil {
+		return errors.New(fmt.Sprintf("Key of %s is not in the wallet", owner))
+	}
+
+	address, err := h.Address()
+
+	if err != nil {
+		return err
+	}
+
+	node := wc.getSwapNode()
+
+	balance, err := node.GetBalance(address)
+
+	if err != nil {
+		return err
+	}
+
+	if balance-wc.Input.Fee < lib.SmallestUnit {
+		return errors.New(fmt.Sprintf("Contract balance %s is not enough", balance))
+	}
+
+	scriptKey := script.ScriptKey(h.Script())
+
+	txBytes, dataToSign, err := node.RequestTransaction(scriptKey, to, balance-wc.Input.Fee, wc.Input.Fee)
+
+	if err != nil {
+		return err
+	}
+
+	if lockTime != 0 {
+		// same as for transactions signed offline. Data to sign are made again with the lock time
+		ot, err := NewOfflineTransaction(address, to, balance-wc.Input.Fee, wc.Input.Fee, scriptKey, txBytes, dataToSign, nil)
+
+		if err != nil {
+			return err
+		}
+
+		err = ot.SetLockTime(lockTime)
+
+		if err != nil {
+			return err
+		}
+
+		txBytes, _ = ot.GetTransaction()
+		dataToSign = [][]byte{}
+
+		for _, data := range ot.DataToSign {
+			d, _ := hex.DecodeString(data)
+			dataToSign = append(dataToSign, d)
+		}
+	}
+
+	signatures, err := utils.SignDataSet(walletobj.GetPublicKey(), walletobj.GetPrivateKey(), dataToSign)
+
+	if err != nil {
+		return err
+	}
+
+	for i, signature := range signatures {
+		signatures[i] = unlock(signature, walletobj.GetPublicKey())
+	}
+
+	txID, err := node.SendTransaction(address, txBytes, signatures)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Success. New transaction: %x\n", txID)
+
+	if lockTime != 0 {
+		fmt.Printf("It is added to a block after the lock time %d\n", lockTime)
+	}
+	return nil
+}
+
+// Returns a secret saved in the wallet
+func (wc *WalletCLI) getSavedSecret(secretHash []byte) ([]byte, error) {
+	err := wc.UnlockIfNeeded()
+
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := wc.WalletsObj.GetSwapSecret(secretHash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if secret == nil {
+		return nil, errors.New("Secret is not found in the wallet. Set it with -secret")
+	}
+	return secret, nil
+}
+
+// Prints a secret saved by initiateswap
+func (wc *WalletCLI) commandShowSecret() error {
+	secretHash, err := wc.getSecretHashArg()
+
+	if err != nil {
+		return err
+	}
+
+	secret, err := wc.getSavedSecret(secretHash)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Secret: %x\n", secret)
+
+	return nil
+}
+
+// Finds a secret revealed when other side redeemed a contract. It is used to redeem
+// the contract on other chain
+func (wc *WalletCLI) commandExtractSecret() error {
+	secretHash, err := wc.getSecretHashArg()
+
+	if err != nil {
+		return err
+	}
+
+	secret, txID, err := wc.getSwapNode().FindSecret(secretHash)
+
+	if err != nil {
+		return err
+	}
+
+	if secret == nil {
+		return errors.New("Secret is not found in the blockchain")
+	}
+
+	fmt.Printf("Secret: %x\n", secret)
+	fmt.Printf("Revealed in transaction: %x\n", txID)
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/taincoin/taincoin/lib/nodeclient"
+)
+
+// Timestamping of documents. A node with the timestamping service collects hashes and anchors
+// a Merkle root of them once per block. A receipt has a path from a hash to the root and a proof
+// of the anchoring transaction, so it is checked with block headers only
+
+// Parses list of hashes separated with comma
+func (wc *WalletCLI) parseHashes() ([][]byte, error) {
+	hashes := [][]byte{}
+
+	for _, h := range strings.Split(wc.Input.Data, ",") {
+		hash, err := hex.DecodeString(strings.TrimSpace(h))
+
+		if err != nil || len(hash) != nodeclient.StampHashSize {
+			return nil, errors.New("Hashes must be SHA256 in hex separated with comma")
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// Sends hashes to the timestamping service of a node
+func (wc *WalletCLI) commandStamp() error {
+	hashes, err := wc.parseHashes()
+
+	if err != nil {
+		return err
+	}
+
+	err = wc.NodeCLI.SendStamp(wc.Node, hashes)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Success. %d hashes will be anchored with next batch\n", len(hashes))
+
+	return nil
+}
+
+// Requests a receipt of a hash and saves it to a file
+func (wc *WalletCLI) commandGetReceipt() error {
+	if wc.Input.File == "" {
+		return errors.New("File is not provided")
+	}
+
+	hash, err := wc.parseData()
+
+	if err != nil {
+		return err
+	}
+
+	receipt, err := wc.NodeCLI.SendGetReceipt(wc.Node, hash)
+
+	if err != nil {
+		return err
+	}
+
+	if len(receipt.Root) == 0 {
+		return errors.New("Hash is not anchored yet. Try again after next block")
+	}
+
+	err = SaveReceipt(receipt, wc.Input.File)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Hash is anchored in block %d with transaction %x\n", receipt.Height, receipt.TXProof.TXID)
+	fmt.Printf("Receipt is saved to %s\n", wc.Input.File)
+
+	return nil
+}
+
+// Checks a receipt with block headers. Headers are loaded from nodes only if the block is not known
+func (wc *WalletCLI) commandVerifyReceipt() error {
+	if wc.Input.File == "" {
+		return errors.New("File is not provided")
+	}
+
+	receipt, err := LoadReceipt(wc.Input.File)
+
+	if err != nil {
+		return err
+	}
+
+	lc := newLightClient(wc)
+
+	err = lc.loadHeaders()
+
+	if err != nil {
+		return err
+	}
+
+	if _, ok := lc.index[string(receipt.TXProof.BlockHash)]; !ok {
+		err = lc.syncHeaders()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	pos, ok := lc.index[string(receipt.TXProof.BlockHash)]
+
+	if !ok {
+		return errors.New("Block of the receipt is not in the main chain")
+	}
+
+	header := lc.Headers[pos]
+
+	err = receipt.Verify(header.MerkleRoot)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Receipt is valid\n")
+	fmt.Printf("Hash:          %x\n", receipt.Hash)
+	fmt.Printf("Transaction:   %x\n", receipt.TXProof.TXID)
+	fmt.Printf("Block:         %x\n", header.Hash)
+	fmt.Printf("Height:        %d\n", header.Height)
+	fmt.Printf("Confirmations: %d\n", len(lc.Headers)-header.Height)
+	fmt.Printf("Time:          %d (%s)\n", header.Timestamp, time.Unix(header.Timestamp, 0).UTC())
+
+	return nil
+}
+
+// Saves a receipt to a JSON file
+func SaveReceipt(receipt nodeclient.ComStampReceipt, file string) error {
+	content, err := json.MarshalIndent(receipt, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, append(content, '\n'), 0644)
+}
+
+func LoadReceipt(file string) (nodeclient.ComStampReceipt, error) {
+	receipt := nodeclient.ComStampReceipt{}
+
+	content, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		return receipt, err
+	}
+
+	err = json.Unmarshal(content, &receipt)
+
+	if err != nil {
+		return receipt, errors.New(fmt.Sprintf("Receipt file is not valid: %s", err.Error()))
+	}
+	return receipt, nil
+}
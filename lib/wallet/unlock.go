@@ -0,0 +1,103 @@
+package wallet
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Wallets kept unlocked in memory of a process. A node uses this to sign blocks and transactions
+// without asking for a passphrase every time. Only the encryption key is kept, a file is decrypted
+// with it on every loading, so changes saved by other processes are not lost. The key is removed after a timeout
+type unlockedWallets struct {
+	key   *walletsKey
+	timer *time.Timer
+}
+
+var unlocked = map[string]*unlockedWallets{}
+var unlockedLock = &sync.Mutex{}
+
+// Keeps keys of unlocked wallets in memory. Wallets loaded from same file by this process are
+// unlocked until the timeout or until ForgetUnlocked is called. Zero timeout means forever
+func KeepUnlocked(ws *Wallets, timeout time.Duration) error {
+	if !ws.Encrypted {
+		return ErrWalletNotCrypted
+	}
+
+	if ws.IsLocked() {
+		return ErrWalletLocked
+	}
+
+	path := getUnlockedKey(ws.getFilePath())
+
+	unlockedLock.Lock()
+	defer unlockedLock.Unlock()
+
+	if u, ok := unlocked[path]; ok && u.timer != nil {
+		u.timer.Stop()
+	}
+
+	u := &unlockedWallets{key: ws.key}
+
+	if timeout > 0 {
+		u.timer = time.AfterFunc(timeout, func() {
+			unlockedLock.Lock()
+			defer unlockedLock.Unlock()
+
+			if unlocked[path] == u {
+				delete(unlocked, path)
+			}
+		})
+	}
+
+	unlocked[path] = u
+
+	return nil
+}
+
+// Removes keys from memory
+func ForgetUnlocked(ws *Wallets) {
+	path := getUnlockedKey(ws.getFilePath())
+
+	unlockedLock.Lock()
+	defer unlockedLock.Unlock()
+
+	if u, ok := unlocked[path]; ok {
+		if u.timer != nil {
+			u.timer.Stop()
+		}
+		delete(unlocked, path)
+	}
+}
+
+// Decrypts wallets loaded from a file with a kept key. Wallets stay locked if the file was
+// encrypted with other key
+func getUnlocked(walletsFile string, ws *Wallets) {
+	unlockedLock.Lock()
+	u, ok := unlocked[getUnlockedKey(walletsFile)]
+	unlockedLock.Unlock()
+
+	if !ok {
+		return
+	}
+
+	wallets, hd, secrets, err := ws.encrypted.decryptWithKey(u.key)
+
+	if err != nil {
+		return
+	}
+
+	ws.Wallets = wallets
+	ws.HD = hd
+	ws.SwapSecrets = secrets
+	ws.key = u.key
+}
+
+func getUnlockedKey(walletsFile string) string {
+	path, err := filepath.Abs(walletsFile)
+
+	if err != nil {
+		return walletsFile
+	}
+	return path
+}
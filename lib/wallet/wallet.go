@@ -21,9 +21,9 @@ type Wallet struct {
 }
 
 type WalletBalance struct {
-	Total    float64
-	Approved float64
-	Pending  float64
+	Total    lib.Amount
+	Approved lib.Amount
+	Pending  lib.Amount
 }
 
 // MakeWallet creates Wallet. It generates new keys pair and assign to the object
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"github.com/taincoin/taincoin/lib/utils"
 )
@@ -21,14 +22,39 @@ type Wallets struct {
 	Logger *utils.LoggerMan
 
 	WalletsFile string
+
+	// seed of HD wallets. nil if wallets are not HD or locked
+	HD *HDWallet
+
+	// secrets of started swaps by a hash in hex. nil if locked
+	SwapSecrets map[string][]byte
+
+	// watch-only addresses and their public keys. A public key is nil if only an address was imported
+	Watched map[string][]byte
+
+	Encrypted bool
+	// encryption key. It is nil if a wallet is locked
+	key       *walletsKey
+	encrypted *encryptedWalletsFile
 }
 
 type WalletsFile struct {
-	Wallets map[string]*Wallet
+	Wallets     map[string]*Wallet
+	HD          *HDWallet
+	Watched     map[string][]byte
+	SwapSecrets map[string][]byte
 }
 
-// CreateWallet adds a Wallet to Wallets
+// CreateWallet adds a Wallet to Wallets. Keys of HD wallets are derived from the seed
 func (ws *Wallets) CreateWallet() (string, error) {
+	if ws.IsLocked() {
+		return "", ErrWalletLocked
+	}
+
+	if ws.HD != nil {
+		return ws.createHDWallet()
+	}
+
 	wallet := Wallet{}
 	wallet.MakeWallet()
 
@@ -56,23 +82,152 @@ func (ws *Wallets) GetAddresses() []string {
 	return addresses
 }
 
+// Checks if an address is in the wallets. It works for a locked wallet too
+func (ws Wallets) HasAddress(address string) bool {
+	_, ok := ws.Wallets[address]
+	return ok
+}
+
 // GetWallet returns a Wallet by its address
 func (ws Wallets) GetWallet(address string) (Wallet, error) {
+	if ws.IsWatchOnly(address) {
+		return Wallet{}, ErrWatchOnly
+	}
+
 	if _, ok := ws.Wallets[address]; ok {
+		if ws.IsLocked() {
+			return Wallet{}, ErrWalletLocked
+		}
 		return *ws.Wallets[address], nil
 	}
 	return Wallet{}, errors.New("Wallet nout found")
 }
 
-// LoadFromFile loads wallets from the file
-func (ws *Wallets) LoadFromFile() error {
-	var walletsFile string
+// Returns public key of an address. It works for locked wallets and watch-only addresses
+// imported with a public key
+func (ws Wallets) GetPublicKey(address string) ([]byte, error) {
+	if w, ok := ws.Wallets[address]; ok {
+		return w.PublicKey, nil
+	}
 
+	if pubKey, ok := ws.Watched[address]; ok && len(pubKey) > 0 {
+		return pubKey, nil
+	}
+	return nil, errors.New("Public key of the address is not known. Import it with importpubkey")
+}
+
+// Returns true if wallets file is encrypted and private keys are not decrypted
+func (ws Wallets) IsLocked() bool {
+	return ws.Encrypted && ws.key == nil
+}
+
+// Encrypts a plain wallets file with the passphrase and saves it. Callers must confirm
+// the passphrase, keys can not be decrypted after a typo
+func (ws *Wallets) Encrypt(passphrase string) error {
+	if ws.Encrypted {
+		return errors.New("Wallet is already encrypted")
+	}
+
+	key, err := newWalletsKey(passphrase)
+
+	if err != nil {
+		return err
+	}
+
+	ws.Encrypted = true
+	ws.key = key
+
+	err = ws.SaveToFile()
+
+	if err != nil {
+		ws.Encrypted = false
+		ws.key = nil
+		return err
+	}
+	return nil
+}
+
+// Decrypts private keys. A plain wallets file must be encrypted with Encrypt first
+func (ws *Wallets) Unlock(passphrase string) error {
+	if passphrase == "" {
+		return ErrEmptyPassphrase
+	}
+
+	if !ws.Encrypted {
+		return ErrWalletNotCrypted
+	}
+
+	if !ws.IsLocked() {
+		return nil
+	}
+
+	wallets, hd, secrets, key, err := ws.encrypted.decrypt(passphrase)
+
+	if err != nil {
+		return err
+	}
+
+	ws.Wallets = wallets
+	ws.HD = hd
+	ws.SwapSecrets = secrets
+	ws.key = key
+
+	return nil
+}
+
+// Removes private keys from memory
+func (ws *Wallets) Lock() {
+	if !ws.Encrypted {
+		return
+	}
+
+	ws.Wallets = ws.encrypted.getPublicWallets()
+	ws.HD = nil
+	ws.SwapSecrets = nil
+	ws.key = nil
+}
+
+// Encrypts wallets with new passphrase
+func (ws *Wallets) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	if !ws.Encrypted {
+		return ErrWalletNotCrypted
+	}
+
+	err := ws.Unlock(oldPassphrase)
+
+	if err != nil {
+		return err
+	}
+
+	key, err := newWalletsKey(newPassphrase)
+
+	if err != nil {
+		return err
+	}
+
+	oldKey := ws.key
+	ws.key = key
+
+	err = ws.SaveToFile()
+
+	if err != nil {
+		ws.key = oldKey
+		return err
+	}
+	return nil
+}
+
+func (ws Wallets) getFilePath() string {
 	if ws.WalletsFile != "" {
-		walletsFile = ws.WalletsFile
-	} else {
-		walletsFile = ws.DataDir + walletFile
+		return ws.WalletsFile
 	}
+	return ws.DataDir + walletFile
+}
+
+// LoadFromFile loads wallets from the file. Encrypted wallets are loaded locked, if keys
+// are not kept unlocked in memory of this process
+func (ws *Wallets) LoadFromFile() error {
+	walletsFile := ws.getFilePath()
 
 	_, err := os.Stat(walletsFile)
 
@@ -85,6 +240,25 @@ func (ws *Wallets) LoadFromFile() error {
 		return err
 	}
 
+	if isEncryptedWallets(fileContent) {
+		ws.encrypted, err = parseEncryptedWallets(fileContent)
+
+		if err != nil {
+			return err
+		}
+
+		ws.Encrypted = true
+		ws.key = nil
+		ws.HD = nil
+		ws.SwapSecrets = nil
+		ws.Wallets = ws.encrypted.getPublicWallets()
+		ws.Watched = ws.encrypted.Watched
+
+		getUnlocked(walletsFile, ws)
+
+		return nil
+	}
+
 	var wallets WalletsFile
 	gob.Register(elliptic.P256())
 	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
@@ -95,19 +269,39 @@ func (ws *Wallets) LoadFromFile() error {
 	}
 
 	ws.Wallets = wallets.Wallets
+	ws.HD = wallets.HD
+	ws.Watched = wallets.Watched
+	ws.SwapSecrets = wallets.SwapSecrets
+	ws.Encrypted = false
 
 	return nil
 }
 
-// SaveToFile saves wallets to a file
-func (ws Wallets) SaveToFile() error {
+// SaveToFile saves wallets to a file. Encrypted wallets must be unlocked
+func (ws *Wallets) SaveToFile() error {
+	if ws.Encrypted {
+		if ws.key == nil {
+			return ErrWalletLocked
+		}
+
+		ef, err := ws.key.encrypt(ws.Wallets, ws.HD, ws.SwapSecrets)
+
+		if err != nil {
+			return err
+		}
+
+		return ws.saveEncrypted(ef)
+	}
+
 	var content bytes.Buffer
-	walletsFile := ws.DataDir + walletFile
 
 	gob.Register(elliptic.P256())
 
 	wsc := WalletsFile{}
 	wsc.Wallets = ws.Wallets
+	wsc.HD = ws.HD
+	wsc.Watched = ws.Watched
+	wsc.SwapSecrets = ws.SwapSecrets
 
 	encoder := gob.NewEncoder(&content)
 	err := encoder.Encode(wsc)
@@ -115,8 +309,55 @@ func (ws Wallets) SaveToFile() error {
 		return err
 	}
 
-	err = ioutil.WriteFile(walletsFile, content.Bytes(), 0644)
+	return ws.writeFile(content.Bytes())
+}
+
+// Saves encrypted wallets with current watch-only addresses
+func (ws *Wallets) saveEncrypted(ef *encryptedWalletsFile) error {
+	ef.Watched = ws.Watched
+
+	data, err := ef.serialize()
+
+	if err != nil {
+		return err
+	}
+
+	err = ws.writeFile(data)
+
+	if err != nil {
+		return err
+	}
+
+	ws.encrypted = ef
+
+	return nil
+}
+
+// Writes to a temp file first, so a wallets file is not broken if writing fails
+func (ws Wallets) writeFile(data []byte) error {
+	walletsFile := ws.getFilePath()
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(walletsFile), "wallet")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpFile.Write(data)
+
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+
+	tmpFile.Close()
+
+	if err == nil {
+		// TempFile creates with mode 0600. Keys must not be readable by others
+		err = os.Rename(tmpFile.Name(), walletsFile)
+	}
+
 	if err != nil {
+		os.Remove(tmpFile.Name())
 		return err
 	}
 
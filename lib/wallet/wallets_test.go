@@ -0,0 +1,382 @@
+package wallet
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/taincoin/taincoin/lib"
+)
+
+func TestWalletsEncryption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallets")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	original := Wallet{}
+	original.MakeWallet()
+
+	address := string(original.GetAddress())
+
+	ws := Wallets{DataDir: dir + "/", Wallets: map[string]*Wallet{address: &original}}
+
+	// a plain wallet is not encrypted by unlock, the passphrase must be confirmed first
+	if err = ws.Unlock("secret"); err != ErrWalletNotCrypted {
+		t.Fatalf("Expected not encrypted error, got %v", err)
+	}
+
+	if err = ws.Encrypt("secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, _ := os.Stat(dir + "/" + walletFile)
+
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("Wallets file mode is %o", info.Mode().Perm())
+	}
+
+	ws = Wallets{DataDir: dir + "/"}
+
+	if err = ws.LoadFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ws.IsLocked() || !ws.HasAddress(address) {
+		t.Fatal("Expected locked wallet with the address")
+	}
+
+	if _, err = ws.GetWallet(address); err != ErrWalletLocked {
+		t.Fatalf("Expected locked error, got %v", err)
+	}
+
+	if err = ws.Unlock("wrong"); err != ErrWrongPassphrase {
+		t.Fatalf("Expected wrong passphrase error, got %v", err)
+	}
+
+	if err = ws.ChangePassphrase("secret", "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	ws = Wallets{DataDir: dir + "/"}
+	ws.LoadFromFile()
+
+	if err = ws.Unlock("other"); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ws.GetWallet(address)
+
+	if err != nil || w.PrivateKey.D.Cmp(original.PrivateKey.D) != 0 {
+		t.Fatal("Private key is not same after decryption")
+	}
+
+	// keys kept in memory unlock wallets loaded later
+	if err = KeepUnlocked(&ws, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	ws2 := Wallets{DataDir: dir + "/"}
+	ws2.LoadFromFile()
+
+	if ws2.IsLocked() {
+		t.Fatal("Expected unlocked wallet")
+	}
+
+	ForgetUnlocked(&ws)
+
+	ws2 = Wallets{DataDir: dir + "/"}
+	ws2.LoadFromFile()
+
+	if !ws2.IsLocked() {
+		t.Fatal("Expected locked wallet")
+	}
+}
+
+func TestWatchOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallets")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ws := Wallets{DataDir: dir + "/", Wallets: map[string]*Wallet{}}
+	ws.Encrypt("secret")
+	ws.CreateWallet()
+	ws.Lock()
+
+	cold := Wallet{}
+	cold.MakeWallet()
+
+	address := string(cold.GetAddress())
+
+	// locked wallet doesn't need a passphrase to import
+	if err = ws.ImportWatchOnly("", cold.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ws.ImportWatchOnly(address, nil); err == nil {
+		t.Fatal("Expected error for already watched address")
+	}
+
+	ws = Wallets{DataDir: dir + "/"}
+	ws.LoadFromFile()
+
+	if !ws.IsWatchOnly(address) || ws.HasAddress(address) {
+		t.Fatal("Expected watch-only address")
+	}
+
+	// keys are still encrypted with same passphrase and watched addresses stay after saving
+	if err = ws.Unlock("secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ws.CreateWallet(); err != nil {
+		t.Fatal(err)
+	}
+
+	ws = Wallets{DataDir: dir + "/"}
+	ws.LoadFromFile()
+
+	if _, err = ws.GetWallet(address); err != ErrWatchOnly {
+		t.Fatalf("Expected watch-only error, got %v", err)
+	}
+
+	if len(ws.GetAddresses()) != 2 || len(ws.GetWatchOnlyAddresses()) != 1 {
+		t.Fatal("Expected 2 own addresses and 1 watch-only")
+	}
+}
+
+func TestSwapSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallets")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w := Wallet{}
+	w.MakeWallet()
+
+	secret, _ := NewSwapSecret()
+	hash := sha256.Sum256(secret)
+
+	ws := Wallets{DataDir: dir + "/", Wallets: map[string]*Wallet{string(w.GetAddress()): &w}}
+
+	if err = ws.Encrypt("pass"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ws.AddSwapSecret(secret); err != nil {
+		t.Fatal(err)
+	}
+
+	// secrets are encrypted with keys
+
+	content, _ := ioutil.ReadFile(dir + "/" + walletFile)
+
+	if bytes.Contains(content, secret) {
+		t.Fatal("Secret is not encrypted")
+	}
+
+	ws = Wallets{DataDir: dir + "/"}
+	ws.LoadFromFile()
+
+	if _, err = ws.GetSwapSecret(hash[:]); err != ErrWalletLocked {
+		t.Fatalf("Expected locked error, got %v", err)
+	}
+
+	if err = ws.AddSwapSecret(secret); err != ErrWalletLocked {
+		t.Fatalf("Expected locked error, got %v", err)
+	}
+
+	if err = ws.Unlock("pass"); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ := ws.GetSwapSecret(hash[:]); !bytes.Equal(s, secret) {
+		t.Fatal("Secret is not same after decryption")
+	}
+
+	if s, _ := ws.GetSwapSecret(make([]byte, 32)); s != nil {
+		t.Fatal("Expected no secret for other hash")
+	}
+
+	// a process keeping the wallet unlocked sees secrets saved by other process
+	if err = KeepUnlocked(&ws, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer ForgetUnlocked(&ws)
+
+	other := Wallets{DataDir: dir + "/"}
+	other.LoadFromFile()
+	other.Unlock("pass")
+
+	secret2, _ := NewSwapSecret()
+	hash2 := sha256.Sum256(secret2)
+
+	if err = other.AddSwapSecret(secret2); err != nil {
+		t.Fatal(err)
+	}
+
+	ws2 := Wallets{DataDir: dir + "/"}
+	ws2.LoadFromFile()
+
+	if s, err := ws2.GetSwapSecret(hash2[:]); err != nil || !bytes.Equal(s, secret2) {
+		t.Fatal("Secret saved by other process is not loaded")
+	}
+}
+
+func TestEncryptedWalletsVersion(t *testing.T) {
+	w := Wallet{}
+	w.MakeWallet()
+
+	wallets := map[string]*Wallet{string(w.GetAddress()): &w}
+	hd := &HDWallet{Seed: []byte{1, 2, 3}, NextIndex: 5}
+
+	key, err := newWalletsKey("pass")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// files without secrets are same as before, old versions can read them
+	ef, err := key.encrypt(wallets, hd, nil)
+
+	if err != nil || ef.Version != 1 {
+		t.Fatalf("Expected version 1, got %d, %v", ef.Version, err)
+	}
+
+	_, hd2, secrets, err := ef.decryptWithKey(key)
+
+	if err != nil || hd2 == nil || hd2.NextIndex != 5 || secrets != nil {
+		t.Fatalf("Wrong data of version 1: %v", err)
+	}
+
+	ef, err = key.encrypt(wallets, hd, map[string][]byte{"aa": []byte{1}})
+
+	if err != nil || ef.Version != 2 {
+		t.Fatalf("Expected version 2, got %d, %v", ef.Version, err)
+	}
+
+	_, hd2, secrets, err = ef.decryptWithKey(key)
+
+	if err != nil || hd2 == nil || hd2.NextIndex != 5 || !bytes.Equal(secrets["aa"], []byte{1}) {
+		t.Fatalf("Wrong data of version 2: %v", err)
+	}
+
+	// without HD wallets
+	ef, _ = key.encrypt(wallets, nil, map[string][]byte{"aa": []byte{1}})
+
+	_, hd2, secrets, err = ef.decryptWithKey(key)
+
+	if err != nil || hd2 != nil || len(secrets) != 1 {
+		t.Fatalf("Wrong data of version 2 without HD: %v", err)
+	}
+}
+
+func TestInitiateSwapSavesSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallets")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	from := Wallet{}
+	from.MakeWallet()
+	to := Wallet{}
+	to.MakeWallet()
+
+	ws := &Wallets{DataDir: dir + "/", Wallets: map[string]*Wallet{string(from.GetAddress()): &from}}
+
+	if err = ws.Encrypt("pass"); err != nil {
+		t.Fatal(err)
+	}
+
+	wc := WalletCLI{WalletsObj: ws}
+	wc.Input.Address = string(from.GetAddress())
+	wc.Input.ToAddress = string(to.GetAddress())
+	wc.Input.Amount = 1
+	wc.Input.LockTime = 100
+
+	sent := false
+
+	wc.SwapNode = &SwapNode{
+		RequestTransaction: func(pubKey []byte, to string, amount, fee lib.Amount) ([]byte, [][]byte, error) {
+			return []byte{1}, [][]byte{[]byte("data")}, nil
+		},
+		SendTransaction: func(from string, txBytes []byte, signatures [][]byte) ([]byte, error) {
+			// the secret must be in the file before coins are sent
+			saved := Wallets{DataDir: dir + "/"}
+			saved.LoadFromFile()
+
+			if err := saved.Unlock("pass"); err != nil || len(saved.SwapSecrets) != 1 {
+				return nil, errors.New("Secret is not saved")
+			}
+			sent = true
+			return []byte{2}, nil
+		},
+	}
+
+	if err = wc.commandInitiateSwap(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !sent {
+		t.Fatal("Contract transaction is not sent")
+	}
+}
+
+func TestCreateWalletEncrypted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallets")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(r *bufio.Reader) { stdinReader = r }(stdinReader)
+
+	wc := WalletCLI{}
+	wc.WalletsObj = &Wallets{DataDir: dir + "/", Wallets: map[string]*Wallet{}}
+
+	// a typo in the repeated passphrase
+	stdinReader = bufio.NewReader(strings.NewReader("secret\nsecrte\n"))
+
+	if err = wc.commandCreatewallet(); err == nil {
+		t.Fatal("Expected error for not matching passphrases")
+	}
+
+	if _, err = os.Stat(dir + "/" + walletFile); !os.IsNotExist(err) {
+		t.Fatal("Wallet file is saved without a confirmed passphrase")
+	}
+
+	stdinReader = bufio.NewReader(strings.NewReader("secret\nsecret\n"))
+
+	if err = wc.commandCreatewallet(); err != nil {
+		t.Fatal(err)
+	}
+
+	ws := Wallets{DataDir: dir + "/"}
+
+	if err = ws.LoadFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ws.IsLocked() || len(ws.GetAddresses()) != 1 {
+		t.Fatal("Expected locked wallet with one address")
+	}
+
+	if err = ws.Unlock("secret"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,116 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/taincoin/taincoin/lib/script"
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+var ErrWatchOnly = errors.New("Address is watch-only. It can not be used to send")
+
+// Adds watch-only address. If a public key is given, the address is made from it.
+// Balance and history of such addresses can be checked, but they can not be spent.
+// Encrypted wallets don't need to be unlocked for this
+func (ws *Wallets) ImportWatchOnly(address string, pubKey []byte) error {
+	if len(pubKey) > 0 {
+		pubKeyAddress, err := utils.PubKeyToAddres(pubKey)
+
+		if err != nil {
+			return err
+		}
+
+		if address != "" && address != pubKeyAddress {
+			return errors.New("Address doesn't match the public key")
+		}
+		address = pubKeyAddress
+
+		if utils.IsMultiSig(pubKey) {
+			if _, err := utils.ParseMultiSig(pubKey); err != nil {
+				return err
+			}
+		}
+
+		if script.IsScriptKey(pubKey) {
+			s, _ := script.ParseScriptKey(pubKey)
+
+			if _, err := script.Disassemble(s); err != nil {
+				return err
+			}
+		}
+	}
+
+	w := Wallet{}
+
+	if !w.ValidateAddress(address) {
+		return errors.New("Address is not valid")
+	}
+
+	if ws.HasAddress(address) {
+		return errors.New(fmt.Sprintf("Address %s is already in the wallet with a private key", address))
+	}
+
+	if oldPubKey, ok := ws.Watched[address]; ok && (len(pubKey) == 0 || len(oldPubKey) > 0) {
+		return errors.New(fmt.Sprintf("Address %s is already watched", address))
+	}
+
+	if ws.Watched == nil {
+		ws.Watched = map[string][]byte{}
+	}
+
+	ws.Watched[address] = pubKey
+
+	if ws.IsLocked() {
+		// private keys are not changed, only public part of the file is updated
+		return ws.saveEncrypted(ws.encrypted)
+	}
+	return ws.SaveToFile()
+}
+
+// Returns true if an address was imported as watch-only
+func (ws Wallets) IsWatchOnly(address string) bool {
+	_, ok := ws.Watched[address]
+	return ok
+}
+
+// Returns list of watch-only addresses
+func (ws Wallets) GetWatchOnlyAddresses() []string {
+	addresses := []string{}
+
+	for address := range ws.Watched {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+// Returns true if an address was made of multisig keys. Such address is kept as watch-only,
+// its public key is serialized multisig keys
+func (ws Wallets) IsMultiSig(address string) bool {
+	return utils.IsMultiSig(ws.Watched[address])
+}
+
+// Returns true if an address is a hash of a script. Coins of it are spent with an unlocking script
+func (ws Wallets) IsScript(address string) bool {
+	return script.IsScriptKey(ws.Watched[address])
+}
+
+// Returns a mark to show next to watch-only addresses
+func (ws Wallets) WatchOnlyMark(address string) string {
+	if ws.IsMultiSig(address) {
+		ms, err := utils.ParseMultiSig(ws.Watched[address])
+
+		if err == nil {
+			return fmt.Sprintf(" [multisig %d of %d]", ms.Required, len(ms.PubKeys))
+		}
+	}
+
+	if ws.IsScript(address) {
+		return " [script]"
+	}
+
+	if ws.IsWatchOnly(address) {
+		return " [watch-only]"
+	}
+	return ""
+}
@@ -1,6 +1,8 @@
 package blockchain
 
 import (
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/script"
 	"github.com/taincoin/taincoin/lib/utils"
 	"github.com/taincoin/taincoin/node/database"
 	"github.com/taincoin/taincoin/node/structures"
@@ -74,7 +76,7 @@ func (i *BlockchainIterator) GetAddressHistory(pubKeyHash []byte, address string
 
 		for _, tx := range block.Transactions {
 
-			income := float64(0)
+			income := lib.Amount(0)
 
 			spent := false
 			spentaddress := ""
@@ -92,13 +94,16 @@ func (i *BlockchainIterator) GetAddressHistory(pubKeyHash []byte, address string
 			if spent {
 				// find how many spent , part of out can be exchange to same address
 
-				spentvalue := float64(0)
-				totalvalue := float64(0) // we need to know total if wallet sent to himself
+				spentvalue := lib.Amount(0)
+				totalvalue := lib.Amount(0) // we need to know total if wallet sent to himself
 
 				destaddress := ""
 
 				// we agree that there can be only one destination in transaction. we don't support scripts
 				for _, out := range tx.Vout {
+					if out.IsData() {
+						continue
+					}
 					if !out.IsLockedWithKey(pubKeyHash) {
 						spentvalue += out.Value
 						destaddress, _ = utils.PubKeyHashToAddres(out.PubKeyHash)
@@ -140,3 +145,39 @@ func (i *BlockchainIterator) GetAddressHistory(pubKeyHash []byte, address string
 
 	return result, nil
 }
+
+// Looks for a secret of hashed time-locked contracts. It is revealed in an input that takes
+// coins from a contract. Returns the secret and ID of the transaction or nil if not found
+func (i *BlockchainIterator) FindHTLCSecret(secretHash []byte) ([]byte, []byte, error) {
+	for {
+		block, err := i.Next()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, tx := range block.Transactions {
+			if tx.IsCoinbase() {
+				continue
+			}
+
+			for _, in := range tx.Vin {
+				if !script.IsScriptKey(in.PubKey) {
+					continue
+				}
+
+				secret := script.ExtractHTLCSecret(in.Signature, secretHash)
+
+				if secret != nil {
+					return secret, tx.ID, nil
+				}
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return nil, nil, nil
+}
@@ -2,8 +2,10 @@ package blockchain
 
 import (
 	"bytes"
+	"encoding/gob"
 	"errors"
 
+	"github.com/taincoin/taincoin/lib"
 	"github.com/taincoin/taincoin/lib/utils"
 	"github.com/taincoin/taincoin/node/database"
 	"github.com/taincoin/taincoin/node/structures"
@@ -314,6 +316,59 @@ func (bc *Blockchain) GetTransactionFromBlock(txID []byte, blockHash []byte) (*s
 	return nil, errors.New("Transaction is not found")
 }
 
+// Returns a proof that a transaction is in a block of the main chain and a hash of that block.
+// The proof can be verified with utils.VerifyMerkleProof having only a header of the block
+func (bc *Blockchain) GetMerkleProof(txID []byte) ([]utils.MerkleProofStep, []byte, error) {
+	txdb, err := bc.DB.GetTransactionsObject()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashesData, err := txdb.GetBlockHashForTX(txID)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if hashesData == nil {
+		return nil, nil, errors.New("Transaction is not found")
+	}
+
+	// transaction can be in blocks of different branches. index keeps all of them
+	var hashes [][]byte
+
+	err = gob.NewDecoder(bytes.NewReader(hashesData)).Decode(&hashes)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blockHash, err := bc.ChooseHashUnderTip(hashes, nil)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if blockHash == nil {
+		return nil, nil, errors.New("Transaction is not found in the main chain")
+	}
+
+	block, err := bc.GetBlock(blockHash)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proof, err := block.GetMerkleProof(txID)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return proof, blockHash, nil
+}
+
 // Returns a block with specified height in current blockchain
 // TODO can be optimized using blocks index
 func (bc *Blockchain) GetBlockAtHeight(height int) (*structures.Block, error) {
@@ -343,6 +398,45 @@ func (bc *Blockchain) GetBlockAtHeight(height int) (*structures.Block, error) {
 	return nil, errors.New("Block with the heigh doesn't exist")
 }
 
+// Returns blocks of current blockchain with heights from "from" to "from+count-1". Blocks are ordered by height
+func (bc *Blockchain) GetBlocksByHeight(from int, count int) ([]*structures.Block, error) {
+	blocks := []*structures.Block{}
+
+	if count <= 0 || from < 0 {
+		return blocks, nil
+	}
+
+	bci, err := NewBlockchainIterator(bc.DB)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		block, err := bci.Next()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if block.Height < from {
+			break
+		}
+
+		if block.Height < from+count {
+			blocks = append(blocks, block)
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	structures.ReverseBlocksSlice(blocks)
+
+	return blocks, nil
+}
+
 // GetBestHeight returns the height of the latest block
 
 func (bc *Blockchain) GetBestHeight() (int, error) {
@@ -436,6 +530,25 @@ func (bc *Blockchain) GetBlock(blockHash []byte) (structures.Block, error) {
 	return block, nil
 }
 
+// Returns median time of a block and blocks before it in its branch.
+// A block on top of this block must have a time more than this
+func (bc *Blockchain) GetMedianTimePast(blockHash []byte) (int64, error) {
+	times := []int64{}
+
+	for len(blockHash) > 0 && len(times) < lib.MedianTimeSpan {
+		block, err := bc.GetBlock(blockHash)
+
+		if err != nil {
+			return 0, err
+		}
+		times = append(times, block.Timestamp)
+
+		blockHash = block.PrevBlockHash
+	}
+
+	return lib.GetMedianTime(times), nil
+}
+
 // Returns a list of blocks short info stating from given block or from a top
 func (bc *Blockchain) GetBlocksShortInfo(startfrom []byte, maxcount int) []*structures.BlockShort {
 	var blocks []*structures.BlockShort
@@ -9,25 +9,50 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/taincoin/taincoin/lib"
 	"github.com/taincoin/taincoin/lib/net"
 	"github.com/taincoin/taincoin/node/database"
 )
 
 // Thi is the struct with all possible command line arguments
 type AllPossibleArgs struct {
-	Address     string
-	From        string
-	To          string
-	Port        int
-	Host        string
-	NodePort    int
-	NodeHost    string
-	Genesis     string
-	Amount      float64
-	LogDest     string
-	Transaction string
-	View        string
-	Clean       bool
+	Address         string
+	From            string
+	To              string
+	Port            int
+	Host            string
+	NodePort        int
+	NodeHost        string
+	Genesis         string
+	Amount          lib.Amount
+	Fee             lib.Amount
+	Height          int
+	LogDest         string
+	Transaction     string
+	View            string
+	Clean           bool
+	Light           bool
+	AddValidator    string
+	RemoveValidator string
+	RPCPort         int
+	RPCHost         string
+	MetricsPort     int
+	MetricsHost     string
+	Timeout         int
+	HD              bool
+	PubKey          string
+	File            string
+	Files           string
+	Required        int
+	PubKeys         string
+	Script          string
+	LockTime        int64
+	Contract        string
+	Secret          string
+	SecretHash      string
+	Data            string
+	StampAddress    string
+	StampFee        lib.Amount
 }
 
 // Input summary
@@ -41,15 +66,98 @@ type AppInput struct {
 	Nodes         []net.NodeAddr
 	Args          AllPossibleArgs
 	Database      database.DatabaseConfig
+	Consensus     ConsensusConfig
+	RPC           RPCConfig
+	Metrics       MetricsConfig
+	Timestamp     TimestampConfig
 }
 
 type AppConfig struct {
-	Minter   string
-	Port     int
-	Host     string
-	Nodes    []net.NodeAddr
-	Logs     []string
-	Database database.DatabaseConfig
+	Minter    string
+	Port      int
+	Host      string
+	Nodes     []net.NodeAddr
+	Logs      []string
+	Database  database.DatabaseConfig
+	Consensus ConsensusConfig
+	RPC       RPCConfig
+	Metrics   MetricsConfig
+	Timestamp TimestampConfig
+}
+
+// HTTP JSON-RPC options. RPC is disabled if a port is not set
+type RPCConfig struct {
+	Port    int
+	Host    string // interface to listen on. localhost if not set
+	AuthStr string // auth string for privileged methods. The auth string of a running node is accepted too
+}
+
+// Check if RPC is enabled
+func (c RPCConfig) IsEnabled() bool {
+	return c.Port > 0
+}
+
+// Prometheus metrics endpoint options. Metrics are disabled if a port is not set
+type MetricsConfig struct {
+	Port int
+	Host string // interface to listen on. localhost if not set
+}
+
+// Check if metrics are enabled
+func (c MetricsConfig) IsEnabled() bool {
+	return c.Port > 0
+}
+
+// Document timestamping service options. The service is disabled if an address is not set
+type TimestampConfig struct {
+	Address string     // address of the node wallet that pays for anchoring transactions
+	Fee     lib.Amount // fee of an anchoring transaction
+}
+
+// Check if timestamping is enabled
+func (c TimestampConfig) IsEnabled() bool {
+	return c.Address != ""
+}
+
+// Consensus engine options
+type ConsensusConfig struct {
+	Kind       string   // name of consensus engine. pow, poa or dev
+	Validators []string // addresses of validators allowed to make blocks. Used by poa on genesis block
+	// votes of this node validator to change the list of validators. Used by poa
+	AddValidators    []string
+	RemoveValidators []string
+	// rules of rewards for blocks. Must be same for all nodes
+	Emission lib.EmissionSchedule
+}
+
+// Check if consensus is not configured
+func (c ConsensusConfig) IsEmpty() bool {
+	return c.Kind == ""
+}
+
+// Check if blocks are sealed with proof of work. Only such block headers can be verified without a state
+func (c ConsensusConfig) IsProofOfWork() bool {
+	return c.IsEmpty() || c.Kind == "pow"
+}
+
+// Check if blocks are signed by validators in turn
+func (c ConsensusConfig) IsProofOfAuthority() bool {
+	return c.Kind == "poa"
+}
+
+// Set default consensus. It is proof of work
+func (c *ConsensusConfig) SetDefault() {
+	c.Kind = "pow"
+	c.Validators = []string{}
+	c.Emission = lib.DefaultEmissionSchedule()
+}
+
+// Returns emission schedule. Default is used if it is not in a config
+func (c ConsensusConfig) GetEmission() lib.EmissionSchedule {
+	if c.Emission.IsEmpty() {
+		return lib.DefaultEmissionSchedule()
+	}
+	return c.Emission
 }
 
 // Parses inout and config file. Command line arguments ovverride config file options
@@ -76,10 +184,34 @@ func GetAppInput() (AppInput, error) {
 	cmd.StringVar(&input.Args.NodeHost, "nodehost", "", "Remote Node Server Host")
 	cmd.IntVar(&input.Args.Port, "port", 0, "Node Server port")
 	cmd.IntVar(&input.Args.NodePort, "nodeport", 0, "Remote Node Server port")
-	cmd.Float64Var(&input.Args.Amount, "amount", 0, "Amount money to send")
+	cmd.Var(&input.Args.Amount, "amount", "Amount money to send. Up to 8 digits after a point")
+	cmd.Var(&input.Args.Fee, "fee", "Fee for a miner. Transactions with bigger fee are approved first")
+	cmd.IntVar(&input.Args.Height, "height", -1, "Height of a block. Top block if not set")
 	cmd.StringVar(&input.Args.LogDest, "logdest", "file", "Destination of logs. file or stdout")
 	cmd.StringVar(&input.Args.View, "view", "", "View format")
 	cmd.BoolVar(&input.Args.Clean, "clean", false, "Clean data/cache")
+	cmd.BoolVar(&input.Args.Light, "light", false, "Light client mode. Data from nodes are verified with block headers and Merkle proofs")
+	cmd.StringVar(&input.Args.AddValidator, "addvalidator", "", "Vote to add a validator address")
+	cmd.StringVar(&input.Args.RemoveValidator, "removevalidator", "", "Vote to remove a validator address")
+	cmd.IntVar(&input.Args.RPCPort, "rpcport", 0, "HTTP JSON-RPC port. 0 to keep a config value, -1 to disable")
+	cmd.StringVar(&input.Args.RPCHost, "rpchost", "", "HTTP JSON-RPC host to listen on")
+	cmd.IntVar(&input.Args.MetricsPort, "metricsport", 0, "Prometheus metrics port. 0 to keep a config value, -1 to disable")
+	cmd.StringVar(&input.Args.MetricsHost, "metricshost", "", "Prometheus metrics host to listen on")
+	cmd.StringVar(&input.Args.StampAddress, "stampaddress", "", "Wallet address which pays for anchoring of timestamped hashes. no to disable")
+	cmd.Var(&input.Args.StampFee, "stampfee", "Fee of a transaction anchoring timestamped hashes")
+	cmd.IntVar(&input.Args.Timeout, "timeout", 300, "Time in seconds to keep a wallet unlocked. 0 to keep until a node stops")
+	cmd.BoolVar(&input.Args.HD, "hd", false, "Create HD wallet. All addresses are derived from one seed")
+	cmd.StringVar(&input.Args.PubKey, "pubkey", "", "Public key in hex")
+	cmd.StringVar(&input.Args.File, "file", "", "File of a transaction to sign offline")
+	cmd.StringVar(&input.Args.Files, "files", "", "Comma separated files of a multisig transaction signed by other keys")
+	cmd.IntVar(&input.Args.Required, "required", 0, "Number of signatures required to spend from multisig address")
+	cmd.StringVar(&input.Args.PubKeys, "pubkeys", "", "Comma separated public keys in hex or addresses of the wallet")
+	cmd.Int64Var(&input.Args.LockTime, "locktime", 0, "Block height or unix time before which a transaction can not be added to a block")
+	cmd.StringVar(&input.Args.Contract, "contract", "", "Script of a swap contract in hex")
+	cmd.StringVar(&input.Args.Secret, "secret", "", "Secret of a swap contract in hex")
+	cmd.StringVar(&input.Args.SecretHash, "secrethash", "", "SHA256 hash of a swap secret in hex")
+	cmd.StringVar(&input.Args.Data, "data", "", "Data in hex to anchor in the blockchain, for example SHA256 of a document")
+	cmd.StringVar(&input.Args.Script, "script", "", "Locking script like \"OP_SHA256 0xHASH OP_EQUAL\"")
 
 	datadirPtr := cmd.String("datadir", "", "Location of data files, config, DB etc")
 	err := cmd.Parse(os.Args[2:])
@@ -139,8 +271,13 @@ func GetAppInput() (AppInput, error) {
 		}
 
 		input.Database = config.Database
+		input.Consensus = config.Consensus
+		input.RPC = config.RPC
+		input.Metrics = config.Metrics
+		input.Timestamp = config.Timestamp
 	} else {
 		input.Database.SetDefault()
+		input.Consensus.SetDefault()
 	}
 	input.Database.DataDir = input.DataDir
 
@@ -177,6 +314,17 @@ func (c AppInput) GetConfig() (*AppConfig, error) {
 		config.Database.SetDefault()
 	}
 
+	err = config.Database.CheckEngine()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Consensus.IsEmpty() {
+
+		config.Consensus.SetDefault()
+	}
+
 	return &config, nil
 }
 func (c AppInput) CheckNeedsHelp() bool {
@@ -251,6 +399,52 @@ func (c AppInput) UpdateConfig() error {
 		config.Logs = []string{}
 	}
 
+	if config.Consensus.IsEmpty() {
+		config.Consensus.SetDefault()
+	}
+
+	if c.Args.RPCPort > 0 {
+		config.RPC.Port = c.Args.RPCPort
+	} else if c.Args.RPCPort < 0 {
+		config.RPC.Port = 0
+	}
+
+	if c.Args.RPCHost != "" {
+		config.RPC.Host = c.Args.RPCHost
+	}
+
+	if c.Args.MetricsPort > 0 {
+		config.Metrics.Port = c.Args.MetricsPort
+	} else if c.Args.MetricsPort < 0 {
+		config.Metrics.Port = 0
+	}
+
+	if c.Args.MetricsHost != "" {
+		config.Metrics.Host = c.Args.MetricsHost
+	}
+
+	if c.Args.StampAddress == "no" {
+		config.Timestamp = TimestampConfig{}
+	} else if c.Args.StampAddress != "" {
+		config.Timestamp.Address = c.Args.StampAddress
+	}
+
+	if c.Args.StampFee > 0 {
+		config.Timestamp.Fee = c.Args.StampFee
+	}
+
+	if c.Args.AddValidator != "" {
+		config.Consensus.RemoveValidators = removeFromList(config.Consensus.RemoveValidators, c.Args.AddValidator)
+		config.Consensus.AddValidators = removeFromList(config.Consensus.AddValidators, c.Args.AddValidator)
+		config.Consensus.AddValidators = append(config.Consensus.AddValidators, c.Args.AddValidator)
+	}
+
+	if c.Args.RemoveValidator != "" {
+		config.Consensus.AddValidators = removeFromList(config.Consensus.AddValidators, c.Args.RemoveValidator)
+		config.Consensus.RemoveValidators = removeFromList(config.Consensus.RemoveValidators, c.Args.RemoveValidator)
+		config.Consensus.RemoveValidators = append(config.Consensus.RemoveValidators, c.Args.RemoveValidator)
+	}
+
 	// convert back to JSON and save to config file
 	file, errf := os.OpenFile(configfile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 
@@ -270,26 +464,63 @@ func (c AppInput) UpdateConfig() error {
 	return nil
 }
 
+// Returns a list without given value
+func removeFromList(list []string, value string) []string {
+	result := []string{}
+
+	for _, v := range list {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
 func (c AppInput) PrintUsage() {
 	fmt.Println("Usage:")
 	fmt.Println("  help - Prints this help")
 	fmt.Println("  == Any of next commands can have optional argument [-datadir /path/to/dir] [-logdest stdout]==")
-	fmt.Println("  createwallet\n\t- Generates a new key-pair and saves it into the wallet file")
+	fmt.Println("  createwallet [-hd]\n\t- Generates a new key-pair and saves it into the wallet file. With -hd keys are derived from a seed, the seed is shown as mnemonic words for a backup")
+	fmt.Println("  restorewallet\n\t- Restores HD wallet from mnemonic words and finds used addresses in the blockchain. Words are read from stdin, so they are not in a shell history")
+	fmt.Println("  rescanwallet\n\t- Finds used addresses of HD wallet. It stops after 20 unused addresses in a row")
 	fmt.Println("  createblockchain -address ADDRESS -genesis GENESISTEXT\n\t- Create a blockchain and send genesis block reward to ADDRESS")
 	fmt.Println("  initblockchain [-nodehost HOST] [-nodeport PORT]\n\t- Loads a blockchain from other node to init the DB.")
 	fmt.Println("  printchain [-view short|long]\n\t- Print all the blocks of the blockchain. Default view is long")
 	fmt.Println("  makeblock [-minter ADDRESS]\n\t- Try to mine new block if there are enough transactions")
 	fmt.Println("  dropblock\n\t- Delete last block fro the block chain. All transaction are returned back to unapproved state")
 	fmt.Println("  reindexcache\n\t- Rebuilds the database of unspent transactions outputs and transaction pointers")
-	fmt.Println("  showunspent -address ADDRESS\n\t- Print the list of all unspent transactions and balance")
+	fmt.Println("  migratedb\n\t- Checks the database for data of old format where amounts were float numbers. Old unapproved transactions are removed. Old blocks can not be converted, the blockchain must be synced again")
+	fmt.Println("  showunspent -address ADDRESS [-light]\n\t- Print the list of all unspent transactions and balance. With -light outputs are verified with Merkle proofs")
 	fmt.Println("  unapprovedtransactions [-clean]\n\t- Print the list of transactions not included in any block yet. If the option -clean provided then cleans the cache")
 
-	fmt.Println("  getbalance -address ADDRESS\n\t- Get balance of ADDRESS")
+	fmt.Println("  getbalance -address ADDRESS [-light]\n\t- Get balance of ADDRESS. With -light only block headers are loaded and the balance is verified with Merkle proofs from known nodes")
 	fmt.Println("  listaddresses\n\t- Lists all addresses from the wallet file")
-	fmt.Println("  getbalances\n\t- Lists all addresses from the wallet file and show balance for each")
+	fmt.Println("  getbalances [-light]\n\t- Lists all addresses from the wallet file and show balance for each")
+	fmt.Println("  importaddress -address ADDRESS\n\t- Adds watch-only address to the wallet. Its balance and history can be checked, but it can not be used to send")
+	fmt.Println("  importpubkey -pubkey PUBKEY\n\t- Adds watch-only address of a public key in hex format")
 	fmt.Println("  addrhistory -address ADDRESS\n\t- Shows all transactions for a wallet address")
-
-	fmt.Println("  send -from FROM -to TO -amount AMOUNT\n\t- Send AMOUNT of coins from FROM address to TO. ")
+	fmt.Println("  unlock [-timeout SECONDS]\n\t- Unlock the wallet in the running node, so it can make blocks and send from RPC. A plain wallet file is encrypted, the new passphrase is asked twice")
+	fmt.Println("  lock\n\t- Remove wallet keys from memory of the running node")
+	fmt.Println("  changepassphrase\n\t- Change passphrase of the encrypted wallet file. Passphrases are read from stdin")
+	fmt.Println("  getsupply [-height HEIGHT]\n\t- Shows number of coins in circulation after a block with HEIGHT. Default is the top block")
+
+	fmt.Println("  send -from FROM -to TO -amount AMOUNT [-fee FEE]\n\t- Send AMOUNT of coins from FROM address to TO. FEE is paid to a miner, transactions with bigger fee are added to blocks first")
+	fmt.Println("  preparetx -from FROM -to TO -amount AMOUNT [-fee FEE] [-locktime LOCKTIME] -file FILE\n\t- Prepare a transaction and save it unsigned to FILE. Only a public key of FROM is needed, it can be imported with importpubkey. LOCKTIME is a block height, or unix time if it is 500000000 or more, before which the transaction stays in the pool")
+	fmt.Println("  signtx -file FILE\n\t- Sign a transaction from FILE. It works without a node, on a machine with the keys. Signatures are saved to same FILE")
+	fmt.Println("  broadcasttx -file FILE\n\t- Send a signed transaction from FILE to the network")
+	fmt.Println("  createmultisig -required M -pubkeys KEY1,KEY2,KEY3\n\t- Make M-of-N multisig address. Keys are public keys in hex or addresses of this wallet. The address is added to the wallet as watch-only")
+	fmt.Println("  createscript -script SCRIPT\n\t- Make address of outputs locked with SCRIPT, for example \"OP_SHA256 0xHASH OP_EQUALVERIFY 0xPUBKEY OP_CHECKSIG\". Data are hex with 0x prefix. The address is added to the wallet as watch-only")
+	fmt.Println("  combinetx -file FILE -files FILE2[,FILE3]\n\t- Add signatures of other multisig keys to FILE. Use it if signers signed copies of a transaction file. signtx adds signatures to same file")
+	fmt.Println("  initiateswap -from FROM -to TO -amount AMOUNT -locktime LOCKTIME [-secrethash HASH] [-fee FEE]\n\t- Send AMOUNT to a new swap contract. TO takes it with a secret, FROM can take it back after LOCKTIME. A new secret is made if HASH of other side's contract is not set")
+	fmt.Println("  redeemswap -contract CONTRACT [-secret SECRET] [-to TO] [-fee FEE]\n\t- Take coins of a swap contract with the secret. They are sent to the receiver of the contract or to TO. A secret saved by initiateswap is used if SECRET is not set")
+	fmt.Println("  refundswap -contract CONTRACT [-to TO] [-fee FEE]\n\t- Return coins of a swap contract to the sender. The transaction waits in the pool until the lock time of the contract")
+	fmt.Println("  extractsecret -contract CONTRACT | -secrethash HASH\n\t- Find a swap secret revealed in the blockchain when other side redeemed a contract")
+	fmt.Println("  showsecret -contract CONTRACT | -secrethash HASH\n\t- Show a swap secret saved in the wallet by initiateswap")
+	fmt.Println("  anchor -from FROM -data DATA [-fee FEE]\n\t- Add DATA in hex to the blockchain with an unspendable output. FROM pays only the fee. DATA can be up to 80 bytes, for example SHA256 of sensor readings")
+	fmt.Println("  findanchor -data DATA\n\t- Show a transaction and a block where DATA were anchored. Time of the block proves the data existed then")
+	fmt.Println("  stamp -data HASH[,HASH...]\n\t- Send SHA256 hashes of documents in hex to the timestamping service of the running node. Hashes are collected to a Merkle tree and its root is anchored once per block")
+	fmt.Println("  getreceipt -data HASH -file FILE\n\t- Save a receipt of timestamped HASH to FILE. It is ready when a batch with the hash is in a block")
+	fmt.Println("  verifyreceipt -file FILE\n\t- Check a receipt with block headers and show time of the block. Saved headers are used, they are loaded from nodes only if the block is not there")
 	fmt.Println("  canceltransaction -transaction TRANSACTIONID\n\t- Cancel unapproved transaction. NOTE!. This cancels only from local cache!")
 
 	fmt.Println("  startnode [-minter ADDRESS] [-host HOST] [-port PORT]\n\t- Start a node server. -minter defines minting address, -host - hostname of the node server and -port - listening port")
@@ -297,6 +528,10 @@ func (c AppInput) PrintUsage() {
 	fmt.Println("  stopnode\n\t- Stop runnning node")
 	fmt.Println("  nodestate\n\t- Print state of the node process")
 	fmt.Println("  updateconfig [-minter ADDRESS] [-host HOST] [-port PORT] [-nodehost HOST] [-nodeport PORT]\n\t- Update config file. Allows to set this node minter address, host and port and remote node host and port")
+	fmt.Println("  updateconfig [-addvalidator ADDRESS] [-removevalidator ADDRESS]\n\t- Vote to add or remove a validator in proof of authority consensus. Votes are included in blocks signed by this node. Restart the node to apply")
+	fmt.Println("  updateconfig [-metricsport PORT] [-metricshost HOST]\n\t- Enable Prometheus metrics on http://HOST:PORT/metrics. -metricsport -1 disables it. Restart the node to apply")
+	fmt.Println("  updateconfig [-stampaddress ADDRESS] [-stampfee FEE]\n\t- Enable the timestamping service. ADDRESS of the node wallet pays FEE for every anchoring transaction. -stampaddress no disables it. Restart the node to apply")
+	fmt.Println("  updateconfig [-rpcport PORT] [-rpchost HOST]\n\t- Enable HTTP JSON-RPC API on the port. -rpcport -1 disables it. Restart the node to apply")
 
 	fmt.Println("  shownodes\n\t- Display list of nodes addresses, including inactive")
 	fmt.Println("  addnode -nodehost HOST -nodeport PORT\n\t- Adds new node to list of connections")
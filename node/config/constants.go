@@ -1,12 +1,38 @@
 package config
 
+import (
+	"github.com/taincoin/taincoin/lib"
+)
+
 // ==========================================================
 // this can be altered to experiment with blockchain
 
-// this defines how strong miming is needed. 16 is simple mining less 5 sec in simple desktop
-// 24 will need 30 seconds in average
-const TargetBits = 16
-const TargetBits_2 = 24
+// Difficulty rules are shared with light clients, they are defined in lib
+const TargetBits = lib.TargetBits
+
+// Difficulty can not go out of this range
+const MinTargetBits = lib.MinTargetBits
+const MaxTargetBits = lib.MaxTargetBits
+
+// Difficulty is recalculated every this number of blocks
+const DifficultyAdjustmentInterval = lib.DifficultyAdjustmentInterval
+
+// Expected time between blocks, in seconds. Retarget tries to keep this speed
+const TargetBlockTime = lib.TargetBlockTime
+
+// Max change of difficulty in one retarget. 2 bits means target can change 4 times max
+const MaxTargetBitsChange = lib.MaxTargetBitsChange
+
+// Proof of authority. Votes to change validators list are counted only in this number of last blocks
+const GovernanceVotesWindow = 100
+
+// Proof of authority. If a validator in turn doesn't make a block, next validators in the list can make it,
+// every next one after this number of seconds more. So the chain doesn't stop when a validator is offline
+const PoAOutOfTurnDelay = 10
+
+// Proof of authority. Max number of seconds a block time can be ahead of local time of a node.
+// It is much less than PoAOutOfTurnDelay, so a validator out of turn can not sign earlier with a time in the future
+const PoAMaxFutureBlockTime = 2
 
 // Max and Min number of transactions per block
 // If number of block in a chain is less this umber then it is a minimum. if more then
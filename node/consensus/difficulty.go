@@ -0,0 +1,71 @@
+package consensus
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/node/config"
+	"github.com/taincoin/taincoin/node/structures"
+)
+
+// Returns difficulty a block with given height and parent must have.
+// Difficulty is same as in previous block except every DifficultyAdjustmentInterval blocks.
+// On that blocks it is recalculated from time spent to make last interval of blocks
+// Calculation is done agains a branch of previous block, not current top branch
+func (n *NodeBlockMaker) getTargetBits(prevBlockHash []byte, height int) (int, error) {
+	if height == 0 {
+		// genesis block
+		return lib.GetNextTargetBits(height, 0, 0), nil
+	}
+
+	bcm := n.getBlockchainManager()
+
+	prevBlock, err := bcm.GetBlock(prevBlockHash)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if prevBlock.Height != height-1 {
+		return 0, errors.New(fmt.Sprintf("Previous block height %d doesn't match block height %d", prevBlock.Height, height))
+	}
+
+	if !lib.IsRetargetHeight(height) {
+		return prevBlock.Bits, nil
+	}
+
+	// find first block of the interval, going down from previous block
+	firstBlock := prevBlock
+
+	for firstBlock.Height > height-config.DifficultyAdjustmentInterval {
+		firstBlock, err = bcm.GetBlock(firstBlock.PrevBlockHash)
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	timespan := prevBlock.Timestamp - firstBlock.Timestamp
+
+	bits := lib.GetNextTargetBits(height, prevBlock.Bits, timespan)
+
+	n.Logger.Trace.Printf("Retarget on height %d. Time spent %d sec. Difficulty %d -> %d", height, timespan, prevBlock.Bits, bits)
+
+	return bits, nil
+}
+
+// Check a block has correct difficulty for its position in the chain
+func (n *NodeBlockMaker) checkTargetBits(block *structures.Block) error {
+	bits, err := n.getTargetBits(block.PrevBlockHash, block.Height)
+
+	if err != nil {
+		return err
+	}
+
+	if block.Bits != bits {
+		return errors.New(fmt.Sprintf("Block difficulty %d is wrong. Expected %d", block.Bits, bits))
+	}
+
+	return nil
+}
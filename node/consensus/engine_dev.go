@@ -0,0 +1,63 @@
+package consensus
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/taincoin/taincoin/node/structures"
+)
+
+// Development consensus engine. Blocks are made instantly, without any work.
+// A hash is just a hash of a block header. Use it only for tests
+type devEngine struct {
+	maker *NodeBlockMaker
+}
+
+func newDevEngine(n *NodeBlockMaker) (ConsensusEngine, error) {
+	return &devEngine{n}, nil
+}
+
+// Any node can make a block at any time
+func (e *devEngine) IsGoodTimeToMakeBlock(prevBlockHash []byte, height int) (bool, error) {
+	return true, nil
+}
+
+// Sets a hash of a block
+func (e *devEngine) SealBlock(b *structures.Block) error {
+	data, err := getBlockHeaderData(b)
+
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(data)
+
+	b.Hash = hash[:]
+	b.Nonce = 0
+
+	return nil
+}
+
+// Checks a hash is made from a block data
+func (e *devEngine) VerifySeal(b *structures.Block) error {
+	err := checkNoValidatorFields(b)
+
+	if err != nil {
+		return err
+	}
+
+	data, err := getBlockHeaderData(b)
+
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(data)
+
+	if !bytes.Equal(hash[:], b.Hash) {
+		return errors.New("Block hash is not valid")
+	}
+
+	return nil
+}
@@ -0,0 +1,505 @@
+package consensus
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/taincoin/taincoin/lib/utils"
+	"github.com/taincoin/taincoin/lib/wallet"
+	"github.com/taincoin/taincoin/node/config"
+	"github.com/taincoin/taincoin/node/structures"
+)
+
+// Proof of authority consensus engine. Only validators from a known list can make blocks.
+// A validator signs a block hash with a key of his wallet. No any mining work.
+// Validators make blocks in turn, a validator for a block is chosen by height of a block (round-robin).
+// If a validator in turn is offline, next validators can make a block after a delay (see config.PoAOutOfTurnDelay).
+// A validator out of turn can not sign if it signed one of last blocks, so one validator can not make all blocks
+// with fake times of blocks. A block time can be only a bit ahead of local time of a node (see config.PoAMaxFutureBlockTime),
+// so the delay is measured by a clock of a node that receives a block, not by a time a signer wants.
+// Initial list of validators is taken from a config and is saved in a genesis block.
+// Later the list can be changed with governance transactions. A validator adds his votes
+// to blocks he signs. A vote is applied when more than half of validators voted same
+type poaEngine struct {
+	maker *NodeBlockMaker
+}
+
+func newPoAEngine(n *NodeBlockMaker) (ConsensusEngine, error) {
+	if len(n.Config.Validators) == 0 {
+		return nil, errors.New("No validators are configured for proof of authority consensus")
+	}
+	return &poaEngine{n}, nil
+}
+
+// Returns list of validators after a block with given hash. This list is used to verify next block
+// For a genesis block the list is taken from a config
+func (e *poaEngine) getValidatorsAfter(blockHash []byte) ([]string, error) {
+	if len(blockHash) == 0 {
+		return e.maker.Config.Validators, nil
+	}
+	block, err := e.maker.getBlockchainManager().GetBlock(blockHash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(block.Validators) == 0 {
+		return e.maker.Config.Validators, nil
+	}
+
+	return block.Validators, nil
+}
+
+// Returns time of a block. It is 0 before a genesis block
+func (e *poaEngine) getBlockTime(blockHash []byte) (int64, error) {
+	if len(blockHash) == 0 {
+		return 0, nil
+	}
+	block, err := e.maker.getBlockchainManager().GetBlock(blockHash)
+
+	if err != nil {
+		return 0, err
+	}
+	return block.Timestamp, nil
+}
+
+// Returns address of a validator who must sign a block with given height
+// For a genesis block any validator from a config can do this
+func (e *poaEngine) getValidatorInTurn(validators []string, height int) string {
+	return validators[height%len(validators)]
+}
+
+// Returns how far an address is from a validator in turn. 0 is in turn, 1 is next validator etc.
+// Returns -1 if the address is not a validator
+func (e *poaEngine) getTurnOffset(validators []string, address string, height int) int {
+	for i, v := range validators {
+		if v == address {
+			return (i - height%len(validators) + len(validators)) % len(validators)
+		}
+	}
+	return -1
+}
+
+// Check if an address is in the list of validators
+func (e *poaEngine) isValidator(validators []string, address string) bool {
+	for _, v := range validators {
+		if v == address {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check if the address can sign a block with given height. elapsed is time between previous block
+// and this block. A validator out of turn waits for validators before it.
+// Time of this block is checked with local time before, see checkSigner
+func (e *poaEngine) isAllowedSigner(validators []string, address string, height int, elapsed int64) bool {
+	if height == 0 {
+		return e.isValidator(validators, address)
+	}
+
+	offset := e.getTurnOffset(validators, address, height)
+
+	if offset < 0 {
+		return false
+	}
+	return int64(offset)*config.PoAOutOfTurnDelay <= elapsed
+}
+
+// Returns number of last blocks a validator out of turn must not sign. It is less than half
+// of validators, so the chain continues when most of validators are online.
+// It is at least 1, a validator never makes 2 blocks in a row out of turn. So 2 validators
+// both must be online
+func (e *poaEngine) getRecentSignersLimit(validators []string) int {
+	limit := (len(validators) - 1) / 2
+
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
+
+// Check if the address signed one of last blocks before a block with given hash
+func (e *poaEngine) signedRecently(blockHash []byte, address string, count int) (bool, error) {
+	bcm := e.maker.getBlockchainManager()
+
+	for i := 0; i < count && len(blockHash) > 0; i++ {
+		block, err := bcm.GetBlock(blockHash)
+
+		if err != nil {
+			return false, err
+		}
+
+		signer, err := utils.PubKeyToAddres(block.Validator)
+
+		if err != nil {
+			return false, err
+		}
+
+		if signer == address {
+			return true, nil
+		}
+
+		blockHash = block.PrevBlockHash
+	}
+	return false, nil
+}
+
+// Checks the address can sign a block with given height and time on top of a block.
+// now is local time when a block is made or received
+func (e *poaEngine) checkSigner(validators []string, address string, prevBlockHash []byte, height int, blockTime int64, now int64) error {
+	// a signer could set a time in the future to skip a wait for validators before it
+	if blockTime > now+config.PoAMaxFutureBlockTime {
+		return errors.New(fmt.Sprintf("Block time %d is ahead of local time %d", blockTime, now))
+	}
+
+	prevTime, err := e.getBlockTime(prevBlockHash)
+
+	if err != nil {
+		return err
+	}
+
+	if !e.isAllowedSigner(validators, address, height, blockTime-prevTime) {
+		return errors.New(fmt.Sprintf("Address %s is not a validator in turn for height %d", address, height))
+	}
+
+	if height == 0 || e.getTurnOffset(validators, address, height) == 0 {
+		return nil
+	}
+
+	recent, err := e.signedRecently(prevBlockHash, address, e.getRecentSignersLimit(validators))
+
+	if err != nil {
+		return err
+	}
+
+	if recent {
+		return errors.New(fmt.Sprintf("Validator %s signed one of last blocks and can not sign out of turn", address))
+	}
+	return nil
+}
+
+// Only validators can make blocks. A validator in turn at any time, others after a delay
+func (e *poaEngine) IsGoodTimeToMakeBlock(prevBlockHash []byte, height int) (bool, error) {
+	validators, err := e.getValidatorsAfter(prevBlockHash)
+
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now().Unix()
+
+	return e.checkSigner(validators, e.maker.MinterAddress, prevBlockHash, height, now, now) == nil, nil
+}
+
+// Check a governance transaction is correct for a list of validators
+func (e *poaEngine) checkGovernanceTransaction(validators []string, g structures.GovernanceTransaction) error {
+	w := wallet.Wallet{}
+
+	if !w.ValidateAddress(g.Address) {
+		return errors.New(fmt.Sprintf("Governance transaction address %s is not valid", g.Address))
+	}
+
+	if g.Action == structures.GovernanceAction_AddValidator {
+		if e.isValidator(validators, g.Address) {
+			return errors.New(fmt.Sprintf("Address %s is a validator already", g.Address))
+		}
+		return nil
+	}
+
+	if g.Action == structures.GovernanceAction_RemoveValidator {
+		if !e.isValidator(validators, g.Address) {
+			return errors.New(fmt.Sprintf("Address %s is not a validator", g.Address))
+		}
+		if len(validators) == 1 {
+			return errors.New("Last validator can not be removed")
+		}
+		return nil
+	}
+
+	return errors.New(fmt.Sprintf("Unknown governance action %d", g.Action))
+}
+
+// Returns governance transactions this node votes for. It is taken from a config
+func (e *poaEngine) getOwnVotes(validators []string) []structures.GovernanceTransaction {
+	list := []structures.GovernanceTransaction{}
+
+	for _, address := range e.maker.Config.AddValidators {
+		list = append(list, structures.GovernanceTransaction{Action: structures.GovernanceAction_AddValidator, Address: address})
+	}
+
+	for _, address := range e.maker.Config.RemoveValidators {
+		list = append(list, structures.GovernanceTransaction{Action: structures.GovernanceAction_RemoveValidator, Address: address})
+	}
+
+	votes := []structures.GovernanceTransaction{}
+
+	for _, g := range list {
+		// skip votes that are already applied or are wrong
+		if e.checkGovernanceTransaction(validators, g) == nil {
+			votes = append(votes, g)
+		}
+	}
+
+	return votes
+}
+
+// Count validators who voted for governance transactions in last blocks.
+// Only blocks made with same list of validators are used
+// Returns a list of voters addresses for every vote
+func (e *poaEngine) getVotesHistory(prevBlockHash []byte, validators []string) (map[string]map[string]bool, error) {
+	votes := map[string]map[string]bool{}
+
+	validatorsStr := structures.ValidatorsListToString(validators)
+
+	bcm := e.maker.getBlockchainManager()
+
+	hash := prevBlockHash
+
+	for i := 0; i < config.GovernanceVotesWindow && len(hash) > 0; i++ {
+		block, err := bcm.GetBlock(hash)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if structures.ValidatorsListToString(block.Validators) != validatorsStr {
+			// list was changed here. older votes are not counted
+			break
+		}
+
+		voter, err := utils.PubKeyToAddres(block.Validator)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, g := range block.Governance {
+			key := string(g.ToBytes())
+
+			if _, ok := votes[key]; !ok {
+				votes[key] = map[string]bool{}
+			}
+			votes[key][voter] = true
+		}
+
+		hash = block.PrevBlockHash
+	}
+
+	return votes, nil
+}
+
+// Calculates the list of validators after a block. Votes from a block are added to votes in previous blocks
+// and a change is applied if more than half of validators voted for it
+func (e *poaEngine) getNewValidators(b *structures.Block, validators []string, voter string) ([]string, error) {
+	if len(b.Governance) == 0 {
+		return validators, nil
+	}
+
+	history, err := e.getVotesHistory(b.PrevBlockHash, validators)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return e.applyVotes(b, validators, history, voter), nil
+}
+
+// Applies governance transactions of a block that got votes of more than half of validators.
+// Votes and the threshold are counted against the list of validators before the block,
+// so a change applied in the block doesn't make next changes easier
+func (e *poaEngine) applyVotes(b *structures.Block, validators []string, history map[string]map[string]bool, voter string) []string {
+	newvalidators := validators
+
+	for _, g := range b.Governance {
+		key := string(g.ToBytes())
+
+		voters := map[string]bool{voter: true}
+
+		for v := range history[key] {
+			voters[v] = true
+		}
+
+		// only current validators votes are counted
+		count := 0
+
+		for v := range voters {
+			if e.isValidator(validators, v) {
+				count++
+			}
+		}
+
+		if count*2 > len(validators) && e.checkGovernanceTransaction(newvalidators, g) == nil {
+			e.maker.Logger.Trace.Printf("Governance transaction applied on height %d: %s", b.Height, g.String())
+
+			newvalidators = g.Apply(newvalidators)
+		}
+	}
+
+	return newvalidators
+}
+
+// Calculates a hash of a block. Validator public key, governance transactions
+// and validators list are part of hashed data
+func (e *poaEngine) getBlockHash(b *structures.Block) ([]byte, error) {
+	data, err := getBlockHeaderData(b)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data = append(data, b.Validator...)
+
+	for _, g := range b.Governance {
+		data = append(data, g.ToBytes()...)
+	}
+
+	data = append(data, []byte(structures.ValidatorsListToString(b.Validators))...)
+
+	hash := sha256.Sum256(data)
+
+	return hash[:], nil
+}
+
+// Signs a block with a key of minter wallet. Minter must be a validator in turn
+func (e *poaEngine) SealBlock(b *structures.Block) error {
+	validators, err := e.getValidatorsAfter(b.PrevBlockHash)
+
+	if err != nil {
+		return err
+	}
+
+	err = e.checkSigner(validators, e.maker.MinterAddress, b.PrevBlockHash, b.Height, b.Timestamp, time.Now().Unix())
+
+	if err != nil {
+		return err
+	}
+
+	wallets := wallet.Wallets{}
+	wallets.DataDir = e.maker.DataDir
+	wallets.Logger = e.maker.Logger
+
+	err = wallets.LoadFromFile()
+
+	if err != nil {
+		return err
+	}
+
+	w, err := wallets.GetWallet(e.maker.MinterAddress)
+
+	if err != nil {
+		return err
+	}
+
+	b.Nonce = 0
+	b.Validator = w.GetPublicKey()
+
+	if b.Height > 0 {
+		b.Governance = e.getOwnVotes(validators)
+	}
+
+	b.Validators, err = e.getNewValidators(b, validators, e.maker.MinterAddress)
+
+	if err != nil {
+		return err
+	}
+
+	b.Hash, err = e.getBlockHash(b)
+
+	if err != nil {
+		return err
+	}
+
+	b.Signature, err = utils.SignData(w.GetPrivateKey(), b.Hash)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Checks a block is signed by a validator in turn, governance transactions are correct
+// and a hash is correct
+func (e *poaEngine) VerifySeal(b *structures.Block) error {
+	if len(b.Validator) == 0 || len(b.Signature) == 0 {
+		return errors.New("Block is not signed by a validator")
+	}
+
+	address, err := utils.PubKeyToAddres(b.Validator)
+
+	if err != nil {
+		return err
+	}
+
+	validators, err := e.getValidatorsAfter(b.PrevBlockHash)
+
+	if err != nil {
+		return err
+	}
+
+	if !e.isValidator(validators, address) {
+		return errors.New(fmt.Sprintf("Block signer %s is not a validator", address))
+	}
+
+	err = e.checkSigner(validators, address, b.PrevBlockHash, b.Height, b.Timestamp, time.Now().Unix())
+
+	if err != nil {
+		return err
+	}
+
+	// check governance transactions
+	if b.Height == 0 && len(b.Governance) > 0 {
+		return errors.New("Genesis block can not have governance transactions")
+	}
+
+	for i, g := range b.Governance {
+		err = e.checkGovernanceTransaction(validators, g)
+
+		if err != nil {
+			return err
+		}
+
+		for _, og := range b.Governance[:i] {
+			if og.IsSame(g) {
+				return errors.New("Duplicate governance transaction in a block")
+			}
+		}
+	}
+
+	newvalidators, err := e.getNewValidators(b, validators, address)
+
+	if err != nil {
+		return err
+	}
+
+	if structures.ValidatorsListToString(newvalidators) != structures.ValidatorsListToString(b.Validators) {
+		return errors.New("List of validators in a block is wrong")
+	}
+
+	hash, err := e.getBlockHash(b)
+
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(hash, b.Hash) {
+		return errors.New("Block hash is not valid")
+	}
+
+	valid, err := utils.VerifySignature(b.Signature, b.Hash, b.Validator)
+
+	if err != nil {
+		return err
+	}
+
+	if !valid {
+		return errors.New("Block signature is not valid")
+	}
+
+	return nil
+}
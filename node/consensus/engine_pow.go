@@ -0,0 +1,86 @@
+package consensus
+
+import (
+	"errors"
+	"time"
+
+	"github.com/taincoin/taincoin/node/config"
+	"github.com/taincoin/taincoin/node/structures"
+)
+
+// Proof of work consensus engine. Any node can make a block, a block hash must be
+// less than a target defined by a difficulty
+type powEngine struct {
+	maker *NodeBlockMaker
+}
+
+func newPoWEngine(n *NodeBlockMaker) (ConsensusEngine, error) {
+	return &powEngine{n}, nil
+}
+
+// Any node can try to make a block at any time
+func (e *powEngine) IsGoodTimeToMakeBlock(prevBlockHash []byte, height int) (bool, error) {
+	return true, nil
+}
+
+// Sets difficulty for a block and does MIMING
+func (e *powEngine) SealBlock(b *structures.Block) error {
+	bits, err := e.maker.getTargetBits(b.PrevBlockHash, b.Height)
+
+	if err != nil {
+		return err
+	}
+
+	b.Bits = bits
+
+	starttime := time.Now()
+
+	pow := NewProofOfWork(b)
+
+	nonce, hash, err := pow.Run()
+
+	if err != nil {
+		return err
+	}
+
+	b.Hash = hash[:]
+	b.Nonce = nonce
+
+	if config.MinimumBlockBuildingTime > 0 {
+		for t := time.Since(starttime).Seconds(); t < float64(config.MinimumBlockBuildingTime); t = time.Since(starttime).Seconds() {
+			time.Sleep(1 * time.Second)
+			e.maker.Logger.Trace.Printf("Sleep")
+		}
+	}
+
+	return nil
+}
+
+// Difficulty must be what is expected for this place in a chain and hash must be correct
+func (e *powEngine) VerifySeal(b *structures.Block) error {
+	err := checkNoValidatorFields(b)
+
+	if err != nil {
+		return err
+	}
+	// It must be done before hash check
+	err = e.maker.checkTargetBits(b)
+
+	if err != nil {
+		return err
+	}
+
+	pow := NewProofOfWork(b)
+
+	valid, err := pow.Validate()
+
+	if err != nil {
+		return err
+	}
+
+	if !valid {
+		return errors.New("Block hash is not valid")
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/utils"
+	"github.com/taincoin/taincoin/lib/wallet"
+	"github.com/taincoin/taincoin/node/config"
+	"github.com/taincoin/taincoin/node/structures"
+)
+
+func TestNewConsensusManager(t *testing.T) {
+	logger := utils.CreateLogger()
+
+	for _, kind := range []string{"pow", "dev", ""} {
+		_, err := NewConsensusManager(config.ConsensusConfig{Kind: kind}, "", "", nil, logger)
+
+		if err != nil {
+			t.Fatalf("Error for engine %s: %s", kind, err.Error())
+		}
+	}
+
+	_, err := NewConsensusManager(config.ConsensusConfig{Kind: "unknown"}, "", "", nil, logger)
+
+	if err == nil {
+		t.Fatalf("Expected error for unknown engine")
+	}
+
+	_, err = NewConsensusManager(config.ConsensusConfig{Kind: "poa"}, "", "", nil, logger)
+
+	if err == nil {
+		t.Fatalf("Expected error for poa engine without validators")
+	}
+}
+
+func TestDevEngineSeal(t *testing.T) {
+	cbtx := &structures.Transaction{}
+
+	err := cbtx.MakeCoinbaseTX("1PZ9kYFt8aUHU5PLT9yLXEgxsGB3RV8dAD", "test", lib.PaymentForBlockMade, 0)
+
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	block := &structures.Block{}
+	block.PrepareNewBlock([]*structures.Transaction{cbtx}, []byte{}, 0)
+
+	engine, _ := newDevEngine(&NodeBlockMaker{})
+
+	err = engine.SealBlock(block)
+
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	err = engine.VerifySeal(block)
+
+	if err != nil {
+		t.Fatalf("Sealed block is not valid: %s", err.Error())
+	}
+
+	block.Timestamp = block.Timestamp + 1
+
+	err = engine.VerifySeal(block)
+
+	if err == nil {
+		t.Fatalf("Changed block must be not valid")
+	}
+}
+
+func TestPoAValidatorsTurn(t *testing.T) {
+	validators := []string{"A", "B", "C"}
+
+	e := &poaEngine{}
+
+	if !e.isAllowedSigner(validators, "C", 0, 0) {
+		t.Fatalf("Any validator can sign genesis block")
+	}
+	if e.isAllowedSigner(validators, "D", 0, 0) {
+		t.Fatalf("Not validator can not sign genesis block")
+	}
+	if !e.isAllowedSigner(validators, "B", 1, 0) || !e.isAllowedSigner(validators, "A", 3, 0) {
+		t.Fatalf("Validator in turn must be allowed")
+	}
+	if e.isAllowedSigner(validators, "A", 1, 0) || e.isAllowedSigner(validators, "C", 4, 0) {
+		t.Fatalf("Validator out of turn must not be allowed")
+	}
+
+	g := structures.GovernanceTransaction{Action: structures.GovernanceAction_AddValidator, Address: "D"}
+	validators = g.Apply(validators)
+
+	if structures.ValidatorsListToString(validators) != "A,B,C,D" {
+		t.Fatalf("Wrong list after adding: %s", structures.ValidatorsListToString(validators))
+	}
+
+	g = structures.GovernanceTransaction{Action: structures.GovernanceAction_RemoveValidator, Address: "B"}
+	validators = g.Apply(validators)
+
+	if structures.ValidatorsListToString(validators) != "A,C,D" {
+		t.Fatalf("Wrong list after removing: %s", structures.ValidatorsListToString(validators))
+	}
+}
+
+func TestPoAOutOfTurnFallback(t *testing.T) {
+	validators := []string{"A", "B", "C"}
+
+	e := &poaEngine{}
+
+	// B is in turn for height 1, C is next, A is last
+	if !e.isAllowedSigner(validators, "B", 1, 0) {
+		t.Fatalf("Validator in turn must be allowed without delay")
+	}
+	if e.isAllowedSigner(validators, "C", 1, config.PoAOutOfTurnDelay-1) {
+		t.Fatalf("Next validator must wait for a validator in turn")
+	}
+	if !e.isAllowedSigner(validators, "C", 1, config.PoAOutOfTurnDelay) {
+		t.Fatalf("Next validator must be allowed when a validator in turn is offline")
+	}
+	if e.isAllowedSigner(validators, "A", 1, config.PoAOutOfTurnDelay) {
+		t.Fatalf("Last validator must wait for all validators before it")
+	}
+	if !e.isAllowedSigner(validators, "A", 1, 2*config.PoAOutOfTurnDelay) {
+		t.Fatalf("Last validator must be allowed when others are offline")
+	}
+	if e.isAllowedSigner(validators, "D", 1, 100*config.PoAOutOfTurnDelay) {
+		t.Fatalf("Not validator can not sign at any time")
+	}
+
+	if e.getRecentSignersLimit(validators) != 1 || e.getRecentSignersLimit([]string{"A"}) != 1 {
+		t.Fatalf("Wrong limit of recent signers")
+	}
+
+	// a validator out of turn never signs 2 blocks in a row
+	if e.getRecentSignersLimit([]string{"A", "B"}) != 1 {
+		t.Fatalf("Wrong limit of recent signers for 2 validators")
+	}
+
+	if e.getRecentSignersLimit([]string{"A", "B", "C", "D", "E"}) != 2 {
+		t.Fatalf("Wrong limit of recent signers for 5 validators")
+	}
+}
+
+func TestPoABlockTimeAhead(t *testing.T) {
+	validators := []string{"A", "B", "C"}
+
+	e := &poaEngine{}
+
+	now := int64(1000000)
+
+	if e.checkSigner(validators, "A", []byte{}, 0, now+config.PoAMaxFutureBlockTime, now) != nil {
+		t.Fatalf("Block time a bit ahead of local time must be allowed")
+	}
+
+	if e.checkSigner(validators, "A", []byte{}, 0, now+config.PoAMaxFutureBlockTime+1, now) == nil {
+		t.Fatalf("Block time far ahead of local time must not be allowed")
+	}
+
+	// a time in the future would let a validator skip the wait
+	if e.checkSigner(validators, "A", []byte{}, 0, now+config.PoAOutOfTurnDelay, now) == nil {
+		t.Fatalf("Block time ahead of local time by out of turn delay must not be allowed")
+	}
+}
+
+func TestPoAVotesThreshold(t *testing.T) {
+	addresses := []string{}
+
+	for i := 0; i < 4; i++ {
+		w := wallet.Wallet{}
+		w.MakeWallet()
+		addresses = append(addresses, string(w.GetAddress()))
+	}
+
+	e := &poaEngine{maker: &NodeBlockMaker{Logger: utils.CreateLogger()}}
+
+	validators := addresses
+
+	// first removing has 3 votes of 4, second one has 2 votes of 4
+	b := &structures.Block{Height: 10}
+	b.Governance = []structures.GovernanceTransaction{
+		structures.GovernanceTransaction{Action: structures.GovernanceAction_RemoveValidator, Address: addresses[0]},
+		structures.GovernanceTransaction{Action: structures.GovernanceAction_RemoveValidator, Address: addresses[1]},
+	}
+
+	history := map[string]map[string]bool{
+		string(b.Governance[0].ToBytes()): map[string]bool{addresses[1]: true, addresses[2]: true},
+		string(b.Governance[1].ToBytes()): map[string]bool{addresses[2]: true},
+	}
+
+	newvalidators := e.applyVotes(b, validators, history, addresses[3])
+
+	// the threshold is counted from 4 validators, not from 3 left after first removing
+	if structures.ValidatorsListToString(newvalidators) != structures.ValidatorsListToString(addresses[1:]) {
+		t.Fatalf("Wrong list after votes: %s", structures.ValidatorsListToString(newvalidators))
+	}
+
+	// votes of not validators are not counted
+	history[string(b.Governance[1].ToBytes())]["1PZ9kYFt8aUHU5PLT9yLXEgxsGB3RV8dAD"] = true
+
+	newvalidators = e.applyVotes(b, validators, history, addresses[3])
+
+	if len(newvalidators) != 3 {
+		t.Fatalf("Votes of not validators must not be counted")
+	}
+
+	history[string(b.Governance[1].ToBytes())][addresses[0]] = true
+
+	newvalidators = e.applyVotes(b, validators, history, addresses[3])
+
+	if structures.ValidatorsListToString(newvalidators) != structures.ValidatorsListToString(addresses[2:]) {
+		t.Fatalf("Removing with 3 votes of 4 must be applied: %s", structures.ValidatorsListToString(newvalidators))
+	}
+}
+
+func TestPoWHeaderVerification(t *testing.T) {
+	cbtx := &structures.Transaction{}
+	cbtx.MakeCoinbaseTX("1PZ9kYFt8aUHU5PLT9yLXEgxsGB3RV8dAD", "test", lib.PaymentForBlockMade, 0)
+
+	block := &structures.Block{}
+	block.PrepareNewBlock([]*structures.Transaction{cbtx}, []byte{1, 2, 3}, 1)
+	block.Bits = 8
+
+	nonce, hash, err := NewProofOfWork(block).Run()
+
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	block.Nonce = nonce
+	block.Hash = hash
+
+	// light clients must get same result as the engine
+	if !utils.VerifyPoWHeader(block.PrevBlockHash, block.MerkleRoot, block.Timestamp, block.Bits, block.Nonce, block.Hash) {
+		t.Fatalf("Header of mined block must be valid")
+	}
+
+	if utils.VerifyPoWHeader(block.PrevBlockHash, block.MerkleRoot, block.Timestamp, block.Bits, block.Nonce+1, block.Hash) {
+		t.Fatalf("Header with other nonce must be not valid")
+	}
+}
@@ -1,7 +1,11 @@
 package consensus
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/taincoin/taincoin/lib/utils"
+	"github.com/taincoin/taincoin/node/config"
 	"github.com/taincoin/taincoin/node/database"
 	"github.com/taincoin/taincoin/node/structures"
 )
@@ -22,10 +26,67 @@ type ConsensusInterface interface {
 	VerifyBlock(block *structures.Block) error
 }
 
-func NewConsensusManager(minter string, DB database.DBManager, Logger *utils.LoggerMan) (ConsensusInterface, error) {
+// Consensus engine. It knows how to seal a block and how to verify a seal of a block.
+// All other work (choose transactions, verify transactions) is same for all engines
+// and is done by NodeBlockMaker
+type ConsensusEngine interface {
+	// Check if this node can make a block on top of given block now
+	IsGoodTimeToMakeBlock(prevBlockHash []byte, height int) (bool, error)
+	// Final work for a prepared block. Sets a hash and engine specific fields
+	SealBlock(block *structures.Block) error
+	// Verify a block hash and engine specific fields
+	VerifySeal(block *structures.Block) error
+}
+
+// Function to create consensus engine for a block maker
+type ConsensusEngineConstructor func(n *NodeBlockMaker) (ConsensusEngine, error)
+
+var engines = map[string]ConsensusEngineConstructor{}
+
+func init() {
+	RegisterEngine("pow", newPoWEngine)
+	RegisterEngine("poa", newPoAEngine)
+	RegisterEngine("dev", newDevEngine)
+}
+
+// Register consensus engine. After this it can be used with this name in a config
+func RegisterEngine(name string, constructor ConsensusEngineConstructor) {
+	engines[name] = constructor
+}
+
+// Check if consensus engine with a name is known
+func EngineExists(name string) bool {
+	_, ok := engines[name]
+	return ok
+}
+
+// Creates block maker object with consensus engine from a config.
+// datadir is used to find validator keys in a wallet file
+func NewConsensusManager(conf config.ConsensusConfig, minter string, datadir string, DB database.DBManager, Logger *utils.LoggerMan) (ConsensusInterface, error) {
+	if conf.IsEmpty() {
+		conf.SetDefault()
+	}
+
+	constructor, ok := engines[conf.Kind]
+
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Unknown consensus engine %s", conf.Kind))
+	}
+
 	bm := &NodeBlockMaker{}
 	bm.DB = DB
 	bm.Logger = Logger
 	bm.MinterAddress = minter
+	bm.DataDir = datadir
+	bm.Config = conf
+
+	engine, err := constructor(bm)
+
+	if err != nil {
+		return nil, err
+	}
+
+	bm.Engine = engine
+
 	return bm, nil
 }
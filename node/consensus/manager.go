@@ -1,10 +1,12 @@
 package consensus
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"time"
 
+	"github.com/taincoin/taincoin/lib"
 	"github.com/taincoin/taincoin/lib/utils"
 	"github.com/taincoin/taincoin/node/blockchain"
 	"github.com/taincoin/taincoin/node/config"
@@ -17,6 +19,9 @@ type NodeBlockMaker struct {
 	DB            database.DBManager
 	Logger        *utils.LoggerMan
 	MinterAddress string // this is the wallet that will receive for mining
+	DataDir       string
+	Config        config.ConsensusConfig
+	Engine        ConsensusEngine // does sealing and seal verification of blocks
 	PreparedBlock *structures.Block
 }
 
@@ -77,10 +82,24 @@ func (n *NodeBlockMaker) getBlockchainManager() *blockchain.Blockchain {
 }
 
 // Checks if this is good time for this node to make a block
-// In this version it is always true
+// It is decided by consensus engine
 
 func (n *NodeBlockMaker) checkGoodTimeToMakeBlock() bool {
-	return true
+	lastHash, lastHeight, err := n.getBlockchainManager().GetState()
+
+	if err != nil {
+		n.Logger.Trace.Printf("Error when check best height: %s", err.Error())
+		return false
+	}
+
+	good, err := n.Engine.IsGoodTimeToMakeBlock(lastHash, lastHeight+1)
+
+	if err != nil {
+		n.Logger.Trace.Printf("Error when check good time to make a block: %s", err.Error())
+		return false
+	}
+
+	return good
 }
 
 // Check if there are abough unapproved transactions to make a block
@@ -159,8 +178,8 @@ func (n *NodeBlockMaker) doPrepareNewBlock() error {
 	return nil
 }
 
-// finalise a block. in this place we do MIMING
-// Block was prepared. Now do final work for this block. In our case it is PoW process
+// finalise a block. Block was prepared. Now do final work for this block.
+// It depends on consensus engine. For PoW it is MIMING
 func (n *NodeBlockMaker) CompleteBlock() (*structures.Block, error) {
 	if n.PreparedBlock == nil {
 		return nil, errors.New("Block was not prepared")
@@ -173,28 +192,14 @@ func (n *NodeBlockMaker) CompleteBlock() (*structures.Block, error) {
 	// it inputs  are not yet stent before
 	// if there is no 2 transaction with same input in one block
 
-	n.Logger.Trace.Printf("Minting: Start proof of work for the block\n")
+	n.Logger.Trace.Printf("Minting: Start sealing of the block with %s engine\n", n.Config.Kind)
 
-	starttime := time.Now()
-
-	pow := NewProofOfWork(b)
-
-	nonce, hash, err := pow.Run()
+	err := n.Engine.SealBlock(b)
 
 	if err != nil {
 		return nil, err
 	}
 
-	b.Hash = hash[:]
-	b.Nonce = nonce
-
-	if config.MinimumBlockBuildingTime > 0 {
-		for t := time.Since(starttime).Seconds(); t < float64(config.MinimumBlockBuildingTime); t = time.Since(starttime).Seconds() {
-			time.Sleep(1 * time.Second)
-			n.Logger.Trace.Printf("Sleep")
-		}
-	}
-
 	n.Logger.Trace.Printf("Minting: New hash is %x\n", b.Hash)
 
 	return b, nil
@@ -210,10 +215,17 @@ func (n *NodeBlockMaker) makeNewBlockFromTransactions(transactions []*structures
 		return nil, err
 	}
 
+	// calculate fees. a miner gets them in a coinbase transaction
+	fees, err := n.getTransactionsFees(transactions, lastHash)
+
+	if err != nil {
+		return nil, err
+	}
+
 	// add transaction - prize for miner
 	cbTx := &structures.Transaction{}
 
-	errc := cbTx.MakeCoinbaseTX(n.MinterAddress, "")
+	errc := cbTx.MakeCoinbaseTX(n.MinterAddress, "", n.Config.GetEmission().GetBlockReward(lastHeight+1), fees)
 
 	if errc != nil {
 		return nil, errc
@@ -235,6 +247,17 @@ func (n *NodeBlockMaker) makeNewBlockFromTransactions(transactions []*structures
 		return nil, err
 	}
 
+	// local clock can be behind times of previous blocks
+	medianTime, err := n.getBlockchainManager().GetMedianTimePast(lastHash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if newblock.Timestamp <= medianTime {
+		newblock.Timestamp = medianTime + 1
+	}
+
 	return &newblock, nil
 }
 
@@ -248,22 +271,43 @@ func (n *NodeBlockMaker) makeNewBlockFromTransactions(transactions []*structures
 //   (output must be before input in same block)
 // 4. all inputs must be in blockchain (correct unspent inputs)
 // 5. Additionally verify each transaction agains signatures, total amount, balance etc
-// 6. Verify hash is correc agains rules
+// 6. Verify hash and seal is correc agains rules of consensus engine
+// 7. Value of coinbase transaction is payment for a block plus fees of all transactions
+//    Payment depends on a height of a block, emission schedule and supply limit
+// 8. Height of a block is next after previous block. Payment depends on it
+// 9. Merkle root in a header is a root of Merkle tree of transactions of a block
+// 10. Time of a block is more than median time of previous blocks and is not far in the future.
+//    Difficulty retarget and time locks depend on it
 func (n *NodeBlockMaker) VerifyBlock(block *structures.Block) error {
 	//6. Verify hash
+	err := n.Engine.VerifySeal(block)
+
+	if err != nil {
+		return err
+	}
+	n.Logger.Trace.Println("block hash verified")
+	// 8.
+	err = n.checkBlockHeight(block)
 
-	pow := NewProofOfWork(block)
+	if err != nil {
+		return err
+	}
+	// 10.
+	err = n.checkBlockTime(block)
 
-	valid, err := pow.Validate()
+	if err != nil {
+		return err
+	}
+	// 9.
+	root, err := block.HashTransactions()
 
 	if err != nil {
 		return err
 	}
 
-	if !valid {
-		return errors.New("Block hash is not valid")
+	if !bytes.Equal(root, block.MerkleRoot) {
+		return errors.New("Merkle root of a block is wrong")
 	}
-	n.Logger.Trace.Println("block hash verified")
 	// 2. check number of TX
 	txnum := len(block.Transactions) - 1 /*minus coinbase TX*/
 
@@ -282,16 +326,18 @@ func (n *NodeBlockMaker) VerifyBlock(block *structures.Block) error {
 	}
 
 	// 1
-	coinbaseused := false
+	var coinbase *structures.Transaction
 
 	prevTXs := []*structures.Transaction{}
 
+	fees := lib.Amount(0)
+
 	for _, tx := range block.Transactions {
 		if tx.IsCoinbase() {
-			if coinbaseused {
+			if coinbase != nil {
 				return errors.New("2 coin base TX in the block")
 			}
-			coinbaseused = true
+			coinbase = tx
 		}
 		vtx, err := n.getTransactionsManager().VerifyTransaction(tx, prevTXs, block.PrevBlockHash)
 
@@ -303,15 +349,87 @@ func (n *NodeBlockMaker) VerifyBlock(block *structures.Block) error {
 			return errors.New(fmt.Sprintf("Transaction in a block is not valid: %x", tx.ID))
 		}
 
+		if !tx.IsCoinbase() {
+			fee, err := n.getTransactionsManager().GetTransactionFee(tx, prevTXs, block.PrevBlockHash)
+
+			if err != nil {
+				return err
+			}
+			fees, err = lib.AddAmounts(fees, fee)
+
+			if err != nil {
+				return err
+			}
+		}
+
 		prevTXs = append(prevTXs, tx)
 	}
 	// 1.
-	if !coinbaseused {
+	if coinbase == nil {
 		return errors.New("No coinbase TX in the block")
 	}
+	// 7.
+	reward := n.Config.GetEmission().GetBlockReward(block.Height)
+
+	if coinbase.Vout[0].Value != reward+fees {
+		return errors.New(fmt.Sprintf("Coinbase value %s is wrong. Expected %s", coinbase.Vout[0].Value, reward+fees))
+	}
+	return nil
+}
+
+// Checks a height of a block is next after a height of previous block
+func (n *NodeBlockMaker) checkBlockHeight(block *structures.Block) error {
+	expected := 0
+
+	if len(block.PrevBlockHash) > 0 {
+		prevBlock, err := n.getBlockchainManager().GetBlock(block.PrevBlockHash)
+
+		if err != nil {
+			return err
+		}
+		expected = prevBlock.Height + 1
+	}
+
+	if block.Height != expected {
+		return errors.New(fmt.Sprintf("Block height %d is wrong. Expected %d", block.Height, expected))
+	}
 	return nil
 }
 
+// Checks a time of a block against median time past of its branch and local time
+func (n *NodeBlockMaker) checkBlockTime(block *structures.Block) error {
+	medianTime, err := n.getBlockchainManager().GetMedianTimePast(block.PrevBlockHash)
+
+	if err != nil {
+		return err
+	}
+	return lib.CheckBlockTime(block.Timestamp, medianTime, time.Now().Unix())
+}
+
+// Returns sum of fees of transactions for new block on top of given block
+func (n *NodeBlockMaker) getTransactionsFees(transactions []*structures.Transaction, tip []byte) (lib.Amount, error) {
+	fees := lib.Amount(0)
+
+	prevTXs := []*structures.Transaction{}
+
+	for _, tx := range transactions {
+		fee, err := n.getTransactionsManager().GetTransactionFee(tx, prevTXs, tip)
+
+		if err != nil {
+			return 0, err
+		}
+		fees, err = lib.AddAmounts(fees, fee)
+
+		if err != nil {
+			return 0, err
+		}
+
+		prevTXs = append(prevTXs, tx)
+	}
+
+	return fees, nil
+}
+
 //Get minimum and maximum number of transaction allowed in block for current chain
 func (n *NodeBlockMaker) getTransactionNumbersLimits(block *structures.Block) (int, int, error) {
 	var min int
@@ -337,3 +455,25 @@ func (n *NodeBlockMaker) getTransactionNumbersLimits(block *structures.Block) (i
 	n.Logger.Trace.Printf("TX count limits %d - %d", min, config.MaxNumberTransactionInBlock)
 	return min, config.MaxNumberTransactionInBlock, nil
 }
+
+// Returns data of a block header common for all consensus engines. It is used to make a block hash
+func getBlockHeaderData(block *structures.Block) ([]byte, error) {
+	data := bytes.Join(
+		[][]byte{
+			block.PrevBlockHash,
+			block.MerkleRoot,
+			utils.IntToHex(block.Timestamp),
+		},
+		[]byte{},
+	)
+
+	return data, nil
+}
+
+// Engines without validators must not have validator fields in a block. They are not part of a hash
+func checkNoValidatorFields(block *structures.Block) error {
+	if len(block.Validator) > 0 || len(block.Signature) > 0 || len(block.Governance) > 0 || len(block.Validators) > 0 {
+		return errors.New("Block can not have validator fields with this consensus")
+	}
+	return nil
+}
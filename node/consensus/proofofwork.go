@@ -1,13 +1,11 @@
 package consensus
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"math"
 	"math/big"
 
 	"github.com/taincoin/taincoin/lib/utils"
-	"github.com/taincoin/taincoin/node/config"
 	"github.com/taincoin/taincoin/node/structures"
 )
 
@@ -23,18 +21,11 @@ type ProofOfWork struct {
 
 // NewProofOfWork builds and returns a ProofOfWork object
 // The object can be used to find a hash for the block
+// Difficulty is taken from the block header
 func NewProofOfWork(b *structures.Block) *ProofOfWork {
 	target := big.NewInt(1)
 
-	var tb int
-
-	if b.Height >= 1000 {
-		tb = config.TargetBits_2
-	} else {
-		tb = config.TargetBits
-	}
-
-	target.Lsh(target, uint(256-tb))
+	target.Lsh(target, uint(256-b.Bits))
 
 	pow := &ProofOfWork{b, target}
 
@@ -44,21 +35,13 @@ func NewProofOfWork(b *structures.Block) *ProofOfWork {
 // Prepares data for next iteration of PoW
 // this will be hashed
 func (pow *ProofOfWork) prepareData() ([]byte, error) {
-	txshash, err := pow.block.HashTransactions()
+	data, err := getBlockHeaderData(pow.block)
 
 	if err != nil {
 		return nil, err
 	}
 
-	data := bytes.Join(
-		[][]byte{
-			pow.block.PrevBlockHash,
-			txshash,
-			utils.IntToHex(pow.block.Timestamp),
-			utils.IntToHex(int64(config.TargetBits)),
-		},
-		[]byte{},
-	)
+	data = append(data, utils.IntToHex(int64(pow.block.Bits))...)
 
 	return data, nil
 }
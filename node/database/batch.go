@@ -0,0 +1,171 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+// Batch of changes of the blockchain database. Objects got while a batch is started
+// keep changes in memory, they are visible to all objects of the manager.
+// All changes are written in one transaction when the batch is committed.
+// Chain pointers, transactions index and unspent outputs are in same database, so
+// adding or removing of a block is saved fully or not saved at all
+
+type batchDB struct {
+	conn kvDB
+	tx   *overlayTx
+}
+
+func newBatchDB(conn kvDB) *batchDB {
+	return &batchDB{conn, newOverlayTx(liveSnapshot{conn}, true)}
+}
+
+// Real connection is closed by a manager
+func (b *batchDB) Close() error {
+	return nil
+}
+
+func (b *batchDB) View(fn func(tx kvTx) error) error {
+	return fn(newOverlayTx(b.tx, false))
+}
+
+// Changes of a failed update are dropped, other changes of the batch stay
+func (b *batchDB) Update(fn func(tx kvTx) error) error {
+	tx := newOverlayTx(b.tx, true)
+
+	err := fn(tx)
+
+	if err != nil {
+		return err
+	}
+
+	b.tx.merge(tx.changes)
+
+	return nil
+}
+
+// Write all changes to the database
+func (b *batchDB) commit() error {
+	if len(b.tx.changes) == 0 {
+		return nil
+	}
+
+	return b.conn.Update(func(tx kvTx) error {
+		for name, c := range b.tx.changes {
+			if c.cleared {
+				err := tx.DeleteBucket(name)
+
+				if err != nil && err != errBucketNotFound {
+					return err
+				}
+			}
+
+			if !c.exists {
+				continue
+			}
+
+			bucket, err := tx.CreateBucketIfNotExists(name)
+
+			if err != nil {
+				return err
+			}
+
+			for k, v := range c.values {
+				if v.deleted {
+					err = bucket.Delete([]byte(k))
+				} else {
+					err = bucket.Put([]byte(k), v.value)
+				}
+
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Reads saved data of a connection. Nobody else can write to the database
+// while a batch is open, a connection holds the lock
+type liveSnapshot struct {
+	conn kvDB
+}
+
+func (s liveSnapshot) bucketExists(name string) bool {
+	exists := false
+
+	s.conn.View(func(tx kvTx) error {
+		exists = tx.Bucket(name) != nil
+		return nil
+	})
+	return exists
+}
+
+func (s liveSnapshot) get(bucket string, key []byte) []byte {
+	var value []byte
+
+	s.conn.View(func(tx kvTx) error {
+		b := tx.Bucket(bucket)
+
+		if b == nil {
+			return nil
+		}
+
+		value = b.Get(key)
+
+		if value != nil {
+			// data of some engines are valid only inside a transaction
+			value = utils.CopyBytes(value)
+		}
+		return nil
+	})
+	return value
+}
+
+func (s liveSnapshot) forEach(bucket string, fn func(k, v []byte) error) error {
+	return s.conn.View(func(tx kvTx) error {
+		b := tx.Bucket(bucket)
+
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(fn)
+	})
+}
+
+// Starts a batch. Objects must be got after this call to be a part of the batch
+func (bdm *kvManager) BeginBatch() error {
+	if bdm.batch != nil {
+		return errors.New("Batch is already started")
+	}
+
+	conn, err := bdm.getConnectionForObject(ClassNameBlockchain)
+
+	if err != nil {
+		return err
+	}
+
+	bdm.batch = newBatchDB(conn)
+
+	return nil
+}
+
+func (bdm *kvManager) CommitBatch() error {
+	if bdm.batch == nil {
+		return errors.New("Batch is not started")
+	}
+
+	batch := bdm.batch
+	bdm.batch = nil
+
+	return batch.commit()
+}
+
+// Drops all changes of a batch
+func (bdm *kvManager) RollbackBatch() error {
+	bdm.batch = nil
+
+	return nil
+}
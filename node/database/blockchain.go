@@ -3,7 +3,6 @@ package database
 import (
 	"bytes"
 
-	"github.com/boltdb/bolt"
 	"github.com/taincoin/taincoin/lib/utils"
 )
 
@@ -11,18 +10,18 @@ const blocksBucket = "blocks"
 const blockChainBucket = "blockchain"
 
 type Blockchain struct {
-	DB *BoltDB
+	DB kvDB
 }
 
 // create bucket etc. DB is already inited
 func (bc *Blockchain) InitDB() error {
-	err := bc.DB.db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucket([]byte(blocksBucket))
+	err := bc.DB.Update(func(tx kvTx) error {
+		_, err := tx.CreateBucket(blocksBucket)
 
 		if err != nil {
 			return err
 		}
-		_, err = tx.CreateBucket([]byte(blockChainBucket))
+		_, err = tx.CreateBucket(blockChainBucket)
 
 		if err != nil {
 			return err
@@ -47,8 +46,8 @@ func (bc *Blockchain) GetTopBlock() ([]byte, error) {
 func (bc *Blockchain) GetBlock(hash []byte) ([]byte, error) {
 	var blockData []byte
 
-	err := bc.DB.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
+	err := bc.DB.View(func(tx kvTx) error {
+		b := tx.Bucket(blocksBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -97,8 +96,8 @@ func (bc *Blockchain) PutBlockOnTop(hash []byte, blockdata []byte) error {
 
 // Add block record
 func (bc *Blockchain) PutBlock(hash []byte, blockdata []byte) error {
-	err := bc.DB.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
+	err := bc.DB.Update(func(tx kvTx) error {
+		b := tx.Bucket(blocksBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -111,8 +110,8 @@ func (bc *Blockchain) PutBlock(hash []byte, blockdata []byte) error {
 
 // Delete block record
 func (bc *Blockchain) DeleteBlock(hash []byte) error {
-	err := bc.DB.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
+	err := bc.DB.Update(func(tx kvTx) error {
+		b := tx.Bucket(blocksBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -125,8 +124,8 @@ func (bc *Blockchain) DeleteBlock(hash []byte) error {
 
 // Save top level block hash
 func (bc *Blockchain) SaveTopHash(hash []byte) error {
-	err := bc.DB.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
+	err := bc.DB.Update(func(tx kvTx) error {
+		b := tx.Bucket(blocksBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -142,8 +141,8 @@ func (bc *Blockchain) SaveTopHash(hash []byte) error {
 func (bc *Blockchain) GetTopHash() ([]byte, error) {
 	var topHash []byte
 
-	err := bc.DB.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
+	err := bc.DB.View(func(tx kvTx) error {
+		b := tx.Bucket(blocksBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -165,10 +164,20 @@ func (bc *Blockchain) GetTopHash() ([]byte, error) {
 	return nil, NewNotFoundDBError("tophash")
 }
 
+// Execute a function for each block record. Records with top and first hashes are skipped
+func (bc *Blockchain) ForEachBlock(callback ForEachKeyIteratorInterface) error {
+	return forEachInBucket(bc.DB, blocksBucket, func(k, v []byte) error {
+		if string(k) == "l" || string(k) == "f" {
+			return nil
+		}
+		return callback(k, v)
+	})
+}
+
 // Save first (or genesis) block hash. It should be called when blockchain is created
 func (bc *Blockchain) SaveFirstHash(hash []byte) error {
-	err := bc.DB.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
+	err := bc.DB.Update(func(tx kvTx) error {
+		b := tx.Bucket(blocksBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -181,8 +190,8 @@ func (bc *Blockchain) SaveFirstHash(hash []byte) error {
 func (bc *Blockchain) GetFirstHash() ([]byte, error) {
 	var firstHash []byte
 
-	err := bc.DB.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
+	err := bc.DB.View(func(tx kvTx) error {
+		b := tx.Bucket(blocksBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -214,8 +223,8 @@ func (bc *Blockchain) AddToChain(hash, prevHash []byte) error {
 
 	emptyHash := make([]byte, length)
 
-	return bc.DB.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blockChainBucket))
+	return bc.DB.Update(func(tx kvTx) error {
+		b := tx.Bucket(blockChainBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -269,8 +278,8 @@ func (bc *Blockchain) RemoveFromChain(hash []byte) error {
 
 	emptyHash := make([]byte, length)
 
-	return bc.DB.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blockChainBucket))
+	return bc.DB.Update(func(tx kvTx) error {
+		b := tx.Bucket(blockChainBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -328,8 +337,8 @@ func (bc *Blockchain) BlockInChain(hash []byte) (bool, error) {
 
 	found := false
 
-	err := bc.DB.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blockChainBucket))
+	err := bc.DB.View(func(tx kvTx) error {
+		b := tx.Bucket(blockChainBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -362,8 +371,8 @@ func (bc *Blockchain) GetLocationInChain(hash []byte) (bool, []byte, []byte, err
 
 	found := false
 
-	err := bc.DB.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blockChainBucket))
+	err := bc.DB.View(func(tx kvTx) error {
+		b := tx.Bucket(blockChainBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -10,8 +10,25 @@ import (
 
 const testFolderName = "testdata"
 
-func getTestDBManagerInited() (*BoltDBManager, error) {
-	obj, err := getTestDBManager()
+// Engines checked by the conformance tests. Every test runs for all of them
+var testEngines = []string{EngineBolt, EngineLevelDB, EngineMemory}
+
+func forEachTestEngine(t *testing.T, test func(t *testing.T, man DBManager)) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			man, err := getTestDBManagerInited(engine)
+
+			defer destroyTestDB(man)
+
+			assert.NoError(t, err, "Can not prepare data")
+
+			test(t, man)
+		})
+	}
+}
+
+func getTestDBManagerInited(engine string) (DBManager, error) {
+	obj, err := getTestDBManager(engine)
 
 	if err != nil {
 		return nil, err
@@ -31,7 +48,7 @@ func getTestDBManagerInited() (*BoltDBManager, error) {
 
 	return obj, nil
 }
-func getTestDBManager() (*BoltDBManager, error) {
+func getTestDBManager(engine string) (DBManager, error) {
 	destroyTestDB(nil)
 
 	err := os.Mkdir(testFolderName, 0744)
@@ -47,16 +64,16 @@ func getTestDBManager() (*BoltDBManager, error) {
 	c := DatabaseConfig{}
 	c.SetDefault()
 	c.DataDir = testFolderName + "/"
+	c.Engine = engine
 
-	obj := &BoltDBManager{}
+	obj := NewDBManager(c, "")
 	obj.SetLockerObject(obj.GetLockerObject())
 	obj.SetLogger(logger)
-	obj.SetConfig(c)
 
 	return obj, nil
 }
 
-func destroyTestDB(man *BoltDBManager) {
+func destroyTestDB(man DBManager) {
 
 	if man != nil {
 		man.CloseConnection()
@@ -68,141 +85,133 @@ func destroyTestDB(man *BoltDBManager) {
 }
 
 func TestBlockChainAdd(t *testing.T) {
-	man, err := getTestDBManagerInited()
+	forEachTestEngine(t, func(t *testing.T, man DBManager) {
+		bcm, err := man.GetBlockchainObject()
 
-	defer destroyTestDB(man)
+		assert.NoError(t, err, "Can not get BC object")
 
-	assert.NoError(t, err, "Can not prepare data")
+		hash1 := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 0}
+		hash2 := []byte{0, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+		hash3 := []byte{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
 
-	bcm, err := man.GetBlockchainObject()
+		err = bcm.AddToChain(hash1, nil)
 
-	assert.NoError(t, err, "Can not get BC object")
+		assert.NoError(t, err, "Adding hash1")
 
-	hash1 := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 0}
-	hash2 := []byte{0, 9, 8, 7, 6, 5, 4, 3, 2, 1}
-	hash3 := []byte{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+		exists, err := bcm.BlockInChain(hash1)
 
-	err = bcm.AddToChain(hash1, nil)
+		assert.NoError(t, err, "Check hash1")
+		assert.True(t, exists, "Block should exist for hash1")
 
-	assert.NoError(t, err, "Adding hash1")
+		err = bcm.AddToChain(hash2, hash1)
 
-	exists, err := bcm.BlockInChain(hash1)
+		assert.NoError(t, err, "Can not add hash2")
 
-	assert.NoError(t, err, "Check hash1")
-	assert.True(t, exists, "Block should exist for hash1")
+		// get state of hash1
 
-	err = bcm.AddToChain(hash2, hash1)
+		exists, prevHash, nextHash, err := bcm.GetLocationInChain(hash1)
 
-	assert.NoError(t, err, "Can not add hash2")
+		assert.NoError(t, err, "Check if in chain hash1 (2)")
+		assert.True(t, exists, "Block should exist fir hash1 (2)")
+		assert.True(t, len(prevHash) == 0, "Prev hash should not be present for hash1")
+		assert.True(t, len(nextHash) > 0, "Next hash should be present hash1")
 
-	// get state of hash1
+		// get state of hash2
+		exists, prevHash, nextHash, err = bcm.GetLocationInChain(hash2)
 
-	exists, prevHash, nextHash, err := bcm.GetLocationInChain(hash1)
+		assert.NoError(t, err, "Check if in chain hash2 (2)")
+		assert.True(t, exists, "Block should exist fir hash2 (2)")
+		assert.True(t, len(prevHash) > 0, "Prev hash should be present for hash2")
+		assert.True(t, len(nextHash) == 0, "Next hash should not be present hash2")
 
-	assert.NoError(t, err, "Check if in chain hash1 (2)")
-	assert.True(t, exists, "Block should exist fir hash1 (2)")
-	assert.True(t, len(prevHash) == 0, "Prev hash should not be present for hash1")
-	assert.True(t, len(nextHash) > 0, "Next hash should be present hash1")
+		err = bcm.AddToChain(hash3, hash2)
 
-	// get state of hash2
-	exists, prevHash, nextHash, err = bcm.GetLocationInChain(hash2)
+		assert.NoError(t, err, "Can not add hash3")
 
-	assert.NoError(t, err, "Check if in chain hash2 (2)")
-	assert.True(t, exists, "Block should exist fir hash2 (2)")
-	assert.True(t, len(prevHash) > 0, "Prev hash should be present for hash2")
-	assert.True(t, len(nextHash) == 0, "Next hash should not be present hash2")
+		// check state of hash 2 now
+		exists, prevHash, nextHash, err = bcm.GetLocationInChain(hash2)
 
-	err = bcm.AddToChain(hash3, hash2)
+		assert.NoError(t, err, "Check if in chain hash2 (3)")
+		assert.True(t, exists, "Block should exist fir hash2 (3)")
+		assert.True(t, len(prevHash) > 0, "Prev hash should be present for hash2 (3)")
+		assert.True(t, len(nextHash) > 0, "Next hash should not be present hash2 (3)")
 
-	assert.NoError(t, err, "Can not add hash3")
+		// check state of hash 3
+		exists, prevHash, nextHash, err = bcm.GetLocationInChain(hash3)
 
-	// check state of hash 2 now
-	exists, prevHash, nextHash, err = bcm.GetLocationInChain(hash2)
+		assert.NoError(t, err, "Check if in chain hash3")
+		assert.True(t, exists, "Block should exist for hash3 ")
+		assert.True(t, len(prevHash) > 0, "Prev hash should be present for hash3")
+		assert.True(t, len(nextHash) == 0, "Next hash should not be present hash3")
 
-	assert.NoError(t, err, "Check if in chain hash2 (3)")
-	assert.True(t, exists, "Block should exist fir hash2 (3)")
-	assert.True(t, len(prevHash) > 0, "Prev hash should be present for hash2 (3)")
-	assert.True(t, len(nextHash) > 0, "Next hash should not be present hash2 (3)")
+		// try to add unexistent previous
+		err = bcm.AddToChain(hash2, []byte{1, 2, 3})
 
-	// check state of hash 3
-	exists, prevHash, nextHash, err = bcm.GetLocationInChain(hash3)
+		assert.Error(t, err, "Error should be on adding over not existent previous")
 
-	assert.NoError(t, err, "Check if in chain hash3")
-	assert.True(t, exists, "Block should exist for hash3 ")
-	assert.True(t, len(prevHash) > 0, "Prev hash should be present for hash3")
-	assert.True(t, len(nextHash) == 0, "Next hash should not be present hash3")
+		// try to add over a hash taht already has next
+		err = bcm.AddToChain(hash1, hash2)
 
-	// try to add unexistent previous
-	err = bcm.AddToChain(hash2, []byte{1, 2, 3})
-
-	assert.Error(t, err, "Error should be on adding over not existent previous")
-
-	// try to add over a hash taht already has next
-	err = bcm.AddToChain(hash1, hash2)
-
-	assert.Error(t, err, "Error should be on adding over hash with existent next hash")
+		assert.Error(t, err, "Error should be on adding over hash with existent next hash")
 
+	})
 }
 
 func TestBlockChainRemove(t *testing.T) {
-	man, err := getTestDBManagerInited()
-
-	defer destroyTestDB(man)
-
-	assert.NoError(t, err, "Can not prepare data")
-
-	bcm, err := man.GetBlockchainObject()
+	forEachTestEngine(t, func(t *testing.T, man DBManager) {
+		bcm, err := man.GetBlockchainObject()
 
-	assert.NoError(t, err, "Can not get BC object")
+		assert.NoError(t, err, "Can not get BC object")
 
-	hash1 := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 0}
-	hash2 := []byte{0, 9, 8, 7, 6, 5, 4, 3, 2, 1}
-	hash3 := []byte{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+		hash1 := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 0}
+		hash2 := []byte{0, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+		hash3 := []byte{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
 
-	bcm.AddToChain(hash1, nil)
-	bcm.AddToChain(hash2, hash1)
-	bcm.AddToChain(hash3, hash2)
+		bcm.AddToChain(hash1, nil)
+		bcm.AddToChain(hash2, hash1)
+		bcm.AddToChain(hash3, hash2)
 
-	// check state of hash 1
-	exists, prevHash, nextHash, err := bcm.GetLocationInChain(hash1)
+		// check state of hash 1
+		exists, prevHash, nextHash, err := bcm.GetLocationInChain(hash1)
 
-	assert.NoError(t, err, "Check if in chain hash1 (2)")
-	assert.True(t, exists, "Block should exist fir hash1 (2)")
-	assert.True(t, len(prevHash) == 0, "Prev hash should not be present for hash1")
-	assert.True(t, len(nextHash) > 0, "Next hash should be present hash1")
+		assert.NoError(t, err, "Check if in chain hash1 (2)")
+		assert.True(t, exists, "Block should exist fir hash1 (2)")
+		assert.True(t, len(prevHash) == 0, "Prev hash should not be present for hash1")
+		assert.True(t, len(nextHash) > 0, "Next hash should be present hash1")
 
-	// check state of hash 2
-	exists, prevHash, nextHash, err = bcm.GetLocationInChain(hash2)
+		// check state of hash 2
+		exists, prevHash, nextHash, err = bcm.GetLocationInChain(hash2)
 
-	assert.NoError(t, err, "Check if in chain hash2 (3)")
-	assert.True(t, exists, "Block should exist fir hash2 (3)")
-	assert.True(t, len(prevHash) > 0, "Prev hash should be present for hash2 (3)")
-	assert.True(t, len(nextHash) > 0, "Next hash should not be present hash2 (3)")
+		assert.NoError(t, err, "Check if in chain hash2 (3)")
+		assert.True(t, exists, "Block should exist fir hash2 (3)")
+		assert.True(t, len(prevHash) > 0, "Prev hash should be present for hash2 (3)")
+		assert.True(t, len(nextHash) > 0, "Next hash should not be present hash2 (3)")
 
-	// check state of hash 3
-	exists, prevHash, nextHash, err = bcm.GetLocationInChain(hash3)
+		// check state of hash 3
+		exists, prevHash, nextHash, err = bcm.GetLocationInChain(hash3)
 
-	assert.NoError(t, err, "Check if in chain hash3")
-	assert.True(t, exists, "Block should exist for hash3 ")
-	assert.True(t, len(prevHash) > 0, "Prev hash should be present for hash3")
-	assert.True(t, len(nextHash) == 0, "Next hash should not be present hash3")
+		assert.NoError(t, err, "Check if in chain hash3")
+		assert.True(t, exists, "Block should exist for hash3 ")
+		assert.True(t, len(prevHash) > 0, "Prev hash should be present for hash3")
+		assert.True(t, len(nextHash) == 0, "Next hash should not be present hash3")
 
-	err = bcm.RemoveFromChain(hash1)
-	assert.Error(t, err, "First hash should not be able to be removed")
+		err = bcm.RemoveFromChain(hash1)
+		assert.Error(t, err, "First hash should not be able to be removed")
 
-	err = bcm.RemoveFromChain(hash3)
-	assert.NoError(t, err, "Last hash must be possible to remove")
+		err = bcm.RemoveFromChain(hash3)
+		assert.NoError(t, err, "Last hash must be possible to remove")
 
-	exists, prevHash, nextHash, err = bcm.GetLocationInChain(hash3)
-	assert.NoError(t, err, "Error loading non existent hash")
+		exists, prevHash, nextHash, err = bcm.GetLocationInChain(hash3)
+		assert.NoError(t, err, "Error loading non existent hash")
 
-	assert.False(t, exists, "Hash3 should not be in chain")
+		assert.False(t, exists, "Hash3 should not be in chain")
 
-	// hash2 now becomes last one
-	exists, prevHash, nextHash, err = bcm.GetLocationInChain(hash2)
+		// hash2 now becomes last one
+		exists, prevHash, nextHash, err = bcm.GetLocationInChain(hash2)
 
-	assert.NoError(t, err, "Check if in chain hash2 (4)")
-	assert.True(t, exists, "Block should exist fir hash2 (4)")
-	assert.True(t, len(prevHash) > 0, "Prev hash should be present for hash2 (3)")
-	assert.True(t, len(nextHash) == 0, "No next hash for hash2")
+		assert.NoError(t, err, "Check if in chain hash2 (4)")
+		assert.True(t, exists, "Block should exist fir hash2 (4)")
+		assert.True(t, len(prevHash) > 0, "Prev hash should be present for hash2 (3)")
+		assert.True(t, len(nextHash) == 0, "No next hash for hash2")
+	})
 }
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// BoltDB engine. Blockchain and nodes are two database files
+type BoltDBManager struct {
+	kvManager
+	locker *DBFileLocker
+}
+
+func NewBoltDBManager() *BoltDBManager {
+	m := &BoltDBManager{}
+	m.engine = m
+	return m
+}
+
+func (bdm *BoltDBManager) GetLockerObject() DatabaseLocker {
+	return newDBFileLocker()
+}
+
+func (bdm *BoltDBManager) SetLockerObject(lockerobj DatabaseLocker) {
+	bdm.locker = lockerobj.(*DBFileLocker)
+}
+
+func (bdm *BoltDBManager) openDB(name string, create bool) (kvDB, error) {
+	boltdbfile, err := bdm.getDBFileForObject(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if bdm.dbExists(boltdbfile) == false && !create {
+		return nil, errors.New(fmt.Sprintf("Database file %s not found", boltdbfile))
+	}
+
+	err = bdm.lockDB(bdm.locker, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(boltdbfile, 0600, &bolt.Options{Timeout: 10 * time.Second})
+
+	if err != nil {
+		bdm.unLockDB(bdm.locker, name)
+		bdm.Logger.Trace.Printf("Error opening DB %s for %s", err.Error(), name)
+		return nil, err
+	}
+
+	return &BoltDB{db, name}, nil
+}
+
+func (bdm *BoltDBManager) closeDB(name string, conn kvDB) {
+	conn.Close()
+	bdm.unLockDB(bdm.locker, name)
+}
@@ -1,9 +1,22 @@
 package database
 
+import (
+	"errors"
+	"fmt"
+)
+
+// Storage engines. Bolt is used if an engine is not set
+const (
+	EngineBolt    = "bolt"
+	EngineLevelDB = "leveldb" // LSM tree, better for large chains
+	EngineMemory  = "memory"  // nothing is saved to disk. Data live while a process runs, it is for tests
+)
+
 type DatabaseConfig struct {
 	DataDir        string
 	BlockchainFile string
 	NodesFile      string
+	Engine         string
 }
 
 func (dbc *DatabaseConfig) IsEmpty() bool {
@@ -18,3 +31,20 @@ func (dbc *DatabaseConfig) SetDefault() error {
 	dbc.NodesFile = "nodeslist.db"
 	return nil
 }
+
+// Returns the engine to use
+func (dbc *DatabaseConfig) GetEngine() string {
+	if dbc.Engine == "" {
+		return EngineBolt
+	}
+	return dbc.Engine
+}
+
+// Checks the engine is known
+func (dbc *DatabaseConfig) CheckEngine() error {
+	switch dbc.GetEngine() {
+	case EngineBolt, EngineLevelDB, EngineMemory:
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Unknown database engine %s", dbc.Engine))
+}
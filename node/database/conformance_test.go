@@ -0,0 +1,392 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+// Same behaviour is expected from every engine
+
+func TestBlockChainBlocks(t *testing.T) {
+	forEachTestEngine(t, func(t *testing.T, man DBManager) {
+		bcm, err := man.GetBlockchainObject()
+
+		assert.NoError(t, err, "Can not get BC object")
+
+		_, err = bcm.GetTopHash()
+		assert.Error(t, err, "Top hash is not set yet")
+
+		exists, err := man.CheckDBExists()
+		assert.NoError(t, err)
+		assert.False(t, exists, "DB is empty")
+
+		hash1 := []byte{1, 1, 1}
+		hash2 := []byte{2, 2, 2}
+
+		assert.NoError(t, bcm.PutBlock(hash1, []byte("block1")))
+		assert.NoError(t, bcm.PutBlockOnTop(hash2, []byte("block2")))
+		assert.NoError(t, bcm.SaveFirstHash(hash1))
+
+		block, err := bcm.GetTopBlock()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("block2"), block)
+
+		first, err := bcm.GetFirstHash()
+		assert.NoError(t, err)
+		assert.Equal(t, hash1, first)
+
+		exists, err = man.CheckDBExists()
+		assert.NoError(t, err)
+		assert.True(t, exists, "DB has top hash")
+
+		exists, err = bcm.CheckBlockExists([]byte{3, 3, 3})
+		assert.NoError(t, err)
+		assert.False(t, exists, "Unknown block")
+
+		blocks := map[string]string{}
+
+		err = bcm.ForEachBlock(func(k, v []byte) error {
+			blocks[string(k)] = string(v)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{string(hash1): "block1", string(hash2): "block2"}, blocks,
+			"Top and first hashes are not blocks")
+
+		assert.NoError(t, bcm.DeleteBlock(hash1))
+
+		block, err = bcm.GetBlock(hash1)
+		assert.NoError(t, err)
+		assert.Nil(t, block, "Block is deleted")
+	})
+}
+
+func TestTransactionsIndex(t *testing.T) {
+	forEachTestEngine(t, func(t *testing.T, man DBManager) {
+		txs, err := man.GetTransactionsObject()
+
+		assert.NoError(t, err)
+
+		txID := []byte("tx1")
+
+		assert.NoError(t, txs.PutTXToBlockLink(txID, []byte("block1")))
+		assert.NoError(t, txs.PutTXSpentOutputs(txID, []byte("outputs")))
+		assert.NoError(t, txs.PutDataToTXLink([]byte("data"), txID))
+
+		blockHash, err := txs.GetBlockHashForTX(txID)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("block1"), blockHash)
+
+		outputs, err := txs.GetTXSpentOutputs(txID)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("outputs"), outputs)
+
+		txIDs, err := txs.GetTXForData([]byte("data"))
+		assert.NoError(t, err)
+		assert.Equal(t, txID, txIDs)
+
+		assert.NoError(t, txs.DeleteTXToBlockLink(txID))
+		assert.NoError(t, txs.DeleteTXSpentData(txID))
+		assert.NoError(t, txs.DeleteDataToTXLink([]byte("data")))
+
+		blockHash, err = txs.GetBlockHashForTX(txID)
+		assert.NoError(t, err)
+		assert.Nil(t, blockHash)
+
+		outputs, err = txs.GetTXSpentOutputs(txID)
+		assert.NoError(t, err)
+		assert.Nil(t, outputs)
+
+		txIDs, err = txs.GetTXForData([]byte("data"))
+		assert.NoError(t, err)
+		assert.Nil(t, txIDs)
+
+		assert.NoError(t, txs.PutTXToBlockLink(txID, []byte("block1")))
+		assert.NoError(t, txs.TruncateDB())
+
+		blockHash, err = txs.GetBlockHashForTX(txID)
+		assert.NoError(t, err)
+		assert.Nil(t, blockHash, "Index is truncated")
+	})
+}
+
+func TestUnapprovedTransactions(t *testing.T) {
+	forEachTestEngine(t, func(t *testing.T, man DBManager) {
+		uts, err := man.GetUnapprovedTransactionsObject()
+
+		assert.NoError(t, err)
+
+		for i := 3; i > 0; i-- {
+			assert.NoError(t, uts.PutTransaction([]byte(fmt.Sprintf("tx%d", i)), []byte(fmt.Sprintf("data%d", i))))
+		}
+
+		count, err := uts.GetCount()
+		assert.NoError(t, err)
+		assert.Equal(t, 3, count)
+
+		tx, err := uts.GetTransaction([]byte("tx2"))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("data2"), tx)
+
+		keys := []string{}
+
+		err = uts.ForEach(func(k, v []byte) error {
+			keys = append(keys, string(k))
+
+			if len(keys) == 2 {
+				return NewDBCursorStopError()
+			}
+			return nil
+		})
+		assert.NoError(t, err, "Break of a loop is not an error")
+		assert.Equal(t, []string{"tx1", "tx2"}, keys, "Keys are sorted")
+
+		assert.NoError(t, uts.DeleteTransaction([]byte("tx2")))
+
+		tx, err = uts.GetTransaction([]byte("tx2"))
+		assert.NoError(t, err)
+		assert.Nil(t, tx)
+
+		assert.NoError(t, uts.TruncateDB())
+
+		count, err = uts.GetCount()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
+
+func TestUnspentOutputs(t *testing.T) {
+	forEachTestEngine(t, func(t *testing.T, man DBManager) {
+		uos, err := man.GetUnspentOutputsObject()
+
+		assert.NoError(t, err)
+
+		assert.NoError(t, uos.PutDataForTransaction([]byte("tx1"), []byte("outputs1")))
+		assert.NoError(t, uos.PutDataForTransaction([]byte("tx2"), []byte("outputs2")))
+		assert.NoError(t, uos.PutDataForTransaction([]byte("tx1"), []byte("outputs3")))
+
+		count, err := uos.GetCount()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		data, err := uos.GetDataForTransaction([]byte("tx1"))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("outputs3"), data, "Data are replaced")
+
+		// a caller can change returned data
+		data[0] = 'X'
+
+		data, err = uos.GetDataForTransaction([]byte("tx1"))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("outputs3"), data, "Saved data are not changed")
+
+		err = uos.ForEach(func(k, v []byte) error {
+			return fmt.Errorf("callback error")
+		})
+		assert.Error(t, err, "Error of a callback is returned")
+
+		assert.NoError(t, uos.DeleteDataForTransaction([]byte("tx1")))
+
+		data, err = uos.GetDataForTransaction([]byte("tx1"))
+		assert.NoError(t, err)
+		assert.Nil(t, data)
+
+		assert.NoError(t, uos.TruncateDB())
+
+		count, err = uos.GetCount()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
+
+func TestNodesList(t *testing.T) {
+	forEachTestEngine(t, func(t *testing.T, man DBManager) {
+		ns, err := man.GetNodesObject()
+
+		assert.NoError(t, err)
+
+		assert.NoError(t, ns.PutNode([]byte("node1"), []byte("addr1")))
+		assert.NoError(t, ns.PutNode([]byte("node2"), []byte("addr2")))
+		assert.NoError(t, ns.DeleteNode([]byte("node1")))
+
+		nodes := map[string]string{}
+
+		err = ns.ForEach(func(k, v []byte) error {
+			nodes[string(k)] = string(v)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"node2": "addr2"}, nodes)
+	})
+}
+
+// Data are kept when a connection is closed and opened again
+func TestReopenConnection(t *testing.T) {
+	forEachTestEngine(t, func(t *testing.T, man DBManager) {
+		bcm, err := man.GetBlockchainObject()
+
+		assert.NoError(t, err)
+		assert.NoError(t, bcm.PutBlockOnTop([]byte{1, 2, 3}, []byte("block")))
+
+		assert.NoError(t, man.CloseConnection())
+		assert.NoError(t, man.OpenConnection("testing"))
+
+		bcm, err = man.GetBlockchainObject()
+		assert.NoError(t, err)
+
+		block, err := bcm.GetTopBlock()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("block"), block)
+	})
+}
+
+func TestMissingDatabase(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			man, err := getTestDBManager(engine)
+
+			defer destroyTestDB(man)
+
+			assert.NoError(t, err)
+			assert.NoError(t, man.OpenConnection("testing"))
+
+			_, err = man.GetBlockchainObject()
+			assert.Error(t, err, "Database is not created")
+
+			exists, err := man.CheckDBExists()
+			assert.NoError(t, err)
+			assert.False(t, exists)
+		})
+	}
+}
+
+// Changes of a failed update are not saved
+func TestUpdateRollback(t *testing.T) {
+	forEachTestEngine(t, func(t *testing.T, man DBManager) {
+		conn, err := man.(interface {
+			getConnectionForObject(name string) (kvDB, error)
+		}).getConnectionForObject(ClassNameBlockchain)
+
+		assert.NoError(t, err)
+
+		err = conn.Update(func(tx kvTx) error {
+			b := tx.Bucket(blocksBucket)
+
+			assert.NoError(t, b.Put([]byte("key"), []byte("value")))
+			assert.Equal(t, []byte("value"), b.Get([]byte("key")), "Change is visible in the transaction")
+
+			_, err := tx.CreateBucket("newbucket")
+			assert.NoError(t, err)
+
+			return fmt.Errorf("rollback")
+		})
+		assert.Error(t, err)
+
+		err = conn.View(func(tx kvTx) error {
+			assert.Nil(t, tx.Bucket(blocksBucket).Get([]byte("key")))
+			assert.Nil(t, tx.Bucket("newbucket"))
+
+			_, err := tx.CreateBucket("other")
+			assert.Error(t, err, "View is read only")
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+}
+
+// Changes of a batch are saved on commit only. All objects of the blockchain DB take part
+func TestBatch(t *testing.T) {
+	forEachTestEngine(t, func(t *testing.T, man DBManager) {
+		assert.NoError(t, man.BeginBatch())
+		assert.Error(t, man.BeginBatch(), "Batch is already started")
+
+		bcm, err := man.GetBlockchainObject()
+		assert.NoError(t, err)
+
+		uos, err := man.GetUnspentOutputsObject()
+		assert.NoError(t, err)
+
+		assert.NoError(t, bcm.PutBlockOnTop([]byte{1, 1, 1}, []byte("block1")))
+		assert.NoError(t, uos.PutDataForTransaction([]byte("tx1"), []byte("outputs1")))
+
+		block, err := bcm.GetTopBlock()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("block1"), block, "Changes are visible in the batch")
+
+		assert.NoError(t, man.RollbackBatch())
+
+		// objects of the batch are not used after it
+		bcm, err = man.GetBlockchainObject()
+		assert.NoError(t, err)
+
+		uos, err = man.GetUnspentOutputsObject()
+		assert.NoError(t, err)
+
+		_, err = bcm.GetTopHash()
+		assert.Error(t, err, "Top hash is not saved")
+
+		data, err := uos.GetDataForTransaction([]byte("tx1"))
+		assert.NoError(t, err)
+		assert.Nil(t, data, "Outputs are not saved")
+
+		assert.NoError(t, man.BeginBatch())
+
+		bcm, err = man.GetBlockchainObject()
+		assert.NoError(t, err)
+
+		uos, err = man.GetUnspentOutputsObject()
+		assert.NoError(t, err)
+
+		assert.NoError(t, bcm.PutBlockOnTop([]byte{2, 2, 2}, []byte("block2")))
+		assert.NoError(t, uos.PutDataForTransaction([]byte("tx2"), []byte("outputs2")))
+		assert.NoError(t, uos.TruncateDB())
+		assert.NoError(t, uos.PutDataForTransaction([]byte("tx3"), []byte("outputs3")))
+
+		assert.NoError(t, man.CommitBatch())
+		assert.Error(t, man.CommitBatch(), "Batch is not started")
+
+		assert.NoError(t, man.CloseConnection())
+		assert.NoError(t, man.OpenConnection("testing"))
+
+		bcm, err = man.GetBlockchainObject()
+		assert.NoError(t, err)
+
+		block, err = bcm.GetTopBlock()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("block2"), block)
+
+		uos, err = man.GetUnspentOutputsObject()
+		assert.NoError(t, err)
+
+		outputs := map[string]string{}
+
+		err = uos.ForEach(func(k, v []byte) error {
+			outputs[string(k)] = string(v)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"tx3": "outputs3"}, outputs, "Truncate is a part of the batch")
+	})
+}
+
+// A batch is dropped if a connection is closed before commit
+func TestBatchClosedConnection(t *testing.T) {
+	forEachTestEngine(t, func(t *testing.T, man DBManager) {
+		assert.NoError(t, man.BeginBatch())
+
+		bcm, err := man.GetBlockchainObject()
+		assert.NoError(t, err)
+		assert.NoError(t, bcm.PutBlockOnTop([]byte{1, 1, 1}, []byte("block1")))
+
+		assert.NoError(t, man.CloseConnection())
+		assert.NoError(t, man.OpenConnection("testing"))
+
+		exists, err := man.CheckDBExists()
+		assert.NoError(t, err)
+		assert.False(t, exists, "Top hash is not saved")
+
+		assert.Error(t, man.CommitBatch())
+	})
+}
@@ -19,54 +19,87 @@ func (bdb *BoltDB) Close() error {
 	return nil
 }
 
-func (bdb *BoltDB) forEachInBucket(bucket string, callback ForEachKeyIteratorInterface) error {
+func (bdb *BoltDB) View(fn func(tx kvTx) error) error {
 	return bdb.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(bucket))
-
-		if b == nil {
-			return NewDBIsNotReadyError()
-		}
-		// we don't use ForEach because we need a way to break
-		c := b.Cursor()
-
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			err := callback(k, v)
-
-			if err, ok := err.(*DBError); ok {
-				if err.IsKind(DBCursorBreak) {
-					// the function wants to break the loop
-					return nil
-				}
-			}
-
-			if err != nil {
-				return err
-			}
-		}
-		return nil
+		return fn(boltTx{tx})
 	})
 }
 
-func (bdb *BoltDB) getCountInBucket(bucket string) (int, error) {
-	count := 0
+func (bdb *BoltDB) Update(fn func(tx kvTx) error) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		return fn(boltTx{tx})
+	})
+}
 
-	err := bdb.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(bucket))
+type boltTx struct {
+	tx *bolt.Tx
+}
 
-		if b == nil {
-			return NewDBIsNotReadyError()
-		}
-		// we don't use ForEach because we need a way to break
-		c := b.Cursor()
+func (t boltTx) Bucket(name string) kvBucket {
+	b := t.tx.Bucket([]byte(name))
 
-		for k, _ := c.First(); k != nil; k, _ = c.Next() {
-			count++
-		}
+	if b == nil {
 		return nil
-	})
+	}
+	return boltBucket{b}
+}
+
+func (t boltTx) CreateBucket(name string) (kvBucket, error) {
+	b, err := t.tx.CreateBucket([]byte(name))
+
+	if err == bolt.ErrBucketExists {
+		return nil, errBucketExists
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return boltBucket{b}, nil
+}
+
+func (t boltTx) CreateBucketIfNotExists(name string) (kvBucket, error) {
+	b, err := t.tx.CreateBucketIfNotExists([]byte(name))
 
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return count, nil
+	return boltBucket{b}, nil
+}
+
+func (t boltTx) DeleteBucket(name string) error {
+	err := t.tx.DeleteBucket([]byte(name))
+
+	if err == bolt.ErrBucketNotFound {
+		return errBucketNotFound
+	}
+	return err
+}
+
+type boltBucket struct {
+	b *bolt.Bucket
+}
+
+func (b boltBucket) Get(key []byte) []byte {
+	return b.b.Get(key)
+}
+
+func (b boltBucket) Put(key []byte, value []byte) error {
+	return b.b.Put(key, value)
+}
+
+func (b boltBucket) Delete(key []byte) error {
+	return b.b.Delete(key)
+}
+
+func (b boltBucket) ForEach(fn func(k, v []byte) error) error {
+	c := b.b.Cursor()
+
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		err := fn(k, v)
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/taincoin/taincoin/lib/utils"
+	"github.com/taincoin/taincoin/node/metrics"
+)
+
+// Engines with database files lock them with lock files. A node server and a command
+// can work with same files, only one process can use them at a time.
+// Goroutines of one process are serialized with mutexes of the shared locker object
+type DBFileLocker struct {
+	lockBC    *sync.Mutex
+	lockNodes *sync.Mutex
+}
+
+func newDBFileLocker() *DBFileLocker {
+	locker := &DBFileLocker{}
+	locker.lockBC = &sync.Mutex{}
+	locker.lockNodes = &sync.Mutex{}
+
+	return locker
+}
+
+// Creates a lock file for DB access. We need this to controll parallel access to the DB
+func (m *kvManager) lockDB(fileLocker *DBFileLocker, name string) error {
+	locksess := m.SessID
+
+	if locksess == "" {
+		locksess = utils.RandString(5)
+		//m.Logger.Trace.Println(string(debug.Stack()))
+	}
+
+	var locker *sync.Mutex
+
+	dbname := ClassNameBlockchain
+
+	if m.isNodesDB(name) {
+		locker = fileLocker.lockNodes
+		dbname = ClassNameNodes
+	} else {
+		locker = fileLocker.lockBC
+	}
+
+	waitstart := time.Now()
+
+	locker.Lock()
+
+	lockfile, err := m.getDBLockFileForObject(name)
+
+	if err != nil {
+		locker.Unlock()
+		return err
+	}
+
+	i := 0
+
+	info, err := os.Stat(lockfile)
+
+	if err == nil {
+		t := time.Since(info.ModTime())
+
+		// this is for case when something goes very wrong , process fails and lock is not removed
+		if t.Minutes() > 60 {
+			os.Remove(lockfile)
+		}
+	}
+
+	for m.dbExists(lockfile) != false {
+
+		if i > 5000 {
+			time.Sleep(1 * time.Second)
+		} else {
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		i++
+
+		if i > 10000 {
+			locker.Unlock()
+			m.Logger.Trace.Println("too long lock. return with error")
+			return errors.New("Can not open DB. Lock failed after many attempts")
+		}
+	}
+
+	metrics.DBLockWait.WithLabelValues(dbname).Observe(time.Since(waitstart).Seconds())
+
+	file, err := os.Create(lockfile)
+
+	if err != nil {
+		locker.Unlock()
+		return err
+	}
+
+	defer file.Close()
+
+	starttime := time.Now().UTC().UnixNano()
+
+	_, err = file.WriteString(strconv.Itoa(int(starttime)))
+
+	if err != nil {
+		locker.Unlock()
+		return err
+	}
+
+	file.WriteString(" " + locksess)
+
+	file.Sync() // flush to disk
+
+	return nil
+}
+
+// Removes DB lock file
+func (m *kvManager) unLockDB(fileLocker *DBFileLocker, name string) {
+
+	var locker *sync.Mutex
+
+	if m.isNodesDB(name) {
+		locker = fileLocker.lockNodes
+	} else {
+		locker = fileLocker.lockBC
+	}
+
+	lockfile, err := m.getDBLockFileForObject(name)
+
+	if err != nil {
+		locker.Unlock()
+		return
+	}
+	if m.dbExists(lockfile) != false {
+		/*
+			lockinfobytes, err := ioutil.ReadFile(lockfile)
+
+			if err == nil {
+				lockinfo := string(lockinfobytes)
+
+				parts := strings.Split(lockinfo, " ")
+
+				starttime, err := strconv.Atoi(parts[0])
+
+				if err == nil {
+					duration := time.Since(time.Unix(0, int64(starttime)))
+					ms := duration.Nanoseconds() / int64(time.Millisecond)
+					m.Logger.Trace.Printf("UnLocked %s after %d ms , %s", name, ms, parts[1])
+				}
+			}
+		*/
+		os.Remove(lockfile)
+	}
+	locker.Unlock()
+}
+
+func (m *kvManager) getDBLockFileForObject(name string) (string, error) {
+	dbfileName, err := m.getDBFileForObject(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	// replace extension to .lock
+	ext := path.Ext(dbfileName)
+	dbfileName = dbfileName[0:len(dbfileName)-len(ext)] + ".lock"
+	return dbfileName, nil
+}
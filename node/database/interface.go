@@ -17,6 +17,12 @@ type DBManager interface {
 	CloseConnection() error
 	IsConnectionOpen() bool
 
+	// Changes of blockchain objects made after BeginBatch are saved together on commit.
+	// Closing of a connection drops a batch
+	BeginBatch() error
+	CommitBatch() error
+	RollbackBatch() error
+
 	GetBlockchainObject() (BlockchainInterface, error)
 	GetTransactionsObject() (TranactionsInterface, error)
 	GetUnapprovedTransactionsObject() (UnapprovedTransactionsInterface, error)
@@ -46,6 +52,7 @@ type BlockchainInterface interface {
 	GetTopHash() ([]byte, error)
 	SaveFirstHash(hash []byte) error
 	GetFirstHash() ([]byte, error)
+	ForEachBlock(callback ForEachKeyIteratorInterface) error
 
 	GetLocationInChain(hash []byte) (bool, []byte, []byte, error)
 	BlockInChain(hash []byte) (bool, error)
@@ -62,6 +69,9 @@ type TranactionsInterface interface {
 	PutTXSpentOutputs(txID []byte, outputs []byte) error
 	GetTXSpentOutputs(txID []byte) ([]byte, error)
 	DeleteTXSpentData(txID []byte) error
+	PutDataToTXLink(data []byte, txIDs []byte) error
+	GetTXForData(data []byte) ([]byte, error)
+	DeleteDataToTXLink(data []byte) error
 }
 
 type UnapprovedTransactionsInterface interface {
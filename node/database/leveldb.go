@@ -0,0 +1,208 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+// LevelDB engine. It is a LSM tree, writes don't rewrite pages of a big file, so it works
+// better than bolt for large chains. A database is a directory named as a bolt file
+// with .ldb extension. Buckets are prefixes of keys
+type LevelDBManager struct {
+	kvManager
+	locker *DBFileLocker
+}
+
+func NewLevelDBManager() *LevelDBManager {
+	m := &LevelDBManager{}
+	m.engine = m
+	return m
+}
+
+func (ldm *LevelDBManager) GetLockerObject() DatabaseLocker {
+	return newDBFileLocker()
+}
+
+func (ldm *LevelDBManager) SetLockerObject(lockerobj DatabaseLocker) {
+	ldm.locker = lockerobj.(*DBFileLocker)
+}
+
+func (ldm *LevelDBManager) getDBDirForObject(name string) (string, error) {
+	dbfileName, err := ldm.getDBFileForObject(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	ext := path.Ext(dbfileName)
+	return dbfileName[0:len(dbfileName)-len(ext)] + ".ldb", nil
+}
+
+func (ldm *LevelDBManager) openDB(name string, create bool) (kvDB, error) {
+	dir, err := ldm.getDBDirForObject(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ldm.dbExists(dir) && !create {
+		return nil, errors.New(fmt.Sprintf("Database %s not found", dir))
+	}
+
+	err = ldm.lockDB(ldm.locker, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := leveldb.OpenFile(dir, &opt.Options{ErrorIfMissing: !create})
+
+	if err != nil {
+		ldm.unLockDB(ldm.locker, name)
+		ldm.Logger.Trace.Printf("Error opening DB %s for %s", err.Error(), name)
+		return nil, err
+	}
+
+	return &levelDB{db}, nil
+}
+
+func (ldm *LevelDBManager) closeDB(name string, conn kvDB) {
+	conn.Close()
+	ldm.unLockDB(ldm.locker, name)
+}
+
+type levelDB struct {
+	db *leveldb.DB
+}
+
+func (ldb *levelDB) Close() error {
+	if ldb.db == nil {
+		return nil
+	}
+	ldb.db.Close()
+	ldb.db = nil
+
+	return nil
+}
+
+func (ldb *levelDB) View(fn func(tx kvTx) error) error {
+	snapshot, err := ldb.db.GetSnapshot()
+
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+
+	return fn(newOverlayTx(levelSnapshot{snapshot}, false))
+}
+
+func (ldb *levelDB) Update(fn func(tx kvTx) error) error {
+	snapshot, err := ldb.db.GetSnapshot()
+
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+
+	tx := newOverlayTx(levelSnapshot{snapshot}, true)
+
+	err = fn(tx)
+
+	if err != nil {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+
+	for name, c := range tx.changes {
+		if c.cleared {
+			iter := snapshot.NewIterator(util.BytesPrefix(levelBucketPrefix(name)), nil)
+
+			for iter.Next() {
+				batch.Delete(utils.CopyBytes(iter.Key()))
+			}
+			iter.Release()
+
+			err = iter.Error()
+
+			if err != nil {
+				return err
+			}
+			batch.Delete(levelBucketMarker(name))
+		}
+
+		if !c.exists {
+			continue
+		}
+
+		batch.Put(levelBucketMarker(name), []byte{})
+
+		for k, v := range c.values {
+			if v.deleted {
+				batch.Delete(levelKey(name, []byte(k)))
+			} else {
+				batch.Put(levelKey(name, []byte(k)), v.value)
+			}
+		}
+	}
+
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	return ldb.db.Write(batch, &opt.WriteOptions{Sync: true})
+}
+
+// Keys of a bucket start with its name and 0 byte. A bucket exists if it has a marker key
+func levelBucketPrefix(bucket string) []byte {
+	return append([]byte(bucket), 0)
+}
+
+func levelBucketMarker(bucket string) []byte {
+	return append([]byte{0}, []byte(bucket)...)
+}
+
+func levelKey(bucket string, key []byte) []byte {
+	return append(levelBucketPrefix(bucket), key...)
+}
+
+type levelSnapshot struct {
+	snapshot *leveldb.Snapshot
+}
+
+func (s levelSnapshot) bucketExists(name string) bool {
+	ok, err := s.snapshot.Has(levelBucketMarker(name), nil)
+
+	return err == nil && ok
+}
+
+func (s levelSnapshot) get(bucket string, key []byte) []byte {
+	value, err := s.snapshot.Get(levelKey(bucket, key), nil)
+
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+func (s levelSnapshot) forEach(bucket string, fn func(k, v []byte) error) error {
+	prefix := levelBucketPrefix(bucket)
+
+	iter := s.snapshot.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		err := fn(iter.Key()[len(prefix):], iter.Value())
+
+		if err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
@@ -2,15 +2,8 @@ package database
 
 import (
 	"errors"
-	"fmt"
 	"os"
-	"path"
 
-	"strconv"
-	"sync"
-	"time"
-
-	"github.com/boltdb/bolt"
 	"github.com/taincoin/taincoin/lib/utils"
 )
 
@@ -22,44 +15,59 @@ const (
 	ClassNameUnspentOutputs         = "unspentoutputs"
 )
 
-type BoltDBManager struct {
+// Common part of all engines. An engine only opens and closes databases
+type kvManager struct {
 	Logger     *utils.LoggerMan
 	Config     DatabaseConfig
-	connBC     *BoltDB
-	connNodes  *BoltDB
-	openedConn bool
-	locker     *BoltDBLocker
 	SessID     string
+	engine     kvEngine
+	connBC     kvDB
+	connNodes  kvDB
+	openedConn bool
+	batch      *batchDB
 }
 
-type BoltDBLocker struct {
-	lockBC    *sync.Mutex
-	lockNodes *sync.Mutex
+type kvEngine interface {
+	// Opens a database of an object. A database is created if it doesn't exist and create is true
+	openDB(name string, create bool) (kvDB, error)
+	closeDB(name string, conn kvDB)
 }
 
-func (bdm *BoltDBManager) GetLockerObject() DatabaseLocker {
-	locker := &BoltDBLocker{}
-	locker.lockBC = &sync.Mutex{}
-	locker.lockNodes = &sync.Mutex{}
+// Returns a manager of the engine set in the config. Session ID is written to lock files
+func NewDBManager(config DatabaseConfig, sessID string) DBManager {
+	var man *kvManager
+	var result DBManager
 
-	return locker
-}
+	switch config.GetEngine() {
+	case EngineMemory:
+		m := NewMemoryDBManager()
+		man, result = &m.kvManager, m
+	case EngineLevelDB:
+		m := NewLevelDBManager()
+		man, result = &m.kvManager, m
+	default:
+		m := NewBoltDBManager()
+		man, result = &m.kvManager, m
+	}
+
+	man.SessID = sessID
+	man.SetConfig(config)
 
-func (bdm *BoltDBManager) SetLockerObject(lockerobj DatabaseLocker) {
-	bdm.locker = lockerobj.(*BoltDBLocker)
+	return result
 }
-func (bdm *BoltDBManager) SetConfig(config DatabaseConfig) error {
+
+func (bdm *kvManager) SetConfig(config DatabaseConfig) error {
 	bdm.Config = config
 
 	return nil
 }
-func (bdm *BoltDBManager) SetLogger(logger *utils.LoggerMan) error {
+func (bdm *kvManager) SetLogger(logger *utils.LoggerMan) error {
 	bdm.Logger = logger
 
 	return nil
 }
 
-func (bdm *BoltDBManager) OpenConnection(reason string) error {
+func (bdm *kvManager) OpenConnection(reason string) error {
 	//bdm.Logger.Trace.Println("open connection for " + reason)
 	if bdm.openedConn {
 		return nil
@@ -72,19 +80,20 @@ func (bdm *BoltDBManager) OpenConnection(reason string) error {
 
 	return nil
 }
-func (bdm *BoltDBManager) CloseConnection() error {
+func (bdm *kvManager) CloseConnection() error {
 	if !bdm.openedConn {
 		return nil
 	}
 
+	// changes not committed are lost
+	bdm.batch = nil
+
 	if bdm.connBC != nil {
-		bdm.connBC.Close()
-		bdm.unLockDB(bdm.connBC.lockFile)
+		bdm.engine.closeDB(ClassNameBlockchain, bdm.connBC)
 		bdm.connBC = nil
 	}
 	if bdm.connNodes != nil {
-		bdm.connNodes.Close()
-		bdm.unLockDB(bdm.connNodes.lockFile)
+		bdm.engine.closeDB(ClassNameNodes, bdm.connNodes)
 		bdm.connNodes = nil
 	}
 
@@ -92,12 +101,12 @@ func (bdm *BoltDBManager) CloseConnection() error {
 	return nil
 }
 
-func (bdm *BoltDBManager) IsConnectionOpen() bool {
+func (bdm *kvManager) IsConnectionOpen() bool {
 	return bdm.openedConn
 }
 
 // create empty database. must create all
-func (bdm *BoltDBManager) InitDatabase() error {
+func (bdm *kvManager) InitDatabase() error {
 
 	bdm.OpenConnection("InitBC")
 
@@ -179,7 +188,7 @@ func (bdm *BoltDBManager) InitDatabase() error {
 }
 
 // Check if database was already inited
-func (bdm *BoltDBManager) CheckDBExists() (bool, error) {
+func (bdm *kvManager) CheckDBExists() (bool, error) {
 	bc, err := bdm.GetBlockchainObject()
 
 	if err != nil {
@@ -200,7 +209,7 @@ func (bdm *BoltDBManager) CheckDBExists() (bool, error) {
 }
 
 // returns BlockChain Database structure. does al init
-func (bdm *BoltDBManager) GetBlockchainObject() (BlockchainInterface, error) {
+func (bdm *kvManager) GetBlockchainObject() (BlockchainInterface, error) {
 	conn, err := bdm.getConnectionForObject(ClassNameBlockchain)
 
 	if err != nil {
@@ -214,7 +223,7 @@ func (bdm *BoltDBManager) GetBlockchainObject() (BlockchainInterface, error) {
 }
 
 // returns Transaction Index Database structure. does al init
-func (bdm *BoltDBManager) GetTransactionsObject() (TranactionsInterface, error) {
+func (bdm *kvManager) GetTransactionsObject() (TranactionsInterface, error) {
 	conn, err := bdm.getConnectionForObject(ClassNameTransactions)
 
 	if err != nil {
@@ -228,7 +237,7 @@ func (bdm *BoltDBManager) GetTransactionsObject() (TranactionsInterface, error)
 }
 
 // returns Unapproved Transaction Database structure. does al init
-func (bdm *BoltDBManager) GetUnapprovedTransactionsObject() (UnapprovedTransactionsInterface, error) {
+func (bdm *kvManager) GetUnapprovedTransactionsObject() (UnapprovedTransactionsInterface, error) {
 	conn, err := bdm.getConnectionForObject(ClassNameUnspentOutputs)
 
 	if err != nil {
@@ -242,7 +251,7 @@ func (bdm *BoltDBManager) GetUnapprovedTransactionsObject() (UnapprovedTransacti
 }
 
 // returns Unspent Transactions Database structure. does al init
-func (bdm *BoltDBManager) GetUnspentOutputsObject() (UnspentOutputsInterface, error) {
+func (bdm *kvManager) GetUnspentOutputsObject() (UnspentOutputsInterface, error) {
 	conn, err := bdm.getConnectionForObject(ClassNameUnapprovedTransactions)
 
 	if err != nil {
@@ -256,7 +265,7 @@ func (bdm *BoltDBManager) GetUnspentOutputsObject() (UnspentOutputsInterface, er
 }
 
 // returns Nodes Database structure. does al init
-func (bdm *BoltDBManager) GetNodesObject() (NodesInterface, error) {
+func (bdm *kvManager) GetNodesObject() (NodesInterface, error) {
 	conn, err := bdm.getConnectionForObject(ClassNameNodes)
 
 	if err != nil {
@@ -270,16 +279,20 @@ func (bdm *BoltDBManager) GetNodesObject() (NodesInterface, error) {
 }
 
 // returns
-func (bdm *BoltDBManager) getConnectionForObject(name string) (*BoltDB, error) {
+func (bdm *kvManager) getConnectionForObject(name string) (kvDB, error) {
 	return bdm.getConnectionForObjectWithCheck(name, false)
 }
 
 // returns DB connection, creates it if needed .
-func (bdm *BoltDBManager) getConnectionForObjectWithCheck(name string, ignoremissed bool) (*BoltDB, error) {
+func (bdm *kvManager) getConnectionForObjectWithCheck(name string, ignoremissed bool) (kvDB, error) {
 	if !bdm.openedConn {
 		return nil, errors.New("Connection was not inited")
 	}
 
+	if bdm.isBCDB(name) && bdm.batch != nil {
+		return bdm.batch, nil
+	}
+
 	if bdm.isBCDB(name) && bdm.connBC != nil {
 		//bdm.Logger.Trace.Println("bc connection exists. rteurn it")
 		return bdm.connBC, nil
@@ -290,162 +303,24 @@ func (bdm *BoltDBManager) getConnectionForObjectWithCheck(name string, ignoremis
 	}
 
 	// create new connection
-	boltdbfile, err := bdm.getDBFileForObject(name)
+	conn, err := bdm.engine.openDB(name, ignoremissed)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if bdm.dbExists(boltdbfile) == false && !ignoremissed {
-		return nil, errors.New(fmt.Sprintf("Database file %s not found", boltdbfile))
-	}
-
-	err = bdm.lockDB(name, bdm.SessID)
-
-	if err != nil {
-		return nil, err
-	}
-
-	db, err := bolt.Open(boltdbfile, 0600, &bolt.Options{Timeout: 10 * time.Second})
-
-	if err != nil {
-		bdm.Logger.Trace.Printf("Error opening DB %s for %s", err.Error(), name)
-		return nil, err
-	}
-
-	// if success create object and assign connection
-	boltDB := BoltDB{db, name}
-
 	if bdm.isBCDB(name) {
-		bdm.connBC = &boltDB
-	}
-
-	if bdm.isNodesDB(name) {
-		bdm.connNodes = &boltDB
+		bdm.connBC = conn
 	}
 
-	return &boltDB, nil
-}
-
-// Creates a lock file for DB access. We need this to controll parallel access to the DB
-func (bdm *BoltDBManager) lockDB(name string, locksess string) error {
-	if locksess == "" {
-		locksess = utils.RandString(5)
-		//bdm.Logger.Trace.Println(string(debug.Stack()))
-	}
-
-	var locker *sync.Mutex
-
 	if bdm.isNodesDB(name) {
-		locker = bdm.locker.lockNodes
-	} else {
-		locker = bdm.locker.lockBC
-	}
-
-	locker.Lock()
-
-	lockfile, err := bdm.getDBLockFileForObject(name)
-
-	if err != nil {
-		locker.Unlock()
-		return err
-	}
-
-	i := 0
-
-	info, err := os.Stat(lockfile)
-
-	if err == nil {
-		t := time.Since(info.ModTime())
-
-		// this is for case when something goes very wrong , process fails and lock is not removed
-		if t.Minutes() > 60 {
-			os.Remove(lockfile)
-		}
-	}
-
-	for bdm.dbExists(lockfile) != false {
-
-		if i > 5000 {
-			time.Sleep(1 * time.Second)
-		} else {
-			time.Sleep(50 * time.Millisecond)
-		}
-
-		i++
-
-		if i > 10000 {
-			locker.Unlock()
-			bdm.Logger.Trace.Println("too long lock. return with error")
-			return errors.New("Can not open DB. Lock failed after many attempts")
-		}
-	}
-
-	file, err := os.Create(lockfile)
-
-	if err != nil {
-		locker.Unlock()
-		return err
+		bdm.connNodes = conn
 	}
 
-	defer file.Close()
-
-	starttime := time.Now().UTC().UnixNano()
-
-	_, err = file.WriteString(strconv.Itoa(int(starttime)))
-
-	if err != nil {
-		locker.Unlock()
-		return err
-	}
-
-	file.WriteString(" " + locksess)
-
-	file.Sync() // flush to disk
-
-	return nil
+	return conn, nil
 }
 
-// Removes DB lock file
-func (bdm *BoltDBManager) unLockDB(name string) {
-
-	var locker *sync.Mutex
-
-	if bdm.isNodesDB(name) {
-		locker = bdm.locker.lockNodes
-	} else {
-		locker = bdm.locker.lockBC
-	}
-
-	lockfile, err := bdm.getDBLockFileForObject(name)
-
-	if err != nil {
-		locker.Unlock()
-		return
-	}
-	if bdm.dbExists(lockfile) != false {
-		/*
-			lockinfobytes, err := ioutil.ReadFile(lockfile)
-
-			if err == nil {
-				lockinfo := string(lockinfobytes)
-
-				parts := strings.Split(lockinfo, " ")
-
-				starttime, err := strconv.Atoi(parts[0])
-
-				if err == nil {
-					duration := time.Since(time.Unix(0, int64(starttime)))
-					ms := duration.Nanoseconds() / int64(time.Millisecond)
-					bdm.Logger.Trace.Printf("UnLocked %s after %d ms , %s", name, ms, parts[1])
-				}
-			}
-		*/
-		os.Remove(lockfile)
-	}
-	locker.Unlock()
-}
-func (bdm *BoltDBManager) getDBFileForObject(name string) (string, error) {
+func (bdm *kvManager) getDBFileForObject(name string) (string, error) {
 	switch name {
 	case ClassNameNodes:
 		return bdm.Config.DataDir + bdm.Config.NodesFile, nil
@@ -455,34 +330,21 @@ func (bdm *BoltDBManager) getDBFileForObject(name string) (string, error) {
 	return "", errors.New("Unknown DB object name " + name)
 }
 
-func (bdm *BoltDBManager) getDBLockFileForObject(name string) (string, error) {
-	dbfileName, err := bdm.getDBFileForObject(name)
-
-	if err != nil {
-		return "", err
-	}
-
-	// replace extension to .lock
-	ext := path.Ext(dbfileName)
-	dbfileName = dbfileName[0:len(dbfileName)-len(ext)] + ".lock"
-	return dbfileName, nil
-}
-
-func (bdm *BoltDBManager) isBCDB(name string) bool {
+func (bdm *kvManager) isBCDB(name string) bool {
 	switch name {
 	case ClassNameBlockchain, ClassNameTransactions, ClassNameUnapprovedTransactions, ClassNameUnspentOutputs:
 		return true
 	}
 	return false
 }
-func (bdm *BoltDBManager) isNodesDB(name string) bool {
+func (bdm *kvManager) isNodesDB(name string) bool {
 	if ClassNameNodes == name {
 		return true
 	}
 	return false
 }
 
-func (bdm *BoltDBManager) dbExists(dbFile string) bool {
+func (bdm *kvManager) dbExists(dbFile string) bool {
 	if _, err := os.Stat(dbFile); os.IsNotExist(err) {
 		return false
 	}
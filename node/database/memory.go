@@ -0,0 +1,178 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+// In-memory engine. Nothing is saved to disk, databases live in the locker object,
+// so they are shared by all connections of a process and lost when it exits. It is used in tests
+type MemoryDBManager struct {
+	kvManager
+	locker *MemoryDBLocker
+}
+
+type MemoryDBLocker struct {
+	lockBC    *sync.Mutex
+	lockNodes *sync.Mutex
+	bc        *memoryStore
+	nodes     *memoryStore
+}
+
+func NewMemoryDBManager() *MemoryDBManager {
+	m := &MemoryDBManager{}
+	m.engine = m
+	return m
+}
+
+func (mdm *MemoryDBManager) GetLockerObject() DatabaseLocker {
+	locker := &MemoryDBLocker{}
+	locker.lockBC = &sync.Mutex{}
+	locker.lockNodes = &sync.Mutex{}
+
+	return locker
+}
+
+func (mdm *MemoryDBManager) SetLockerObject(lockerobj DatabaseLocker) {
+	mdm.locker = lockerobj.(*MemoryDBLocker)
+}
+
+func (mdm *MemoryDBManager) openDB(name string, create bool) (kvDB, error) {
+	locker := mdm.locker.lockBC
+	store := &mdm.locker.bc
+
+	if mdm.isNodesDB(name) {
+		locker = mdm.locker.lockNodes
+		store = &mdm.locker.nodes
+	}
+
+	locker.Lock()
+
+	if *store == nil {
+		if !create {
+			locker.Unlock()
+			return nil, errors.New(fmt.Sprintf("Database %s not found", name))
+		}
+		*store = newMemoryStore()
+	}
+
+	return &memoryDB{*store}, nil
+}
+
+func (mdm *MemoryDBManager) closeDB(name string, conn kvDB) {
+	conn.Close()
+
+	if mdm.isNodesDB(name) {
+		mdm.locker.lockNodes.Unlock()
+	} else {
+		mdm.locker.lockBC.Unlock()
+	}
+}
+
+// Records of a database. A bucket is a map of keys to values
+type memoryStore struct {
+	lock    *sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{&sync.RWMutex{}, map[string]map[string][]byte{}}
+}
+
+func (ms *memoryStore) bucketExists(name string) bool {
+	_, ok := ms.buckets[name]
+	return ok
+}
+
+func (ms *memoryStore) get(bucket string, key []byte) []byte {
+	value, ok := ms.buckets[bucket][string(key)]
+
+	if !ok {
+		return nil
+	}
+	// a caller can change data. the stored copy must stay same
+	return utils.CopyBytes(value)
+}
+
+func (ms *memoryStore) forEach(bucket string, fn func(k, v []byte) error) error {
+	b := ms.buckets[bucket]
+
+	keys := make([]string, 0, len(b))
+
+	for k := range b {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		err := fn([]byte(k), utils.CopyBytes(b[k]))
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms *memoryStore) apply(changes map[string]*bucketChanges) {
+	for name, c := range changes {
+		if c.cleared {
+			delete(ms.buckets, name)
+		}
+
+		if !c.exists {
+			continue
+		}
+
+		b, ok := ms.buckets[name]
+
+		if !ok {
+			b = map[string][]byte{}
+			ms.buckets[name] = b
+		}
+
+		for k, v := range c.values {
+			if v.deleted {
+				delete(b, k)
+			} else {
+				b[k] = v.value
+			}
+		}
+	}
+}
+
+type memoryDB struct {
+	store *memoryStore
+}
+
+func (mdb *memoryDB) Close() error {
+	return nil
+}
+
+func (mdb *memoryDB) View(fn func(tx kvTx) error) error {
+	mdb.store.lock.RLock()
+	defer mdb.store.lock.RUnlock()
+
+	return fn(newOverlayTx(mdb.store, false))
+}
+
+func (mdb *memoryDB) Update(fn func(tx kvTx) error) error {
+	mdb.store.lock.Lock()
+	defer mdb.store.lock.Unlock()
+
+	tx := newOverlayTx(mdb.store, true)
+
+	err := fn(tx)
+
+	if err != nil {
+		return err
+	}
+
+	mdb.store.apply(tx.changes)
+
+	return nil
+}
@@ -1,18 +1,14 @@
 package database
 
-import (
-	"github.com/boltdb/bolt"
-)
-
 const nodesBucket = "nodes"
 
 type Nodes struct {
-	DB *BoltDB
+	DB kvDB
 }
 
 func (ns *Nodes) InitDB() error {
-	err := ns.DB.db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucket([]byte(nodesBucket))
+	err := ns.DB.Update(func(tx kvTx) error {
+		_, err := tx.CreateBucket(nodesBucket)
 
 		if err != nil {
 			return err
@@ -28,18 +24,18 @@ func (ns *Nodes) InitDB() error {
 
 // retrns nodes list iterator
 func (ns *Nodes) ForEach(callback ForEachKeyIteratorInterface) error {
-	return ns.DB.forEachInBucket(nodesBucket, callback)
+	return forEachInBucket(ns.DB, nodesBucket, callback)
 }
 
 // get count of records in the table
 func (ns *Nodes) GetCount() (int, error) {
-	return ns.DB.getCountInBucket(nodesBucket)
+	return getCountInBucket(ns.DB, nodesBucket)
 }
 
 // Save node info
 func (ns *Nodes) PutNode(nodeID []byte, nodeData []byte) error {
-	return ns.DB.db.Update(func(txDB *bolt.Tx) error {
-		b := txDB.Bucket([]byte(nodesBucket))
+	return ns.DB.Update(func(txDB kvTx) error {
+		b := txDB.Bucket(nodesBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -49,8 +45,8 @@ func (ns *Nodes) PutNode(nodeID []byte, nodeData []byte) error {
 }
 
 func (ns *Nodes) DeleteNode(nodeID []byte) error {
-	return ns.DB.db.Update(func(txDB *bolt.Tx) error {
-		b := txDB.Bucket([]byte(nodesBucket))
+	return ns.DB.Update(func(txDB kvTx) error {
+		b := txDB.Bucket(nodesBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -0,0 +1,224 @@
+package database
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/taincoin/taincoin/lib/utils"
+)
+
+// Transactions for engines that can only read saved data and write a set of changes at once.
+// Changes are kept in memory and visible in the transaction. They are written on commit
+
+// Saved data of an engine
+type kvSnapshot interface {
+	bucketExists(name string) bool
+	get(bucket string, key []byte) []byte
+	// keys must be in sorted order
+	forEach(bucket string, fn func(k, v []byte) error) error
+}
+
+// Changes of a bucket made in a transaction
+type bucketChanges struct {
+	exists  bool // bucket exists after changes
+	cleared bool // bucket was deleted, saved records are not used
+	values  map[string]*changedValue
+}
+
+type changedValue struct {
+	value   []byte
+	deleted bool
+}
+
+type overlayTx struct {
+	base     kvSnapshot
+	writable bool
+	changes  map[string]*bucketChanges
+}
+
+func newOverlayTx(base kvSnapshot, writable bool) *overlayTx {
+	return &overlayTx{base, writable, map[string]*bucketChanges{}}
+}
+
+func (tx *overlayTx) bucketExists(name string) bool {
+	if c, ok := tx.changes[name]; ok {
+		return c.exists
+	}
+	return tx.base.bucketExists(name)
+}
+
+func (tx *overlayTx) getChanges(name string) *bucketChanges {
+	c, ok := tx.changes[name]
+
+	if !ok {
+		c = &bucketChanges{tx.base.bucketExists(name), false, map[string]*changedValue{}}
+		tx.changes[name] = c
+	}
+	return c
+}
+
+func (tx *overlayTx) Bucket(name string) kvBucket {
+	if !tx.bucketExists(name) {
+		return nil
+	}
+	return &overlayBucket{tx, name}
+}
+
+func (tx *overlayTx) CreateBucket(name string) (kvBucket, error) {
+	if !tx.writable {
+		return nil, errTxNotWritable
+	}
+
+	if name == "" {
+		return nil, errors.New("Bucket name is empty")
+	}
+
+	if tx.bucketExists(name) {
+		return nil, errBucketExists
+	}
+
+	tx.getChanges(name).exists = true
+
+	return &overlayBucket{tx, name}, nil
+}
+
+func (tx *overlayTx) CreateBucketIfNotExists(name string) (kvBucket, error) {
+	if tx.bucketExists(name) {
+		return &overlayBucket{tx, name}, nil
+	}
+	return tx.CreateBucket(name)
+}
+
+func (tx *overlayTx) DeleteBucket(name string) error {
+	if !tx.writable {
+		return errTxNotWritable
+	}
+
+	if !tx.bucketExists(name) {
+		return errBucketNotFound
+	}
+
+	c := tx.getChanges(name)
+	c.exists = false
+	c.cleared = true
+	c.values = map[string]*changedValue{}
+
+	return nil
+}
+
+type overlayBucket struct {
+	tx   *overlayTx
+	name string
+}
+
+func (b *overlayBucket) Get(key []byte) []byte {
+	if c, ok := b.tx.changes[b.name]; ok {
+		if v, ok := c.values[string(key)]; ok {
+			if v.deleted {
+				return nil
+			}
+			return v.value
+		}
+
+		if c.cleared {
+			return nil
+		}
+	}
+	return b.tx.base.get(b.name, key)
+}
+
+func (b *overlayBucket) Put(key []byte, value []byte) error {
+	if !b.tx.writable {
+		return errTxNotWritable
+	}
+
+	if len(key) == 0 {
+		return errors.New("Key is empty")
+	}
+
+	b.tx.getChanges(b.name).values[string(key)] = &changedValue{utils.CopyBytes(value), false}
+
+	return nil
+}
+
+func (b *overlayBucket) Delete(key []byte) error {
+	if !b.tx.writable {
+		return errTxNotWritable
+	}
+
+	b.tx.getChanges(b.name).values[string(key)] = &changedValue{nil, true}
+
+	return nil
+}
+
+func (b *overlayBucket) ForEach(fn func(k, v []byte) error) error {
+	c, ok := b.tx.changes[b.name]
+
+	if !ok {
+		// nothing changed. read saved records directly
+		return b.tx.base.forEach(b.name, fn)
+	}
+
+	records := map[string][]byte{}
+
+	if !c.cleared {
+		err := b.tx.base.forEach(b.name, func(k, v []byte) error {
+			records[string(k)] = utils.CopyBytes(v)
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for k, v := range c.values {
+		if v.deleted {
+			delete(records, k)
+		} else {
+			records[k] = v.value
+		}
+	}
+
+	keys := make([]string, 0, len(records))
+
+	for k := range records {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		err := fn([]byte(k), records[k])
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A transaction is saved data for a nested transaction of a batch
+func (tx *overlayTx) get(bucket string, key []byte) []byte {
+	return (&overlayBucket{tx, bucket}).Get(key)
+}
+
+func (tx *overlayTx) forEach(bucket string, fn func(k, v []byte) error) error {
+	return (&overlayBucket{tx, bucket}).ForEach(fn)
+}
+
+// Adds changes of a nested transaction
+func (tx *overlayTx) merge(changes map[string]*bucketChanges) {
+	for name, c := range changes {
+		p := tx.getChanges(name)
+
+		if c.cleared {
+			p.cleared = true
+			p.values = map[string]*changedValue{}
+		}
+		p.exists = c.exists
+
+		for k, v := range c.values {
+			p.values[k] = v
+		}
+	}
+}
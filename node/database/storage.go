@@ -0,0 +1,80 @@
+package database
+
+import (
+	"errors"
+)
+
+// Objects of the database keep records in buckets of keys and values. Every engine
+// gives same buckets API, so objects work same way with any engine.
+// All changes made in one Update call are saved together or not saved at all
+
+var (
+	errBucketExists   = errors.New("Bucket already exists")
+	errBucketNotFound = errors.New("Bucket is not found")
+	errTxNotWritable  = errors.New("Transaction is read only")
+)
+
+type kvDB interface {
+	DatabaseConnection
+	View(fn func(tx kvTx) error) error
+	Update(fn func(tx kvTx) error) error
+}
+
+type kvTx interface {
+	// Returns nil if a bucket doesn't exist
+	Bucket(name string) kvBucket
+	CreateBucket(name string) (kvBucket, error)
+	CreateBucketIfNotExists(name string) (kvBucket, error)
+	DeleteBucket(name string) error
+}
+
+type kvBucket interface {
+	// Returns nil if a key is not found. Data are valid only inside a transaction
+	Get(key []byte) []byte
+	Put(key []byte, value []byte) error
+	Delete(key []byte) error
+	// Calls a function for keys in sorted order. Stops on first error and returns it
+	ForEach(fn func(k, v []byte) error) error
+}
+
+func forEachInBucket(db kvDB, bucket string, callback ForEachKeyIteratorInterface) error {
+	return db.View(func(tx kvTx) error {
+		b := tx.Bucket(bucket)
+
+		if b == nil {
+			return NewDBIsNotReadyError()
+		}
+
+		err := b.ForEach(callback)
+
+		if err, ok := err.(*DBError); ok {
+			if err.IsKind(DBCursorBreak) {
+				// the function wants to break the loop
+				return nil
+			}
+		}
+		return err
+	})
+}
+
+func getCountInBucket(db kvDB, bucket string) (int, error) {
+	count := 0
+
+	err := db.View(func(tx kvTx) error {
+		b := tx.Bucket(bucket)
+
+		if b == nil {
+			return NewDBIsNotReadyError()
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			count++
+			return nil
+		})
+	})
+
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -1,20 +1,21 @@
 package database
 
 import (
-	"github.com/boltdb/bolt"
+	"github.com/taincoin/taincoin/lib/utils"
 )
 
 const transactionsBucket = "transactions"
 const transactionsOutputsBucket = "transactionsoutputs"
+const transactionsDataBucket = "transactionsdata"
 
 type Tranactions struct {
-	DB *BoltDB
+	DB kvDB
 }
 
 // Init database
 func (txs *Tranactions) InitDB() error {
-	err := txs.DB.db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucket([]byte(transactionsBucket))
+	err := txs.DB.Update(func(tx kvTx) error {
+		_, err := tx.CreateBucket(transactionsBucket)
 
 		if err != nil {
 			return err
@@ -24,8 +25,19 @@ func (txs *Tranactions) InitDB() error {
 	if err != nil {
 		return err
 	}
-	err = txs.DB.db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucket([]byte(transactionsOutputsBucket))
+	err = txs.DB.Update(func(tx kvTx) error {
+		_, err := tx.CreateBucket(transactionsOutputsBucket)
+
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = txs.DB.Update(func(tx kvTx) error {
+		_, err := tx.CreateBucket(transactionsDataBucket)
 
 		if err != nil {
 			return err
@@ -38,14 +50,14 @@ func (txs *Tranactions) InitDB() error {
 	return nil
 }
 func (txs *Tranactions) TruncateDB() error {
-	err := txs.DB.db.Update(func(tx *bolt.Tx) error {
-		err := tx.DeleteBucket([]byte(transactionsBucket))
+	err := txs.DB.Update(func(tx kvTx) error {
+		err := tx.DeleteBucket(transactionsBucket)
 
-		if err != nil && err != bolt.ErrBucketNotFound {
+		if err != nil && err != errBucketNotFound {
 			return err
 		}
 
-		_, err = tx.CreateBucket([]byte(transactionsBucket))
+		_, err = tx.CreateBucket(transactionsBucket)
 
 		if err != nil {
 			return err
@@ -55,14 +67,31 @@ func (txs *Tranactions) TruncateDB() error {
 	if err != nil {
 		return err
 	}
-	err = txs.DB.db.Update(func(tx *bolt.Tx) error {
-		err := tx.DeleteBucket([]byte(transactionsOutputsBucket))
+	err = txs.DB.Update(func(tx kvTx) error {
+		err := tx.DeleteBucket(transactionsOutputsBucket)
 
-		if err != nil && err != bolt.ErrBucketNotFound {
+		if err != nil && err != errBucketNotFound {
 			return err
 		}
 
-		_, err = tx.CreateBucket([]byte(transactionsOutputsBucket))
+		_, err = tx.CreateBucket(transactionsOutputsBucket)
+
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = txs.DB.Update(func(tx kvTx) error {
+		err := tx.DeleteBucket(transactionsDataBucket)
+
+		if err != nil && err != errBucketNotFound {
+			return err
+		}
+
+		_, err = tx.CreateBucket(transactionsDataBucket)
 
 		if err != nil {
 			return err
@@ -77,8 +106,8 @@ func (txs *Tranactions) TruncateDB() error {
 
 // Save link between TX and block hash
 func (txs *Tranactions) PutTXToBlockLink(txID []byte, blockHash []byte) error {
-	return txs.DB.db.Update(func(txDB *bolt.Tx) error {
-		b := txDB.Bucket([]byte(transactionsBucket))
+	return txs.DB.Update(func(txDB kvTx) error {
+		b := txDB.Bucket(transactionsBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -91,8 +120,8 @@ func (txs *Tranactions) PutTXToBlockLink(txID []byte, blockHash []byte) error {
 func (txs *Tranactions) GetBlockHashForTX(txID []byte) ([]byte, error) {
 	var blockHash []byte
 
-	err := txs.DB.db.View(func(txDB *bolt.Tx) error {
-		b := txDB.Bucket([]byte(transactionsBucket))
+	err := txs.DB.View(func(txDB kvTx) error {
+		b := txDB.Bucket(transactionsBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -100,6 +129,10 @@ func (txs *Tranactions) GetBlockHashForTX(txID []byte) ([]byte, error) {
 
 		blockHash = b.Get(txID)
 
+		if len(blockHash) > 0 {
+			blockHash = utils.CopyBytes(blockHash)
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -110,8 +143,8 @@ func (txs *Tranactions) GetBlockHashForTX(txID []byte) ([]byte, error) {
 
 // Delete link between TX and a block hash
 func (txs *Tranactions) DeleteTXToBlockLink(txID []byte) error {
-	return txs.DB.db.Update(func(txDB *bolt.Tx) error {
-		b := txDB.Bucket([]byte(transactionsBucket))
+	return txs.DB.Update(func(txDB kvTx) error {
+		b := txDB.Bucket(transactionsBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -122,8 +155,8 @@ func (txs *Tranactions) DeleteTXToBlockLink(txID []byte) error {
 
 // Save spent outputs for TX
 func (txs *Tranactions) PutTXSpentOutputs(txID []byte, outputs []byte) error {
-	return txs.DB.db.Update(func(txDB *bolt.Tx) error {
-		b := txDB.Bucket([]byte(transactionsOutputsBucket))
+	return txs.DB.Update(func(txDB kvTx) error {
+		b := txDB.Bucket(transactionsOutputsBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -136,8 +169,8 @@ func (txs *Tranactions) PutTXSpentOutputs(txID []byte, outputs []byte) error {
 func (txs *Tranactions) GetTXSpentOutputs(txID []byte) ([]byte, error) {
 	var outputsData []byte
 
-	err := txs.DB.db.View(func(txDB *bolt.Tx) error {
-		b := txDB.Bucket([]byte(transactionsOutputsBucket))
+	err := txs.DB.View(func(txDB kvTx) error {
+		b := txDB.Bucket(transactionsOutputsBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -145,6 +178,10 @@ func (txs *Tranactions) GetTXSpentOutputs(txID []byte) ([]byte, error) {
 
 		outputsData = b.Get(txID)
 
+		if len(outputsData) > 0 {
+			outputsData = utils.CopyBytes(outputsData)
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -155,8 +192,8 @@ func (txs *Tranactions) GetTXSpentOutputs(txID []byte) ([]byte, error) {
 
 // Delete info about spent outputs for TX
 func (txs *Tranactions) DeleteTXSpentData(txID []byte) error {
-	return txs.DB.db.Update(func(txDB *bolt.Tx) error {
-		b := txDB.Bucket([]byte(transactionsOutputsBucket))
+	return txs.DB.Update(func(txDB kvTx) error {
+		b := txDB.Bucket(transactionsOutputsBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -164,3 +201,54 @@ func (txs *Tranactions) DeleteTXSpentData(txID []byte) error {
 		return b.Delete(txID)
 	})
 }
+
+// Save list of transactions having a data output
+// The bucket is created if it is not there. Databases made by older versions don't have it
+func (txs *Tranactions) PutDataToTXLink(data []byte, txIDs []byte) error {
+	return txs.DB.Update(func(txDB kvTx) error {
+		b, err := txDB.CreateBucketIfNotExists(transactionsDataBucket)
+
+		if err != nil {
+			return err
+		}
+		return b.Put(data, txIDs)
+	})
+}
+
+// Get list of transactions having a data output
+func (txs *Tranactions) GetTXForData(data []byte) ([]byte, error) {
+	var txIDs []byte
+
+	err := txs.DB.View(func(txDB kvTx) error {
+		b := txDB.Bucket(transactionsDataBucket)
+
+		if b == nil {
+			// nothing was saved yet
+			return nil
+		}
+
+		txIDs = b.Get(data)
+
+		if len(txIDs) > 0 {
+			txIDs = utils.CopyBytes(txIDs)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return txIDs, nil
+}
+
+// Delete link between data and transactions
+func (txs *Tranactions) DeleteDataToTXLink(data []byte) error {
+	return txs.DB.Update(func(txDB kvTx) error {
+		b := txDB.Bucket(transactionsDataBucket)
+
+		if b == nil {
+			return nil
+		}
+		return b.Delete(data)
+	})
+}
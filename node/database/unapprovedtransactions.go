@@ -1,18 +1,18 @@
 package database
 
 import (
-	"github.com/boltdb/bolt"
+	"github.com/taincoin/taincoin/lib/utils"
 )
 
 const unapprovedTransactionsBucket = "unapprovedtransactions"
 
 type UnapprovedTransactions struct {
-	DB *BoltDB
+	DB kvDB
 }
 
 func (uts *UnapprovedTransactions) InitDB() error {
-	err := uts.DB.db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucket([]byte(unapprovedTransactionsBucket))
+	err := uts.DB.Update(func(tx kvTx) error {
+		_, err := tx.CreateBucket(unapprovedTransactionsBucket)
 
 		if err != nil {
 			return err
@@ -28,23 +28,23 @@ func (uts *UnapprovedTransactions) InitDB() error {
 
 // execute functon for each key/value in the bucket
 func (uts *UnapprovedTransactions) ForEach(callback ForEachKeyIteratorInterface) error {
-	return uts.DB.forEachInBucket(unapprovedTransactionsBucket, callback)
+	return forEachInBucket(uts.DB, unapprovedTransactionsBucket, callback)
 }
 
 // get count of records in the table
 func (uts *UnapprovedTransactions) GetCount() (int, error) {
-	return uts.DB.getCountInBucket(unapprovedTransactionsBucket)
+	return getCountInBucket(uts.DB, unapprovedTransactionsBucket)
 }
 
 func (uts *UnapprovedTransactions) TruncateDB() error {
-	err := uts.DB.db.Update(func(tx *bolt.Tx) error {
-		err := tx.DeleteBucket([]byte(unapprovedTransactionsBucket))
+	err := uts.DB.Update(func(tx kvTx) error {
+		err := tx.DeleteBucket(unapprovedTransactionsBucket)
 
-		if err != nil && err != bolt.ErrBucketNotFound {
+		if err != nil && err != errBucketNotFound {
 			return err
 		}
 
-		_, err = tx.CreateBucket([]byte(unapprovedTransactionsBucket))
+		_, err = tx.CreateBucket(unapprovedTransactionsBucket)
 
 		if err != nil {
 			return err
@@ -61,8 +61,8 @@ func (uts *UnapprovedTransactions) TruncateDB() error {
 func (uts *UnapprovedTransactions) GetTransaction(txID []byte) ([]byte, error) {
 	var txBytes []byte
 
-	err := uts.DB.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(unapprovedTransactionsBucket))
+	err := uts.DB.View(func(tx kvTx) error {
+		b := tx.Bucket(unapprovedTransactionsBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -70,6 +70,10 @@ func (uts *UnapprovedTransactions) GetTransaction(txID []byte) ([]byte, error) {
 
 		txBytes = b.Get(txID)
 
+		if len(txBytes) > 0 {
+			txBytes = utils.CopyBytes(txBytes)
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -80,8 +84,8 @@ func (uts *UnapprovedTransactions) GetTransaction(txID []byte) ([]byte, error) {
 
 // Add transaction record
 func (uts *UnapprovedTransactions) PutTransaction(txID []byte, txdata []byte) error {
-	return uts.DB.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(unapprovedTransactionsBucket))
+	return uts.DB.Update(func(tx kvTx) error {
+		b := tx.Bucket(unapprovedTransactionsBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -93,8 +97,8 @@ func (uts *UnapprovedTransactions) PutTransaction(txID []byte, txdata []byte) er
 
 // delete transation from DB
 func (uts *UnapprovedTransactions) DeleteTransaction(txID []byte) error {
-	return uts.DB.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(unapprovedTransactionsBucket))
+	return uts.DB.Update(func(tx kvTx) error {
+		b := tx.Bucket(unapprovedTransactionsBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -1,40 +1,40 @@
 package database
 
 import (
-	"github.com/boltdb/bolt"
+	"github.com/taincoin/taincoin/lib/utils"
 )
 
 const unspentTransactionsBucket = "unspentoutputstransactions"
 
 type UnspentOutputs struct {
-	DB *BoltDB
+	DB kvDB
 }
 
 func (uos *UnspentOutputs) InitDB() error {
-	return uos.DB.db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucket([]byte(unspentTransactionsBucket))
+	return uos.DB.Update(func(tx kvTx) error {
+		_, err := tx.CreateBucket(unspentTransactionsBucket)
 		return err
 	})
 }
 
 // execute functon for each key/value in the bucket
 func (uos *UnspentOutputs) ForEach(callback ForEachKeyIteratorInterface) error {
-	return uos.DB.forEachInBucket(unspentTransactionsBucket, callback)
+	return forEachInBucket(uos.DB, unspentTransactionsBucket, callback)
 }
 
 // get count of records in the table
 func (uos *UnspentOutputs) GetCount() (int, error) {
-	return uos.DB.getCountInBucket(unspentTransactionsBucket)
+	return getCountInBucket(uos.DB, unspentTransactionsBucket)
 }
 
 func (uos *UnspentOutputs) TruncateDB() error {
-	return uos.DB.db.Update(func(tx *bolt.Tx) error {
-		err := tx.DeleteBucket([]byte(unspentTransactionsBucket))
+	return uos.DB.Update(func(tx kvTx) error {
+		err := tx.DeleteBucket(unspentTransactionsBucket)
 
 		if err != nil {
 			return err
 		}
-		_, err = tx.CreateBucket([]byte(unspentTransactionsBucket))
+		_, err = tx.CreateBucket(unspentTransactionsBucket)
 
 		return err
 	})
@@ -43,8 +43,8 @@ func (uos *UnspentOutputs) TruncateDB() error {
 func (uos *UnspentOutputs) GetDataForTransaction(txID []byte) ([]byte, error) {
 	var txData []byte
 
-	err := uos.DB.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(unspentTransactionsBucket))
+	err := uos.DB.View(func(tx kvTx) error {
+		b := tx.Bucket(unspentTransactionsBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -52,6 +52,10 @@ func (uos *UnspentOutputs) GetDataForTransaction(txID []byte) ([]byte, error) {
 
 		txData = b.Get(txID)
 
+		if len(txData) > 0 {
+			txData = utils.CopyBytes(txData)
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -61,8 +65,8 @@ func (uos *UnspentOutputs) GetDataForTransaction(txID []byte) ([]byte, error) {
 }
 
 func (uos *UnspentOutputs) DeleteDataForTransaction(txID []byte) error {
-	return uos.DB.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(unspentTransactionsBucket))
+	return uos.DB.Update(func(tx kvTx) error {
+		b := tx.Bucket(unspentTransactionsBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
@@ -72,8 +76,8 @@ func (uos *UnspentOutputs) DeleteDataForTransaction(txID []byte) error {
 	})
 }
 func (uos *UnspentOutputs) PutDataForTransaction(txID []byte, txData []byte) error {
-	return uos.DB.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(unspentTransactionsBucket))
+	return uos.DB.Update(func(tx kvTx) error {
+		b := tx.Bucket(unspentTransactionsBucket)
 
 		if b == nil {
 			return NewDBIsNotReadyError()
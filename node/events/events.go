@@ -0,0 +1,115 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/taincoin/taincoin/lib"
+)
+
+// Kinds of events
+const (
+	KindBlock       = "block"       // new block on top of the main chain
+	KindReorg       = "reorg"       // main chain was switched to other branch
+	KindTransaction = "transaction" // new transaction in the pool of unapproved transactions
+	KindPayment     = "payment"     // output to an address. In a block of the main chain or in the pool
+)
+
+// size of a subscriber channel. Events are dropped for a subscriber that doesn't read them
+const subscriberBuffer = 100
+
+// Something happened in a node. Only fields related to a kind are set
+type Event struct {
+	Kind      string
+	BlockHash []byte
+	Height    int
+	PrevTop   []byte // top before a reorg
+	Removed   int    // number of blocks removed from the main chain in a reorg
+	Added     int    // number of blocks added to the main chain in a reorg
+	TXID      []byte
+	Vout      int
+	Address   string
+	Amount    lib.Amount
+	Confirmed bool // a transaction is in a block
+}
+
+// Something that events are sent to. It is a bus or a queue
+type Publisher interface {
+	Publish(e Event)
+}
+
+// Publish/subscribe for node events. A nil bus is allowed, then events are not published
+type Bus struct {
+	lock        *sync.Mutex
+	subscribers map[int]chan Event
+	lastID      int
+}
+
+func NewBus() *Bus {
+	return &Bus{&sync.Mutex{}, map[int]chan Event{}, 0}
+}
+
+// Adds a subscriber. Returns ID to unsubscribe and a channel with events
+func (b *Bus) Subscribe() (int, <-chan Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.lastID++
+
+	ch := make(chan Event, subscriberBuffer)
+	b.subscribers[b.lastID] = ch
+
+	return b.lastID, ch
+}
+
+// Removes a subscriber and closes its channel
+func (b *Bus) Unsubscribe(id int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if ch, ok := b.subscribers[id]; ok {
+		close(ch)
+		delete(b.subscribers, id)
+	}
+}
+
+// Sends an event to all subscribers. Never waits for a subscriber
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Events collected to publish later. It is used while changes of the DB are not saved yet.
+// Events are published only if changes are saved, so subscribers never see changes that were
+// rolled back
+type Queue struct {
+	bus    *Bus
+	events []Event
+}
+
+func NewQueue(b *Bus) *Queue {
+	return &Queue{b, []Event{}}
+}
+
+// Keeps an event until Flush
+func (q *Queue) Publish(e Event) {
+	q.events = append(q.events, e)
+}
+
+// Publishes all kept events to the bus
+func (q *Queue) Flush() {
+	for _, e := range q.events {
+		q.bus.Publish(e)
+	}
+	q.events = []Event{}
+}
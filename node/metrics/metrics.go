@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "taincoin"
+
+// Counters and timers updated by a node
+var (
+	CommandsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "network_commands_total",
+		Help:      "Number of handled network commands.",
+	}, []string{"command", "result"})
+
+	CommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "network_command_duration_seconds",
+		Help:      "Time of handling of a network command.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 9),
+	}, []string{"command"})
+
+	BlockVerifyDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "block_verify_duration_seconds",
+		Help:      "Time of verification of a block before it is added to the chain.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 9),
+	})
+
+	ReorgsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reorgs_total",
+		Help:      "Number of times the main chain was switched to other branch.",
+	})
+
+	DBLockWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_lock_wait_seconds",
+		Help:      "Time of waiting for a DB lock.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+	}, []string{"db"})
+)
+
+// State of a node at a moment of scraping
+type State struct {
+	Height                int
+	ExpectingBlocksHeight int
+	Mempool               int
+	UnspentOutputs        int
+	Peers                 int
+	Syncing               bool
+	SyncHeadersHeight     int
+	SyncBlocksHeight      int
+}
+
+// Function to get a node state. It is set by a node server
+type StateFunc func() (State, error)
+
+var registry *prometheus.Registry
+
+func init() {
+	registry = prometheus.NewRegistry()
+
+	registry.MustRegister(CommandsTotal, CommandDuration, BlockVerifyDuration, ReorgsTotal, DBLockWait)
+	registry.MustRegister(prometheus.NewGoCollector())
+	registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	registry.MustRegister(stateSource)
+}
+
+// Sets a function to read node state on every scrape
+func SetStateFunc(f StateFunc) {
+	stateSource.lock.Lock()
+	defer stateSource.lock.Unlock()
+
+	stateSource.f = f
+}
+
+// HTTP handler for the /metrics endpoint. Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Collects gauges of a node state. The state is read from the DB only when metrics are scraped
+type stateCollector struct {
+	lock *sync.Mutex
+	f    StateFunc
+
+	height        *prometheus.Desc
+	expecting     *prometheus.Desc
+	mempool       *prometheus.Desc
+	unspent       *prometheus.Desc
+	peers         *prometheus.Desc
+	syncing       *prometheus.Desc
+	headersHeight *prometheus.Desc
+	blocksHeight  *prometheus.Desc
+	stateError    *prometheus.Desc
+}
+
+var stateSource = newStateCollector()
+
+func newStateCollector() *stateCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, nil, nil)
+	}
+
+	c := &stateCollector{lock: &sync.Mutex{}}
+
+	c.height = desc("chain_height", "Height of the top block of the main chain.")
+	c.expecting = desc("expecting_blocks_height", "Max height of blocks announced by other nodes.")
+	c.mempool = desc("mempool_transactions", "Number of unapproved transactions.")
+	c.unspent = desc("unspent_outputs", "Number of unspent transaction outputs.")
+	c.peers = desc("peers", "Number of known nodes.")
+	c.syncing = desc("sync_running", "1 if blocks synchronization is running.")
+	c.headersHeight = desc("sync_headers_height", "Height of last loaded header of a running synchronization.")
+	c.blocksHeight = desc("sync_blocks_height", "Height of last added block of a running synchronization.")
+	c.stateError = desc("state_error", "1 if the node state could not be read.")
+
+	return c
+}
+
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.height, c.expecting, c.mempool, c.unspent, c.peers,
+		c.syncing, c.headersHeight, c.blocksHeight, c.stateError} {
+		ch <- d
+	}
+}
+
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.Lock()
+	f := c.f
+	c.lock.Unlock()
+
+	if f == nil {
+		return
+	}
+
+	state, err := f()
+
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.stateError, prometheus.GaugeValue, 1)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.stateError, prometheus.GaugeValue, 0)
+
+	syncing := 0.0
+
+	if state.Syncing {
+		syncing = 1
+	}
+
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+
+	gauge(c.height, float64(state.Height))
+	gauge(c.expecting, float64(state.ExpectingBlocksHeight))
+	gauge(c.mempool, float64(state.Mempool))
+	gauge(c.unspent, float64(state.UnspentOutputs))
+	gauge(c.peers, float64(state.Peers))
+	gauge(c.syncing, syncing)
+	gauge(c.headersHeight, float64(state.SyncHeadersHeight))
+	gauge(c.blocksHeight, float64(state.SyncBlocksHeight))
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/taincoin/taincoin/lib"
 	"github.com/taincoin/taincoin/lib/net"
 	"github.com/taincoin/taincoin/lib/nodeclient"
 	"github.com/taincoin/taincoin/lib/utils"
@@ -81,6 +82,7 @@ func (c *NodeCLI) CreateNode() {
 
 	node.Logger = c.Logger
 	node.MinterAddress = c.Input.MinterAddress
+	node.ConsensusConfig = c.Input.Consensus
 
 	node.Init()
 	node.InitNodes(c.Input.Nodes, false)
@@ -100,6 +102,7 @@ func (c NodeCLI) isInteractiveMode() bool {
 		"printchain",
 		"makeblock",
 		"reindexcache",
+		"migratedb",
 		"send",
 		"getbalance",
 		"getbalances",
@@ -111,9 +114,33 @@ func (c NodeCLI) isInteractiveMode() bool {
 		"dropblock",
 		"addrhistory",
 		"showunspent",
+		"getsupply",
 		"shownodes",
 		"addnode",
-		"removenode"}
+		"removenode",
+		"unlock",
+		"lock",
+		"changepassphrase",
+		"restorewallet",
+		"rescanwallet",
+		"importaddress",
+		"importpubkey",
+		"preparetx",
+		"signtx",
+		"broadcasttx",
+		"createmultisig",
+		"combinetx",
+		"createscript",
+		"initiateswap",
+		"redeemswap",
+		"refundswap",
+		"extractsecret",
+		"showsecret",
+		"anchor",
+		"findanchor",
+		"stamp",
+		"getreceipt",
+		"verifyreceipt"}
 
 	for _, cm := range commands {
 		if cm == c.Command {
@@ -144,10 +171,29 @@ func (c NodeCLI) isNodeManageMode() bool {
 func (c NodeCLI) ExecuteCommand() error {
 	c.CreateNode() // init node struct
 
+	if c.Input.Args.Light &&
+		(c.Command == "getbalance" || c.Command == "getbalances" || c.Command == "showunspent") {
+		// light client doesn't need local blockchain. all data are loaded from nodes and verified
+		defer c.Node.DBConn.CloseConnection()
+
+		return c.forwardCommandToWallet()
+	}
+
 	if c.Command != "createblockchain" &&
 		c.Command != "initblockchain" &&
 		c.Command != "createwallet" &&
 		c.Command != "listaddresses" &&
+		c.Command != "unlock" &&
+		c.Command != "lock" &&
+		c.Command != "changepassphrase" &&
+		c.Command != "importaddress" &&
+		c.Command != "importpubkey" &&
+		c.Command != "signtx" &&
+		c.Command != "createmultisig" &&
+		c.Command != "combinetx" &&
+		c.Command != "createscript" &&
+		c.Command != "showsecret" &&
+		c.Command != "verifyreceipt" &&
 		c.Command != "nodestate" {
 		// only these 3 addresses can be executed if no blockchain yet
 		if !c.Node.BlockchainExist() {
@@ -169,6 +215,9 @@ func (c NodeCLI) ExecuteCommand() error {
 	} else if c.Command == "reindexcache" {
 		return c.commandReindexCache()
 
+	} else if c.Command == "migratedb" {
+		return c.commandMigrateDB()
+
 	} else if c.Command == "getbalance" {
 		return c.commandGetBalance()
 
@@ -202,6 +251,9 @@ func (c NodeCLI) ExecuteCommand() error {
 	} else if c.Command == "showunspent" {
 		return c.commandShowUnspent()
 
+	} else if c.Command == "getsupply" {
+		return c.commandGetSupply()
+
 	} else if c.Command == "shownodes" {
 		return c.commandShowNodes()
 
@@ -210,6 +262,54 @@ func (c NodeCLI) ExecuteCommand() error {
 
 	} else if c.Command == "removenode" {
 		return c.commandRemoveNode()
+
+	} else if c.Command == "unlock" {
+		return c.commandUnlock()
+
+	} else if c.Command == "lock" {
+		return c.commandLock()
+
+	} else if c.Command == "changepassphrase" {
+		return c.forwardCommandToWallet()
+
+	} else if c.Command == "restorewallet" || c.Command == "rescanwallet" {
+		return c.forwardCommandToWallet()
+
+	} else if c.Command == "importaddress" || c.Command == "importpubkey" {
+		return c.forwardCommandToWallet()
+
+	} else if c.Command == "preparetx" {
+		return c.commandPrepareTransaction()
+
+	} else if c.Command == "signtx" {
+		return c.forwardCommandToWallet()
+
+	} else if c.Command == "broadcasttx" {
+		return c.commandBroadcastTransaction()
+
+	} else if c.Command == "createmultisig" || c.Command == "combinetx" || c.Command == "createscript" ||
+		c.Command == "showsecret" {
+		return c.forwardCommandToWallet()
+
+	} else if c.Command == "anchor" {
+		return c.commandAnchor()
+
+	} else if c.Command == "findanchor" {
+		return c.commandFindAnchor()
+
+	} else if c.Command == "initiateswap" || c.Command == "redeemswap" ||
+		c.Command == "refundswap" || c.Command == "extractsecret" {
+		return c.forwardCommandToWallet()
+
+	} else if c.Command == "stamp" || c.Command == "getreceipt" {
+		// the timestamping service works only in the node server
+		if c.AlreadyRunningPort == 0 {
+			return errors.New("Node server is not running")
+		}
+		return c.forwardCommandToWallet()
+
+	} else if c.Command == "verifyreceipt" {
+		return c.forwardCommandToWallet()
 	}
 
 	return errors.New("Unknown management command")
@@ -232,6 +332,9 @@ func (c NodeCLI) createDaemonManager() (*server.NodeDaemon, error) {
 	nd.Port = c.Input.Port
 	nd.Host = c.Input.Host
 	nd.Node = c.Node
+	nd.RPC = c.Input.RPC
+	nd.Metrics = c.Input.Metrics
+	nd.Stamps = c.Input.Timestamp
 	nd.Init()
 
 	return &nd, nil
@@ -275,7 +378,23 @@ func (c *NodeCLI) getWalletsCLI() (*wallet.WalletCLI, error) {
 	winput.NodePort = c.Input.Port
 	winput.NodeHost = "localhost"
 	winput.Amount = c.Input.Args.Amount
+	winput.Fee = c.Input.Args.Fee
 	winput.ToAddress = c.Input.Args.To
+	winput.Height = c.Input.Args.Height
+	winput.Light = c.Input.Args.Light
+	winput.Nodes = c.Input.Nodes
+	winput.HD = c.Input.Args.HD
+	winput.PubKey = c.Input.Args.PubKey
+	winput.File = c.Input.Args.File
+	winput.Files = c.Input.Args.Files
+	winput.Required = c.Input.Args.Required
+	winput.PubKeys = c.Input.Args.PubKeys
+	winput.Script = c.Input.Args.Script
+	winput.LockTime = c.Input.Args.LockTime
+	winput.Contract = c.Input.Args.Contract
+	winput.Secret = c.Input.Args.Secret
+	winput.SecretHash = c.Input.Args.SecretHash
+	winput.Data = c.Input.Args.Data
 
 	if c.Input.Args.From != "" {
 		winput.Address = c.Input.Args.From
@@ -287,15 +406,63 @@ func (c *NodeCLI) getWalletsCLI() (*wallet.WalletCLI, error) {
 	if c.AlreadyRunningPort > 0 {
 		winput.NodePort = c.AlreadyRunningPort
 		winput.NodeHost = "localhost"
+	} else if c.Input.Args.Light {
+		// only remote nodes are asked if local node is not running
+		winput.NodePort = c.Input.Args.NodePort
+		winput.NodeHost = c.Input.Args.NodeHost
 	}
 
 	walletscli.Init(c.Logger, winput)
 
+	if c.AlreadyRunningPort > 0 {
+		// privileged commands, like stamp, are sent to the local node
+		walletscli.NodeCLI.SetAuthStr(c.NodeAuthStr)
+	}
+
 	walletscli.NodeMode = true
 
+	if c.AlreadyRunningPort == 0 {
+		// node is not running. history of addresses is in local DB
+		walletscli.IsAddressUsed = func(address string) (bool, error) {
+			history, err := c.Node.NodeBC.GetAddressHistory(address)
+
+			if err != nil {
+				return false, err
+			}
+			return len(history) > 0, nil
+		}
+
+		walletscli.SwapNode = c.getLocalSwapNode()
+	}
+
 	return &walletscli, nil
 }
 
+// Swaps use local DB if a node is not running
+func (c *NodeCLI) getLocalSwapNode() *wallet.SwapNode {
+	return &wallet.SwapNode{
+		GetBalance: func(address string) (lib.Amount, error) {
+			balance, err := c.Node.GetTransactionsManager().GetAddressBalance(address)
+			return balance.Approved, err
+		},
+		RequestTransaction: func(pubKey []byte, to string, amount, fee lib.Amount) ([]byte, [][]byte, error) {
+			return c.Node.GetTransactionsManager().PrepareNewTransaction(pubKey, to, amount, fee)
+		},
+		SendTransaction: func(from string, txBytes []byte, signatures [][]byte) ([]byte, error) {
+			tx, err := c.Node.GetTransactionsManager().ReceivedNewTransactionData(txBytes, signatures)
+
+			if err != nil {
+				return nil, err
+			}
+
+			c.Node.SendTransactionToAll(tx)
+
+			return tx.ID, nil
+		},
+		FindSecret: c.Node.NodeBC.FindHTLCSecret,
+	}
+}
+
 // Forwards a command to wallet object. This is needed for cases when a node does some
 // operation with local wallets
 func (c *NodeCLI) forwardCommandToWallet() error {
@@ -392,6 +559,7 @@ func (c *NodeCLI) commandPrintChain() error {
 			fmt.Printf("============ Block %x ============\n", block.Hash)
 			fmt.Printf("Height: %d\n", block.Height)
 			fmt.Printf("Prev. block: %x\n", block.PrevBlockHash)
+			fmt.Printf("Merkle root: %x\n", block.MerkleRoot)
 
 			for _, tx := range block.Transactions {
 				fmt.Println(tx)
@@ -449,10 +617,12 @@ func (c *NodeCLI) commandAddressesBalance() error {
 	if err != nil {
 		return err
 	}
-	// get addresses in local wallets
+	// get addresses in local wallets. watch-only addresses are shown too
 	result := map[string]wallet.WalletBalance{}
 
-	for _, address := range walletscli.WalletsObj.GetAddresses() {
+	addresses := append(walletscli.WalletsObj.GetAddresses(), walletscli.WalletsObj.GetWatchOnlyAddresses()...)
+
+	for _, address := range addresses {
 		balance, err := c.Node.GetTransactionsManager().GetAddressBalance(address)
 
 		if err != nil {
@@ -465,7 +635,8 @@ func (c *NodeCLI) commandAddressesBalance() error {
 	fmt.Println()
 
 	for address, balance := range result {
-		fmt.Printf("%s: %.8f (Approved - %.8f, Pending - %.8f)\n", address, balance.Total, balance.Approved, balance.Pending)
+		fmt.Printf("%s%s: %s (Approved - %s, Pending - %s)\n", address, walletscli.WalletsObj.WatchOnlyMark(address),
+			balance.Total, balance.Approved, balance.Pending)
 	}
 
 	return nil
@@ -484,12 +655,12 @@ func (c *NodeCLI) commandAddressHistory() error {
 	if err != nil {
 		return err
 	}
-	fmt.Println("History of transactions:")
+	fmt.Printf("History of transactions%s:\n", c.watchOnlyMark(c.Input.Args.Address))
 	for _, rec := range result {
 		if rec.IOType {
-			fmt.Printf("%f\t In from\t%s\n", rec.Value, rec.Address)
+			fmt.Printf("%s\t In from\t%s\n", rec.Value, rec.Address)
 		} else {
-			fmt.Printf("%f\t Out To  \t%s\n", rec.Value, rec.Address)
+			fmt.Printf("%s\t Out To  \t%s\n", rec.Value, rec.Address)
 		}
 
 	}
@@ -504,11 +675,11 @@ func (c *NodeCLI) commandShowUnspent() error {
 		return c.forwardCommandToWallet()
 	}
 
-	balance := float64(0)
+	balance := lib.Amount(0)
 
 	err := c.Node.GetTransactionsManager().ForEachUnspentOutput(c.Input.Args.Address,
-		func(fromaddr string, value float64, txID []byte, output int, isbase bool) error {
-			fmt.Printf("%f\t from\t%s in transaction %x output #%d\n", value, fromaddr, txID, output)
+		func(fromaddr string, value lib.Amount, txID []byte, output int, isbase bool) error {
+			fmt.Printf("%s\t from\t%s in transaction %x output #%d\n", value, fromaddr, txID, output)
 			balance += value
 			return nil
 		})
@@ -517,7 +688,7 @@ func (c *NodeCLI) commandShowUnspent() error {
 		return err
 	}
 
-	fmt.Printf("\nBalance - %f\n", balance)
+	fmt.Printf("\nBalance - %s%s\n", balance, c.watchOnlyMark(c.Input.Args.Address))
 
 	return nil
 }
@@ -535,9 +706,36 @@ func (c *NodeCLI) commandGetBalance() error {
 		return err
 	}
 
-	fmt.Printf("Balance of '%s': \nTotal - %.8f\n", c.Input.Args.Address, balance.Total)
-	fmt.Printf("Approved - %.8f\n", balance.Approved)
-	fmt.Printf("Pending - %.8f\n", balance.Pending)
+	fmt.Printf("Balance of '%s'%s: \nTotal - %s\n", c.Input.Args.Address, c.watchOnlyMark(c.Input.Args.Address), balance.Total)
+	fmt.Printf("Approved - %s\n", balance.Approved)
+	fmt.Printf("Pending - %s\n", balance.Pending)
+	return nil
+}
+
+// Returns a mark if an address is watch-only in local wallets
+func (c *NodeCLI) watchOnlyMark(address string) string {
+	walletscli, err := c.getWalletsCLI()
+
+	if err != nil || walletscli.WalletsObj == nil {
+		return ""
+	}
+	return walletscli.WalletsObj.WatchOnlyMark(address)
+}
+
+// Display supply of coins after a block
+func (c *NodeCLI) commandGetSupply() error {
+	if c.AlreadyRunningPort > 0 {
+		// run in wallet mode.
+		return c.forwardCommandToWallet()
+	}
+
+	supply, err := c.Node.GetSupply(c.Input.Args.Height)
+
+	if err != nil {
+		return err
+	}
+
+	wallet.PrintSupply(supply)
 	return nil
 }
 
@@ -558,6 +756,16 @@ func (c *NodeCLI) commandSend() error {
 		return err
 	}
 
+	if walletscli.WalletsObj.IsWatchOnly(c.Input.Args.From) {
+		return wallet.ErrWatchOnly
+	}
+
+	err = walletscli.UnlockIfNeeded()
+
+	if err != nil {
+		return err
+	}
+
 	walletobj, err := walletscli.WalletsObj.GetWallet(c.Input.Args.From)
 
 	if err != nil {
@@ -565,7 +773,53 @@ func (c *NodeCLI) commandSend() error {
 	}
 
 	txid, err := c.Node.Send(walletobj.GetPublicKey(), walletobj.GetPrivateKey(),
-		c.Input.Args.To, c.Input.Args.Amount)
+		c.Input.Args.To, c.Input.Args.Amount, c.Input.Args.Fee)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Success. New transaction: %x\n", txid)
+
+	return nil
+}
+
+// Adds data to the blockchain with a data output
+func (c *NodeCLI) commandAnchor() error {
+	if c.AlreadyRunningPort > 0 {
+		// run in wallet mode.
+		return c.forwardCommandToWallet()
+	}
+
+	data, err := hex.DecodeString(c.Input.Args.Data)
+
+	if err != nil || len(data) == 0 {
+		return errors.New("Data must be a hex string")
+	}
+
+	walletscli, err := c.getWalletsCLI()
+
+	if err != nil {
+		return err
+	}
+
+	if walletscli.WalletsObj.IsWatchOnly(c.Input.Args.From) {
+		return wallet.ErrWatchOnly
+	}
+
+	err = walletscli.UnlockIfNeeded()
+
+	if err != nil {
+		return err
+	}
+
+	walletobj, err := walletscli.WalletsObj.GetWallet(c.Input.Args.From)
+
+	if err != nil {
+		return err
+	}
+
+	txid, err := c.Node.SendData(walletobj.GetPublicKey(), walletobj.GetPrivateKey(), data, c.Input.Args.Fee)
 
 	if err != nil {
 		return err
@@ -576,6 +830,132 @@ func (c *NodeCLI) commandSend() error {
 	return nil
 }
 
+// Shows where data were anchored
+func (c *NodeCLI) commandFindAnchor() error {
+	if c.AlreadyRunningPort > 0 {
+		// run in wallet mode.
+		return c.forwardCommandToWallet()
+	}
+
+	data, err := hex.DecodeString(c.Input.Args.Data)
+
+	if err != nil || len(data) == 0 {
+		return errors.New("Data must be a hex string")
+	}
+
+	tx, block, err := c.Node.GetTransactionsManager().FindDataTransaction(data)
+
+	if err != nil {
+		return err
+	}
+
+	if tx == nil {
+		return errors.New("Data are not found in the blockchain")
+	}
+
+	wallet.PrintAnchor(nodeclient.ComAnchor{TXID: tx.ID, BlockHash: block.Hash, Height: block.Height, Timestamp: block.Timestamp})
+
+	return nil
+}
+
+// Prepares a transaction and saves it to a file to sign offline
+func (c *NodeCLI) commandPrepareTransaction() error {
+	if c.AlreadyRunningPort > 0 {
+		// run in wallet mode.
+		return c.forwardCommandToWallet()
+	}
+
+	if c.Input.Args.File == "" {
+		return errors.New("File is not provided")
+	}
+
+	walletscli, err := c.getWalletsCLI()
+
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := walletscli.WalletsObj.GetPublicKey(c.Input.Args.From)
+
+	if err != nil {
+		return err
+	}
+
+	w := wallet.Wallet{}
+
+	if !w.ValidateAddress(c.Input.Args.To) {
+		return errors.New("Recipient address is not valid")
+	}
+
+	txBytes, dataToSign, err := c.Node.GetTransactionsManager().PrepareNewTransaction(pubKey,
+		c.Input.Args.To, c.Input.Args.Amount, c.Input.Args.Fee)
+
+	if err != nil {
+		return err
+	}
+
+	prevTXs, err := c.Node.GetTransactionsManager().GetInputTransactions(txBytes)
+
+	if err != nil {
+		return err
+	}
+
+	ot, err := wallet.NewOfflineTransaction(c.Input.Args.From, c.Input.Args.To, c.Input.Args.Amount, c.Input.Args.Fee,
+		pubKey, txBytes, dataToSign, prevTXs)
+
+	if err != nil {
+		return err
+	}
+
+	if c.Input.Args.LockTime != 0 {
+		err = ot.SetLockTime(c.Input.Args.LockTime)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return wallet.SaveOfflineTransaction(ot, c.Input.Args.File)
+}
+
+// Sends transaction signed offline
+func (c *NodeCLI) commandBroadcastTransaction() error {
+	if c.AlreadyRunningPort > 0 {
+		// run in wallet mode.
+		return c.forwardCommandToWallet()
+	}
+
+	ot, err := wallet.LoadOfflineTransaction(c.Input.Args.File)
+
+	if err != nil {
+		return err
+	}
+
+	txBytes, err := ot.GetTransaction()
+
+	if err != nil {
+		return err
+	}
+
+	signatures, err := ot.GetSignatures()
+
+	if err != nil {
+		return err
+	}
+
+	tx, err := c.Node.GetTransactionsManager().ReceivedNewTransactionData(txBytes, signatures)
+
+	if err != nil {
+		return err
+	}
+
+	c.Node.SendTransactionToAll(tx)
+
+	fmt.Printf("Success. New transaction: %x\n", tx.ID)
+
+	return nil
+}
+
 // Reindex cache of transactions information
 func (c *NodeCLI) commandReindexCache() error {
 	info, err := c.Node.GetTransactionsManager().ReindexData()
@@ -588,6 +968,20 @@ func (c *NodeCLI) commandReindexCache() error {
 	return nil
 }
 
+// Check DB for data of old format with float amounts
+func (c *NodeCLI) commandMigrateDB() error {
+	info, err := c.Node.MigrateDatabase()
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Done! Removed %d unapproved transactions of old format. There are %d transactions in the UTXO set.\n",
+		info["unapproved"], info["unspentoutputs"])
+
+	return nil
+}
+
 // Try to mine a block if there is anough unapproved transactions
 func (c *NodeCLI) commandMakeBlock() error {
 	block, err := c.Node.TryToMakeBlock([]byte{})
@@ -692,6 +1086,11 @@ func (c *NodeCLI) commandShowState(daemon *server.NodeDaemon) error {
 		fmt.Printf("  Loaded %d of %d blocks\n", info.BlocksNumber, info.ExpectingBlocksHeight+1)
 	}
 
+	if info.Syncing {
+		fmt.Printf("  Synchronizing with %s. Headers verified up to %d, blocks added up to %d, loading from %d nodes\n",
+			info.SyncNode, info.SyncHeadersHeight, info.SyncBlocksHeight, info.SyncNodes)
+	}
+
 	fmt.Printf("  Number of unapproved transactions - %d\n", info.TransactionsCached)
 
 	fmt.Printf("  Number of unspent transactions outputs - %d\n", info.UnspentOutputs)
@@ -766,3 +1165,90 @@ func (c *NodeCLI) commandRemoveNode() error {
 
 	return nil
 }
+
+// Unlock the wallet in the running node. If the node is not running, only checks the passphrase.
+// A plain wallet file is encrypted first, the new passphrase is asked twice
+func (c *NodeCLI) commandUnlock() error {
+	if c.Input.Args.Timeout < 0 {
+		return errors.New("Timeout can not be negative")
+	}
+
+	walletscli, err := c.getWalletsCLI()
+
+	if err != nil {
+		return err
+	}
+
+	var passphrase string
+
+	if !walletscli.WalletsObj.Encrypted {
+		fmt.Println("Wallet file is not encrypted. Keep the passphrase, keys can not be recovered without it")
+
+		passphrase, err = wallet.ReadNewPassphrase("New passphrase: ", "Repeat new passphrase: ")
+
+		if err != nil {
+			return err
+		}
+
+		err = walletscli.WalletsObj.Encrypt(passphrase)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Wallet file is encrypted with the passphrase")
+	} else {
+		passphrase, err = wallet.ReadPassphrase("Wallet passphrase: ")
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.AlreadyRunningPort > 0 {
+		nc := c.getLocalNetworkClient()
+
+		err = nc.SendUnlockWallet(passphrase, c.Input.Args.Timeout)
+
+		if err != nil {
+			return err
+		}
+
+		if c.Input.Args.Timeout > 0 {
+			fmt.Printf("Wallet is unlocked in the node for %d seconds\n", c.Input.Args.Timeout)
+		} else {
+			fmt.Println("Wallet is unlocked in the node until it stops")
+		}
+		return nil
+	}
+
+	err = walletscli.WalletsObj.Unlock(passphrase)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Passphrase is correct. Node is not running, so the wallet stays locked")
+
+	return nil
+}
+
+// Remove wallet keys from memory of the running node
+func (c *NodeCLI) commandLock() error {
+	if c.AlreadyRunningPort == 0 {
+		fmt.Println("Node is not running. Wallet keys are not kept in memory")
+		return nil
+	}
+
+	nc := c.getLocalNetworkClient()
+
+	err := nc.SendLockWallet()
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Wallet is locked")
+
+	return nil
+}
@@ -2,19 +2,26 @@ package nodemanager
 
 import (
 	"errors"
+	"time"
 
 	"github.com/taincoin/taincoin/lib/utils"
 	"github.com/taincoin/taincoin/lib/wallet"
 	"github.com/taincoin/taincoin/node/blockchain"
+	"github.com/taincoin/taincoin/node/config"
 	"github.com/taincoin/taincoin/node/consensus"
+	"github.com/taincoin/taincoin/node/events"
+	"github.com/taincoin/taincoin/node/metrics"
 	"github.com/taincoin/taincoin/node/structures"
 	"github.com/taincoin/taincoin/node/transactions"
 )
 
 type NodeBlockchain struct {
-	Logger        *utils.LoggerMan
-	MinterAddress string
-	DBConn        *Database
+	Logger          *utils.LoggerMan
+	MinterAddress   string
+	DataDir         string
+	ConsensusConfig config.ConsensusConfig
+	DBConn          *Database
+	Events          *events.Bus
 }
 
 func (n *NodeBlockchain) GetBCManager() *blockchain.Blockchain {
@@ -23,7 +30,7 @@ func (n *NodeBlockchain) GetBCManager() *blockchain.Blockchain {
 }
 
 func (n *NodeBlockchain) getTransactionsManager() transactions.TransactionsManagerInterface {
-	return transactions.NewManager(n.DBConn.DB(), n.Logger)
+	return transactions.NewManagerWithEvents(n.DBConn.DB(), n.Logger, n.Events)
 }
 
 // Checks if a block exists in the chain. It will go over blocks list
@@ -85,6 +92,17 @@ func (n *NodeBlockchain) GetAddressHistory(address string) ([]structures.Transac
 	return bci.GetAddressHistory(pubKeyHash, address)
 }
 
+// Finds a secret of hashed time-locked contracts revealed in the blockchain
+func (n *NodeBlockchain) FindHTLCSecret(secretHash []byte) ([]byte, []byte, error) {
+	bci, err := blockchain.NewBlockchainIterator(n.DBConn.DB())
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return bci.FindHTLCSecret(secretHash)
+}
+
 // Drop block from a top of blockchain
 func (n *NodeBlockchain) DropBlock() (*structures.Block, error) {
 	return n.GetBCManager().DeleteBlock()
@@ -111,14 +129,18 @@ func (n *NodeBlockchain) AddBlock(block *structures.Block) (uint, error) {
 		return blockchain.BCBAddState_notAddedNoPrev, nil
 	}
 
-	Minter, err := consensus.NewConsensusManager(n.MinterAddress, n.DBConn.DB(), n.Logger)
+	Minter, err := consensus.NewConsensusManager(n.ConsensusConfig, n.MinterAddress, n.DataDir, n.DBConn.DB(), n.Logger)
 
 	if err != nil {
 		return 0, err
 	}
 	// verify this block against rules.
+	starttime := time.Now()
+
 	err = Minter.VerifyBlock(block)
 
+	metrics.BlockVerifyDuration.Observe(time.Since(starttime).Seconds())
+
 	if err != nil {
 		return 0, err
 	}
@@ -9,15 +9,18 @@ import (
 	"github.com/taincoin/taincoin/lib/utils"
 	"github.com/taincoin/taincoin/lib/wallet"
 	"github.com/taincoin/taincoin/node/blockchain"
+	"github.com/taincoin/taincoin/node/config"
 	"github.com/taincoin/taincoin/node/consensus"
 	"github.com/taincoin/taincoin/node/structures"
 	"github.com/taincoin/taincoin/node/transactions"
 )
 
 type makeBlockchain struct {
-	Logger        *utils.LoggerMan
-	MinterAddress string
-	DBConn        *Database
+	Logger          *utils.LoggerMan
+	MinterAddress   string
+	DataDir         string
+	ConsensusConfig config.ConsensusConfig
+	DBConn          *Database
 }
 
 // Blockchain DB manager object
@@ -33,7 +36,7 @@ func (n *makeBlockchain) getTransactionsManager() transactions.TransactionsManag
 
 // Init block maker object. It is used to make new blocks
 func (n *makeBlockchain) getBlockMakeManager() (consensus.ConsensusInterface, error) {
-	return consensus.NewConsensusManager(n.MinterAddress, n.DBConn.DB(), n.Logger)
+	return consensus.NewConsensusManager(n.ConsensusConfig, n.MinterAddress, n.DataDir, n.DBConn.DB(), n.Logger)
 }
 
 // Create new blockchain, add genesis block witha given text
@@ -44,9 +47,13 @@ func (n *makeBlockchain) CreateBlockchain(genesisCoinbaseData string) error {
 		return err
 	}
 
-	Minter, _ := n.getBlockMakeManager()
+	Minter, err := n.getBlockMakeManager()
 
-	n.Logger.Trace.Printf("Complete genesis block proof of work\n")
+	if err != nil {
+		return err
+	}
+
+	n.Logger.Trace.Printf("Complete genesis block\n")
 
 	Minter.SetPreparedBlock(genesisBlock)
 
@@ -99,15 +106,13 @@ func (n *makeBlockchain) InitBlockchainFromOther(addr net.NodeAddr, nodeclient *
 	err = n.addFirstBlock(block)
 
 	if err != nil {
-		return false, errors.New(fmt.Sprintf("Create DB abd add first block: %", err.Error()))
+		return false, errors.New(fmt.Sprintf("Create DB abd add first block: %s", err.Error()))
 	}
 
 	defer n.DBConn.CloseConnection()
 
 	MH := block.Height
 
-	TXMan := n.getTransactionsManager()
-
 	if len(result.Blocks) > 1 {
 		// add all blocks
 
@@ -125,14 +130,20 @@ func (n *makeBlockchain) InitBlockchainFromOther(addr net.NodeAddr, nodeclient *
 				return false, err
 			}
 
-			_, err = BC.AddBlock(block)
+			err = n.DBConn.InBatch(func() error {
+				_, err := BC.AddBlock(block)
+
+				if err != nil {
+					return err
+				}
+
+				return n.getTransactionsManager().BlockAdded(block, true)
+			})
 
 			if err != nil {
 				return false, err
 			}
 
-			TXMan.BlockAdded(block, true)
-
 			MH = block.Height
 		}
 	}
@@ -158,7 +169,7 @@ func (n *makeBlockchain) prepareGenesisBlock(address, genesisCoinbaseData string
 
 	cbtx := &structures.Transaction{}
 
-	errc := cbtx.MakeCoinbaseTX(address, genesisCoinbaseData)
+	errc := cbtx.MakeCoinbaseTX(address, genesisCoinbaseData, n.ConsensusConfig.GetEmission().GetBlockReward(0), 0)
 
 	if errc != nil {
 		return nil, errc
@@ -183,41 +194,42 @@ func (n *makeBlockchain) addFirstBlock(genesis *structures.Block) error {
 		return err
 	}
 
-	bcdb, err := n.DBConn.DB().GetBlockchainObject()
+	// genesis block and caches are saved together
+	return n.DBConn.InBatch(func() error {
+		bcdb, err := n.DBConn.DB().GetBlockchainObject()
 
-	if err != nil {
-		n.Logger.Error.Printf("Can not create conn object: %s", err.Error())
-		return err
-	}
+		if err != nil {
+			n.Logger.Error.Printf("Can not create conn object: %s", err.Error())
+			return err
+		}
 
-	blockdata, err := genesis.Serialize()
+		blockdata, err := genesis.Serialize()
 
-	if err != nil {
-		return err
-	}
+		if err != nil {
+			return err
+		}
 
-	err = bcdb.PutBlockOnTop(genesis.Hash, blockdata)
+		err = bcdb.PutBlockOnTop(genesis.Hash, blockdata)
 
-	if err != nil {
-		return err
-	}
-
-	err = bcdb.SaveFirstHash(genesis.Hash)
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
-		return err
-	}
+		err = bcdb.SaveFirstHash(genesis.Hash)
 
-	// add first rec to chain list
-	err = bcdb.AddToChain(genesis.Hash, []byte{})
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
-		return err
-	}
+		// add first rec to chain list
+		err = bcdb.AddToChain(genesis.Hash, []byte{})
 
-	n.Logger.Trace.Printf("Prepare TX caches\n")
+		if err != nil {
+			return err
+		}
 
-	n.getTransactionsManager().BlockAdded(genesis, true)
+		n.Logger.Trace.Printf("Prepare TX caches\n")
 
-	return err
+		return n.getTransactionsManager().BlockAdded(genesis, true)
+	})
 }
@@ -75,11 +75,8 @@ func (db *Database) OpenConnection(reason string, sessid string) error {
 }
 
 func (db *Database) PrepareConnection(sessid string) {
-	obj := &database.BoltDBManager{}
-	obj.SessID = sessid
-	db.db = obj
+	db.db = database.NewDBManager(db.Config, sessid)
 	db.db.SetLogger(db.Logger)
-	db.db.SetConfig(db.Config)
 
 	if db.lockerObj != nil {
 		db.db.SetLockerObject(db.lockerObj)
@@ -125,3 +122,24 @@ func (db *Database) CheckConnectionIsOpen() bool {
 	}
 	return false
 }
+
+// Executes a function in a batch of the blockchain DB. Changes are saved only if it returns no error.
+// DB objects must be got inside the function
+func (db *Database) InBatch(fn func() error) error {
+	dbman := db.DB()
+
+	err := dbman.BeginBatch()
+
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+
+	if err != nil {
+		dbman.RollbackBatch()
+		return err
+	}
+
+	return dbman.CommitBatch()
+}
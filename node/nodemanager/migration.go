@@ -0,0 +1,97 @@
+package nodemanager
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/taincoin/taincoin/node/structures"
+)
+
+// Checks the database for data of old format, where amounts were float numbers.
+// Hashes of blocks, IDs and signatures of transactions were made for float data. They can not be made
+// again for integer amounts without keys of all senders, and other nodes could not verify a converted chain.
+// So blocks of old format are not converted, the blockchain must be loaded again from nodes of new version.
+// Unapproved transactions of old format are removed, they can not be verified too. Caches are rebuilt after this
+func (n *Node) MigrateDatabase() (map[string]int, error) {
+	bcdb, err := n.DBConn.DB().GetBlockchainObject()
+
+	if err != nil {
+		return nil, err
+	}
+
+	oldBlocks := 0
+
+	err = bcdb.ForEachBlock(func(hash, blockdata []byte) error {
+		block := structures.Block{}
+
+		if block.DeserializeBlock(blockdata) == nil {
+			// new format already
+			return nil
+		}
+
+		_, err := structures.DeserializeBlockFloat(blockdata)
+
+		if err != nil {
+			return err
+		}
+
+		oldBlocks++
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if oldBlocks > 0 {
+		return nil, errors.New(fmt.Sprintf("Database has %d blocks of old format. They can not be converted, other nodes would not accept them. Remove the blockchain DB and sync again", oldBlocks))
+	}
+
+	utdb, err := n.DBConn.DB().GetUnapprovedTransactionsObject()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// collect all first. DB can not be updated when we iterate over it
+	txIDs := [][]byte{}
+
+	err = utdb.ForEach(func(txID, txdata []byte) error {
+		tx := structures.Transaction{}
+
+		if tx.DeserializeTransaction(txdata) == nil {
+			return nil
+		}
+
+		_, err := structures.DeserializeTransactionFloat(txdata)
+
+		if err != nil {
+			return err
+		}
+
+		txIDs = append(txIDs, append([]byte{}, txID...))
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, txID := range txIDs {
+		err = utdb.DeleteTransaction(txID)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	info, err := n.GetTransactionsManager().ReindexData()
+
+	if err != nil {
+		return nil, err
+	}
+
+	info["unapproved"] = len(txIDs)
+
+	return info, nil
+}
@@ -0,0 +1,131 @@
+package nodemanager
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/node/database"
+	"github.com/taincoin/taincoin/node/structures"
+)
+
+// Old format of data with float amounts. Gob matches fields by names
+type oldTestOutput struct {
+	Value      float64
+	PubKeyHash []byte
+}
+
+type oldTestTransaction struct {
+	ID   []byte
+	Vin  []structures.TXInput
+	Vout []oldTestOutput
+	Time int64
+}
+
+type oldTestBlock struct {
+	Timestamp     int64
+	Transactions  []*oldTestTransaction
+	PrevBlockHash []byte
+	Hash          []byte
+	Height        int
+}
+
+func gobEncodeTest(t *testing.T, v interface{}) []byte {
+	var buff bytes.Buffer
+
+	if err := gob.NewEncoder(&buff).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return buff.Bytes()
+}
+
+// Saves a genesis block of new format
+func putTestGenesis(t *testing.T, bcdb database.BlockchainInterface) {
+	cbtx := &structures.Transaction{}
+
+	err := cbtx.MakeCoinbaseTX("1PZ9kYFt8aUHU5PLT9yLXEgxsGB3RV8dAD", "test", lib.PaymentForBlockMade, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genesis := &structures.Block{Hash: []byte{1}, Transactions: []*structures.Transaction{cbtx}}
+
+	data, err := genesis.Serialize()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bcdb.PutBlockOnTop(genesis.Hash, data)
+	bcdb.SaveFirstHash(genesis.Hash)
+	bcdb.AddToChain(genesis.Hash, []byte{})
+}
+
+func TestMigrateUnapprovedTransactions(t *testing.T) {
+	n, _ := newTestNode(t, nil)
+
+	bcdb, _ := n.DBConn.DB().GetBlockchainObject()
+	putTestGenesis(t, bcdb)
+
+	utdb, _ := n.DBConn.DB().GetUnapprovedTransactionsObject()
+
+	oldtx := oldTestTransaction{ID: []byte{2}, Vout: []oldTestOutput{{Value: 1.5}}}
+	utdb.PutTransaction(oldtx.ID, gobEncodeTest(t, oldtx))
+
+	newtx := structures.Transaction{ID: []byte{3}, Vout: []structures.TXOutput{{Value: lib.AmountUnit}}}
+	newdata, err := newtx.Serialize()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	utdb.PutTransaction(newtx.ID, newdata)
+
+	info, err := n.MigrateDatabase()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info["unapproved"] != 1 || info["unspentoutputs"] != 1 {
+		t.Fatalf("Got info %v", info)
+	}
+
+	if data, _ := utdb.GetTransaction(oldtx.ID); len(data) > 0 {
+		t.Fatal("Transaction of old format is not removed")
+	}
+
+	if data, _ := utdb.GetTransaction(newtx.ID); !bytes.Equal(data, newdata) {
+		t.Fatal("Transaction of new format is changed")
+	}
+
+	// nothing to do second time
+	info, err = n.MigrateDatabase()
+
+	if err != nil || info["unapproved"] != 0 {
+		t.Fatalf("Got info %v, %v", info, err)
+	}
+}
+
+func TestMigrateRefusesOldBlocks(t *testing.T) {
+	n, _ := newTestNode(t, nil)
+
+	bcdb, _ := n.DBConn.DB().GetBlockchainObject()
+	putTestGenesis(t, bcdb)
+
+	old := oldTestBlock{Hash: []byte{2}, PrevBlockHash: []byte{1}, Height: 1}
+	old.Transactions = []*oldTestTransaction{{ID: []byte{4}, Vout: []oldTestOutput{{Value: 10}}}}
+
+	olddata := gobEncodeTest(t, old)
+	bcdb.PutBlock(old.Hash, olddata)
+
+	if _, err := n.MigrateDatabase(); err == nil {
+		t.Fatal("Expected error for blocks of old format")
+	}
+
+	// hashes of old blocks can not be kept over new data, the block must stay as it is
+	if data, _ := bcdb.GetBlock(old.Hash); !bytes.Equal(data, olddata) {
+		t.Fatal("Block of old format is changed")
+	}
+}
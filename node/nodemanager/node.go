@@ -1,17 +1,24 @@
 package nodemanager
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"errors"
+	"fmt"
+	"math/big"
 	"math/rand"
 	"time"
 
+	"github.com/taincoin/taincoin/lib"
 	"github.com/taincoin/taincoin/lib/net"
 	"github.com/taincoin/taincoin/lib/nodeclient"
 	"github.com/taincoin/taincoin/lib/utils"
 	"github.com/taincoin/taincoin/lib/wallet"
 	"github.com/taincoin/taincoin/node/blockchain"
+	"github.com/taincoin/taincoin/node/config"
 	"github.com/taincoin/taincoin/node/consensus"
+	"github.com/taincoin/taincoin/node/events"
+	"github.com/taincoin/taincoin/node/metrics"
 	"github.com/taincoin/taincoin/node/structures"
 	"github.com/taincoin/taincoin/node/transactions"
 )
@@ -23,11 +30,13 @@ type Node struct {
 	Logger  *utils.LoggerMan
 	DataDir string
 
-	MinterAddress string
-	NodeClient    *nodeclient.NodeClient
-	OtherNodes    []net.NodeAddr
-	DBConn        *Database
-	SessionID     string
+	MinterAddress   string
+	ConsensusConfig config.ConsensusConfig
+	NodeClient      *nodeclient.NodeClient
+	OtherNodes      []net.NodeAddr
+	DBConn          *Database
+	SessionID       string
+	Events          *events.Bus // can be nil if nobody listens for events
 }
 
 // Init node.
@@ -39,8 +48,11 @@ func (n *Node) Init() {
 	n.NodeBC.Logger = n.Logger
 
 	n.NodeBC.MinterAddress = n.MinterAddress
+	n.NodeBC.ConsensusConfig = n.ConsensusConfig
+	n.NodeBC.DataDir = n.DataDir
 
 	n.NodeBC.DBConn = n.DBConn
+	n.NodeBC.Events = n.Events
 
 	// Nodes list storage
 	n.NodeNet.SetExtraManager(NodesListStorage{n.DBConn, n.SessionID})
@@ -54,7 +66,7 @@ func (n *Node) Init() {
 
 // Build transaction manager structure
 func (n *Node) GetTransactionsManager() transactions.TransactionsManagerInterface {
-	return transactions.NewManager(n.DBConn.DB(), n.Logger)
+	return transactions.NewManagerWithEvents(n.DBConn.DB(), n.Logger, n.Events)
 }
 
 // Build BC manager structure
@@ -69,12 +81,12 @@ func (n *Node) GetBlockChainIterator() (*blockchain.BlockchainIterator, error) {
 
 // Init block maker object. It is used to make new blocks
 func (n *Node) getBlockMakeManager() (consensus.ConsensusInterface, error) {
-	return consensus.NewConsensusManager(n.MinterAddress, n.DBConn.DB(), n.Logger)
+	return consensus.NewConsensusManager(n.ConsensusConfig, n.MinterAddress, n.DataDir, n.DBConn.DB(), n.Logger)
 }
 
 // Init block maker object. It is used to make new blocks
 func (n *Node) getCreateManager() *makeBlockchain {
-	return &makeBlockchain{n.Logger, n.MinterAddress, n.DBConn}
+	return &makeBlockchain{n.Logger, n.MinterAddress, n.DataDir, n.ConsensusConfig, n.DBConn}
 }
 
 // Init network client object. It is used to communicate with other nodes
@@ -256,7 +268,7 @@ func (n *Node) SendVersionToNodes(nodes []net.NodeAddr) {
 func (n *Node) CheckAddressKnown(addr net.NodeAddr) bool {
 	if !n.NodeNet.CheckIsKnown(addr) {
 		// send him all addresses
-		n.Logger.Trace.Printf("sending list of address to %s , %s", addr.NodeAddrToString(), n.NodeNet.Nodes)
+		n.Logger.Trace.Printf("sending list of address to %s , %v", addr.NodeAddrToString(), n.NodeNet.Nodes)
 		n.NodeClient.SendAddrList(addr, n.NodeNet.Nodes)
 
 		n.NodeNet.AddNodeToKnown(addr)
@@ -271,7 +283,7 @@ func (n *Node) CheckAddressKnown(addr net.NodeAddr) bool {
 * Send money .
 * This adds a transaction directly to the DB. Can be executed when a node server is not running
  */
-func (n *Node) Send(PubKey []byte, privKey ecdsa.PrivateKey, to string, amount float64) ([]byte, error) {
+func (n *Node) Send(PubKey []byte, privKey ecdsa.PrivateKey, to string, amount lib.Amount, fee lib.Amount) ([]byte, error) {
 	// get pubkey of the wallet with "from" address
 	if to == "" {
 		return nil, errors.New("Recipient address is not provided")
@@ -282,7 +294,19 @@ func (n *Node) Send(PubKey []byte, privKey ecdsa.PrivateKey, to string, amount f
 		return nil, errors.New("Recipient address is not valid")
 	}
 
-	tx, err := n.GetTransactionsManager().CreateTransaction(PubKey, privKey, to, amount)
+	tx, err := n.GetTransactionsManager().CreateTransaction(PubKey, privKey, to, amount, fee)
+
+	if err != nil {
+		return nil, err
+	}
+	n.SendTransactionToAll(tx)
+
+	return tx.ID, nil
+}
+
+// Sends a transaction with a data output. Inputs of the key cover a fee, the rest goes back
+func (n *Node) SendData(PubKey []byte, privKey ecdsa.PrivateKey, data []byte, fee lib.Amount) ([]byte, error) {
+	tx, err := n.GetTransactionsManager().CreateDataTransaction(PubKey, privKey, data, fee)
 
 	if err != nil {
 		return nil, err
@@ -304,7 +328,11 @@ func (n *Node) TryToMakeBlock(newTransactionID []byte) ([]byte, error) {
 
 	n.Logger.Trace.Println("Create block maker")
 	// check how many transactions are ready to be added to a block
-	Minter, _ := n.getBlockMakeManager()
+	Minter, err := n.getBlockMakeManager()
+
+	if err != nil {
+		return nil, err
+	}
 
 	prepres, err := Minter.PrepareNewBlock()
 
@@ -369,7 +397,9 @@ func (n *Node) TryToMakeBlock(newTransactionID []byte) ([]byte, error) {
 }
 
 // Add new block to blockchain.
-// It can be executed when new block was created locally or received from other node
+// It can be executed when new block was created locally or received from other node.
+// The block, chain pointers and transactions caches are saved together, events about them
+// are published after this
 
 func (n *Node) AddBlock(block *structures.Block) (uint, error) {
 	bcm, err := n.GetBCManager()
@@ -380,48 +410,75 @@ func (n *Node) AddBlock(block *structures.Block) (uint, error) {
 
 	curLastHash, _, err := bcm.GetState()
 
-	// we need to know how the block was added to managed transactions caches correctly
-	addstate, err := n.NodeBC.AddBlock(block)
+	var addstate uint
+	var newChain, oldChain []*structures.Block
 
-	if err != nil {
-		return 0, err
-	}
+	err = n.inBatchWithEvents(func(txm transactions.TransactionsManagerInterface) error {
+		var err error
+		// we need to know how the block was added to managed transactions caches correctly
+		addstate, err = n.NodeBC.AddBlock(block)
 
-	if addstate == blockchain.BCBAddState_addedToParallel ||
-		addstate == blockchain.BCBAddState_addedToTop ||
-		addstate == blockchain.BCBAddState_addedToParallelTop {
+		if err != nil {
+			return err
+		}
 
-		n.GetTransactionsManager().BlockAdded(block, addstate == blockchain.BCBAddState_addedToTop)
-	}
+		if addstate == blockchain.BCBAddState_addedToParallel ||
+			addstate == blockchain.BCBAddState_addedToTop ||
+			addstate == blockchain.BCBAddState_addedToParallelTop {
+
+			err = txm.BlockAdded(block, addstate == blockchain.BCBAddState_addedToTop)
+
+			if err != nil {
+				return err
+			}
+		}
 
-	if addstate == blockchain.BCBAddState_addedToParallelTop {
+		if addstate != blockchain.BCBAddState_addedToParallelTop {
+			return nil
+		}
 		// get 2 blocks branches that replaced each other
-		newChain, oldChain, err := n.NodeBC.GetBranchesReplacement(curLastHash, []byte{})
+		newChain, oldChain, err = n.NodeBC.GetBranchesReplacement(curLastHash, []byte{})
 
 		if err != nil {
-			return 0, err
+			return err
+		}
+
+		if newChain == nil || oldChain == nil {
+			return nil
 		}
 
-		if newChain != nil && oldChain != nil {
-			for _, block := range oldChain {
+		for _, block := range oldChain {
 
-				err := n.GetTransactionsManager().BlockRemovedFromPrimaryChain(block)
+			err := txm.BlockRemovedFromPrimaryChain(block)
 
-				if err != nil {
+			if err != nil {
 
-					return 0, err
-				}
+				return err
 			}
-			for _, block := range newChain {
+		}
+		for _, block := range newChain {
 
-				err := n.GetTransactionsManager().BlockAddedToPrimaryChain(block)
+			err := txm.BlockAddedToPrimaryChain(block)
 
-				if err != nil {
+			if err != nil {
 
-					return 0, err
-				}
+				return err
 			}
 		}
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if newChain != nil && oldChain != nil {
+		metrics.ReorgsTotal.Inc()
+
+		topHash, topHeight, _ := bcm.GetState()
+
+		n.Events.Publish(events.Event{Kind: events.KindReorg, BlockHash: topHash, Height: topHeight,
+			PrevTop: curLastHash, Removed: len(oldChain), Added: len(newChain)})
 	}
 
 	return addstate, nil
@@ -432,13 +489,31 @@ func (n *Node) AddBlock(block *structures.Block) (uint, error) {
 * This will not check if there are other branch that can now be longest and becomes main branch
  */
 func (n *Node) DropBlock() error {
-	block, err := n.NodeBC.DropBlock()
+	return n.inBatchWithEvents(func(txm transactions.TransactionsManagerInterface) error {
+		block, err := n.NodeBC.DropBlock()
+
+		if err != nil {
+			return err
+		}
+
+		return txm.BlockRemoved(block)
+	})
+}
+
+// Executes a function in a batch of the blockchain DB. Events of the transactions manager are
+// published only after the batch is saved. If it fails, nobody hears about blocks that were rolled back
+func (n *Node) inBatchWithEvents(fn func(txm transactions.TransactionsManagerInterface) error) error {
+	queue := events.NewQueue(n.Events)
+
+	err := n.DBConn.InBatch(func() error {
+		return fn(transactions.NewManagerWithEvents(n.DBConn.DB(), n.Logger, queue))
+	})
 
 	if err != nil {
 		return err
 	}
 
-	n.GetTransactionsManager().BlockRemoved(block)
+	queue.Flush()
 
 	return nil
 }
@@ -539,3 +614,158 @@ func (n *Node) GetNodeState() (nodeclient.ComGetNodeState, error) {
 
 	return result, nil
 }
+
+// Returns number of coins in circulation after a block with given height.
+// If height is negative then a top block is used
+func (n *Node) GetSupply(height int) (nodeclient.ComSupply, error) {
+	result := nodeclient.ComSupply{}
+
+	bh, err := n.NodeBC.GetBestHeight()
+
+	if err != nil {
+		return result, err
+	}
+
+	if height < 0 {
+		height = bh
+	}
+
+	if height > bh {
+		return result, errors.New(fmt.Sprintf("Height %d is more than height of the chain %d", height, bh))
+	}
+
+	emission := n.ConsensusConfig.GetEmission()
+
+	result.Height = height
+	result.Supply = emission.GetSupply(height)
+	result.BlockReward = emission.GetBlockReward(height)
+	result.MaxSupply = emission.MaxSupply
+
+	return result, nil
+}
+
+// Returns headers of blocks of the main chain starting from given height. It is used by light clients
+func (n *Node) GetHeaders(from int, count int) ([]nodeclient.ComBlockHeader, error) {
+	if count > nodeclient.MaxHeadersPerRequest {
+		count = nodeclient.MaxHeadersPerRequest
+	}
+
+	blocks, err := n.NodeBC.GetBCManager().GetBlocksByHeight(from, count)
+
+	if err != nil {
+		return nil, err
+	}
+
+	headers := []nodeclient.ComBlockHeader{}
+
+	for _, block := range blocks {
+		headers = append(headers, makeBlockHeader(block))
+	}
+
+	return headers, nil
+}
+
+// Returns headers of a block and blocks before it, not more than count. Headers are in order of heights
+func (n *Node) GetHeadersBefore(hash []byte, count int) ([]nodeclient.ComBlockHeader, error) {
+	bcm := n.NodeBC.GetBCManager()
+
+	headers := []nodeclient.ComBlockHeader{}
+
+	for len(hash) > 0 && len(headers) < count {
+		block, err := bcm.GetBlock(hash)
+
+		if err != nil {
+			return nil, err
+		}
+		headers = append([]nodeclient.ComBlockHeader{makeBlockHeader(&block)}, headers...)
+
+		hash = block.PrevBlockHash
+	}
+	return headers, nil
+}
+
+// Returns proof of work and number of blocks of the main chain above given block
+func (n *Node) GetChainWorkAbove(hash []byte) (*big.Int, int, error) {
+	bcm := n.NodeBC.GetBCManager()
+
+	work := big.NewInt(0)
+	count := 0
+
+	top, err := n.NodeBC.GetTopBlockHash()
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for len(top) > 0 && !bytes.Equal(top, hash) {
+		block, err := bcm.GetBlock(top)
+
+		if err != nil {
+			return nil, 0, err
+		}
+		work.Add(work, utils.GetWorkForBits(block.Bits))
+		count++
+
+		top = block.PrevBlockHash
+	}
+
+	if len(top) == 0 && len(hash) > 0 {
+		return nil, 0, errors.New("Block is not in the main chain")
+	}
+	return work, count, nil
+}
+
+func makeBlockHeader(block *structures.Block) nodeclient.ComBlockHeader {
+	h := nodeclient.ComBlockHeader{}
+	h.Hash = block.Hash
+	h.PrevBlockHash = block.PrevBlockHash
+	h.MerkleRoot = block.MerkleRoot
+	h.Timestamp = block.Timestamp
+	h.Nonce = block.Nonce
+	h.Height = block.Height
+	h.Bits = block.Bits
+
+	return h
+}
+
+// Returns a proof that a transaction is in a block of the main chain. It is used by light clients
+func (n *Node) GetMerkleProof(txID []byte) (nodeclient.ComMerkleProof, error) {
+	result := nodeclient.ComMerkleProof{}
+
+	bcm := n.NodeBC.GetBCManager()
+
+	proof, blockHash, err := bcm.GetMerkleProof(txID)
+
+	if err != nil {
+		return result, err
+	}
+
+	tx, err := bcm.GetTransactionFromBlock(txID, blockHash)
+
+	if err != nil {
+		return result, err
+	}
+
+	result.BlockHash = blockHash
+	result.Proof = proof
+	result.TXID = tx.ID
+	result.Time = tx.Time
+	result.LockTime = tx.LockTime
+	result.Inputs = [][]byte{}
+	result.Outputs = []nodeclient.ComTXOutput{}
+
+	for _, vin := range tx.Vin {
+		b, err := vin.ToBytes()
+
+		if err != nil {
+			return result, err
+		}
+		result.Inputs = append(result.Inputs, b)
+	}
+
+	for _, vout := range tx.Vout {
+		result.Outputs = append(result.Outputs, nodeclient.ComTXOutput{Value: vout.Value, PubKeyHash: vout.PubKeyHash, Data: vout.Data})
+	}
+
+	return result, nil
+}
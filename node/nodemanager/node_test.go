@@ -0,0 +1,107 @@
+package nodemanager
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/utils"
+	"github.com/taincoin/taincoin/node/database"
+	"github.com/taincoin/taincoin/node/events"
+	"github.com/taincoin/taincoin/node/structures"
+	"github.com/taincoin/taincoin/node/transactions"
+)
+
+// DB manager that fails to save a batch with given error
+type failingCommitDB struct {
+	database.DBManager
+	commitErr error
+}
+
+func (db *failingCommitDB) CommitBatch() error {
+	if db.commitErr != nil {
+		db.DBManager.RollbackBatch()
+		return db.commitErr
+	}
+	return db.DBManager.CommitBatch()
+}
+
+func newTestNode(t *testing.T, commitErr error) (*Node, <-chan events.Event) {
+	logger := utils.CreateLogger()
+
+	man := database.NewDBManager(database.DatabaseConfig{Engine: database.EngineMemory}, "")
+	man.SetLogger(logger)
+	man.SetLockerObject(man.GetLockerObject())
+
+	err := man.InitDatabase()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = man.OpenConnection("testing")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := &Node{}
+	n.Logger = logger
+	n.Events = events.NewBus()
+	n.DBConn = &Database{}
+	n.DBConn.db = &failingCommitDB{man, commitErr}
+
+	_, ch := n.Events.Subscribe()
+
+	return n, ch
+}
+
+// Adds a block with a coinbase transaction to the main chain caches. It publishes block and payment events
+func addTestBlock(txm transactions.TransactionsManagerInterface) error {
+	cbtx := &structures.Transaction{}
+
+	err := cbtx.MakeCoinbaseTX("1PZ9kYFt8aUHU5PLT9yLXEgxsGB3RV8dAD", "test", lib.PaymentForBlockMade, 0)
+
+	if err != nil {
+		return err
+	}
+
+	block := &structures.Block{Hash: []byte{1}, Height: 1, Transactions: []*structures.Transaction{cbtx}}
+
+	return txm.BlockAddedToPrimaryChain(block)
+}
+
+func TestEventsNotPublishedIfCommitFails(t *testing.T) {
+	n, ch := newTestNode(t, errors.New("disk is full"))
+
+	err := n.inBatchWithEvents(addTestBlock)
+
+	if err == nil {
+		t.Fatal("Expected error of commit")
+	}
+
+	select {
+	case e := <-ch:
+		t.Fatalf("Event %s is published for rolled back changes", e.Kind)
+	default:
+	}
+}
+
+func TestEventsPublishedAfterCommit(t *testing.T) {
+	n, ch := newTestNode(t, nil)
+
+	err := n.inBatchWithEvents(addTestBlock)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-ch:
+		if e.Kind != events.KindBlock {
+			t.Fatalf("Expected block event first. Got %s", e.Kind)
+		}
+	default:
+		t.Fatal("Block event is not published")
+	}
+}
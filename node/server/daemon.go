@@ -16,6 +16,7 @@ import (
 	"github.com/taincoin/taincoin/lib/utils"
 	"github.com/taincoin/taincoin/lib/wallet"
 	"github.com/taincoin/taincoin/node/config"
+	"github.com/taincoin/taincoin/node/events"
 	"github.com/taincoin/taincoin/node/nodemanager"
 )
 
@@ -26,6 +27,9 @@ type NodeDaemon struct {
 	Server  *NodeServer
 	Logger  *utils.LoggerMan
 	Node    *nodemanager.Node
+	RPC     config.RPCConfig
+	Metrics config.MetricsConfig
+	Stamps  config.TimestampConfig
 }
 
 func (n *NodeDaemon) Init() error {
@@ -60,8 +64,18 @@ func (n *NodeDaemon) createServer() error {
 	server.Logger = n.Logger
 
 	server.Transit.Init(n.Logger)
+	server.Sync.Init(&server, n.Logger)
 
 	server.Node = n.Node
+	server.RPC = n.RPC
+	server.Metrics = n.Metrics
+	server.Timestamp = n.Stamps
+
+	if n.RPC.IsEnabled() || n.Stamps.IsEnabled() || n.Metrics.IsEnabled() {
+		// events are pushed to websocket subscribers. timestamps are anchored on new blocks.
+		// metrics read the DB state again only after changes
+		n.Node.Events = events.NewBus()
+	}
 
 	n.Server = &server
 
@@ -124,9 +138,8 @@ func (n *NodeDaemon) checkArgumentsAreFine() error {
 
 	walletscli.Init(n.Logger, winput)
 
-	_, err := walletscli.WalletsObj.GetWallet(n.Server.Node.MinterAddress)
-
-	if err != nil {
+	// wallet can be encrypted. only check the address is there
+	if walletscli.WalletsObj == nil || !walletscli.WalletsObj.HasAddress(n.Server.Node.MinterAddress) {
 		return errors.New("Minter Address can not be loaded from wallet. Does it exist?")
 	}
 
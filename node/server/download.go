@@ -0,0 +1,324 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/taincoin/taincoin/lib/net"
+	"github.com/taincoin/taincoin/lib/nodeclient"
+	"github.com/taincoin/taincoin/node/nodemanager"
+	"github.com/taincoin/taincoin/node/structures"
+)
+
+const (
+	downloadRangeSize    = 16               // number of blocks in one range requested from a node
+	downloadMaxInFlight  = 8                // max number of ranges loading at same time
+	downloadMaxAhead     = 512              // max number of blocks loaded but not yet added to the chain
+	downloadStallTimeout = 60 * time.Second // a range not loaded in this time is requested from other node
+)
+
+// Range of headers to load bodies for. Indexes in a headers list
+type downloadRange struct {
+	start int
+	end   int
+}
+
+// Range loading in progress
+type downloadRequest struct {
+	node    int
+	started time.Time
+}
+
+// Result of loading of a range from a node
+type downloadResult struct {
+	r      downloadRange
+	node   int
+	blocks []*structures.Block
+	err    error
+}
+
+// Loaded block waiting to be added to the chain
+type loadedBlock struct {
+	block *structures.Block
+	r     downloadRange
+	node  int
+}
+
+// Download scheduler. Missed blocks are split to ranges of heights and ranges are loaded
+// from all nodes in parallel. One node loads one range at a time. A range that failed or stalled is
+// requested from other node, such node is not used more.
+// Loaded blocks are added to the chain in order of heights. If a block can not be added, it is
+// requested from other node, blocks got from the node that sent it are not used
+type blocksDownloader struct {
+	ns      *nodeSync
+	headers []nodeclient.ComBlockHeader
+	nodes   []net.NodeAddr
+
+	pending  []downloadRange
+	inflight map[downloadRange]downloadRequest
+	busy     []bool
+	bad      []bool
+	invalid  []bool // nodes sent a block that was not added
+	loaded   map[int]loadedBlock
+	next     int // index of next block to add
+	results  chan downloadResult
+
+	loadBlock func(node net.NodeAddr, header nodeclient.ComBlockHeader) (*structures.Block, error)
+}
+
+func newBlocksDownloader(ns *nodeSync, n *nodemanager.Node, headers []nodeclient.ComBlockHeader, nodes []net.NodeAddr) *blocksDownloader {
+	d := &blocksDownloader{}
+	d.ns = ns
+	d.headers = headers
+	d.nodes = nodes
+
+	d.pending = []downloadRange{}
+
+	for start := 0; start < len(headers); start += downloadRangeSize {
+		end := start + downloadRangeSize
+
+		if end > len(headers) {
+			end = len(headers)
+		}
+		d.pending = append(d.pending, downloadRange{start, end})
+	}
+
+	d.inflight = map[downloadRange]downloadRequest{}
+	d.busy = make([]bool, len(nodes))
+	d.bad = make([]bool, len(nodes))
+	d.invalid = make([]bool, len(nodes))
+	d.loaded = map[int]loadedBlock{}
+	// every node has max one request. so, a routine never waits to send a result, even after we return
+	d.results = make(chan downloadResult, len(nodes))
+
+	d.loadBlock = func(node net.NodeAddr, header nodeclient.ComBlockHeader) (*structures.Block, error) {
+		return ns.loadBlock(n, node, header)
+	}
+
+	return d
+}
+
+// Loads all blocks. Calls add for every block in order of heights. Returns number of added blocks
+func (d *blocksDownloader) Run(add func(block *structures.Block) error) (int, error) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for d.next < len(d.headers) {
+		d.schedule()
+
+		if len(d.inflight) == 0 && len(d.pending) > 0 {
+			return d.next, errors.New(fmt.Sprintf("No nodes to load block %x from", d.headers[d.pending[0].start].Hash))
+		}
+
+		select {
+		case res := <-d.results:
+			d.received(res)
+		case <-ticker.C:
+			d.checkStalled()
+		}
+
+		// hand blocks to the chain in order
+		for d.next < len(d.headers) {
+			lb, ok := d.loaded[d.next]
+
+			if !ok {
+				break
+			}
+
+			err := add(lb.block)
+
+			if err != nil {
+				if !d.retry(lb, err) {
+					return d.next, err
+				}
+				break
+			}
+			delete(d.loaded, d.next)
+			d.next++
+		}
+	}
+	return d.next, nil
+}
+
+// Returns number of nodes still used
+func (d *blocksDownloader) GetGoodNodesCount() int {
+	count := 0
+
+	for i := range d.nodes {
+		if !d.bad[i] {
+			count++
+		}
+	}
+	return count
+}
+
+// Sends pending ranges to free nodes
+func (d *blocksDownloader) schedule() {
+	for len(d.pending) > 0 && len(d.inflight) < downloadMaxInFlight {
+		r := d.pending[0]
+
+		if r.start-d.next >= downloadMaxAhead {
+			// wait for blocks before to be added
+			return
+		}
+
+		node := d.getFreeNode()
+
+		if node < 0 {
+			return
+		}
+
+		d.pending = d.pending[1:]
+		d.inflight[r] = downloadRequest{node, time.Now()}
+		d.busy[node] = true
+
+		go d.load(r, node)
+	}
+}
+
+func (d *blocksDownloader) getFreeNode() int {
+	for i := range d.nodes {
+		if !d.busy[i] && !d.bad[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// Loads blocks of a range from a node. Works in a separate routine
+func (d *blocksDownloader) load(r downloadRange, node int) {
+	res := downloadResult{r: r, node: node, blocks: []*structures.Block{}}
+
+	for i := r.start; i < r.end; i++ {
+		block, err := d.loadBlock(d.nodes[node], d.headers[i])
+
+		if err != nil {
+			res.err = err
+			break
+		}
+		res.blocks = append(res.blocks, block)
+	}
+	d.results <- res
+}
+
+func (d *blocksDownloader) received(res downloadResult) {
+	d.busy[res.node] = false
+
+	if d.invalid[res.node] {
+		// the node sent a block we could not add, don't trust other its blocks
+		res.err = errors.New("Node sent invalid block before")
+	}
+
+	req, ok := d.inflight[res.r]
+
+	if ok && req.node == res.node {
+		delete(d.inflight, res.r)
+	} else {
+		// the range was requested from other node after a stall. still use blocks if they are fine
+		ok = false
+	}
+
+	if res.err != nil {
+		d.ns.Logger.Trace.Printf("Blocks %d-%d from %s failed: %s", d.headers[res.r.start].Height,
+			d.headers[res.r.end-1].Height, d.nodes[res.node].NodeAddrToString(), res.err.Error())
+
+		d.setBad(res.node)
+
+		if ok {
+			d.requeue(res.r)
+		}
+		return
+	}
+
+	if !ok {
+		d.removePending(res.r)
+	}
+
+	for i, block := range res.blocks {
+		if res.r.start+i >= d.next {
+			d.loaded[res.r.start+i] = loadedBlock{block, res.r, res.node}
+		}
+	}
+}
+
+// A block was not added to the chain. Drops all blocks of the node sent it and requests
+// them from other nodes. Returns false if there are no other nodes
+func (d *blocksDownloader) retry(lb loadedBlock, err error) bool {
+	d.ns.Logger.Trace.Printf("Block %d from %s was not added: %s", d.headers[d.next].Height,
+		d.nodes[lb.node].NodeAddrToString(), err.Error())
+
+	d.invalid[lb.node] = true
+	d.setBad(lb.node)
+
+	ranges := map[downloadRange]bool{}
+
+	for i, l := range d.loaded {
+		if l.node == lb.node {
+			ranges[l.r] = true
+			delete(d.loaded, i)
+		}
+	}
+
+	for r := range ranges {
+		if r.start < d.next {
+			// blocks before are in the chain already
+			r.start = d.next
+		}
+		d.requeue(r)
+	}
+
+	return d.GetGoodNodesCount() > 0
+}
+
+// Finds ranges loading too long and requests them from other nodes
+func (d *blocksDownloader) checkStalled() {
+	for r, req := range d.inflight {
+		if time.Since(req.started) < downloadStallTimeout {
+			continue
+		}
+		d.ns.Logger.Trace.Printf("Blocks %d-%d from %s stalled", d.headers[r.start].Height,
+			d.headers[r.end-1].Height, d.nodes[req.node].NodeAddrToString())
+
+		delete(d.inflight, r)
+		// the node stays busy until its request returns
+		d.setBad(req.node)
+		d.requeue(r)
+	}
+}
+
+// Returns a range to the queue. The queue is kept in order of heights
+func (d *blocksDownloader) requeue(r downloadRange) {
+	if _, ok := d.loaded[r.start]; ok || r.start < d.next {
+		// was loaded by other node
+		return
+	}
+
+	pos := 0
+
+	for pos < len(d.pending) && d.pending[pos].start < r.start {
+		pos++
+	}
+
+	d.pending = append(d.pending[:pos], append([]downloadRange{r}, d.pending[pos:]...)...)
+}
+
+// Removes a range from the queue. It is used when a stalled node returned blocks later
+func (d *blocksDownloader) removePending(r downloadRange) {
+	for i, p := range d.pending {
+		if p == r {
+			d.pending = append(d.pending[:i], d.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+func (d *blocksDownloader) setBad(node int) {
+	d.bad[node] = true
+
+	count := d.GetGoodNodesCount()
+
+	d.ns.updateState(func(state *syncState) {
+		state.Nodes = count
+	})
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/taincoin/taincoin/lib/net"
+	"github.com/taincoin/taincoin/lib/nodeclient"
+	"github.com/taincoin/taincoin/lib/utils"
+	"github.com/taincoin/taincoin/node/structures"
+)
+
+// Makes a downloader for given number of blocks and nodes. Blocks are loaded with a function
+func makeTestDownloader(count int, nodes int,
+	load func(node int, height int) (*structures.Block, error)) *blocksDownloader {
+
+	ns := &nodeSync{}
+	ns.Init(nil, utils.CreateLogger())
+
+	headers := []nodeclient.ComBlockHeader{}
+
+	for i := 0; i < count; i++ {
+		headers = append(headers, nodeclient.ComBlockHeader{Hash: []byte{byte(i)}, Height: i + 1})
+	}
+
+	addrs := []net.NodeAddr{}
+
+	for i := 0; i < nodes; i++ {
+		addrs = append(addrs, net.NodeAddr{Host: "localhost", Port: 20000 + i})
+	}
+
+	d := newBlocksDownloader(ns, nil, headers, addrs)
+
+	d.loadBlock = func(node net.NodeAddr, header nodeclient.ComBlockHeader) (*structures.Block, error) {
+		return load(node.Port-20000, header.Height)
+	}
+	return d
+}
+
+func makeTestBlock(node int, height int) *structures.Block {
+	return &structures.Block{Height: height, Hash: []byte{byte(height)}, Nonce: node}
+}
+
+// Collects heights of added blocks and checks they are in order
+func checkAddedInOrder(t *testing.T, d *blocksDownloader, count int, add func(block *structures.Block) error) {
+	added := []int{}
+
+	n, err := d.Run(func(block *structures.Block) error {
+		err := add(block)
+
+		if err == nil {
+			added = append(added, block.Height)
+		}
+		return err
+	})
+
+	if err != nil {
+		t.Fatalf("Download failed: %s", err.Error())
+	}
+
+	if n != count || len(added) != count {
+		t.Fatalf("Expected %d blocks added, got %d, %d", count, n, len(added))
+	}
+
+	for i, h := range added {
+		if h != i+1 {
+			t.Fatalf("Blocks added not in order: %v", added)
+		}
+	}
+}
+
+func TestDownloadOutOfOrder(t *testing.T) {
+	count := 4 * downloadRangeSize
+
+	// first ranges are loaded slower than last ones
+	d := makeTestDownloader(count, 4, func(node int, height int) (*structures.Block, error) {
+		if height <= 2*downloadRangeSize {
+			time.Sleep(time.Millisecond)
+		}
+		return makeTestBlock(node, height), nil
+	})
+
+	checkAddedInOrder(t, d, count, func(block *structures.Block) error {
+		return nil
+	})
+
+	if d.GetGoodNodesCount() != 4 {
+		t.Fatalf("All nodes must stay good")
+	}
+}
+
+func TestDownloadNodeFailed(t *testing.T) {
+	count := 3 * downloadRangeSize
+
+	// node 0 fails on second block of its range
+	d := makeTestDownloader(count, 2, func(node int, height int) (*structures.Block, error) {
+		if node == 0 && height == 2 {
+			return nil, errors.New("Connection closed")
+		}
+		return makeTestBlock(node, height), nil
+	})
+
+	checkAddedInOrder(t, d, count, func(block *structures.Block) error {
+		return nil
+	})
+
+	if !d.bad[0] || d.bad[1] {
+		t.Fatalf("Only failed node must be marked bad")
+	}
+}
+
+func TestDownloadRetryNotAddedBlock(t *testing.T) {
+	count := 3 * downloadRangeSize
+
+	lock := sync.Mutex{}
+	loadedFrom := map[int][]int{}
+
+	d := makeTestDownloader(count, 3, func(node int, height int) (*structures.Block, error) {
+		lock.Lock()
+		loadedFrom[height] = append(loadedFrom[height], node)
+		lock.Unlock()
+
+		return makeTestBlock(node, height), nil
+	})
+
+	// a block from node 0 can not be added. It must be loaded from other node
+	checkAddedInOrder(t, d, count, func(block *structures.Block) error {
+		if block.Nonce == 0 {
+			return errors.New("Block is not valid")
+		}
+		return nil
+	})
+
+	if !d.bad[0] || !d.invalid[0] {
+		t.Fatalf("Node sent not valid block must be marked bad")
+	}
+
+	if len(loadedFrom[1]) != 2 {
+		t.Fatalf("First block must be loaded again from other node, loaded from %v", loadedFrom[1])
+	}
+}
+
+func TestDownloadNoNodesToRetry(t *testing.T) {
+	d := makeTestDownloader(downloadRangeSize, 1, func(node int, height int) (*structures.Block, error) {
+		return makeTestBlock(node, height), nil
+	})
+
+	n, err := d.Run(func(block *structures.Block) error {
+		if block.Height == 3 {
+			return errors.New("Block is not valid")
+		}
+		return nil
+	})
+
+	if err == nil || err.Error() != "Block is not valid" {
+		t.Fatalf("Expected add error when no other nodes")
+	}
+
+	if n != 2 {
+		t.Fatalf("Expected 2 blocks added, got %d", n)
+	}
+}
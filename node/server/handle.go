@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/taincoin/taincoin/lib"
 	"github.com/taincoin/taincoin/lib/net"
 	"github.com/taincoin/taincoin/lib/nodeclient"
 	"github.com/taincoin/taincoin/lib/utils"
@@ -68,7 +69,7 @@ func (s *NodeServerRequest) handleGetUnspent() error {
 	}
 
 	err = s.Node.GetTransactionsManager().ForEachUnspentOutput(payload.Address,
-		func(fromaddr string, value float64, txID []byte, output int, isbase bool) error {
+		func(fromaddr string, value lib.Amount, txID []byte, output int, isbase bool) error {
 			ut := nodeclient.ComUnspentTransaction{}
 			ut.Amount = value
 			ut.TXID = txID
@@ -165,7 +166,7 @@ func (s *NodeServerRequest) handleGetBalance() error {
 	if err != nil {
 		return err
 	}
-	s.Logger.Trace.Printf("Return balance for %s. %.8f, %.8f, %.8f", payload.Address, balance.Total, balance.Approved, balance.Pending)
+	s.Logger.Trace.Printf("Return balance for %s. %s, %s, %s", payload.Address, balance.Total, balance.Approved, balance.Pending)
 	return nil
 }
 
@@ -255,8 +256,16 @@ func (s *NodeServerRequest) handleTxRequest() error {
 
 	result := nodeclient.ComRequestTransactionData{}
 
-	TXBytes, DataToSign, err := s.Node.GetTransactionsManager().
-		PrepareNewTransaction(payload.PubKey, payload.To, payload.Amount)
+	var TXBytes []byte
+	var DataToSign [][]byte
+
+	if len(payload.Data) > 0 {
+		TXBytes, DataToSign, err = s.Node.GetTransactionsManager().
+			PrepareNewDataTransaction(payload.PubKey, payload.Data, payload.Fee)
+	} else {
+		TXBytes, DataToSign, err = s.Node.GetTransactionsManager().
+			PrepareNewTransaction(payload.PubKey, payload.To, payload.Amount, payload.Fee)
+	}
 
 	if err != nil {
 		return err
@@ -265,6 +274,12 @@ func (s *NodeServerRequest) handleTxRequest() error {
 	result.DataToSign = DataToSign
 	result.TX = TXBytes
 
+	result.PrevTXs, err = s.Node.GetTransactionsManager().GetInputTransactions(TXBytes)
+
+	if err != nil {
+		return err
+	}
+
 	s.Response, err = net.GobEncode(result)
 
 	if err != nil {
@@ -322,7 +337,7 @@ func (s *NodeServerRequest) handleAddr() error {
 	}
 	addednodes := []net.NodeAddr{}
 
-	s.Logger.Trace.Printf("SessID: %s . Received nodes %s", s.SessID, payload)
+	s.Logger.Trace.Printf("SessID: %s . Received nodes %v", s.SessID, payload)
 
 	for _, node := range payload {
 		s.Logger.Trace.Printf("SessID: %s . node %s", s.SessID, node.NodeAddrToString())
@@ -434,7 +449,10 @@ func (s *NodeServerRequest) handleInv() error {
 
 	s.Logger.Trace.Printf("SessID: %s . Recevied inventory with %d %s\n", s.SessID, len(payload.Items), payload.Type)
 
-	if payload.Type == "block" {
+	if payload.Type == "block" && s.S.Sync.IsRunning() {
+		// blocks will be loaded by the synchronization. new blocks are requested after it completes
+		s.Logger.Trace.Printf("Skip blocks inventory. Synchronization is in progress")
+	} else if payload.Type == "block" {
 
 		s.S.Transit.AddBlocks(payload.AddrFrom, payload.Items)
 
@@ -703,7 +721,7 @@ func (s *NodeServerRequest) handleVersion() error {
 		return err
 	}
 
-	topHash, myBestHeight, err := s.Node.NodeBC.GetBCManager().GetState()
+	_, myBestHeight, err := s.Node.NodeBC.GetBCManager().GetState()
 
 	if err != nil {
 		return err
@@ -725,7 +743,9 @@ func (s *NodeServerRequest) handleVersion() error {
 			s.S.Transit.MaxKnownHeigh = foreignerBestHeight
 		}
 
-		s.Node.NodeClient.SendGetBlocksUpper(payload.AddrFrom, topHash)
+		if !s.S.Sync.Start(payload.AddrFrom) {
+			s.Logger.Trace.Printf("Synchronization is already in progress")
+		}
 
 	} else if myBestHeight > foreignerBestHeight {
 		s.Logger.Trace.Printf("Send my version back to %s\n", payload.AddrFrom.NodeAddrToString())
@@ -808,6 +828,247 @@ func (s *NodeServerRequest) handleRemoveNode() error {
 	return nil
 }
 
+// Supply of coins after a block with given height
+func (s *NodeServerRequest) handleGetSupply() error {
+	s.HasResponse = true
+
+	var payload nodeclient.ComGetSupply
+
+	err := s.parseRequestData(&payload)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := s.Node.GetSupply(payload.Height)
+
+	if err != nil {
+		return err
+	}
+
+	s.Response, err = net.GobEncode(result)
+
+	if err != nil {
+		return err
+	}
+	s.Logger.Trace.Printf("Return supply %s for height %d", result.Supply, result.Height)
+	return nil
+}
+
+// Headers of blocks. Light clients use it to verify proof of work without full blocks
+func (s *NodeServerRequest) handleGetHeaders() error {
+	s.HasResponse = true
+
+	var payload nodeclient.ComGetHeaders
+
+	err := s.parseRequestData(&payload)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := s.Node.GetHeaders(payload.From, payload.Count)
+
+	if err != nil {
+		return err
+	}
+
+	s.Response, err = net.GobEncode(result)
+
+	if err != nil {
+		return err
+	}
+	s.Logger.Trace.Printf("Return %d headers from height %d", len(result), payload.From)
+	return nil
+}
+
+// Proof that a transaction is in a block. Light clients check it against a block header
+func (s *NodeServerRequest) handleGetMerkleProof() error {
+	s.HasResponse = true
+
+	var payload nodeclient.ComGetMerkleProof
+
+	err := s.parseRequestData(&payload)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := s.Node.GetMerkleProof(payload.TXID)
+
+	if err != nil {
+		return err
+	}
+
+	s.Response, err = net.GobEncode(result)
+
+	if err != nil {
+		return err
+	}
+	s.Logger.Trace.Printf("Return merkle proof for %x in block %x", payload.TXID, result.BlockHash)
+	return nil
+}
+
+// Secret of a hashed time-locked contract. Wallets use it to finish atomic swaps
+func (s *NodeServerRequest) handleGetSecret() error {
+	s.HasResponse = true
+
+	var payload nodeclient.ComGetSecret
+
+	err := s.parseRequestData(&payload)
+
+	if err != nil {
+		return err
+	}
+
+	result := nodeclient.ComSecret{}
+
+	result.Secret, result.TXID, err = s.Node.NodeBC.FindHTLCSecret(payload.SecretHash)
+
+	if err != nil {
+		return err
+	}
+
+	s.Response, err = net.GobEncode(result)
+
+	if err != nil {
+		return err
+	}
+	s.Logger.Trace.Printf("Return secret for hash %x found in %x", payload.SecretHash, result.TXID)
+	return nil
+}
+
+// Block where data were anchored. Clients use it to prove data existed at time of the block
+func (s *NodeServerRequest) handleGetAnchor() error {
+	s.HasResponse = true
+
+	var payload nodeclient.ComGetAnchor
+
+	err := s.parseRequestData(&payload)
+
+	if err != nil {
+		return err
+	}
+
+	result := nodeclient.ComAnchor{}
+
+	tx, block, err := s.Node.GetTransactionsManager().FindDataTransaction(payload.Data)
+
+	if err != nil {
+		return err
+	}
+
+	if tx != nil {
+		result.TXID = tx.ID
+		result.BlockHash = block.Hash
+		result.Height = block.Height
+		result.Timestamp = block.Timestamp
+	}
+
+	s.Response, err = net.GobEncode(result)
+
+	if err != nil {
+		return err
+	}
+	s.Logger.Trace.Printf("Return anchor of %x found in %x", payload.Data, result.TXID)
+	return nil
+}
+
+// Hashes of documents to timestamp. They are anchored with next batch
+func (s *NodeServerRequest) handleStamp() error {
+	if !s.NodeAuthStrIsGood {
+		return errors.New("Local Network Auth is required")
+	}
+
+	s.HasResponse = true
+
+	if s.S.stamps == nil {
+		return errors.New("Timestamping is not enabled on this node")
+	}
+
+	var payload nodeclient.ComStamp
+
+	err := s.parseRequestData(&payload)
+
+	if err != nil {
+		return err
+	}
+
+	err = s.S.stamps.Add(payload.Hashes)
+
+	if err != nil {
+		return err
+	}
+
+	s.Response = []byte{}
+
+	return nil
+}
+
+// Receipt of a timestamped hash
+func (s *NodeServerRequest) handleGetReceipt() error {
+	s.HasResponse = true
+
+	if s.S.stamps == nil {
+		return errors.New("Timestamping is not enabled on this node")
+	}
+
+	var payload nodeclient.ComGetReceipt
+
+	err := s.parseRequestData(&payload)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := s.S.stamps.GetReceipt(s.Node, payload.Hash)
+
+	if err != nil {
+		return err
+	}
+
+	s.Response, err = net.GobEncode(result)
+
+	if err != nil {
+		return err
+	}
+	s.Logger.Trace.Printf("Return receipt of %x anchored with root %x", payload.Hash, result.Root)
+	return nil
+}
+
+// Full block by a hash. It is requested by nodes loading bodies of blocks after headers
+func (s *NodeServerRequest) handleGetBlock() error {
+	s.HasResponse = true
+
+	var payload nodeclient.ComGetBlock
+
+	err := s.parseRequestData(&payload)
+
+	if err != nil {
+		return err
+	}
+
+	block, err := s.Node.NodeBC.GetBlock(payload.Hash)
+
+	if err != nil {
+		return err
+	}
+
+	blockdata, err := block.Serialize()
+
+	if err != nil {
+		return err
+	}
+
+	s.Response, err = net.GobEncode(blockdata)
+
+	if err != nil {
+		return err
+	}
+	s.Logger.Trace.Printf("Return block %x", payload.Hash)
+	return nil
+}
+
 // Return node state, including pending blocks to load
 func (s *NodeServerRequest) handleGetState() error {
 	if !s.NodeAuthStrIsGood {
@@ -816,14 +1077,12 @@ func (s *NodeServerRequest) handleGetState() error {
 
 	s.HasResponse = true
 
-	info, err := s.Node.GetNodeState()
+	info, err := s.S.GetNodeState(s.Node)
 
 	if err != nil {
 		return err
 	}
 
-	info.ExpectingBlocksHeight = s.S.Transit.MaxKnownHeigh
-
 	s.Response, err = net.GobEncode(&info)
 
 	if err != nil {
@@ -831,3 +1090,45 @@ func (s *NodeServerRequest) handleGetState() error {
 	}
 	return nil
 }
+
+// Unlock the node wallet for some time
+func (s *NodeServerRequest) handleUnlockWallet() error {
+	if !s.NodeAuthStrIsGood {
+		return errors.New("Local Network Auth is required")
+	}
+
+	s.HasResponse = true
+
+	var payload nodeclient.ComUnlockWallet
+
+	err := s.parseRequestData(&payload)
+
+	if err != nil {
+		return err
+	}
+
+	err = s.S.UnlockWallet(payload.Passphrase, payload.Timeout)
+
+	if err != nil {
+		return err
+	}
+
+	s.Response = []byte{}
+
+	return nil
+}
+
+// Remove keys of the node wallet from memory
+func (s *NodeServerRequest) handleLockWallet() error {
+	if !s.NodeAuthStrIsGood {
+		return errors.New("Local Network Auth is required")
+	}
+
+	s.HasResponse = true
+
+	s.S.LockWallet()
+
+	s.Response = []byte{}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/taincoin/taincoin/node/events"
+	"github.com/taincoin/taincoin/node/metrics"
+)
+
+const (
+	metricsRefreshInterval = 15 * time.Second // the DB state is not read more often
+	metricsStateMaxAge     = 5 * time.Minute  // the DB state is read after this even without events
+)
+
+// Network commands known by the server. Other commands are counted as "unknown",
+// so a client can not make a lot of metrics series
+var metricsCommands = map[string]bool{
+	"addr": true, "viod": true, "block": true, "inv": true, "getblocks": true, "getblocksup": true,
+	"getdata": true, "getunspent": true, "gethistory": true, "getbalance": true, "getsupply": true,
+	"getheaders": true, "getproof": true, "getsecret": true, "getanchor": true, "stamp": true, "getreceipt": true, "getblock": true, "getfblocks": true, "tx": true,
+	"txfull": true, "txdata": true, "txrequest": true, "getnodes": true, "addnode": true,
+	"removenode": true, "getstate": true, "version": true, "unlockwallet": true, "lockwallet": true,
+}
+
+// Starts HTTP server with the /metrics endpoint
+func (s *NodeServer) startMetrics() error {
+	host := s.Metrics.Host
+
+	if host == "" {
+		host = "localhost"
+	}
+
+	ln, err := net.Listen("tcp", host+":"+strconv.Itoa(s.Metrics.Port))
+
+	if err != nil {
+		return errors.New(fmt.Sprintf("Metrics listening error: %s", err.Error()))
+	}
+
+	s.metricsState = newMetricsStateCache(s.readMetricsChainState, s.readMetricsPoolState)
+
+	if s.Node.Events != nil {
+		id, eventsChan := s.Node.Events.Subscribe()
+		s.metricsSubID = id
+
+		go func() {
+			for e := range eventsChan {
+				s.metricsState.eventReceived(e)
+			}
+		}()
+	}
+
+	metrics.SetStateFunc(s.getMetricsState)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+
+	s.metricsServer = &http.Server{Handler: mux, ReadTimeout: 30 * time.Second, WriteTimeout: 60 * time.Second}
+
+	s.Logger.Trace.Printf("Start metrics on %s:%d", host, s.Metrics.Port)
+
+	go func() {
+		err := s.metricsServer.Serve(ln)
+
+		if err != nil && err != http.ErrServerClosed {
+			s.Logger.Error.Println("Metrics server error: ", err.Error())
+		}
+	}()
+
+	return nil
+}
+
+func (s *NodeServer) stopMetrics() {
+	metrics.SetStateFunc(nil)
+
+	if s.Node.Events != nil && s.metricsSubID > 0 {
+		s.Node.Events.Unsubscribe(s.metricsSubID)
+	}
+
+	if s.metricsServer != nil {
+		s.metricsServer.Close()
+	}
+}
+
+// Returns node state for gauges. It is called on every scrape. Values from the DB are cached,
+// other values are read every time
+func (s *NodeServer) getMetricsState() (metrics.State, error) {
+	result, err := s.metricsState.get(time.Now())
+
+	if err != nil {
+		return result, err
+	}
+
+	result.ExpectingBlocksHeight = s.Transit.MaxKnownHeigh
+	result.Peers = len(s.Node.NodeNet.Nodes)
+
+	state := s.Sync.GetState()
+
+	result.Syncing = state.Running
+
+	if state.Running {
+		result.SyncHeadersHeight = state.HeadersHeight
+		result.SyncBlocksHeight = state.BlocksHeight
+	}
+
+	return result, nil
+}
+
+// Reads height, unapproved transactions and unspent outputs. Counting of outputs takes the DB lock for long
+func (s *NodeServer) readMetricsChainState() (metrics.State, error) {
+	result := metrics.State{}
+
+	node := s.CloneNode()
+
+	err := node.DBConn.OpenConnection("Metrics", node.SessionID)
+
+	if err != nil {
+		return result, err
+	}
+	defer node.DBConn.CloseConnection()
+
+	info, err := node.GetNodeState()
+
+	if err != nil {
+		return result, err
+	}
+
+	result.Height = info.BlocksNumber - 1
+	result.Mempool = info.TransactionsCached
+	result.UnspentOutputs = info.UnspentOutputs
+
+	return result, nil
+}
+
+// Reads only number of unapproved transactions
+func (s *NodeServer) readMetricsPoolState() (int, error) {
+	node := s.CloneNode()
+
+	err := node.DBConn.OpenConnection("Metrics", node.SessionID)
+
+	if err != nil {
+		return 0, err
+	}
+	defer node.DBConn.CloseConnection()
+
+	return node.GetTransactionsManager().GetUnapprovedCount()
+}
+
+// Node state from the DB for metrics. It is read again after blocks or transactions were
+// added or removed, but not more often than metricsRefreshInterval. A scrape doesn't count
+// unspent outputs if the chain was not changed
+type metricsStateCache struct {
+	lock *sync.Mutex
+
+	state        metrics.State
+	updated      time.Time
+	loaded       bool
+	chainChanged bool
+	poolChanged  bool
+
+	readChain func() (metrics.State, error)
+	readPool  func() (int, error)
+}
+
+func newMetricsStateCache(readChain func() (metrics.State, error), readPool func() (int, error)) *metricsStateCache {
+	c := &metricsStateCache{}
+	c.lock = &sync.Mutex{}
+	c.readChain = readChain
+	c.readPool = readPool
+
+	return c
+}
+
+func (c *metricsStateCache) eventReceived(e events.Event) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	switch e.Kind {
+	case events.KindBlock, events.KindReorg:
+		c.chainChanged = true
+	case events.KindTransaction:
+		c.poolChanged = true
+	}
+}
+
+// Returns cached state. Reads it again if there were changes and the state is not too fresh.
+// Events can be missed if a subscriber is slow, so the state is also read after max age
+func (c *metricsStateCache) get(now time.Time) (metrics.State, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	age := now.Sub(c.updated)
+
+	if c.loaded && age < metricsRefreshInterval {
+		return c.state, nil
+	}
+
+	switch {
+	case !c.loaded || c.chainChanged || age >= metricsStateMaxAge:
+		state, err := c.readChain()
+
+		if err != nil {
+			return c.state, err
+		}
+		c.state = state
+		c.loaded = true
+		c.chainChanged = false
+		c.poolChanged = false
+
+	case c.poolChanged:
+		count, err := c.readPool()
+
+		if err != nil {
+			return c.state, err
+		}
+		c.state.Mempool = count
+		c.poolChanged = false
+
+	default:
+		// nothing changed, don't move the time. Next change is read on next scrape
+		return c.state, nil
+	}
+
+	c.updated = now
+
+	return c.state, nil
+}
+
+func (s *NodeServer) updateCommandMetrics(command string, duration time.Duration, err error) {
+	if !metricsCommands[command] {
+		command = "unknown"
+	}
+
+	result := "ok"
+
+	if err != nil {
+		result = "error"
+	}
+
+	metrics.CommandsTotal.WithLabelValues(command, result).Inc()
+	metrics.CommandDuration.WithLabelValues(command).Observe(duration.Seconds())
+}
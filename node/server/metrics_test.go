@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/taincoin/taincoin/node/events"
+	"github.com/taincoin/taincoin/node/metrics"
+)
+
+func TestMetricsStateCache(t *testing.T) {
+	chainReads := 0
+	poolReads := 0
+	var readErr error
+
+	c := newMetricsStateCache(func() (metrics.State, error) {
+		chainReads++
+		return metrics.State{Height: chainReads, Mempool: 5, UnspentOutputs: 100 + chainReads}, readErr
+	}, func() (int, error) {
+		poolReads++
+		return 5 + poolReads, readErr
+	})
+
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		event      string
+		after      time.Duration
+		chainReads int
+		poolReads  int
+		height     int
+		mempool    int
+	}{
+		{"first scrape", "", 0, 1, 0, 1, 5},
+		{"no changes", "", time.Second, 1, 0, 1, 5},
+		{"no changes after interval", "", metricsRefreshInterval, 1, 0, 1, 5},
+		{"block", events.KindBlock, time.Second, 2, 0, 2, 5},
+		{"block too early", events.KindBlock, time.Second, 2, 0, 2, 5},
+		{"block after interval", "", metricsRefreshInterval, 3, 0, 3, 5},
+		{"transaction", events.KindTransaction, metricsRefreshInterval, 3, 1, 3, 6},
+		{"payment is not a change", events.KindPayment, metricsRefreshInterval, 3, 1, 3, 6},
+		{"reorg", events.KindReorg, metricsRefreshInterval, 4, 1, 4, 5},
+		{"max age", "", metricsStateMaxAge, 5, 1, 5, 5},
+	}
+
+	for _, test := range tests {
+		if test.event != "" {
+			c.eventReceived(events.Event{Kind: test.event})
+		}
+
+		now = now.Add(test.after)
+
+		state, err := c.get(now)
+
+		if err != nil {
+			t.Fatalf("%s: error %s", test.name, err.Error())
+		}
+
+		if chainReads != test.chainReads || poolReads != test.poolReads {
+			t.Fatalf("%s: expected %d chain and %d pool reads, got %d and %d", test.name,
+				test.chainReads, test.poolReads, chainReads, poolReads)
+		}
+
+		if state.Height != test.height || state.Mempool != test.mempool || state.UnspentOutputs != 100+test.chainReads {
+			t.Fatalf("%s: wrong state %+v", test.name, state)
+		}
+	}
+
+	// a failed read is tried again on next scrape
+	readErr = errors.New("DB is closed")
+	c.eventReceived(events.Event{Kind: events.KindBlock})
+	now = now.Add(metricsRefreshInterval)
+
+	if _, err := c.get(now); err == nil {
+		t.Fatalf("Expected read error")
+	}
+
+	readErr = nil
+
+	state, err := c.get(now)
+
+	if err != nil || chainReads != 7 || state.Height != 7 {
+		t.Fatalf("Expected state to be read again after error, got %+v, %d reads", state, chainReads)
+	}
+}
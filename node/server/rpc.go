@@ -0,0 +1,320 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/taincoin/taincoin/lib/utils"
+	"github.com/taincoin/taincoin/node/config"
+	"github.com/taincoin/taincoin/node/nodemanager"
+)
+
+// max size of a HTTP request body
+const rpcMaxRequestSize = 1024 * 1024
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcErrorParse          = -32700
+	rpcErrorInvalidRequest = -32600
+	rpcErrorMethodNotFound = -32601
+	rpcErrorInvalidParams  = -32602
+	rpcErrorInternal       = -32603
+	rpcErrorAuthRequired   = -32001
+)
+
+// HTTP JSON-RPC 2.0 API. It works beside the node network protocol and allows
+// to integrate a node with services not written in Go.
+// Privileged methods need the node auth string in the "Authorization: Bearer AUTHSTR" header
+type rpcServer struct {
+	S      *NodeServer
+	Config config.RPCConfig
+	Logger *utils.LoggerMan
+
+	server  *http.Server
+	methods map[string]rpcMethod
+}
+
+// Method handler. Params are decoded by a method itself
+type rpcMethodHandler func(node *nodemanager.Node, params json.RawMessage) (interface{}, error)
+
+type rpcMethod struct {
+	handler    rpcMethodHandler
+	privileged bool // needs auth string
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	Result  *json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+	ID      json.RawMessage  `json:"id"`
+}
+
+// Error with JSON-RPC code. Methods return it when a code is not "internal error"
+type rpcCodeError struct {
+	code    int
+	message string
+}
+
+func (e *rpcCodeError) Error() string {
+	return e.message
+}
+
+func newRPCInvalidParams(message string) error {
+	return &rpcCodeError{rpcErrorInvalidParams, message}
+}
+
+func newRPCServer(s *NodeServer, conf config.RPCConfig, logger *utils.LoggerMan) *rpcServer {
+	rs := &rpcServer{}
+	rs.S = s
+	rs.Config = conf
+	rs.Logger = logger
+	rs.methods = map[string]rpcMethod{}
+
+	rs.registerMethods()
+
+	return rs
+}
+
+func (rs *rpcServer) register(name string, handler rpcMethodHandler, privileged bool) {
+	rs.methods[name] = rpcMethod{handler, privileged}
+}
+
+// Starts listening. Requests are served in a separate routine
+func (rs *rpcServer) Start() error {
+	host := rs.Config.Host
+
+	if host == "" {
+		host = "localhost"
+	}
+
+	ln, err := net.Listen("tcp", host+":"+strconv.Itoa(rs.Config.Port))
+
+	if err != nil {
+		return errors.New(fmt.Sprintf("RPC listening error: %s", err.Error()))
+	}
+
+	rs.server = &http.Server{Handler: rs, ReadTimeout: 30 * time.Second, WriteTimeout: 60 * time.Second}
+
+	rs.Logger.Trace.Printf("Start JSON-RPC on %s:%d", host, rs.Config.Port)
+
+	go func() {
+		err := rs.server.Serve(ln)
+
+		if err != nil && err != http.ErrServerClosed {
+			rs.Logger.Error.Println("RPC server error: ", err.Error())
+		}
+	}()
+
+	return nil
+}
+
+// Stops listening
+func (rs *rpcServer) Stop() {
+	if rs.server != nil {
+		rs.server.Close()
+	}
+}
+
+// Checks if a request has correct auth string
+func (rs *rpcServer) isAuthorized(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+
+	auth := []byte(strings.TrimPrefix(header, "Bearer "))
+
+	for _, good := range []string{rs.S.NodeAuthStr, rs.Config.AuthStr} {
+		if len(good) > 0 && subtle.ConstantTimeCompare(auth, []byte(good)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTP handler. Accepts single request or a batch
+func (rs *rpcServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == rpcWebSocketPath {
+		rs.serveWebSocket(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, rpcMaxRequestSize))
+
+	if err != nil {
+		rs.writeResponse(w, rs.errorResponse(nil, rpcErrorParse, err.Error()))
+		return
+	}
+
+	authorized := rs.isAuthorized(r)
+
+	body = []byte(strings.TrimSpace(string(body)))
+
+	if len(body) > 0 && body[0] == '[' {
+		var requests []json.RawMessage
+
+		err = json.Unmarshal(body, &requests)
+
+		if err != nil {
+			rs.writeResponse(w, rs.errorResponse(nil, rpcErrorParse, err.Error()))
+			return
+		}
+
+		if len(requests) == 0 {
+			rs.writeResponse(w, rs.errorResponse(nil, rpcErrorInvalidRequest, "Empty batch"))
+			return
+		}
+
+		responses := []*rpcResponse{}
+
+		for _, request := range requests {
+			if response := rs.handleRequest(request, authorized); response != nil {
+				responses = append(responses, response)
+			}
+		}
+
+		if len(responses) == 0 {
+			// only notifications
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		rs.writeResponse(w, responses)
+		return
+	}
+
+	response := rs.handleRequest(body, authorized)
+
+	if response == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	rs.writeResponse(w, response)
+}
+
+// Executes one request. Returns nil for a notification
+func (rs *rpcServer) handleRequest(data []byte, authorized bool) *rpcResponse {
+	request := rpcRequest{}
+
+	err := json.Unmarshal(data, &request)
+
+	if err != nil {
+		return rs.errorResponse(nil, rpcErrorParse, err.Error())
+	}
+
+	if request.JSONRPC != "2.0" || request.Method == "" {
+		return rs.errorResponse(request.ID, rpcErrorInvalidRequest, "Invalid request")
+	}
+
+	result, err := rs.call(request.Method, request.Params, authorized)
+
+	if request.ID == nil {
+		// notification. no response
+		return nil
+	}
+
+	if err != nil {
+		code := rpcErrorInternal
+
+		if cerr, ok := err.(*rpcCodeError); ok {
+			code = cerr.code
+		}
+		return rs.errorResponse(request.ID, code, err.Error())
+	}
+
+	resultdata, err := json.Marshal(result)
+
+	if err != nil {
+		return rs.errorResponse(request.ID, rpcErrorInternal, err.Error())
+	}
+
+	raw := json.RawMessage(resultdata)
+
+	return &rpcResponse{JSONRPC: "2.0", Result: &raw, ID: request.ID}
+}
+
+// Finds a method and executes it with own node object and DB connection
+func (rs *rpcServer) call(name string, params json.RawMessage, authorized bool) (interface{}, error) {
+	method, ok := rs.methods[name]
+
+	if !ok {
+		return nil, &rpcCodeError{rpcErrorMethodNotFound, "Method not found"}
+	}
+
+	if method.privileged && !authorized {
+		return nil, &rpcCodeError{rpcErrorAuthRequired, "Auth is required"}
+	}
+
+	sessid := utils.RandString(5)
+
+	rs.Logger.Trace.Printf("RPC call %s, sess %s", name, sessid)
+
+	node := rs.S.CloneNode()
+	node.SessionID = sessid
+
+	err := node.DBConn.OpenConnection("RPC "+name, sessid)
+
+	if err != nil {
+		return nil, err
+	}
+	defer node.DBConn.CloseConnection()
+
+	return method.handler(node, params)
+}
+
+func (rs *rpcServer) errorResponse(id json.RawMessage, code int, message string) *rpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &rpcResponse{JSONRPC: "2.0", Error: &rpcError{code, message}, ID: id}
+}
+
+func (rs *rpcServer) writeResponse(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(response)
+
+	if err != nil {
+		rs.Logger.Error.Println("RPC response error: ", err.Error())
+	}
+}
+
+// Decodes params of a method. Params must be an object. Empty params are allowed
+func parseRPCParams(params json.RawMessage, payload interface{}) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+
+	err := json.Unmarshal(params, payload)
+
+	if err != nil {
+		return newRPCInvalidParams("Params must be an object: " + err.Error())
+	}
+	return nil
+}
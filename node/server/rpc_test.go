@@ -0,0 +1,233 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/utils"
+	"github.com/taincoin/taincoin/lib/wallet"
+	"github.com/taincoin/taincoin/node/config"
+)
+
+// RPC server without a node. Requests that fail before a method is executed don't need it
+func makeTestRPCServer() *rpcServer {
+	s := &NodeServer{}
+	s.NodeAuthStr = "nodeauth"
+
+	return newRPCServer(s, config.RPCConfig{AuthStr: "rpcauth"}, utils.CreateLogger())
+}
+
+func TestRPCRequestErrors(t *testing.T) {
+	rs := makeTestRPCServer()
+
+	tests := []struct {
+		name string
+		body string
+		auth string
+		code int
+		id   string
+	}{
+		{"not json", `{"jsonrpc":"2.0",`, "", rpcErrorParse, "null"},
+		{"not object", `"getbalance"`, "", rpcErrorParse, "null"},
+		{"no version", `{"method":"getbalance","id":1}`, "", rpcErrorInvalidRequest, "1"},
+		{"wrong version", `{"jsonrpc":"1.0","method":"getbalance","id":1}`, "", rpcErrorInvalidRequest, "1"},
+		{"no method", `{"jsonrpc":"2.0","id":"a"}`, "", rpcErrorInvalidRequest, `"a"`},
+		{"unknown method", `{"jsonrpc":"2.0","method":"nomethod","id":2}`, "", rpcErrorMethodNotFound, "2"},
+		{"empty batch", `[]`, "", rpcErrorInvalidRequest, "null"},
+		{"broken batch", `[{"jsonrpc":"2.0"`, "", rpcErrorParse, "null"},
+		{"no auth", `{"jsonrpc":"2.0","method":"send","id":3}`, "", rpcErrorAuthRequired, "3"},
+		{"wrong auth", `{"jsonrpc":"2.0","method":"lock","id":4}`, "Bearer wrong", rpcErrorAuthRequired, "4"},
+		{"auth without bearer", `{"jsonrpc":"2.0","method":"unlock","id":5}`, "rpcauth", rpcErrorAuthRequired, "5"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+
+		w := httptest.NewRecorder()
+
+		rs.ServeHTTP(w, req)
+
+		response := rpcResponse{}
+
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+
+		if err != nil {
+			t.Fatalf("%s: response is not valid: %s", test.name, err.Error())
+		}
+
+		if response.Error == nil || response.Error.Code != test.code {
+			t.Fatalf("%s: expected error %d, got %s", test.name, test.code, w.Body.String())
+		}
+
+		if response.Result != nil || response.JSONRPC != "2.0" || string(response.ID) != test.id {
+			t.Fatalf("%s: wrong response %s", test.name, w.Body.String())
+		}
+	}
+}
+
+func TestRPCBatch(t *testing.T) {
+	rs := makeTestRPCServer()
+
+	body := `[{"jsonrpc":"2.0","method":"nomethod","id":1},
+		{"jsonrpc":"2.0","method":"send"},
+		{"jsonrpc":"2.0","method":"stamp","id":2}]`
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	rs.ServeHTTP(w, req)
+
+	responses := []rpcResponse{}
+
+	err := json.Unmarshal(w.Body.Bytes(), &responses)
+
+	if err != nil {
+		t.Fatalf("Batch response is not valid: %s", err.Error())
+	}
+
+	// a notification has no response
+	if len(responses) != 2 {
+		t.Fatalf("Expected 2 responses, got %s", w.Body.String())
+	}
+
+	if string(responses[0].ID) != "1" || responses[0].Error.Code != rpcErrorMethodNotFound {
+		t.Fatalf("Wrong first response %s", w.Body.String())
+	}
+
+	if string(responses[1].ID) != "2" || responses[1].Error.Code != rpcErrorAuthRequired {
+		t.Fatalf("Wrong second response %s", w.Body.String())
+	}
+
+	// only notifications
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[{"jsonrpc":"2.0","method":"send"}]`))
+	w = httptest.NewRecorder()
+
+	rs.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Fatalf("Expected no content for notifications, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRPCOnlyPost(t *testing.T) {
+	rs := makeTestRPCServer()
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		req := httptest.NewRequest(method, "/", nil)
+		w := httptest.NewRecorder()
+
+		rs.ServeHTTP(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("Expected not allowed for %s, got %d", method, w.Code)
+		}
+	}
+}
+
+func TestRPCAuthorization(t *testing.T) {
+	rs := makeTestRPCServer()
+
+	tests := []struct {
+		header     string
+		authorized bool
+	}{
+		{"", false},
+		{"Bearer", false},
+		{"Bearer ", false},
+		{"Bearer rpcauth", true},
+		{"Bearer nodeauth", true},
+		{"Bearer rpcauth2", false},
+		{"bearer rpcauth", false},
+		{"Basic rpcauth", false},
+		{"rpcauth", false},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Authorization", test.header)
+
+		if rs.isAuthorized(req) != test.authorized {
+			t.Fatalf("Header %q: expected authorized %v", test.header, test.authorized)
+		}
+	}
+
+	// empty auth strings are never accepted
+	rs = newRPCServer(&NodeServer{}, config.RPCConfig{}, utils.CreateLogger())
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Authorization", "Bearer ")
+
+	if rs.isAuthorized(req) {
+		t.Fatalf("Empty auth string must not be accepted")
+	}
+}
+
+// Returns new valid address
+func makeTestAddress() string {
+	w := wallet.Wallet{}
+	w.MakeWallet()
+
+	return string(w.GetAddress())
+}
+
+func TestRPCParams(t *testing.T) {
+	rs := makeTestRPCServer()
+
+	address := makeTestAddress()
+	wrong := []byte(address)
+	wrong[len(wrong)-1]++
+
+	tests := []struct {
+		params  string
+		address string
+		good    bool
+	}{
+		{`{"address":"` + address + `"}`, address, true},
+		{`{"address":"` + string(wrong) + `"}`, "", false},
+		{`{"address":""}`, "", false},
+		{`{}`, "", false},
+		{`null`, "", false},
+		{`["` + address + `"]`, "", false},
+		{`{"address":1}`, "", false},
+	}
+
+	for _, test := range tests {
+		address, err := rs.parseAddress(json.RawMessage(test.params))
+
+		if test.good != (err == nil) || address != test.address {
+			t.Fatalf("Params %s: got %q, %v", test.params, address, err)
+		}
+
+		if err != nil {
+			if cerr, ok := err.(*rpcCodeError); !ok || cerr.code != rpcErrorInvalidParams {
+				t.Fatalf("Params %s: expected invalid params error", test.params)
+			}
+		}
+	}
+}
+
+func TestRPCAmount(t *testing.T) {
+	var a rpcAmount
+
+	if err := json.Unmarshal([]byte(`"1.5"`), &a); err != nil || lib.Amount(a) != 3*lib.AmountUnit/2 {
+		t.Fatalf("Got %d, %v", a, err)
+	}
+
+	if err := json.Unmarshal([]byte(`2`), &a); err != nil || lib.Amount(a) != 2*lib.AmountUnit {
+		t.Fatalf("Got %d, %v", a, err)
+	}
+
+	for _, data := range []string{`"1.+5"`, `"."`, `"+1"`, `""`, `true`} {
+		if err := json.Unmarshal([]byte(data), &a); err == nil {
+			t.Fatalf("Expected error for %s", data)
+		}
+	}
+}
@@ -0,0 +1,711 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/net"
+	"github.com/taincoin/taincoin/lib/nodeclient"
+	"github.com/taincoin/taincoin/lib/utils"
+	"github.com/taincoin/taincoin/lib/wallet"
+	"github.com/taincoin/taincoin/node/nodemanager"
+	"github.com/taincoin/taincoin/node/structures"
+)
+
+// Amount in RPC params. It can be a string "1.5" or a number 1.5
+type rpcAmount lib.Amount
+
+func (a *rpcAmount) UnmarshalJSON(data []byte) error {
+	amount, err := lib.ParseAmount(strings.Trim(string(data), "\""))
+
+	if err != nil {
+		return err
+	}
+	*a = rpcAmount(amount)
+	return nil
+}
+
+// Params of methods
+type rpcAddressParams struct {
+	Address string `json:"address"`
+}
+
+type rpcBlockParams struct {
+	Hash   string `json:"hash"`
+	Height *int   `json:"height"`
+}
+
+type rpcTransactionParams struct {
+	TXID string `json:"txid"`
+}
+
+type rpcSendParams struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Amount rpcAmount `json:"amount"`
+	Fee    rpcAmount `json:"fee"`
+}
+
+type rpcUnlockParams struct {
+	Passphrase string `json:"passphrase"`
+	Timeout    int    `json:"timeout"`
+}
+
+type rpcNodeParams struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+type rpcSupplyParams struct {
+	Height *int `json:"height"`
+}
+
+type rpcAnchorParams struct {
+	Data string `json:"data"`
+}
+
+type rpcStampParams struct {
+	Hashes []string `json:"hashes"`
+}
+
+type rpcReceiptParams struct {
+	Hash string `json:"hash"`
+}
+
+// Results of methods. Amounts are strings to keep exact value, binary data are hex strings
+type rpcBalance struct {
+	Address  string `json:"address"`
+	Total    string `json:"total"`
+	Approved string `json:"approved"`
+	Pending  string `json:"pending"`
+}
+
+type rpcHistoryRecord struct {
+	TXID    string `json:"txid"`
+	Type    string `json:"type"` // "in" or "out"
+	Address string `json:"address"`
+	Amount  string `json:"amount"`
+}
+
+type rpcUnspentOutput struct {
+	TXID     string `json:"txid"`
+	Vout     int    `json:"vout"`
+	Amount   string `json:"amount"`
+	From     string `json:"from"`
+	Coinbase bool   `json:"coinbase"`
+}
+
+type rpcInput struct {
+	TXID    string `json:"txid"`
+	Vout    int    `json:"vout"`
+	Address string `json:"address,omitempty"`
+}
+
+type rpcOutput struct {
+	Amount  string `json:"amount"`
+	Address string `json:"address"`
+	Data    string `json:"data,omitempty"` // data output has no address
+}
+
+type rpcTransaction struct {
+	TXID      string      `json:"txid"`
+	Time      int64       `json:"time"`
+	Coinbase  bool        `json:"coinbase"`
+	Inputs    []rpcInput  `json:"inputs"`
+	Outputs   []rpcOutput `json:"outputs"`
+	BlockHash string      `json:"blockhash,omitempty"` // empty if a transaction is not in a block yet
+}
+
+type rpcBlock struct {
+	Hash          string           `json:"hash"`
+	PrevBlockHash string           `json:"prevblockhash"`
+	MerkleRoot    string           `json:"merkleroot"`
+	Height        int              `json:"height"`
+	Timestamp     int64            `json:"timestamp"`
+	Nonce         int              `json:"nonce"`
+	Bits          int              `json:"bits"`
+	Transactions  []rpcTransaction `json:"transactions"`
+}
+
+type rpcNodeState struct {
+	BlocksNumber          int    `json:"blocks"`
+	ExpectingBlocksHeight int    `json:"expectingheight"`
+	TransactionsCached    int    `json:"mempool"`
+	UnspentOutputs        int    `json:"unspentoutputs"`
+	Syncing               bool   `json:"syncing"`
+	SyncNode              string `json:"syncnode,omitempty"`
+	SyncHeadersHeight     int    `json:"syncheadersheight"`
+	SyncBlocksHeight      int    `json:"syncblocksheight"`
+}
+
+type rpcAnchor struct {
+	TXID      string `json:"txid"`
+	BlockHash string `json:"blockhash"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Receipt is in the format of receipt files of the wallet, it is checked with verifyreceipt command
+type rpcReceipt struct {
+	Hash      string                     `json:"hash"`
+	Root      string                     `json:"root,omitempty"`
+	TXID      string                     `json:"txid,omitempty"`
+	BlockHash string                     `json:"blockhash,omitempty"`
+	Height    int                        `json:"height"`
+	Timestamp int64                      `json:"timestamp"`
+	Receipt   nodeclient.ComStampReceipt `json:"receipt"`
+}
+
+type rpcSupply struct {
+	Height      int    `json:"height"`
+	Supply      string `json:"supply"`
+	BlockReward string `json:"blockreward"`
+	MaxSupply   string `json:"maxsupply"`
+}
+
+func (rs *rpcServer) registerMethods() {
+	rs.register("getbalance", rs.methodGetBalance, false)
+	rs.register("gethistory", rs.methodGetHistory, false)
+	rs.register("listunspent", rs.methodListUnspent, false)
+	rs.register("getblock", rs.methodGetBlock, false)
+	rs.register("gettransaction", rs.methodGetTransaction, false)
+	rs.register("getmempool", rs.methodGetMempool, false)
+	rs.register("getsupply", rs.methodGetSupply, false)
+	rs.register("getnodes", rs.methodGetNodes, false)
+	rs.register("getanchor", rs.methodGetAnchor, false)
+	rs.register("getreceipt", rs.methodGetReceipt, false)
+
+	rs.register("send", rs.methodSend, true)
+	rs.register("stamp", rs.methodStamp, true)
+	rs.register("getnodestate", rs.methodGetNodeState, true)
+	rs.register("addnode", rs.methodAddNode, true)
+	rs.register("removenode", rs.methodRemoveNode, true)
+	rs.register("unlock", rs.methodUnlock, true)
+	rs.register("lock", rs.methodLock, true)
+}
+
+func (rs *rpcServer) parseAddress(params json.RawMessage) (string, error) {
+	payload := rpcAddressParams{}
+
+	err := parseRPCParams(params, &payload)
+
+	if err != nil {
+		return "", err
+	}
+
+	w := wallet.Wallet{}
+
+	if !w.ValidateAddress(payload.Address) {
+		return "", newRPCInvalidParams("Address is not valid")
+	}
+	return payload.Address, nil
+}
+
+func (rs *rpcServer) parseHex(value string, name string) ([]byte, error) {
+	data, err := hex.DecodeString(value)
+
+	if err != nil || len(data) == 0 {
+		return nil, newRPCInvalidParams(fmt.Sprintf("%s must be a hex string", name))
+	}
+	return data, nil
+}
+
+// Balance of an address
+func (rs *rpcServer) methodGetBalance(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	address, err := rs.parseAddress(params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := node.GetTransactionsManager().GetAddressBalance(address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rpcBalance{address, balance.Total.String(), balance.Approved.String(), balance.Pending.String()}, nil
+}
+
+// History of transactions of an address
+func (rs *rpcServer) methodGetHistory(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	address, err := rs.parseAddress(params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := node.NodeBC.GetAddressHistory(address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := []rpcHistoryRecord{}
+
+	for _, t := range history {
+		record := rpcHistoryRecord{TXID: hex.EncodeToString(t.TXID), Address: t.Address, Amount: t.Value.String()}
+
+		if t.IOType {
+			record.Type = "in"
+		} else {
+			record.Type = "out"
+		}
+		result = append(result, record)
+	}
+	return result, nil
+}
+
+// Unspent outputs of an address
+func (rs *rpcServer) methodListUnspent(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	address, err := rs.parseAddress(params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := []rpcUnspentOutput{}
+
+	err = node.GetTransactionsManager().ForEachUnspentOutput(address,
+		func(fromaddr string, value lib.Amount, txID []byte, output int, isbase bool) error {
+			result = append(result, rpcUnspentOutput{hex.EncodeToString(txID), output, value.String(), fromaddr, isbase})
+			return nil
+		})
+
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Block by a hash or a height in the main chain
+func (rs *rpcServer) methodGetBlock(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	payload := rpcBlockParams{}
+
+	err := parseRPCParams(params, &payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var block *structures.Block
+
+	if payload.Hash != "" {
+		hash, err := rs.parseHex(payload.Hash, "Hash")
+
+		if err != nil {
+			return nil, err
+		}
+
+		exists, err := node.NodeBC.CheckBlockExists(hash)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			return nil, errors.New("Block is not found")
+		}
+
+		block, err = node.NodeBC.GetBlock(hash)
+
+		if err != nil {
+			return nil, err
+		}
+	} else if payload.Height != nil {
+		blocks, err := node.NodeBC.GetBCManager().GetBlocksByHeight(*payload.Height, 1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(blocks) == 0 {
+			return nil, errors.New(fmt.Sprintf("No block with height %d", *payload.Height))
+		}
+		block = blocks[0]
+	} else {
+		return nil, newRPCInvalidParams("Hash or height is required")
+	}
+
+	result := rpcBlock{}
+	result.Hash = hex.EncodeToString(block.Hash)
+	result.PrevBlockHash = hex.EncodeToString(block.PrevBlockHash)
+	result.MerkleRoot = hex.EncodeToString(block.MerkleRoot)
+	result.Height = block.Height
+	result.Timestamp = block.Timestamp
+	result.Nonce = block.Nonce
+	result.Bits = block.Bits
+	result.Transactions = []rpcTransaction{}
+
+	for _, tx := range block.Transactions {
+		result.Transactions = append(result.Transactions, rs.makeTransaction(tx, block.Hash))
+	}
+	return result, nil
+}
+
+// Transaction from the main chain or from the pool of unapproved transactions
+func (rs *rpcServer) methodGetTransaction(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	payload := rpcTransactionParams{}
+
+	err := parseRPCParams(params, &payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	txID, err := rs.parseHex(payload.TXID, "TXID")
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := node.GetTransactionsManager().GetIfUnapprovedExists(txID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if tx != nil {
+		return rs.makeTransaction(tx, nil), nil
+	}
+
+	_, blockHash, err := node.NodeBC.GetBCManager().GetMerkleProof(txID)
+
+	if err != nil {
+		return nil, errors.New("Transaction is not found")
+	}
+
+	tx, err = node.NodeBC.GetBCManager().GetTransactionFromBlock(txID, blockHash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rs.makeTransaction(tx, blockHash), nil
+}
+
+// IDs of unapproved transactions
+func (rs *rpcServer) methodGetMempool(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	result := []string{}
+
+	_, err := node.GetTransactionsManager().ForEachUnapprovedTransaction(func(txhash, txstr string) error {
+		result = append(result, txhash)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Supply of coins after a block. Top block if a height is not set
+func (rs *rpcServer) methodGetSupply(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	payload := rpcSupplyParams{}
+
+	err := parseRPCParams(params, &payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	height := -1
+
+	if payload.Height != nil {
+		height = *payload.Height
+	}
+
+	supply, err := node.GetSupply(height)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rpcSupply{supply.Height, supply.Supply.String(), supply.BlockReward.String(), supply.MaxSupply.String()}, nil
+}
+
+// Block where data were anchored with a data output
+func (rs *rpcServer) methodGetAnchor(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	payload := rpcAnchorParams{}
+
+	err := parseRPCParams(params, &payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := rs.parseHex(payload.Data, "Data")
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx, block, err := node.GetTransactionsManager().FindDataTransaction(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if tx == nil {
+		return nil, errors.New("Data are not found")
+	}
+
+	return rpcAnchor{hex.EncodeToString(tx.ID), hex.EncodeToString(block.Hash), block.Height, block.Timestamp}, nil
+}
+
+// Adds hashes to the timestamping service
+func (rs *rpcServer) methodStamp(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	if rs.S.stamps == nil {
+		return nil, errors.New("Timestamping is not enabled on this node")
+	}
+
+	payload := rpcStampParams{}
+
+	err := parseRPCParams(params, &payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := [][]byte{}
+
+	for _, h := range payload.Hashes {
+		hash, err := rs.parseHex(h, "Hash")
+
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	err = rs.S.stamps.Add(hashes)
+
+	if err != nil {
+		return nil, newRPCInvalidParams(err.Error())
+	}
+
+	return len(hashes), nil
+}
+
+// Receipt of a timestamped hash. Root is empty while the hash is not in a block
+func (rs *rpcServer) methodGetReceipt(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	if rs.S.stamps == nil {
+		return nil, errors.New("Timestamping is not enabled on this node")
+	}
+
+	payload := rpcReceiptParams{}
+
+	err := parseRPCParams(params, &payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := rs.parseHex(payload.Hash, "Hash")
+
+	if err != nil {
+		return nil, err
+	}
+
+	receipt, err := rs.S.stamps.GetReceipt(node, hash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := rpcReceipt{Hash: payload.Hash, Receipt: receipt}
+
+	if len(receipt.Root) > 0 {
+		result.Root = hex.EncodeToString(receipt.Root)
+		result.TXID = hex.EncodeToString(receipt.TXProof.TXID)
+		result.BlockHash = hex.EncodeToString(receipt.TXProof.BlockHash)
+		result.Height = receipt.Height
+		result.Timestamp = receipt.Timestamp
+	}
+	return result, nil
+}
+
+// Known nodes
+func (rs *rpcServer) methodGetNodes(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	result := []rpcNodeParams{}
+
+	for _, n := range rs.S.Node.NodeNet.GetNodes() {
+		result = append(result, rpcNodeParams{n.Host, n.Port})
+	}
+	return result, nil
+}
+
+// Sends money from an address in the node wallet. Returns new transaction ID
+func (rs *rpcServer) methodSend(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	payload := rpcSendParams{}
+
+	err := parseRPCParams(params, &payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	w := wallet.Wallet{}
+
+	if !w.ValidateAddress(payload.To) {
+		return nil, newRPCInvalidParams("Recipient address is not valid")
+	}
+
+	wallets := wallet.Wallets{}
+	wallets.Wallets = map[string]*wallet.Wallet{}
+	wallets.DataDir = rs.S.DataDir
+
+	err = wallets.LoadFromFile()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if wallets.IsWatchOnly(payload.From) {
+		return nil, newRPCInvalidParams("Sender address is watch-only")
+	}
+
+	if !wallets.HasAddress(payload.From) {
+		return nil, newRPCInvalidParams("Sender address is not in the node wallet")
+	}
+
+	walletobj, err := wallets.GetWallet(payload.From)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := node.GetTransactionsManager().CreateTransaction(walletobj.GetPublicKey(), walletobj.GetPrivateKey(),
+		payload.To, lib.Amount(payload.Amount), lib.Amount(payload.Fee))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// try to make a block. the transaction is sent to other nodes if a block is not made
+	rs.S.TryToMakeNewBlock(tx.ID)
+
+	return hex.EncodeToString(tx.ID), nil
+}
+
+// Keeps the node wallet unlocked for timeout seconds
+func (rs *rpcServer) methodUnlock(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	payload := rpcUnlockParams{}
+
+	err := parseRPCParams(params, &payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if payload.Passphrase == "" || payload.Timeout < 0 {
+		return nil, newRPCInvalidParams("Passphrase is required and timeout can not be negative")
+	}
+
+	err = rs.S.UnlockWallet(payload.Passphrase, payload.Timeout)
+
+	if err == wallet.ErrWrongPassphrase {
+		return nil, newRPCInvalidParams(err.Error())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return true, nil
+}
+
+func (rs *rpcServer) methodLock(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	rs.S.LockWallet()
+
+	return true, nil
+}
+
+// Node state, including synchronization progress
+func (rs *rpcServer) methodGetNodeState(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	info, err := rs.S.GetNodeState(node)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rpcNodeState{info.BlocksNumber, info.ExpectingBlocksHeight, info.TransactionsCached, info.UnspentOutputs,
+		info.Syncing, info.SyncNode, info.SyncHeadersHeight, info.SyncBlocksHeight}, nil
+}
+
+func (rs *rpcServer) parseNode(params json.RawMessage) (net.NodeAddr, error) {
+	payload := rpcNodeParams{}
+
+	err := parseRPCParams(params, &payload)
+
+	if err != nil {
+		return net.NodeAddr{}, err
+	}
+
+	if payload.Host == "" || payload.Port < 1 {
+		return net.NodeAddr{}, newRPCInvalidParams("Host and port are required")
+	}
+	return net.NodeAddr{Host: payload.Host, Port: payload.Port}, nil
+}
+
+// Adds a node to known nodes
+func (rs *rpcServer) methodAddNode(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	addr, err := rs.parseNode(params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rs.S.Node.AddNodeToKnown(addr, true)
+
+	return true, nil
+}
+
+// Removes a node from known nodes
+func (rs *rpcServer) methodRemoveNode(node *nodemanager.Node, params json.RawMessage) (interface{}, error) {
+	addr, err := rs.parseNode(params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rs.S.Node.NodeNet.RemoveNodeFromKnown(addr)
+
+	return true, nil
+}
+
+func (rs *rpcServer) makeTransaction(tx *structures.Transaction, blockHash []byte) rpcTransaction {
+	result := rpcTransaction{}
+	result.TXID = hex.EncodeToString(tx.ID)
+	result.Time = tx.Time
+	result.Coinbase = tx.IsCoinbase()
+	result.Inputs = []rpcInput{}
+	result.Outputs = []rpcOutput{}
+
+	if len(blockHash) > 0 {
+		result.BlockHash = hex.EncodeToString(blockHash)
+	}
+
+	if !result.Coinbase {
+		for _, vin := range tx.Vin {
+			input := rpcInput{TXID: hex.EncodeToString(vin.Txid), Vout: vin.Vout}
+			input.Address, _ = utils.PubKeyToAddres(vin.PubKey)
+
+			result.Inputs = append(result.Inputs, input)
+		}
+	}
+
+	for _, vout := range tx.Vout {
+		if vout.IsData() {
+			result.Outputs = append(result.Outputs, rpcOutput{Amount: vout.Value.String(), Data: hex.EncodeToString(vout.Data)})
+			continue
+		}
+		address, _ := utils.PubKeyHashToAddres(vout.PubKeyHash)
+
+		result.Outputs = append(result.Outputs, rpcOutput{Amount: vout.Value.String(), Address: address})
+	}
+	return result
+}
@@ -0,0 +1,308 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/taincoin/taincoin/lib/wallet"
+	"github.com/taincoin/taincoin/node/events"
+)
+
+// URL path of the WebSocket endpoint on the RPC server
+const rpcWebSocketPath = "/ws"
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingPeriod   = 30 * time.Second
+)
+
+// WebSocket connection. Works with same JSON-RPC 2.0 messages as HTTP, plus
+// methods "subscribe" and "unsubscribe". Events are pushed as notifications:
+// {"jsonrpc":"2.0","method":"event","params":{"subscription":1,"event":{...}}}
+type wsConnection struct {
+	rs         *rpcServer
+	conn       *websocket.Conn
+	authorized bool
+
+	writeLock *sync.Mutex
+	lock      *sync.Mutex
+	subs      map[int]wsSubscription
+	lastID    int
+}
+
+type wsSubscription struct {
+	kind    string
+	address string // only for payments
+}
+
+type rpcSubscribeParams struct {
+	Kind    string `json:"kind"`
+	Address string `json:"address"`
+}
+
+type rpcUnsubscribeParams struct {
+	Subscription int `json:"subscription"`
+}
+
+type rpcEventNotification struct {
+	JSONRPC string         `json:"jsonrpc"`
+	Method  string         `json:"method"`
+	Params  rpcEventParams `json:"params"`
+}
+
+type rpcEventParams struct {
+	Subscription int      `json:"subscription"`
+	Event        rpcEvent `json:"event"`
+}
+
+type rpcEvent struct {
+	Kind      string `json:"kind"`
+	BlockHash string `json:"blockhash,omitempty"`
+	Height    *int   `json:"height,omitempty"`
+	PrevTop   string `json:"prevtop,omitempty"`
+	Removed   int    `json:"removed,omitempty"`
+	Added     int    `json:"added,omitempty"`
+	TXID      string `json:"txid,omitempty"`
+	Vout      *int   `json:"vout,omitempty"`
+	Address   string `json:"address,omitempty"`
+	Amount    string `json:"amount,omitempty"`
+	Confirmed *bool  `json:"confirmed,omitempty"`
+}
+
+var wsUpgrader = websocket.Upgrader{ReadBufferSize: 4096, WriteBufferSize: 4096}
+
+func (rs *rpcServer) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	if rs.S.Node.Events == nil {
+		http.Error(w, "Events are not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+
+	if err != nil {
+		// the upgrader already wrote an error response
+		rs.Logger.Trace.Println("WebSocket upgrade error: ", err.Error())
+		return
+	}
+
+	c := &wsConnection{}
+	c.rs = rs
+	c.conn = conn
+	c.authorized = rs.isAuthorized(r)
+	c.writeLock = &sync.Mutex{}
+	c.lock = &sync.Mutex{}
+	c.subs = map[int]wsSubscription{}
+
+	go c.run()
+}
+
+// Reads requests and sends events until the connection is closed
+func (c *wsConnection) run() {
+	defer c.conn.Close()
+
+	busID, eventsChan := c.rs.S.Node.Events.Subscribe()
+	defer c.rs.S.Node.Events.Unsubscribe(busID)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go c.sendEvents(eventsChan, done)
+
+	c.conn.SetReadLimit(rpcMaxRequestSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+
+		if err != nil {
+			return
+		}
+
+		response := c.handleRequest(data)
+
+		if response != nil {
+			if c.write(response) != nil {
+				return
+			}
+		}
+	}
+}
+
+// Forwards events matching subscriptions. Pings a client to detect dead connections
+func (c *wsConnection) sendEvents(eventsChan <-chan events.Event, done chan struct{}) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case e, ok := <-eventsChan:
+			if !ok {
+				return
+			}
+			for _, id := range c.getMatchingSubscriptions(e) {
+				notification := rpcEventNotification{"2.0", "event", rpcEventParams{id, makeRPCEvent(e)}}
+
+				if c.write(notification) != nil {
+					return
+				}
+			}
+		case <-ticker.C:
+			c.writeLock.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+			c.writeLock.Unlock()
+
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Subscriptions are handled here, all other methods same way as HTTP requests
+func (c *wsConnection) handleRequest(data []byte) *rpcResponse {
+	request := rpcRequest{}
+
+	err := json.Unmarshal(data, &request)
+
+	if err != nil {
+		return c.rs.errorResponse(nil, rpcErrorParse, err.Error())
+	}
+
+	var result interface{}
+
+	switch request.Method {
+	case "subscribe":
+		result, err = c.subscribe(request.Params)
+	case "unsubscribe":
+		result, err = c.unsubscribe(request.Params)
+	default:
+		return c.rs.handleRequest(data, c.authorized)
+	}
+
+	if request.ID == nil {
+		return nil
+	}
+
+	if err != nil {
+		return c.rs.errorResponse(request.ID, rpcErrorInvalidParams, err.Error())
+	}
+
+	resultdata, _ := json.Marshal(result)
+	raw := json.RawMessage(resultdata)
+
+	return &rpcResponse{JSONRPC: "2.0", Result: &raw, ID: request.ID}
+}
+
+func (c *wsConnection) subscribe(params json.RawMessage) (int, error) {
+	payload := rpcSubscribeParams{}
+
+	err := parseRPCParams(params, &payload)
+
+	if err != nil {
+		return 0, err
+	}
+
+	switch payload.Kind {
+	case events.KindBlock, events.KindReorg, events.KindTransaction:
+		payload.Address = ""
+	case events.KindPayment:
+		w := wallet.Wallet{}
+
+		if !w.ValidateAddress(payload.Address) {
+			return 0, newRPCInvalidParams("Address is not valid")
+		}
+	default:
+		return 0, newRPCInvalidParams("Unknown kind of events")
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.lastID++
+	c.subs[c.lastID] = wsSubscription{payload.Kind, payload.Address}
+
+	return c.lastID, nil
+}
+
+func (c *wsConnection) unsubscribe(params json.RawMessage) (bool, error) {
+	payload := rpcUnsubscribeParams{}
+
+	err := parseRPCParams(params, &payload)
+
+	if err != nil {
+		return false, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.subs[payload.Subscription]; !ok {
+		return false, newRPCInvalidParams("Subscription is not found")
+	}
+	delete(c.subs, payload.Subscription)
+
+	return true, nil
+}
+
+func (c *wsConnection) getMatchingSubscriptions(e events.Event) []int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ids := []int{}
+
+	for id, sub := range c.subs {
+		if sub.kind == e.Kind && (sub.address == "" || sub.address == e.Address) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Only one routine can write to a websocket connection
+func (c *wsConnection) write(message interface{}) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+
+	return c.conn.WriteJSON(message)
+}
+
+func makeRPCEvent(e events.Event) rpcEvent {
+	result := rpcEvent{Kind: e.Kind}
+
+	if len(e.BlockHash) > 0 {
+		result.BlockHash = hex.EncodeToString(e.BlockHash)
+		height := e.Height
+		result.Height = &height
+	}
+
+	switch e.Kind {
+	case events.KindReorg:
+		result.PrevTop = hex.EncodeToString(e.PrevTop)
+		result.Removed = e.Removed
+		result.Added = e.Added
+	case events.KindTransaction:
+		result.TXID = hex.EncodeToString(e.TXID)
+	case events.KindPayment:
+		vout := e.Vout
+		confirmed := e.Confirmed
+
+		result.TXID = hex.EncodeToString(e.TXID)
+		result.Vout = &vout
+		result.Address = e.Address
+		result.Amount = e.Amount.String()
+		result.Confirmed = &confirmed
+	}
+	return result
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/taincoin/taincoin/node/events"
+)
+
+// WebSocket connection without a network connection. Requests are passed to handleRequest directly
+func makeTestWSConnection(authorized bool) *wsConnection {
+	c := &wsConnection{}
+	c.rs = makeTestRPCServer()
+	c.authorized = authorized
+	c.lock = &sync.Mutex{}
+	c.subs = map[int]wsSubscription{}
+
+	return c
+}
+
+func TestWSRequests(t *testing.T) {
+	c := makeTestWSConnection(false)
+
+	address := makeTestAddress()
+
+	tests := []struct {
+		request string
+		code    int // 0 if a result is expected
+		result  string
+	}{
+		{`{"jsonrpc":"2.0","method":"subscribe","params":{"kind":"block"},"id":1}`, 0, "1"},
+		{`{"jsonrpc":"2.0","method":"subscribe","params":{"kind":"reorg","address":"x"},"id":2}`, 0, "2"},
+		{`{"jsonrpc":"2.0","method":"subscribe","params":{"kind":"payment","address":"` + address + `"},"id":3}`, 0, "3"},
+		{`{"jsonrpc":"2.0","method":"subscribe","params":{"kind":"payment","address":"bad"},"id":4}`, rpcErrorInvalidParams, ""},
+		{`{"jsonrpc":"2.0","method":"subscribe","params":{"kind":"payment"},"id":5}`, rpcErrorInvalidParams, ""},
+		{`{"jsonrpc":"2.0","method":"subscribe","params":{"kind":"unknown"},"id":6}`, rpcErrorInvalidParams, ""},
+		{`{"jsonrpc":"2.0","method":"subscribe","params":"block","id":7}`, rpcErrorInvalidParams, ""},
+		{`{"jsonrpc":"2.0","method":"unsubscribe","params":{"subscription":2},"id":8}`, 0, "true"},
+		{`{"jsonrpc":"2.0","method":"unsubscribe","params":{"subscription":2},"id":9}`, rpcErrorInvalidParams, ""},
+		{`{"jsonrpc":"2.0","method":"subscribe",`, rpcErrorParse, ""},
+		{`{"jsonrpc":"2.0","method":"send","id":10}`, rpcErrorAuthRequired, ""},
+		{`{"jsonrpc":"2.0","method":"nomethod","id":11}`, rpcErrorMethodNotFound, ""},
+	}
+
+	for _, test := range tests {
+		response := c.handleRequest([]byte(test.request))
+
+		if response == nil {
+			t.Fatalf("%s: no response", test.request)
+		}
+
+		if test.code != 0 {
+			if response.Error == nil || response.Error.Code != test.code {
+				t.Fatalf("%s: expected error %d", test.request, test.code)
+			}
+			continue
+		}
+
+		if response.Error != nil || response.Result == nil || string(*response.Result) != test.result {
+			t.Fatalf("%s: expected result %s", test.request, test.result)
+		}
+	}
+
+	// notification has no response
+	if c.handleRequest([]byte(`{"jsonrpc":"2.0","method":"subscribe","params":{"kind":"transaction"}}`)) != nil {
+		t.Fatalf("Expected no response for a notification")
+	}
+}
+
+func TestWSSubscriptionFilter(t *testing.T) {
+	c := makeTestWSConnection(false)
+
+	address := makeTestAddress()
+
+	for _, params := range []string{
+		`{"kind":"block"}`,
+		`{"kind":"payment","address":"` + address + `"}`,
+		`{"kind":"transaction"}`,
+		`{"kind":"block"}`,
+	} {
+		_, err := c.subscribe(json.RawMessage(params))
+
+		if err != nil {
+			t.Fatalf("Subscribe %s failed: %s", params, err.Error())
+		}
+	}
+
+	tests := []struct {
+		event events.Event
+		ids   []int
+	}{
+		{events.Event{Kind: events.KindBlock, Height: 1}, []int{1, 4}},
+		{events.Event{Kind: events.KindPayment, Address: address}, []int{2}},
+		{events.Event{Kind: events.KindPayment, Address: makeTestAddress()}, []int{}},
+		{events.Event{Kind: events.KindTransaction}, []int{3}},
+		{events.Event{Kind: events.KindReorg}, []int{}},
+	}
+
+	for _, test := range tests {
+		ids := c.getMatchingSubscriptions(test.event)
+		sort.Ints(ids)
+
+		if len(ids) != len(test.ids) {
+			t.Fatalf("Event %s: expected subscriptions %v, got %v", test.event.Kind, test.ids, ids)
+		}
+
+		for i := range ids {
+			if ids[i] != test.ids[i] {
+				t.Fatalf("Event %s: expected subscriptions %v, got %v", test.event.Kind, test.ids, ids)
+			}
+		}
+	}
+
+	_, err := c.unsubscribe(json.RawMessage(`{"subscription":1}`))
+
+	if err != nil {
+		t.Fatalf("Unsubscribe failed: %s", err.Error())
+	}
+
+	if ids := c.getMatchingSubscriptions(events.Event{Kind: events.KindBlock}); len(ids) != 1 || ids[0] != 4 {
+		t.Fatalf("Expected only subscription 4 after unsubscribe, got %v", ids)
+	}
+}
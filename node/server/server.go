@@ -7,12 +7,16 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	netlib "github.com/taincoin/taincoin/lib/net"
 	"github.com/taincoin/taincoin/lib/nodeclient"
 	"github.com/taincoin/taincoin/lib/utils"
+	"github.com/taincoin/taincoin/lib/wallet"
+	"github.com/taincoin/taincoin/node/config"
 	"github.com/taincoin/taincoin/node/nodemanager"
 )
 
@@ -23,14 +27,29 @@ type NodeServer struct {
 	NodeAddress netlib.NodeAddr
 
 	Transit nodeTransit
+	Sync    nodeSync
 
 	Logger *utils.LoggerMan
 	// Channels to manipulate roitunes
 	StopMainChan        chan struct{}
 	StopMainConfirmChan chan struct{}
 	BlockBilderChan     chan []byte
+	// the builder channel is closed when the routine exits. sending is done under the lock
+	blockBuilderLock    sync.Mutex
+	blockBuilderStopped bool
 
 	NodeAuthStr string
+
+	RPC config.RPCConfig
+	rpc *rpcServer
+
+	Metrics       config.MetricsConfig
+	metricsServer *http.Server
+	metricsState  *metricsStateCache
+	metricsSubID  int
+
+	Timestamp config.TimestampConfig
+	stamps    *timestampService
 }
 
 func (s *NodeServer) GetClient() *nodeclient.NodeClient {
@@ -112,6 +131,30 @@ func (s *NodeServer) handleConnection(conn net.Conn) {
 	case "getbalance":
 		rerr = requestobj.handleGetBalance()
 
+	case "getsupply":
+		rerr = requestobj.handleGetSupply()
+
+	case "getheaders":
+		rerr = requestobj.handleGetHeaders()
+
+	case "getproof":
+		rerr = requestobj.handleGetMerkleProof()
+
+	case "getsecret":
+		rerr = requestobj.handleGetSecret()
+
+	case "getanchor":
+		rerr = requestobj.handleGetAnchor()
+
+	case "stamp":
+		rerr = requestobj.handleStamp()
+
+	case "getreceipt":
+		rerr = requestobj.handleGetReceipt()
+
+	case "getblock":
+		rerr = requestobj.handleGetBlock()
+
 	case "getfblocks":
 		rerr = requestobj.handleGetFirstBlocks()
 
@@ -139,6 +182,12 @@ func (s *NodeServer) handleConnection(conn net.Conn) {
 	case "getstate":
 		rerr = requestobj.handleGetState()
 
+	case "unlockwallet":
+		rerr = requestobj.handleUnlockWallet()
+
+	case "lockwallet":
+		rerr = requestobj.handleLockWallet()
+
 	case "version":
 		rerr = requestobj.handleVersion()
 	default:
@@ -175,6 +224,8 @@ func (s *NodeServer) handleConnection(conn net.Conn) {
 	ms := duration.Nanoseconds() / int64(time.Millisecond)
 	s.Logger.Trace.Printf("Complete processing %s command. Time: %d ms, sess %s", command, ms, sessid)
 
+	s.updateCommandMetrics(command, duration, rerr)
+
 	conn.Close()
 }
 
@@ -217,6 +268,46 @@ func (s *NodeServer) StartServer(serverStartResult chan string) error {
 	// client will use the address to include it in requests
 	s.Node.NodeClient.SetNodeAddress(s.NodeAddress)
 
+	if s.RPC.IsEnabled() {
+		s.rpc = newRPCServer(s, s.RPC, s.Logger)
+
+		err = s.rpc.Start()
+
+		if err != nil {
+			serverStartResult <- err.Error()
+
+			close(s.StopMainConfirmChan)
+			return err
+		}
+		defer s.rpc.Stop()
+	}
+
+	if s.Metrics.IsEnabled() {
+		err = s.startMetrics()
+
+		if err != nil {
+			serverStartResult <- err.Error()
+
+			close(s.StopMainConfirmChan)
+			return err
+		}
+		defer s.stopMetrics()
+	}
+
+	if s.Timestamp.IsEnabled() {
+		s.stamps = newTimestampService(s, s.Timestamp, s.Logger)
+
+		err = s.stamps.Start()
+
+		if err != nil {
+			serverStartResult <- err.Error()
+
+			close(s.StopMainConfirmChan)
+			return err
+		}
+		defer s.stamps.Stop()
+	}
+
 	s.Node.SendVersionToNodes([]netlib.NodeAddr{})
 
 	s.Logger.Trace.Println("Start block bilding routine")
@@ -230,6 +321,10 @@ func (s *NodeServer) StartServer(serverStartResult chan string) error {
 
 	go s.BlockBuilder()
 
+	if s.Node.ConsensusConfig.IsProofOfAuthority() {
+		go s.BlockTimer()
+	}
+
 	s.Logger.Trace.Println("Start listening connections on port ", s.NodeAddress.Port)
 
 	for {
@@ -275,6 +370,13 @@ func (s *NodeServer) StartServer(serverStartResult chan string) error {
 * And try to make a block if there are enough transactions
  */
 func (s *NodeServer) TryToMakeNewBlock(tx []byte) {
+	s.blockBuilderLock.Lock()
+	defer s.blockBuilderLock.Unlock()
+
+	if s.blockBuilderStopped {
+		return
+	}
+
 	// don't block sending. buffer size is 100
 	// TX will be skipped if a buffer is full
 	select {
@@ -300,7 +402,11 @@ func (s *NodeServer) BlockBuilder() {
 
 		if len(txID) == 0 {
 			// this is return signal from main thread
+			s.blockBuilderLock.Lock()
+			s.blockBuilderStopped = true
 			close(s.BlockBilderChan)
+			s.blockBuilderLock.Unlock()
+
 			s.Logger.Trace.Printf("Exit BlockBuilder thread")
 			return
 		}
@@ -319,6 +425,80 @@ func (s *NodeServer) BlockBuilder() {
 	}
 }
 
+// Proof of authority. Blocks are tried to make periodically, not only when a new transaction is received.
+// If a validator in turn is offline, other validators make a block after a delay
+func (s *NodeServer) BlockTimer() {
+	ticker := time.NewTicker(time.Duration(config.PoAOutOfTurnDelay) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.StopMainChan:
+			return
+		case <-ticker.C:
+		}
+
+		// it does nothing if the builder routine exited already
+		s.TryToMakeNewBlock([]byte{0})
+	}
+}
+
+// Returns node state with progress of loading of blocks
+func (s *NodeServer) GetNodeState(node *nodemanager.Node) (nodeclient.ComGetNodeState, error) {
+	info, err := node.GetNodeState()
+
+	if err != nil {
+		return info, err
+	}
+
+	info.ExpectingBlocksHeight = s.Transit.MaxKnownHeigh
+
+	state := s.Sync.GetState()
+
+	info.Syncing = state.Running
+
+	if state.Running {
+		info.SyncNode = state.Node.NodeAddrToString()
+		info.SyncHeadersHeight = state.HeadersHeight
+		info.SyncBlocksHeight = state.BlocksHeight
+		info.SyncNodes = state.Nodes
+	}
+	return info, nil
+}
+
+// Decrypts the node wallet and keeps keys in memory. A plain wallet must be encrypted with the unlock command first
+func (s *NodeServer) UnlockWallet(passphrase string, timeout int) error {
+	wallets := wallet.Wallets{}
+	wallets.DataDir = s.DataDir
+	wallets.Logger = s.Logger
+
+	err := wallets.LoadFromFile()
+
+	if err != nil {
+		return err
+	}
+
+	err = wallets.Unlock(passphrase)
+
+	if err != nil {
+		return err
+	}
+
+	s.Logger.Trace.Printf("Wallet unlocked for %d seconds", timeout)
+
+	return wallet.KeepUnlocked(&wallets, time.Duration(timeout)*time.Second)
+}
+
+// Removes keys of the node wallet from memory
+func (s *NodeServer) LockWallet() {
+	wallets := wallet.Wallets{}
+	wallets.DataDir = s.DataDir
+
+	wallet.ForgetUnlocked(&wallets)
+
+	s.Logger.Trace.Println("Wallet locked")
+}
+
 /*
 * Creates clone of a node object. We use this in case if we need separate object
 * for a routine. This prevents conflicts of pointers in different routines
@@ -331,6 +511,8 @@ func (s *NodeServer) CloneNode() *nodemanager.Node {
 	node.DataDir = s.DataDir
 	node.Logger = s.Logger
 	node.MinterAddress = orignode.MinterAddress
+	node.ConsensusConfig = orignode.ConsensusConfig
+	node.Events = orignode.Events
 	// clone DB object
 	ndb := orignode.DBConn.Clone()
 	node.DBConn = &ndb
@@ -2,8 +2,30 @@ package server
 
 import (
 	"testing"
+
+	"github.com/taincoin/taincoin/lib/utils"
 )
 
 func TestServerStart(t *testing.T) {
 
 }
+
+func TestTryToMakeNewBlockAfterStop(t *testing.T) {
+	s := &NodeServer{Logger: utils.CreateLogger()}
+	s.BlockBilderChan = make(chan []byte, 100)
+
+	done := make(chan struct{})
+
+	go func() {
+		s.BlockBuilder()
+		close(done)
+	}()
+
+	// exit signal
+	s.BlockBilderChan <- []byte{}
+
+	<-done
+
+	// the channel is closed now. a signal from a timer or a handler must not panic
+	s.TryToMakeNewBlock([]byte{0})
+}
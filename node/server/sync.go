@@ -0,0 +1,490 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/net"
+	"github.com/taincoin/taincoin/lib/nodeclient"
+	"github.com/taincoin/taincoin/lib/utils"
+	"github.com/taincoin/taincoin/node/blockchain"
+	"github.com/taincoin/taincoin/node/nodemanager"
+	"github.com/taincoin/taincoin/node/structures"
+)
+
+// Headers first synchronization.
+// Headers of missed blocks are loaded from a node with longer chain and verified first (links,
+// difficulty and proof of work) and compared with our chain by work. Only after this bodies are loaded,
+// in parallel from all known nodes (see blocksDownloader).
+// So, a node can not make us to load a lot of fake blocks, and a sync doesn't stop if one node goes away
+type nodeSync struct {
+	S      *NodeServer
+	Logger *utils.LoggerMan
+	lock   *sync.Mutex
+	state  syncState
+
+	// requests headers from a node. Tests replace it
+	requestHeaders func(n *nodemanager.Node, node net.NodeAddr, from int, count int) ([]nodeclient.ComBlockHeader, error)
+}
+
+// Progress of a synchronization
+type syncState struct {
+	Running       bool
+	Node          net.NodeAddr // node headers are loaded from
+	HeadersHeight int
+	BlocksHeight  int
+	Nodes         int // number of nodes bodies are loaded from
+}
+
+// Max number of headers loaded from a node in one synchronization. Headers are kept in memory
+// until blocks are loaded. If a node has more, next sync continues from the new top
+const MaxSyncHeaders = 50000
+
+func (ns *nodeSync) Init(s *NodeServer, l *utils.LoggerMan) error {
+	ns.S = s
+	ns.Logger = l
+	ns.lock = &sync.Mutex{}
+
+	ns.requestHeaders = func(n *nodemanager.Node, node net.NodeAddr, from int, count int) ([]nodeclient.ComBlockHeader, error) {
+		return n.NodeClient.SendGetHeaders(node, from, count)
+	}
+	return nil
+}
+
+// Returns copy of current progress
+func (ns *nodeSync) GetState() syncState {
+	ns.lock.Lock()
+	defer ns.lock.Unlock()
+
+	return ns.state
+}
+
+func (ns *nodeSync) IsRunning() bool {
+	return ns.GetState().Running
+}
+
+func (ns *nodeSync) updateState(update func(state *syncState)) {
+	ns.lock.Lock()
+	defer ns.lock.Unlock()
+
+	update(&ns.state)
+}
+
+// Starts synchronization with a node in a separate routine. Returns false if other synchronization is running
+func (ns *nodeSync) Start(node net.NodeAddr) bool {
+	ns.lock.Lock()
+	defer ns.lock.Unlock()
+
+	if ns.state.Running {
+		return false
+	}
+
+	ns.state = syncState{Running: true, Node: node, HeadersHeight: -1, BlocksHeight: -1, Nodes: 1}
+
+	go ns.run(node)
+
+	return true
+}
+
+func (ns *nodeSync) run(node net.NodeAddr) {
+	n := ns.S.CloneNode()
+
+	added, err := ns.sync(n, node)
+
+	ns.updateState(func(state *syncState) {
+		state.Running = false
+	})
+
+	if err != nil {
+		ns.Logger.Trace.Printf("Headers sync with %s failed: %s", node.NodeAddrToString(), err.Error())
+
+		if added == 0 {
+			// maybe this node doesn't support headers. load blocks with old way
+			ns.requestBlocksUpper(n, node)
+		}
+		return
+	}
+
+	ns.Logger.Trace.Printf("Headers sync with %s complete. Added %d blocks", node.NodeAddrToString(), added)
+
+	if added > 0 {
+		// let other nodes know we have new blocks. If some node has more, it will send version back
+		// and we will continue
+		n.SendVersionToNodes([]net.NodeAddr{})
+		// maybe some transactions become unapproved. try to make new block from them
+		ns.S.TryToMakeNewBlock([]byte{1})
+	}
+}
+
+// Old way of loading blocks. Request for a list of blocks after our top
+func (ns *nodeSync) requestBlocksUpper(n *nodemanager.Node, node net.NodeAddr) {
+	err := n.DBConn.OpenConnection("SyncRequestBlocks", n.SessionID)
+
+	if err != nil {
+		return
+	}
+
+	topHash, err := n.NodeBC.GetTopBlockHash()
+
+	n.DBConn.CloseConnection()
+
+	if err == nil {
+		n.NodeClient.SendGetBlocksUpper(node, topHash)
+	}
+}
+
+// Loads headers, then bodies and adds blocks to the chain. Returns number of added blocks
+func (ns *nodeSync) sync(n *nodemanager.Node, node net.NodeAddr) (int, error) {
+	headers, err := ns.loadHeaders(n, node)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if len(headers) == 0 {
+		return 0, nil
+	}
+
+	err = ns.checkBranch(n, headers)
+
+	if err != nil {
+		return 0, err
+	}
+
+	nodes := ns.getNodesForBlocks(n, node)
+
+	ns.updateState(func(state *syncState) {
+		state.Nodes = len(nodes)
+	})
+
+	downloader := newBlocksDownloader(ns, n, headers, nodes)
+
+	return downloader.Run(func(block *structures.Block) error {
+		err := ns.addBlock(n, block)
+
+		if err != nil {
+			return err
+		}
+
+		ns.updateState(func(state *syncState) {
+			state.BlocksHeight = block.Height
+		})
+		return nil
+	})
+}
+
+// Loads headers of blocks we don't have. Finds last common block first
+func (ns *nodeSync) loadHeaders(n *nodemanager.Node, node net.NodeAddr) ([]nodeclient.ComBlockHeader, error) {
+	err := n.DBConn.OpenConnection("SyncHeaders", n.SessionID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	bestHeight, err := n.NodeBC.GetBestHeight()
+
+	n.DBConn.CloseConnection()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// go down from our top until a header is linked to a block we have
+	from := bestHeight + 1
+	step := 1
+
+	var list []nodeclient.ComBlockHeader
+
+	next := 0
+
+	for {
+		list, err = ns.getHeaders(n, node, from)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(list) > 0 {
+			next = list[len(list)-1].Height + 1
+
+			list, err = ns.skipKnownHeaders(n, list)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if list != nil {
+				break
+			}
+		}
+
+		if from == 0 {
+			return nil, errors.New("Node has other genesis block")
+		}
+
+		from -= step
+		step *= 2
+
+		if from < 0 {
+			from = 0
+		}
+	}
+
+	// headers of our blocks before loaded headers. They are needed to check difficulty and time
+	var chain []nodeclient.ComBlockHeader
+
+	headers := []nodeclient.ComBlockHeader{}
+
+	for {
+		if len(headers) == 0 {
+			// all headers in previous list could be known
+			list, err = ns.skipKnownHeaders(n, list)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if len(list) > 0 {
+				chain, err = ns.getHeadersBefore(n, list[0].PrevBlockHash)
+
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		for _, h := range list {
+			err = ns.verifyHeader(n, chain, h)
+
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, h)
+			headers = append(headers, h)
+		}
+
+		if len(list) > 0 {
+			last := headers[len(headers)-1]
+
+			ns.updateState(func(state *syncState) {
+				state.HeadersHeight = last.Height
+			})
+		}
+
+		if len(headers) >= MaxSyncHeaders {
+			// other headers are loaded on next sync
+			break
+		}
+
+		list, err = ns.getHeaders(n, node, next)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(list) == 0 {
+			break
+		}
+		next = list[len(list)-1].Height + 1
+	}
+
+	ns.Logger.Trace.Printf("Loaded %d headers from %s", len(headers), node.NodeAddrToString())
+
+	return headers, nil
+}
+
+// Requests headers from a node. A node can not return more than a limit
+func (ns *nodeSync) getHeaders(n *nodemanager.Node, node net.NodeAddr, from int) ([]nodeclient.ComBlockHeader, error) {
+	list, err := ns.requestHeaders(n, node, from, nodeclient.MaxHeadersPerRequest)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list) > nodeclient.MaxHeadersPerRequest {
+		return nil, errors.New(fmt.Sprintf("Node returned %d headers. Max is %d", len(list), nodeclient.MaxHeadersPerRequest))
+	}
+	return list, nil
+}
+
+// Returns headers of our blocks ending with given block. There are enough of them to check
+// difficulty and time of a next header
+func (ns *nodeSync) getHeadersBefore(n *nodemanager.Node, hash []byte) ([]nodeclient.ComBlockHeader, error) {
+	err := n.DBConn.OpenConnection("SyncHeadersBefore", n.SessionID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer n.DBConn.CloseConnection()
+
+	count := lib.DifficultyAdjustmentInterval
+
+	if lib.MedianTimeSpan > count {
+		count = lib.MedianTimeSpan
+	}
+	return n.GetHeadersBefore(hash, count)
+}
+
+// Removes headers of blocks we already have. Returns nil if first header is not linked to our blocks
+func (ns *nodeSync) skipKnownHeaders(n *nodemanager.Node, list []nodeclient.ComBlockHeader) ([]nodeclient.ComBlockHeader, error) {
+	if len(list) == 0 {
+		return list, nil
+	}
+
+	err := n.DBConn.OpenConnection("SyncCheckHeaders", n.SessionID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer n.DBConn.CloseConnection()
+
+	hash := list[0].PrevBlockHash
+
+	if list[0].Height == 0 {
+		hash = list[0].Hash
+	}
+
+	exists, err := n.NodeBC.CheckBlockExists(hash)
+
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	for len(list) > 0 {
+		exists, err = n.NodeBC.CheckBlockExists(list[0].Hash)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			break
+		}
+		list = list[1:]
+	}
+
+	return list, nil
+}
+
+// Checks a header continues a chain. Proof of work headers must have difficulty and time
+// allowed by the chain rules. Headers of other consensus engines can not be checked without
+// a state, only links are checked. Their blocks are verified completely when added
+func (ns *nodeSync) verifyHeader(n *nodemanager.Node, chain []nodeclient.ComBlockHeader, h nodeclient.ComBlockHeader) error {
+	if n.ConsensusConfig.IsProofOfWork() {
+		return nodeclient.VerifyNextHeader(h, chain, time.Now().Unix())
+	}
+
+	if len(chain) == 0 {
+		return nil
+	}
+
+	prev := chain[len(chain)-1]
+
+	if h.Height != prev.Height+1 {
+		return errors.New(fmt.Sprintf("Header height %d is wrong. Expected %d", h.Height, prev.Height+1))
+	}
+
+	if !bytes.Equal(h.PrevBlockHash, prev.Hash) {
+		return errors.New(fmt.Sprintf("Header %d is not linked to previous header", h.Height))
+	}
+	return nil
+}
+
+// Checks a branch of headers is better than our chain above the same block. Proof of work
+// branches are compared by work, a long branch of easy blocks is not better. Other branches by length
+func (ns *nodeSync) checkBranch(n *nodemanager.Node, headers []nodeclient.ComBlockHeader) error {
+	err := n.DBConn.OpenConnection("SyncCheckBranch", n.SessionID)
+
+	if err != nil {
+		return err
+	}
+	defer n.DBConn.CloseConnection()
+
+	ourWork, ourCount, err := n.GetChainWorkAbove(headers[0].PrevBlockHash)
+
+	if err != nil {
+		return err
+	}
+
+	if !n.ConsensusConfig.IsProofOfWork() {
+		if len(headers) <= ourCount {
+			return errors.New(fmt.Sprintf("Branch of %d blocks is not longer than our chain of %d blocks", len(headers), ourCount))
+		}
+		return nil
+	}
+
+	work := big.NewInt(0)
+
+	for _, h := range headers {
+		work.Add(work, utils.GetWorkForBits(h.Bits))
+	}
+
+	if work.Cmp(ourWork) <= 0 {
+		return errors.New("Branch has not more work than our chain")
+	}
+	return nil
+}
+
+// List of nodes to load bodies from. The node we got headers from is first
+func (ns *nodeSync) getNodesForBlocks(n *nodemanager.Node, node net.NodeAddr) []net.NodeAddr {
+	nodes := []net.NodeAddr{node}
+
+	for _, other := range n.NodeNet.Nodes {
+		if other.CompareToAddress(node) || other.CompareToAddress(n.NodeClient.NodeAddress) {
+			continue
+		}
+		nodes = append(nodes, other)
+	}
+	return nodes
+}
+
+// Loads a block and checks it is a block for the header
+func (ns *nodeSync) loadBlock(n *nodemanager.Node, node net.NodeAddr, header nodeclient.ComBlockHeader) (*structures.Block, error) {
+	blockdata, err := n.NodeClient.SendGetBlock(node, header.Hash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block := &structures.Block{}
+
+	err = block.DeserializeBlock(blockdata)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(block.Hash, header.Hash) ||
+		!bytes.Equal(block.PrevBlockHash, header.PrevBlockHash) ||
+		!bytes.Equal(block.MerkleRoot, header.MerkleRoot) ||
+		block.Height != header.Height {
+		return nil, errors.New("Block is not same as the header")
+	}
+	return block, nil
+}
+
+// Verifies and adds a block to the chain
+func (ns *nodeSync) addBlock(n *nodemanager.Node, block *structures.Block) error {
+	err := n.DBConn.OpenConnection("SyncAddBlock", n.SessionID)
+
+	if err != nil {
+		return err
+	}
+	defer n.DBConn.CloseConnection()
+
+	addstate, err := n.AddBlock(block)
+
+	if err != nil {
+		return err
+	}
+
+	if addstate == blockchain.BCBAddState_notAddedNoPrev {
+		return errors.New(fmt.Sprintf("Previous block for %x is not found", block.Hash))
+	}
+	return nil
+}
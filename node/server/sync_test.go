@@ -0,0 +1,263 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/taincoin/taincoin/lib/net"
+	"github.com/taincoin/taincoin/lib/nodeclient"
+	"github.com/taincoin/taincoin/lib/utils"
+	"github.com/taincoin/taincoin/node/blockchain"
+	"github.com/taincoin/taincoin/node/config"
+	"github.com/taincoin/taincoin/node/database"
+	"github.com/taincoin/taincoin/node/nodemanager"
+	"github.com/taincoin/taincoin/node/structures"
+)
+
+// Hash of a test block. Blocks of a fork have other branch number
+func makeTestHash(branch int, height int) []byte {
+	return []byte{byte(branch), byte(height)}
+}
+
+// Headers of a chain. Blocks up to forkHeight are common, blocks above are of the branch
+func makeTestHeaders(branch int, forkHeight int, top int) []nodeclient.ComBlockHeader {
+	headers := []nodeclient.ComBlockHeader{}
+
+	for height := 0; height <= top; height++ {
+		h := nodeclient.ComBlockHeader{Height: height, Hash: makeTestHash(0, height)}
+
+		if height > forkHeight {
+			h.Hash = makeTestHash(branch, height)
+		}
+
+		if height > 0 {
+			h.PrevBlockHash = headers[height-1].Hash
+		}
+		headers = append(headers, h)
+	}
+	return headers
+}
+
+// Makes a node with a chain of test blocks in a memory DB. Consensus is not proof of work,
+// so only links of headers are checked
+func newTestSyncNode(t *testing.T, top int) *nodemanager.Node {
+	logger := utils.CreateLogger()
+
+	db := &nodemanager.Database{}
+	db.SetLogger(logger)
+	db.SetConfig(database.DatabaseConfig{Engine: database.EngineMemory})
+	db.Init()
+
+	err := db.InitDatabase()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := &nodemanager.Node{}
+	n.Logger = logger
+	n.DBConn = db
+	n.ConsensusConfig = config.ConsensusConfig{Kind: "poa"}
+	n.NodeBC.Logger = logger
+	n.NodeBC.DBConn = db
+
+	err = db.OpenConnection("test", "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.CloseConnection()
+
+	bcdb, err := db.DB().GetBlockchainObject()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bcm, _ := blockchain.NewBlockchainManager(db.DB(), logger)
+
+	for _, h := range makeTestHeaders(0, top, top) {
+		block := &structures.Block{Hash: h.Hash, PrevBlockHash: h.PrevBlockHash, Height: h.Height}
+
+		data, err := block.Serialize()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if h.Height == 0 {
+			bcdb.PutBlockOnTop(block.Hash, data)
+			bcdb.SaveFirstHash(block.Hash)
+			bcdb.AddToChain(block.Hash, []byte{})
+			continue
+		}
+
+		state, err := bcm.AddBlock(block)
+
+		if err != nil || state != blockchain.BCBAddState_addedToTop {
+			t.Fatalf("Block %d is not added: %d %v", h.Height, state, err)
+		}
+	}
+	return n
+}
+
+// Makes sync object that gets headers from a list instead of a node
+func newTestSync(remote []nodeclient.ComBlockHeader) *nodeSync {
+	ns := &nodeSync{}
+	ns.Init(nil, utils.CreateLogger())
+
+	ns.requestHeaders = func(n *nodemanager.Node, node net.NodeAddr, from int, count int) ([]nodeclient.ComBlockHeader, error) {
+		list := []nodeclient.ComBlockHeader{}
+
+		for _, h := range remote {
+			if h.Height >= from && len(list) < count {
+				list = append(list, h)
+			}
+		}
+		return list, nil
+	}
+	return ns
+}
+
+func checkHeaders(t *testing.T, headers []nodeclient.ComBlockHeader, branch int, from int, to int) {
+	if len(headers) != to-from+1 {
+		t.Fatalf("Expected %d headers, got %d", to-from+1, len(headers))
+	}
+
+	for i, h := range headers {
+		if string(h.Hash) != string(makeTestHash(branch, from+i)) {
+			t.Fatalf("Header %d is %x, expected block %d of branch %d", i, h.Hash, from+i, branch)
+		}
+	}
+}
+
+func TestSkipKnownHeaders(t *testing.T) {
+	n := newTestSyncNode(t, 5)
+	ns := newTestSync(nil)
+
+	// known prefix is removed
+	list, err := ns.skipKnownHeaders(n, makeTestHeaders(0, 8, 8)[2:])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHeaders(t, list, 0, 6, 8)
+
+	// all are known
+	list, err = ns.skipKnownHeaders(n, makeTestHeaders(0, 5, 5)[1:])
+
+	if err != nil || list == nil || len(list) != 0 {
+		t.Fatalf("Expected empty list, got %v %v", list, err)
+	}
+
+	// first header is not linked to our blocks
+	list, err = ns.skipKnownHeaders(n, makeTestHeaders(1, 3, 8)[5:])
+
+	if err != nil || list != nil {
+		t.Fatalf("Expected nil for not linked headers, got %v %v", list, err)
+	}
+}
+
+func TestLoadHeadersKnownPrefix(t *testing.T) {
+	n := newTestSyncNode(t, 5)
+	ns := newTestSync(makeTestHeaders(0, 9, 9))
+
+	headers, err := ns.loadHeaders(n, net.NodeAddr{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHeaders(t, headers, 0, 6, 9)
+
+	if err = ns.checkBranch(n, headers); err != nil {
+		t.Fatal(err)
+	}
+
+	if ns.GetState().HeadersHeight != 9 {
+		t.Fatalf("Headers height in state is %d", ns.GetState().HeadersHeight)
+	}
+}
+
+func TestLoadHeadersFork(t *testing.T) {
+	n := newTestSyncNode(t, 5)
+
+	// the node has a longer branch from block 3. Common blocks are skipped
+	ns := newTestSync(makeTestHeaders(1, 3, 8))
+
+	headers, err := ns.loadHeaders(n, net.NodeAddr{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHeaders(t, headers, 1, 4, 8)
+
+	if err = ns.checkBranch(n, headers); err != nil {
+		t.Fatal(err)
+	}
+
+	// a branch of same length as our chain is not loaded
+	ns = newTestSync(makeTestHeaders(1, 3, 5))
+
+	headers, err = ns.loadHeaders(n, net.NodeAddr{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHeaders(t, headers, 1, 4, 5)
+
+	if err = ns.checkBranch(n, headers); err == nil {
+		t.Fatal("Expected error for a branch not longer than our chain")
+	}
+}
+
+func TestLoadHeadersBadHeader(t *testing.T) {
+	n := newTestSyncNode(t, 5)
+
+	remote := makeTestHeaders(0, 9, 9)
+	// block 8 is not linked to block 7
+	remote[8].PrevBlockHash = makeTestHash(1, 7)
+
+	ns := newTestSync(remote)
+
+	headers, err := ns.loadHeaders(n, net.NodeAddr{})
+
+	if err == nil || headers != nil {
+		t.Fatalf("Expected error for not linked header, got %d headers", len(headers))
+	}
+
+	// wrong height
+	remote = makeTestHeaders(0, 9, 9)
+	remote[7].Height = 8
+	remote[8].Height = 9
+
+	ns = newTestSync(remote[:9])
+
+	if _, err = ns.loadHeaders(n, net.NodeAddr{}); err == nil {
+		t.Fatal("Expected error for wrong height of a header")
+	}
+}
+
+func TestLoadHeadersEmptyResponse(t *testing.T) {
+	n := newTestSyncNode(t, 5)
+
+	// the node has same chain
+	ns := newTestSync(makeTestHeaders(0, 5, 5))
+
+	headers, err := ns.loadHeaders(n, net.NodeAddr{})
+
+	if err != nil || len(headers) != 0 {
+		t.Fatalf("Expected no headers, got %d %v", len(headers), err)
+	}
+
+	count, err := ns.sync(n, net.NodeAddr{})
+
+	if err != nil || count != 0 {
+		t.Fatalf("Expected nothing added, got %d %v", count, err)
+	}
+
+	// the node returns nothing at all
+	ns = newTestSync(nil)
+
+	if _, err = ns.loadHeaders(n, net.NodeAddr{}); err == nil {
+		t.Fatal("Expected error if a node has no common blocks")
+	}
+}
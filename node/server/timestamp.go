@@ -0,0 +1,481 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/taincoin/taincoin/lib/nodeclient"
+	"github.com/taincoin/taincoin/lib/utils"
+	"github.com/taincoin/taincoin/lib/wallet"
+	"github.com/taincoin/taincoin/node/config"
+	"github.com/taincoin/taincoin/node/events"
+	"github.com/taincoin/taincoin/node/nodemanager"
+)
+
+const timestampsFile = "timestamps.dat"
+
+// Limits of stamp requests
+const (
+	MaxStampHashesPerRequest = 1000
+	MaxStampPendingHashes    = 100000 // hashes waiting for next batch
+	MaxStampHashesPerBatch   = 10000  // hashes anchored with one transaction
+)
+
+// Timestamping service. Hashes of documents are collected and a Merkle root of them is anchored
+// with a data output. Only one transaction is made per block, next batch is anchored when
+// previous one is in a block. Batches are kept, so a receipt can be requested any time later
+type timestampService struct {
+	S      *NodeServer
+	Config config.TimestampConfig
+	Logger *utils.LoggerMan
+
+	lock  *sync.Mutex
+	state timestampState
+	index map[string]int // hash to a batch. -1 for pending hashes
+
+	busID    int
+	anchorCh chan struct{}
+}
+
+// State of the service
+type timestampState struct {
+	Pending [][]byte
+	Batches []stampBatch
+}
+
+// Change of the state. Changes are appended to the file, the state is restored from them on start.
+// Hashes are added to pending. Or first Count pending hashes are anchored with TXID.
+// Or a batch with TXID is dropped and its hashes are pending again. Old records have no TXID,
+// it is the last batch then
+type stampRecord struct {
+	Hashes  [][]byte
+	Count   int
+	Root    []byte
+	TXID    []byte
+	Dropped bool
+}
+
+// Hashes anchored with one transaction
+type stampBatch struct {
+	Hashes [][]byte
+	Root   []byte
+	TXID   []byte
+}
+
+func newTimestampService(s *NodeServer, conf config.TimestampConfig, logger *utils.LoggerMan) *timestampService {
+	ts := &timestampService{S: s, Config: conf, Logger: logger}
+	ts.lock = &sync.Mutex{}
+	ts.state = timestampState{[][]byte{}, []stampBatch{}}
+	ts.index = map[string]int{}
+	return ts
+}
+
+// Loads saved hashes and starts a routine to anchor them on every new block
+func (ts *timestampService) Start() error {
+	if ts.S.Node.Events == nil {
+		return errors.New("Timestamping needs node events")
+	}
+
+	err := ts.load()
+
+	if err != nil {
+		return errors.New(fmt.Sprintf("Timestamps loading error: %s", err.Error()))
+	}
+
+	busID, ch := ts.S.Node.Events.Subscribe()
+	ts.busID = busID
+	ts.anchorCh = make(chan struct{}, 1)
+
+	go ts.run(ch)
+
+	// hashes could be left pending before a restart
+	ts.tryToAnchor()
+
+	return nil
+}
+
+func (ts *timestampService) Stop() {
+	ts.S.Node.Events.Unsubscribe(ts.busID)
+}
+
+func (ts *timestampService) run(ch <-chan events.Event) {
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if e.Kind != events.KindBlock && e.Kind != events.KindReorg {
+				continue
+			}
+		case <-ts.anchorCh:
+		}
+
+		err := ts.anchor()
+
+		if err != nil {
+			ts.Logger.Error.Printf("Timestamps anchoring error: %s", err.Error())
+		}
+	}
+}
+
+// Signals the routine to anchor pending hashes. DB is used there, so it is not done
+// in a routine that has DB connection open already
+func (ts *timestampService) tryToAnchor() {
+	select {
+	case ts.anchorCh <- struct{}{}:
+	default:
+	}
+}
+
+func (ts *timestampService) load() error {
+	fileContent, err := ioutil.ReadFile(ts.S.DataDir + timestampsFile)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	ts.state = timestampState{[][]byte{}, []stampBatch{}}
+	ts.index = map[string]int{}
+
+	reader := bytes.NewReader(fileContent)
+
+	for reader.Len() > 0 {
+		var size uint32
+
+		err = binary.Read(reader, binary.BigEndian, &size)
+
+		if err != nil || int(size) > reader.Len() {
+			// the last record was not written completely
+			ts.Logger.Warning.Printf("Timestamps: broken record at the end of the file is skipped")
+			break
+		}
+
+		record := stampRecord{}
+
+		err = gob.NewDecoder(io.LimitReader(reader, int64(size))).Decode(&record)
+
+		if err != nil {
+			return err
+		}
+
+		ts.apply(record)
+	}
+	return nil
+}
+
+// Changes the state with a record
+func (ts *timestampService) apply(record stampRecord) {
+	if record.Dropped {
+		pos := len(ts.state.Batches) - 1
+
+		if len(record.TXID) > 0 {
+			pos = -1
+
+			for i, batch := range ts.state.Batches {
+				if bytes.Equal(batch.TXID, record.TXID) {
+					pos = i
+					break
+				}
+			}
+		}
+
+		if pos < 0 {
+			return
+		}
+		dropped := ts.state.Batches[pos]
+
+		ts.state.Pending = append(dropped.Hashes, ts.state.Pending...)
+		ts.state.Batches = append(ts.state.Batches[:pos:pos], ts.state.Batches[pos+1:]...)
+
+		for _, hash := range dropped.Hashes {
+			ts.index[string(hash)] = -1
+		}
+
+		// next batches moved
+		for i := pos; i < len(ts.state.Batches); i++ {
+			for _, hash := range ts.state.Batches[i].Hashes {
+				ts.index[string(hash)] = i
+			}
+		}
+		return
+	}
+
+	if len(record.Root) == 0 {
+		for _, hash := range record.Hashes {
+			ts.state.Pending = append(ts.state.Pending, hash)
+			ts.index[string(hash)] = -1
+		}
+		return
+	}
+
+	count := record.Count
+
+	if count > len(ts.state.Pending) {
+		count = len(ts.state.Pending)
+	}
+
+	batch := stampBatch{ts.state.Pending[:count:count], record.Root, record.TXID}
+
+	ts.state.Batches = append(ts.state.Batches, batch)
+	ts.state.Pending = ts.state.Pending[count:]
+
+	for _, hash := range batch.Hashes {
+		ts.index[string(hash)] = len(ts.state.Batches) - 1
+	}
+}
+
+// Appends a record to the file. The file is not rewritten, so a request costs only its size
+func (ts *timestampService) save(record stampRecord) error {
+	var content bytes.Buffer
+
+	err := gob.NewEncoder(&content).Encode(record)
+
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(ts.S.DataDir+timestampsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = binary.Write(f, binary.BigEndian, uint32(content.Len()))
+
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(content.Bytes())
+
+	return err
+}
+
+// Adds hashes to next batch. Known hashes are skipped
+func (ts *timestampService) Add(hashes [][]byte) error {
+	if len(hashes) == 0 || len(hashes) > MaxStampHashesPerRequest {
+		return errors.New(fmt.Sprintf("Number of hashes must be from 1 to %d", MaxStampHashesPerRequest))
+	}
+
+	for _, hash := range hashes {
+		if len(hash) != nodeclient.StampHashSize {
+			return errors.New(fmt.Sprintf("Hash size must be %d bytes", nodeclient.StampHashSize))
+		}
+	}
+
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	record := stampRecord{}
+	added := map[string]bool{}
+
+	for _, hash := range hashes {
+		if _, ok := ts.index[string(hash)]; ok || added[string(hash)] {
+			continue
+		}
+		record.Hashes = append(record.Hashes, utils.CopyBytes(hash))
+		added[string(hash)] = true
+	}
+
+	if len(record.Hashes) == 0 {
+		return nil
+	}
+
+	if len(ts.state.Pending)+len(record.Hashes) > MaxStampPendingHashes {
+		return errors.New("Too many hashes wait for anchoring. Try again after next block")
+	}
+
+	err := ts.save(record)
+
+	if err != nil {
+		return err
+	}
+
+	ts.apply(record)
+
+	ts.Logger.Trace.Printf("Timestamps: %d hashes added", len(record.Hashes))
+
+	ts.tryToAnchor()
+
+	return nil
+}
+
+// Anchors pending hashes if there is no batch waiting for a block
+func (ts *timestampService) anchor() error {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	node := ts.S.CloneNode()
+
+	err := node.DBConn.OpenConnection("Timestamps", node.SessionID)
+
+	if err != nil {
+		return err
+	}
+	defer node.DBConn.CloseConnection()
+
+	txm := node.GetTransactionsManager()
+
+	waiting := false
+
+	// a transaction of any batch can be lost, not only of the last one. a block with it
+	// can be removed from the main chain in a reorg
+	for _, batch := range append([]stampBatch{}, ts.state.Batches...) {
+		tx, _, err := txm.FindDataTransaction(batch.Root)
+
+		if err != nil {
+			return err
+		}
+
+		if tx != nil {
+			continue
+		}
+
+		tx, err = txm.GetIfUnapprovedExists(batch.TXID)
+
+		if err != nil {
+			return err
+		}
+
+		if tx != nil {
+			waiting = true
+			continue
+		}
+
+		// the transaction was dropped. hashes go to next batch
+		ts.Logger.Trace.Printf("Timestamps: transaction %x is lost. Anchor again", batch.TXID)
+
+		record := stampRecord{Dropped: true, TXID: batch.TXID}
+
+		err = ts.save(record)
+
+		if err != nil {
+			return err
+		}
+
+		ts.apply(record)
+	}
+
+	if waiting {
+		// wait for a block with previous batch
+		return nil
+	}
+
+	if len(ts.state.Pending) == 0 {
+		return nil
+	}
+
+	wallets := wallet.Wallets{}
+	wallets.Wallets = map[string]*wallet.Wallet{}
+	wallets.DataDir = ts.S.DataDir
+
+	err = wallets.LoadFromFile()
+
+	if err != nil {
+		return err
+	}
+
+	walletobj, err := wallets.GetWallet(ts.Config.Address)
+
+	if err != nil {
+		return err
+	}
+
+	count := len(ts.state.Pending)
+
+	if count > MaxStampHashesPerBatch {
+		// others go to next batches
+		count = MaxStampHashesPerBatch
+	}
+
+	root := utils.NewMerkleTree(ts.state.Pending[:count]).RootNode.Data
+
+	tx, err := txm.CreateDataTransaction(walletobj.GetPublicKey(), walletobj.GetPrivateKey(), root, ts.Config.Fee)
+
+	if err != nil {
+		return err
+	}
+
+	record := stampRecord{Count: count, Root: root, TXID: tx.ID}
+
+	err = ts.save(record)
+
+	if err != nil {
+		return err
+	}
+
+	ts.apply(record)
+
+	ts.Logger.Trace.Printf("Timestamps: %d hashes anchored with %x, root %x", count, tx.ID, root)
+
+	// try to make a block. the transaction is sent to other nodes if a block is not made
+	ts.S.TryToMakeNewBlock(tx.ID)
+
+	return nil
+}
+
+// Builds a receipt of a hash. Root of the receipt is empty if the hash is not in a block yet
+func (ts *timestampService) GetReceipt(node *nodemanager.Node, hash []byte) (nodeclient.ComStampReceipt, error) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	result := nodeclient.ComStampReceipt{}
+	result.Hash = hash
+
+	pos, ok := ts.index[string(hash)]
+
+	if !ok {
+		return result, errors.New("Hash was not timestamped on this node")
+	}
+
+	if pos < 0 {
+		return result, nil
+	}
+
+	batch := ts.state.Batches[pos]
+
+	tx, block, err := node.GetTransactionsManager().FindDataTransaction(batch.Root)
+
+	if err != nil || tx == nil {
+		return result, err
+	}
+
+	for i, h := range batch.Hashes {
+		if bytes.Equal(h, hash) {
+			result.Proof, err = utils.GetMerkleProof(batch.Hashes, i)
+			break
+		}
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	result.TXProof, err = node.GetMerkleProof(tx.ID)
+
+	if err != nil {
+		return result, err
+	}
+
+	result.Root = batch.Root
+	result.Height = block.Height
+	result.Timestamp = block.Timestamp
+
+	return result, nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/taincoin/taincoin/lib/utils"
+	"github.com/taincoin/taincoin/node/config"
+)
+
+func TestTimestampDropBatch(t *testing.T) {
+	ts := newTimestampService(nil, config.TimestampConfig{}, nil)
+
+	hashes := [][]byte{}
+
+	for i := 0; i < 6; i++ {
+		hash := sha256.Sum256([]byte{byte(i)})
+		hashes = append(hashes, hash[:])
+	}
+
+	ts.apply(stampRecord{Hashes: hashes})
+
+	// three batches of 2 hashes
+	for i := 0; i < 3; i++ {
+		root := utils.NewMerkleTree(ts.state.Pending[:2]).RootNode.Data
+		ts.apply(stampRecord{Count: 2, Root: root, TXID: []byte{byte(i)}})
+	}
+
+	if len(ts.state.Pending) != 0 || len(ts.state.Batches) != 3 {
+		t.Fatalf("Got %d pending and %d batches", len(ts.state.Pending), len(ts.state.Batches))
+	}
+
+	// a batch in the middle is dropped in a reorg
+	ts.apply(stampRecord{Dropped: true, TXID: []byte{1}})
+
+	if len(ts.state.Batches) != 2 || ts.state.Batches[1].TXID[0] != 2 {
+		t.Fatalf("Batch is not dropped. Got %d batches", len(ts.state.Batches))
+	}
+
+	tests := []struct {
+		hash int
+		pos  int
+	}{
+		{0, 0},
+		{1, 0},
+		{2, -1},
+		{3, -1},
+		{4, 1},
+		{5, 1},
+	}
+
+	for _, test := range tests {
+		pos, ok := ts.index[string(hashes[test.hash])]
+
+		if !ok || pos != test.pos {
+			t.Fatalf("Hash %d: got position %d, expected %d", test.hash, pos, test.pos)
+		}
+	}
+
+	if len(ts.state.Pending) != 2 {
+		t.Fatalf("Got %d pending hashes, expected 2", len(ts.state.Pending))
+	}
+
+	// old record without TXID drops the last batch
+	ts.apply(stampRecord{Dropped: true})
+
+	if len(ts.state.Batches) != 1 || len(ts.state.Pending) != 4 {
+		t.Fatalf("Got %d batches and %d pending", len(ts.state.Batches), len(ts.state.Pending))
+	}
+
+	// unknown transaction
+	ts.apply(stampRecord{Dropped: true, TXID: []byte{9}})
+
+	if len(ts.state.Batches) != 1 {
+		t.Fatalf("Got %d batches, expected 1", len(ts.state.Batches))
+	}
+}
@@ -3,14 +3,14 @@ package server
 import (
 	"testing"
 
-	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/net"
 )
 
 func TestAddBlockSimple(t *testing.T) {
 	tr := nodeTransit{}
 	tr.Init(nil)
 
-	addr := lib.NodeAddr{"localhost", 20000}
+	addr := net.NodeAddr{Host: "localhost", Port: 20000}
 
 	blocks := [][]byte{{1, 2, 4}, {4, 5, 6}}
 
@@ -20,7 +20,7 @@ func TestAddBlockSimple(t *testing.T) {
 		t.Fatalf("Expected 2 blocks")
 	}
 
-	if tr.GetBlocksCount(lib.NodeAddr{}) != 0 {
+	if tr.GetBlocksCount(net.NodeAddr{}) != 0 {
 		t.Fatalf("Expected 0 blocks")
 	}
 }
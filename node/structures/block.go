@@ -3,6 +3,7 @@ package structures
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
 	"time"
 
 	"github.com/taincoin/taincoin/lib/utils"
@@ -14,8 +15,14 @@ type Block struct {
 	Transactions  []*Transaction
 	PrevBlockHash []byte
 	Hash          []byte
+	MerkleRoot    []byte // root of Merkle tree of transactions. Allows to prove a transaction is in a block
 	Nonce         int
 	Height        int
+	Bits          int                     // difficulty. number of leading zero bits the hash must have
+	Validator     []byte                  // public key of a validator who signed the block. Used by proof of authority
+	Signature     []byte                  // signature of a block hash by a validator
+	Governance    []GovernanceTransaction // votes of a validator to change validators list
+	Validators    []string                // validators list after this block. Used by proof of authority
 }
 
 // short info about a block. to exchange over network
@@ -31,8 +38,10 @@ type BlockSimpler struct {
 	Transactions  []string
 	PrevBlockHash []byte
 	Hash          []byte
+	MerkleRoot    []byte
 	Nonce         int
 	Height        int
+	Bits          int
 }
 
 // Reverce list of blocks
@@ -104,6 +113,8 @@ func (b *Block) GetSimpler() *BlockSimpler {
 	Block := BlockSimpler{}
 	Block.Hash = b.Hash[:]
 	Block.Height = b.Height
+	Block.Bits = b.Bits
+	Block.MerkleRoot = b.MerkleRoot[:]
 	Block.PrevBlockHash = b.PrevBlockHash[:]
 
 	Block.Transactions = []string{}
@@ -111,6 +122,9 @@ func (b *Block) GetSimpler() *BlockSimpler {
 	for _, tx := range b.Transactions {
 		Block.Transactions = append(Block.Transactions, tx.String())
 	}
+	for _, g := range b.Governance {
+		Block.Transactions = append(Block.Transactions, g.String())
+	}
 	return &Block
 }
 
@@ -132,8 +146,14 @@ func (b *Block) Copy() *Block {
 		copy(bc.Hash, b.Hash)
 	}
 
+	bc.MerkleRoot = utils.CopyBytes(b.MerkleRoot)
 	bc.Nonce = b.Nonce
 	bc.Height = b.Height
+	bc.Bits = b.Bits
+	bc.Validator = utils.CopyBytes(b.Validator)
+	bc.Signature = utils.CopyBytes(b.Signature)
+	bc.Governance = append([]GovernanceTransaction{}, b.Governance...)
+	bc.Validators = append([]string{}, b.Validators...)
 
 	for _, t := range b.Transactions {
 		tc, _ := t.Copy()
@@ -156,12 +176,24 @@ func (b *Block) PrepareNewBlock(transactions []*Transaction, prevBlockHash []byt
 	b.Hash = []byte{}
 	b.Nonce = 0
 	b.Height = height
+	b.Bits = 0
+	b.Validator = []byte{}
+	b.Signature = []byte{}
+	b.Governance = []GovernanceTransaction{}
+	b.Validators = []string{}
+
+	root, err := b.HashTransactions()
+
+	if err != nil {
+		return err
+	}
+	b.MerkleRoot = root
 
 	return nil
 }
 
-// HashTransactions returns a hash of the transactions in the block
-func (b *Block) HashTransactions() ([]byte, error) {
+// Returns transactions as list of bytes slices. This is data for a Merkle tree
+func (b *Block) getTransactionsData() ([][]byte, error) {
 	var transactions [][]byte
 
 	for _, tx := range b.Transactions {
@@ -172,11 +204,39 @@ func (b *Block) HashTransactions() ([]byte, error) {
 		}
 		transactions = append(transactions, txser)
 	}
+	return transactions, nil
+}
+
+// HashTransactions returns a hash of the transactions in the block
+// It is a root of Merkle tree of transactions
+func (b *Block) HashTransactions() ([]byte, error) {
+	transactions, err := b.getTransactionsData()
+
+	if err != nil {
+		return nil, err
+	}
 	mTree := utils.NewMerkleTree(transactions)
 
 	return mTree.RootNode.Data, nil
 }
 
+// Returns a proof that a transaction is in the block. The proof can be checked with
+// utils.VerifyMerkleProof having transaction bytes (tx.ToBytes()) and MerkleRoot only
+func (b *Block) GetMerkleProof(txID []byte) ([]utils.MerkleProofStep, error) {
+	for i, tx := range b.Transactions {
+		if bytes.Equal(tx.ID, txID) {
+			transactions, err := b.getTransactionsData()
+
+			if err != nil {
+				return nil, err
+			}
+
+			return utils.GetMerkleProof(transactions, i)
+		}
+	}
+	return nil, errors.New("Transaction is not found in the block")
+}
+
 // Serialize serializes the block
 func (b *Block) Serialize() ([]byte, error) {
 	var result bytes.Buffer
@@ -0,0 +1,61 @@
+package structures
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Actions of governance transactions
+const GovernanceAction_AddValidator = 1
+const GovernanceAction_RemoveValidator = 2
+
+// Governance transaction. It is a vote of a block validator to add or remove
+// a validator in proof of authority consensus. It is recorded in a block signed by a voter
+type GovernanceTransaction struct {
+	Action  int
+	Address string
+}
+
+// Check if it is same vote
+func (g GovernanceTransaction) IsSame(other GovernanceTransaction) bool {
+	return g.Action == other.Action && g.Address == other.Address
+}
+
+// Converts to slice of bytes. It is part of a block hash
+func (g GovernanceTransaction) ToBytes() []byte {
+	return []byte(fmt.Sprintf("%d:%s", g.Action, g.Address))
+}
+
+// String returns a human-readable representation of a governance transaction
+func (g GovernanceTransaction) String() string {
+	action := "unknown"
+
+	if g.Action == GovernanceAction_AddValidator {
+		action = "add"
+	} else if g.Action == GovernanceAction_RemoveValidator {
+		action = "remove"
+	}
+	return fmt.Sprintf("--- Governance: %s validator %s", action, g.Address)
+}
+
+// Returns new validators list after a governance transaction is applied
+func (g GovernanceTransaction) Apply(validators []string) []string {
+	list := []string{}
+
+	for _, v := range validators {
+		if v != g.Address {
+			list = append(list, v)
+		}
+	}
+
+	if g.Action == GovernanceAction_AddValidator {
+		list = append(list, g.Address)
+	}
+
+	return list
+}
+
+// Convert list of validators to string. It is used for hashing and comparing of lists
+func ValidatorsListToString(validators []string) string {
+	return strings.Join(validators, ",")
+}
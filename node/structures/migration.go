@@ -0,0 +1,98 @@
+package structures
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/taincoin/taincoin/lib"
+)
+
+// Structures of old format of data. Before amounts were stored as float numbers.
+// It is used only to find data of old format in a DB
+
+type txOutputFloat struct {
+	Value      float64
+	PubKeyHash []byte
+}
+
+type transactionFloat struct {
+	ID   []byte
+	Vin  []TXInput
+	Vout []txOutputFloat
+	Time int64
+}
+
+type blockFloat struct {
+	Timestamp     int64
+	Transactions  []*transactionFloat
+	PrevBlockHash []byte
+	Hash          []byte
+	Nonce         int
+	Height        int
+	Bits          int
+	Validator     []byte
+	Signature     []byte
+	Governance    []GovernanceTransaction
+	Validators    []string
+}
+
+// Converts a transaction of old format. ID is not changed
+func (t transactionFloat) toTransaction() *Transaction {
+	tx := &Transaction{}
+	tx.ID = t.ID
+	tx.Vin = t.Vin
+	tx.Time = t.Time
+	tx.Vout = []TXOutput{}
+
+	for _, out := range t.Vout {
+		tx.Vout = append(tx.Vout, TXOutput{Value: lib.AmountFromFloat(out.Value), PubKeyHash: out.PubKeyHash})
+	}
+	return tx
+}
+
+// Deserializes a transaction of old format (float amounts) and converts it to new format
+// ID of a transaction is kept same. It is not calculated again
+func DeserializeTransactionFloat(data []byte) (*Transaction, error) {
+	t := transactionFloat{}
+
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err := decoder.Decode(&t)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t.toTransaction(), nil
+}
+
+// Deserializes a block of old format (float amounts) and converts it to new format
+// Hash of a block and IDs of transactions are kept same
+func DeserializeBlockFloat(data []byte) (*Block, error) {
+	b := blockFloat{}
+
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err := decoder.Decode(&b)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block := &Block{}
+	block.Timestamp = b.Timestamp
+	block.PrevBlockHash = b.PrevBlockHash
+	block.Hash = b.Hash
+	block.Nonce = b.Nonce
+	block.Height = b.Height
+	block.Bits = b.Bits
+	block.Validator = b.Validator
+	block.Signature = b.Signature
+	block.Governance = b.Governance
+	block.Validators = b.Validators
+	block.Transactions = []*Transaction{}
+
+	for _, t := range b.Transactions {
+		block.Transactions = append(block.Transactions, t.toTransaction())
+	}
+
+	return block, nil
+}
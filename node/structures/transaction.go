@@ -7,7 +7,6 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"errors"
-	"math"
 	"strings"
 	"time"
 
@@ -15,6 +14,7 @@ import (
 	"fmt"
 
 	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/script"
 	"github.com/taincoin/taincoin/lib/utils"
 )
 
@@ -24,6 +24,10 @@ type Transaction struct {
 	Vin  []TXInput
 	Vout []TXOutput
 	Time int64
+
+	// block height or unix time before which the transaction can not be added to a block.
+	// See IsFinal
+	LockTime int64
 }
 
 // IsCoinbase checks whether the transaction is coinbase
@@ -40,10 +44,7 @@ func (tx *Transaction) TimeNow() {
 func (tx *Transaction) Hash() ([]byte, error) {
 	var hash [32]byte
 
-	txCopy := *tx
-	txCopy.ID = []byte{}
-
-	txser, err := txCopy.Serialize()
+	txser, err := tx.SerializeForHash()
 
 	if err != nil {
 		return nil, err
@@ -61,9 +62,12 @@ func (tx Transaction) String() string {
 	from, _ := utils.PubKeyToAddres(tx.Vin[0].PubKey)
 	fromhash, _ := utils.HashPubKey(tx.Vin[0].PubKey)
 	to := ""
-	amount := 0.0
+	amount := lib.Amount(0)
 
 	for _, output := range tx.Vout {
+		if output.IsData() {
+			continue
+		}
 		if bytes.Compare(fromhash, output.PubKeyHash) != 0 {
 			to, _ = utils.PubKeyHashToAddres(output.PubKeyHash)
 			amount = output.Value
@@ -72,8 +76,15 @@ func (tx Transaction) String() string {
 	}
 
 	lines = append(lines, fmt.Sprintf("--- Transaction %x:", tx.ID))
-	lines = append(lines, fmt.Sprintf("    FROM %s TO %s VALUE %f", from, to, amount))
-	lines = append(lines, fmt.Sprintf("    Time %d (%s)", tx.Time, time.Unix(0, tx.Time)))
+	lines = append(lines, fmt.Sprintf("    FROM %s TO %s VALUE %s", from, to, amount))
+	// in UTC. Data to sign are made from this text, so it must not depend on a time zone of a machine
+	lines = append(lines, fmt.Sprintf("    Time %d (%s)", tx.Time, time.Unix(0, tx.Time).UTC()))
+
+	// locks are shown only if set. Data to sign are made from this text,
+	// so signatures of transactions without locks stay same
+	if tx.LockTime != 0 {
+		lines = append(lines, fmt.Sprintf("    LockTime %d", tx.LockTime))
+	}
 
 	for i, input := range tx.Vin {
 		address, _ := utils.PubKeyToAddres(input.PubKey)
@@ -83,13 +94,22 @@ func (tx Transaction) String() string {
 		lines = append(lines, fmt.Sprintf("       Signature: %x", input.Signature))
 		lines = append(lines, fmt.Sprintf("       PubKey:    %x", input.PubKey))
 		lines = append(lines, fmt.Sprintf("       Address:   %s", address))
+
+		if input.RelativeLock != 0 {
+			lines = append(lines, fmt.Sprintf("       RelativeLock: %d", input.RelativeLock))
+		}
 	}
 
 	for i, output := range tx.Vout {
-		address, _ := utils.PubKeyHashToAddres(output.PubKeyHash)
 		lines = append(lines, fmt.Sprintf("     Output %d:", i))
-		lines = append(lines, fmt.Sprintf("       Value:  %f", output.Value))
+		lines = append(lines, fmt.Sprintf("       Value:  %s", output.Value))
 		lines = append(lines, fmt.Sprintf("       Script: %x", output.PubKeyHash))
+
+		if output.IsData() {
+			lines = append(lines, fmt.Sprintf("       Data:   %x", output.Data))
+			continue
+		}
+		address, _ := utils.PubKeyHashToAddres(output.PubKeyHash)
 		lines = append(lines, fmt.Sprintf("       Address: %s", address))
 	}
 
@@ -102,16 +122,16 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 	var outputs []TXOutput
 
 	for _, vin := range tx.Vin {
-		inputs = append(inputs, TXInput{vin.Txid, vin.Vout, nil, nil})
+		inputs = append(inputs, TXInput{vin.Txid, vin.Vout, nil, nil, vin.RelativeLock})
 	}
 
 	for _, vout := range tx.Vout {
 		pkh := utils.CopyBytes(vout.PubKeyHash)
 
-		outputs = append(outputs, TXOutput{vout.Value, pkh})
+		outputs = append(outputs, TXOutput{vout.Value, pkh, utils.CopyBytes(vout.Data)})
 	}
 	txID := utils.CopyBytes(tx.ID)
-	txCopy := Transaction{txID, inputs, outputs, tx.Time}
+	txCopy := Transaction{txID, inputs, outputs, tx.Time, tx.LockTime}
 
 	return txCopy
 }
@@ -129,18 +149,18 @@ func (tx *Transaction) Copy() (Transaction, error) {
 
 		pk := utils.CopyBytes(vin.PubKey)
 
-		inputs = append(inputs, TXInput{vin.Txid, vin.Vout, sig, pk})
+		inputs = append(inputs, TXInput{vin.Txid, vin.Vout, sig, pk, vin.RelativeLock})
 	}
 
 	for _, vout := range tx.Vout {
 		pkh := utils.CopyBytes(vout.PubKeyHash)
 
-		outputs = append(outputs, TXOutput{vout.Value, pkh})
+		outputs = append(outputs, TXOutput{vout.Value, pkh, utils.CopyBytes(vout.Data)})
 	}
 
 	txID := utils.CopyBytes(tx.ID)
 
-	txCopy := Transaction{txID, inputs, outputs, tx.Time}
+	txCopy := Transaction{txID, inputs, outputs, tx.Time, tx.LockTime}
 
 	return txCopy, nil
 }
@@ -252,11 +272,12 @@ func (tx *Transaction) SetSignatures(signatures [][]byte) error {
 }
 
 // Verify verifies signatures of Transaction inputs
-// And total amount of inputs and outputs
+// And total amount of inputs and outputs. Outputs can be less than inputs, the difference is a fee
 func (tx *Transaction) Verify(prevTXs map[int]*Transaction) error {
 	if tx.IsCoinbase() {
-		// coinbase has only 1 output and it must have value equal to constant
-		if tx.Vout[0].Value != lib.PaymentForBlockMade {
+		// coinbase has only 1 output. Its value depends on a height of a block and fees in a block
+		// Exact value is checked when a block is verified
+		if !tx.Vout[0].Value.IsValid() {
 			return errors.New("Value of coinbase transaction is wrong")
 		}
 		if len(tx.Vout) > 1 {
@@ -265,14 +286,20 @@ func (tx *Transaction) Verify(prevTXs map[int]*Transaction) error {
 		return nil
 	}
 	// calculate total input
-	totalinput := float64(0)
+	totalinput := lib.Amount(0)
 
 	for vind, vin := range tx.Vin {
 		if prevTXs[vind].ID == nil {
 			return errors.New("Previous transaction is not correct")
 		}
 		amount := prevTXs[vind].Vout[vin.Vout].Value
-		totalinput += amount
+
+		var err error
+		totalinput, err = lib.AddAmounts(totalinput, amount)
+
+		if err != nil {
+			return err
+		}
 	}
 
 	txCopy := tx.TrimmedCopy()
@@ -286,6 +313,10 @@ func (tx *Transaction) Verify(prevTXs map[int]*Transaction) error {
 		// full input transaction
 		prevTx := prevTXs[inID]
 
+		if prevTx.Vout[vin.Vout].IsData() {
+			return errors.New(fmt.Sprintf("Input %x uses a data output. It can not be spent", vin.Txid))
+		}
+
 		//hash of key who signed this input
 		signPubKeyHash, _ := utils.HashPubKey(vin.PubKey)
 
@@ -298,7 +329,7 @@ func (tx *Transaction) Verify(prevTXs map[int]*Transaction) error {
 
 		dataToVerify := fmt.Sprintf("%x\n", txCopy)
 
-		v, err := utils.VerifySignature(vin.Signature, []byte(dataToVerify), vin.PubKey)
+		v, err := verifyInputSignature(tx, inID, prevTx.Vout[vin.Vout], []byte(dataToVerify))
 
 		if err != nil {
 			return err
@@ -311,26 +342,178 @@ func (tx *Transaction) Verify(prevTXs map[int]*Transaction) error {
 	}
 
 	// calculate total output of transaction
-	totaloutput := float64(0)
+	totaloutput := lib.Amount(0)
+	dataOutputs := 0
 
 	for _, vout := range tx.Vout {
+		if vout.IsData() {
+			// data outputs can not have coins, they would be lost
+			if vout.Value != 0 || len(vout.PubKeyHash) > 0 {
+				return errors.New("Data output can not have a value or a key")
+			}
+			if len(vout.Data) > MaxOutputDataSize {
+				return errors.New(fmt.Sprintf("Data of an output can not be longer %d bytes", MaxOutputDataSize))
+			}
+			dataOutputs++
+
+			if dataOutputs > 1 {
+				return errors.New("Transaction can have only 1 data output")
+			}
+			continue
+		}
 		if vout.Value < lib.SmallestUnit {
-			return errors.New(fmt.Sprintf("Too small output value %f", vout.Value))
+			return errors.New(fmt.Sprintf("Too small output value %s", vout.Value))
 		}
-		totaloutput += vout.Value
+		if !vout.Value.IsValid() {
+			return errors.New(fmt.Sprintf("Output value %s is more than max supply", vout.Value))
+		}
+
+		var err error
+		// a sum of big outputs must not overflow and become less than inputs
+		totaloutput, err = lib.AddAmounts(totaloutput, vout.Value)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if totaloutput > totalinput {
+		return errors.New(fmt.Sprintf("Output value of a transaction is more than input: %s vs %s . Diff %s", totalinput, totaloutput, totalinput-totaloutput))
+	}
+
+	return nil
+}
+
+// Checks an input with the script engine. Locking script of an usual output is the standard
+// P2PKH template, a multisig input has serialized keys instead of a public key and they are
+// the standard multisig script, a script input has the script there and an unlocking script
+// in place of a signature
+func verifyInputSignature(tx *Transaction, inID int, prevOut TXOutput, data []byte) (bool, error) {
+	vin := tx.Vin[inID]
+
+	var unlock, lock []byte
+
+	switch {
+	case script.IsScriptKey(vin.PubKey):
+		s, err := script.ParseScriptKey(vin.PubKey)
+
+		if err != nil {
+			return false, err
+		}
+		unlock = vin.Signature
+		lock = s
+
+	case utils.IsMultiSig(vin.PubKey):
+		ms, err := utils.ParseMultiSig(vin.PubKey)
+
+		if err != nil {
+			return false, err
+		}
+
+		// signatures have key indexes, the script needs them only in order of keys
+		signatures, err := ms.SplitSignatures(vin.Signature)
+
+		if err != nil {
+			return false, err
+		}
+		unlock = script.MultiSigUnlock(signatures)
+		lock = script.MultiSig(ms.Required, ms.PubKeys)
+
+	default:
+		unlock = script.PayToPubKeyHashUnlock(vin.Signature, vin.PubKey)
+		lock = prevOut.LockScript()
 	}
 
-	if math.Abs(totalinput-totaloutput) >= lib.SmallestUnit {
-		return errors.New(fmt.Sprintf("Input and output values of a transaction are not same: %.10f vs %.10f . Diff %.10f", totalinput, totaloutput, totalinput-totaloutput))
+	err := script.Execute(unlock, lock, &inputChecker{data, tx, inID})
+
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Checks conditions of scripts for one input
+type inputChecker struct {
+	data []byte
+	tx   *Transaction
+	inID int
+}
+
+func (c *inputChecker) CheckSignature(signature, pubKey []byte) bool {
+	v, err := utils.VerifySignature(signature, c.data, pubKey)
+
+	return err == nil && v
+}
+
+// A script can only require a lock time. The transaction must have it, then it is checked
+// when the transaction is added to a block
+func (c *inputChecker) CheckLockTime(lockTime int64) error {
+	if !sameLockKind(lockTime, c.tx.LockTime) || lockTime > c.tx.LockTime {
+		return errors.New(fmt.Sprintf("Lock time of the transaction must be at least %d", lockTime))
 	}
+	return nil
+}
+
+// Same as CheckLockTime for a relative lock of the input
+func (c *inputChecker) CheckSequence(sequence int64) error {
+	relativeLock := c.tx.Vin[c.inID].RelativeLock
 
+	if (sequence&RelativeLockTimeFlag == 0) != (relativeLock&RelativeLockTimeFlag == 0) ||
+		sequence > relativeLock {
+		return errors.New(fmt.Sprintf("Relative lock of the input must be at least %d", sequence))
+	}
 	return nil
 }
 
+// Returns a fee of a transaction. It is difference between inputs and outputs
+// prevTXs must be same as for Verify
+func (tx *Transaction) GetFee(prevTXs map[int]*Transaction) (lib.Amount, error) {
+	if tx.IsCoinbase() {
+		return 0, nil
+	}
+
+	totalinput := lib.Amount(0)
+
+	for vind, vin := range tx.Vin {
+		prevTx, ok := prevTXs[vind]
+
+		if !ok || prevTx == nil || vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+			return 0, errors.New("Previous transaction is not correct")
+		}
+		var err error
+		totalinput, err = lib.AddAmounts(totalinput, prevTx.Vout[vin.Vout].Value)
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	totaloutput := lib.Amount(0)
+
+	for _, vout := range tx.Vout {
+		var err error
+		totaloutput, err = lib.AddAmounts(totaloutput, vout.Value)
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	fee := totalinput - totaloutput
+
+	if fee < 0 {
+		return 0, errors.New("Output value of a transaction is more than input")
+	}
+
+	return fee, nil
+}
+
 /*
 * Make a transaction to be coinbase.
+* reward is a payment for a block. It depends on a height of a block
+* fees is a sum of fees of all transactions in a block. Miner gets it together with a payment
  */
-func (tx *Transaction) MakeCoinbaseTX(to, data string) error {
+func (tx *Transaction) MakeCoinbaseTX(to, data string, reward lib.Amount, fees lib.Amount) error {
 	if data == "" {
 		randData := make([]byte, 20)
 		_, err := rand.Read(randData)
@@ -342,8 +525,8 @@ func (tx *Transaction) MakeCoinbaseTX(to, data string) error {
 		data = fmt.Sprintf("%x", randData)
 	}
 
-	txin := TXInput{[]byte{}, -1, nil, []byte(data)}
-	txout := NewTXOutput(lib.PaymentForBlockMade, to)
+	txin := TXInput{[]byte{}, -1, nil, []byte(data), 0}
+	txout := NewTXOutput(reward+fees, to)
 	tx.Vin = []TXInput{txin}
 	tx.Vout = []TXOutput{*txout}
 
@@ -369,6 +552,15 @@ func (tx Transaction) Serialize() ([]byte, error) {
 	return encoded.Bytes(), nil
 }
 
+// Returns serialized transaction without ID. ID is a hash of these bytes, so anybody
+// can check the bytes are of the transaction with the ID
+func (tx Transaction) SerializeForHash() ([]byte, error) {
+	txCopy := tx
+	txCopy.ID = []byte{}
+
+	return txCopy.Serialize()
+}
+
 // DeserializeTransaction deserializes a transaction
 func (tx *Transaction) DeserializeTransaction(data []byte) error {
 	decoder := gob.NewDecoder(bytes.NewReader(data))
@@ -382,11 +574,15 @@ func (tx *Transaction) DeserializeTransaction(data []byte) error {
 }
 
 // converts transaction to slice of bytes
-// this will be used to do a hash of transactions
+// this will be used to do a hash of transactions. It is a leaf of a Merkle tree, light clients
+// build same bytes from parts of a transaction (see nodeclient.ComMerkleProof).
+// Lists and byte fields have a length before, so the bytes can be split to parts only one way
 func (tx Transaction) ToBytes() ([]byte, error) {
 	buff := new(bytes.Buffer)
 
-	err := binary.Write(buff, binary.BigEndian, tx.ID)
+	utils.WriteWithLength(buff, tx.ID)
+
+	err := binary.Write(buff, binary.BigEndian, uint32(len(tx.Vin)))
 
 	if err != nil {
 		return nil, err
@@ -398,10 +594,13 @@ func (tx Transaction) ToBytes() ([]byte, error) {
 			return nil, err
 		}
 
-		err = binary.Write(buff, binary.BigEndian, b)
-		if err != nil {
-			return nil, err
-		}
+		utils.WriteWithLength(buff, b)
+	}
+
+	err = binary.Write(buff, binary.BigEndian, uint32(len(tx.Vout)))
+
+	if err != nil {
+		return nil, err
 	}
 
 	for _, vout := range tx.Vout {
@@ -409,10 +608,8 @@ func (tx Transaction) ToBytes() ([]byte, error) {
 		if err != nil {
 			return nil, err
 		}
-		err = binary.Write(buff, binary.BigEndian, b)
-		if err != nil {
-			return nil, err
-		}
+
+		buff.Write(b)
 	}
 
 	err = binary.Write(buff, binary.BigEndian, tx.Time)
@@ -421,6 +618,12 @@ func (tx Transaction) ToBytes() ([]byte, error) {
 		return nil, err
 	}
 
+	err = binary.Write(buff, binary.BigEndian, tx.LockTime)
+
+	if err != nil {
+		return nil, err
+	}
+
 	return buff.Bytes(), nil
 }
 
@@ -1,10 +1,14 @@
 package structures
 
+import (
+	"github.com/taincoin/taincoin/lib"
+)
+
 // Sructures to display extra info related to tranactions
 
 type TransactionsHistory struct {
 	IOType  bool
 	TXID    []byte
 	Address string
-	Value   float64
+	Value   lib.Amount
 }
@@ -15,6 +15,10 @@ type TXInput struct {
 	Vout      int
 	Signature []byte
 	PubKey    []byte // this is the wallet who spends transaction
+
+	// number of blocks or seconds after confirmation of the spent output before
+	// the input can be added to a block. See RelativeLockTimeFlag
+	RelativeLock int64
 }
 
 // UsesKey checks whether the address initiated the transaction
@@ -57,5 +61,12 @@ func (input TXInput) ToBytes() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	if input.RelativeLock != 0 {
+		err = binary.Write(buff, binary.BigEndian, input.RelativeLock)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return buff.Bytes(), nil
 }
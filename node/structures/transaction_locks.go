@@ -0,0 +1,120 @@
+package structures
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// Lock time less than this is a block height, other values are unix time in seconds
+	LockTimeThreshold = 500000000
+	// If this bit of a relative lock is set, the lock is in seconds. Otherwise it is in blocks
+	RelativeLockTimeFlag = int64(1) << 32
+)
+
+// Block where an output spent by an input was added. Time is a median time past of the block
+// before it, same time a lock of the block would be compared with
+type OutputConfirmation struct {
+	Height int
+	Time   int64
+}
+
+// Makes a relative lock for a number of seconds
+func RelativeLockSeconds(seconds int64) int64 {
+	return seconds | RelativeLockTimeFlag
+}
+
+func sameLockKind(a, b int64) bool {
+	return (a < LockTimeThreshold) == (b < LockTimeThreshold)
+}
+
+// Returns true if the transaction can be added to a block with the height.
+// A lock time in seconds is compared with a median time past of the previous block.
+// A time of one block is chosen by a miner, so it is not used
+func (tx Transaction) IsFinal(height int, medianTime int64) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+
+	if tx.LockTime < LockTimeThreshold {
+		return tx.LockTime <= int64(height)
+	}
+	return tx.LockTime <= medianTime
+}
+
+// Returns true if the transaction has any lock
+func (tx Transaction) HasLocks() bool {
+	if tx.LockTime != 0 {
+		return true
+	}
+
+	for _, vin := range tx.Vin {
+		if vin.RelativeLock != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if the transaction can be added to a block with the height. Confirmations are blocks
+// of outputs spent by inputs with relative locks, outputs not yet in blocks are confirmed in
+// same block. Returns error if the transaction is not mature yet
+func (tx Transaction) CheckLocks(height int, medianTime int64, confirmations map[int]OutputConfirmation) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+
+	if !tx.IsFinal(height, medianTime) {
+		return errors.New(fmt.Sprintf("Transaction is locked until %d", tx.LockTime))
+	}
+
+	for inID, vin := range tx.Vin {
+		if vin.RelativeLock == 0 {
+			continue
+		}
+
+		if vin.RelativeLock < 0 {
+			return errors.New("Relative lock can not be negative")
+		}
+
+		c, ok := confirmations[inID]
+
+		if !ok {
+			c = OutputConfirmation{height, medianTime}
+		}
+
+		if vin.RelativeLock&RelativeLockTimeFlag != 0 {
+			seconds := vin.RelativeLock &^ RelativeLockTimeFlag
+
+			if medianTime-c.Time < seconds {
+				return errors.New(fmt.Sprintf("Input %d is locked for %d seconds after confirmation", inID, seconds))
+			}
+		} else if int64(height-c.Height) < vin.RelativeLock {
+			return errors.New(fmt.Sprintf("Input %d is locked for %d blocks after confirmation", inID, vin.RelativeLock))
+		}
+	}
+	return nil
+}
+
+// Returns true if the transaction can not be added to any block in given number of blocks or
+// seconds after a block with the height and median time. Relative locks are counted from that block
+func (tx Transaction) IsLockedLongerThan(height int, medianTime int64, blocks int, seconds int64) bool {
+	if tx.LockTime >= LockTimeThreshold && tx.LockTime > medianTime+seconds {
+		return true
+	}
+
+	if tx.LockTime > 0 && tx.LockTime < LockTimeThreshold && tx.LockTime > int64(height+blocks) {
+		return true
+	}
+
+	for _, vin := range tx.Vin {
+		if vin.RelativeLock&RelativeLockTimeFlag != 0 {
+			if vin.RelativeLock&^RelativeLockTimeFlag > seconds {
+				return true
+			}
+		} else if vin.RelativeLock > int64(blocks) {
+			return true
+		}
+	}
+	return false
+}
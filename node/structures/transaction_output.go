@@ -4,24 +4,33 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/script"
 	"github.com/taincoin/taincoin/lib/utils"
 )
 
+// Max size of data in a data output. It is enough for a hash or a short record
+const MaxOutputDataSize = 80
+
 // TXOutput represents a transaction output
 type TXOutput struct {
-	Value      float64
+	Value      lib.Amount
 	PubKeyHash []byte
+
+	// data of an unspendable output. Such output has no value and no key hash. See NewDataOutput
+	Data []byte
 }
 
 // Simplified output format. To use externally
 // It has all info in human readable format
 // this can be used to display info abut outputs wihout references to transaction object
 type TXOutputIndependent struct {
-	Value          float64
+	Value          lib.Amount
 	DestPubKeyHash []byte
 	SendPubKeyHash []byte
 	TXID           []byte
@@ -44,6 +53,20 @@ func (out *TXOutput) IsLockedWithKey(pubKeyHash []byte) bool {
 	return bytes.Compare(out.PubKeyHash, pubKeyHash) == 0
 }
 
+// Checks if the output only carries data
+func (out *TXOutput) IsData() bool {
+	return len(out.Data) > 0
+}
+
+// Returns the locking script of the output. Outputs keep only a hash, so it is the standard
+// P2PKH template. Outputs locked with other scripts have a hash of the script here
+func (out *TXOutput) LockScript() []byte {
+	if out.IsData() {
+		return script.DataOutput(out.Data)
+	}
+	return script.PayToPubKeyHash(out.PubKeyHash)
+}
+
 // Same as IsLockedWithKey but for simpler structure
 func (out *TXOutputIndependent) IsLockedWithKey(pubKeyHash []byte) bool {
 	return bytes.Compare(out.DestPubKeyHash, pubKeyHash) == 0
@@ -61,13 +84,27 @@ func (out *TXOutputIndependent) LoadFromSimple(sout TXOutput, txid []byte, ind i
 }
 
 // NewTXOutput create a new TXOutput
-func NewTXOutput(value float64, address string) *TXOutput {
-	txo := &TXOutput{value, nil}
+func NewTXOutput(value lib.Amount, address string) *TXOutput {
+	txo := &TXOutput{Value: value}
 	txo.Lock([]byte(address))
 
 	return txo
 }
 
+// Makes an output that only carries data, for example a hash of a document.
+// Nobody can spend it, so it is not added to unspent outputs
+func NewDataOutput(data []byte) (*TXOutput, error) {
+	if len(data) == 0 {
+		return nil, errors.New("Data of an output can not be empty")
+	}
+
+	if len(data) > MaxOutputDataSize {
+		return nil, errors.New(fmt.Sprintf("Data of an output can not be longer %d bytes", MaxOutputDataSize))
+	}
+
+	return &TXOutput{Value: 0, Data: utils.CopyBytes(data)}, nil
+}
+
 // TXOutputs collects TXOutput
 type TXOutputs struct {
 	Outputs []TXOutput
@@ -102,12 +139,17 @@ func DeserializeOutputs(data []byte) TXOutputs {
 func (output TXOutput) String() string {
 	lines := []string{}
 
-	lines = append(lines, fmt.Sprintf("       Value:  %f", output.Value))
+	lines = append(lines, fmt.Sprintf("       Value:  %s", output.Value))
 	lines = append(lines, fmt.Sprintf("       Script: %x", output.PubKeyHash))
 
+	if output.IsData() {
+		lines = append(lines, fmt.Sprintf("       Data:   %x", output.Data))
+	}
+
 	return strings.Join(lines, "\n")
 }
 
+// Key hash and data have a length before, so data can not be read as a key hash
 func (output TXOutput) ToBytes() ([]byte, error) {
 	buff := new(bytes.Buffer)
 
@@ -116,10 +158,9 @@ func (output TXOutput) ToBytes() ([]byte, error) {
 		return nil, err
 	}
 
-	err = binary.Write(buff, binary.BigEndian, output.PubKeyHash)
-	if err != nil {
-		return nil, err
-	}
+	utils.WriteWithLength(buff, output.PubKeyHash)
+	utils.WriteWithLength(buff, output.Data)
+
 	return buff.Bytes(), nil
 }
 
@@ -2,13 +2,18 @@ package structures
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/gob"
 	"encoding/hex"
+	"math"
 
 	"time"
 
 	"testing"
 
+	"github.com/taincoin/taincoin/lib"
+	"github.com/taincoin/taincoin/lib/script"
+	"github.com/taincoin/taincoin/lib/utils"
 	"github.com/taincoin/taincoin/lib/wallet"
 )
 
@@ -16,16 +21,16 @@ func TestHash(t *testing.T) {
 	PubKey := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9}
 
 	inputs := []TXInput{
-		TXInput{[]byte{1, 2, 3}, 0, []byte{}, PubKey},
-		TXInput{[]byte{4, 5, 6}, 1, []byte{}, PubKey},
+		TXInput{[]byte{1, 2, 3}, 0, []byte{}, PubKey, 0},
+		TXInput{[]byte{4, 5, 6}, 1, []byte{}, PubKey, 0},
 	}
 
 	outputs := []TXOutput{
-		TXOutput{1, []byte{4, 3, 2, 1}},
-		TXOutput{2, PubKey},
+		TXOutput{1, []byte{4, 3, 2, 1}, nil},
+		TXOutput{2, PubKey, nil},
 	}
 
-	newTX := Transaction{nil, inputs, outputs, 0}
+	newTX := Transaction{nil, inputs, outputs, 0, 0}
 
 	layout := "2006-01-02T15:04:05.000Z"
 	str := "2014-11-12T11:45:26.371Z"
@@ -197,3 +202,405 @@ func TestVerify(t *testing.T) {
 	}
 }
 */
+
+func TestGetFee(t *testing.T) {
+	prevTX := &Transaction{[]byte{1, 2, 3}, []TXInput{}, []TXOutput{TXOutput{5 * lib.AmountUnit, []byte{1}, nil}, TXOutput{3 * lib.AmountUnit, []byte{2}, nil}}, 0, 0}
+
+	tx := Transaction{[]byte{4, 5, 6}, []TXInput{TXInput{prevTX.ID, 0, nil, nil, 0}, TXInput{prevTX.ID, 1, nil, nil, 0}},
+		[]TXOutput{TXOutput{6 * lib.AmountUnit, []byte{3}, nil}, TXOutput{lib.AmountUnit + lib.AmountUnit/2, []byte{1}, nil}}, 0, 0}
+
+	prevTXs := map[int]*Transaction{0: prevTX, 1: prevTX}
+
+	fee, err := tx.GetFee(prevTXs)
+
+	if err != nil {
+		t.Fatalf("Fee Error: %s", err.Error())
+	}
+
+	if fee != lib.AmountUnit/2 {
+		t.Fatalf("Fee is wrong: %s", fee)
+	}
+
+	tx.Vout[0].Value = 7 * lib.AmountUnit
+
+	_, err = tx.GetFee(prevTXs)
+
+	if err == nil {
+		t.Fatalf("Expected error when outputs are more than inputs")
+	}
+}
+
+// Sums of huge outputs must not wrap and become less than inputs
+func TestVerifyHugeOutputs(t *testing.T) {
+	w := wallet.Wallet{}
+	w.MakeWallet()
+	keyHash, _ := utils.HashPubKey(w.PublicKey)
+
+	prevTX := &Transaction{[]byte{1, 2, 3}, []TXInput{}, []TXOutput{TXOutput{5 * lib.AmountUnit, keyHash, nil}}, 0, 0}
+	prevTXs := map[int]*Transaction{0: prevTX}
+
+	half := lib.Amount(math.MaxInt64/2 + 1)
+
+	outputs := [][]TXOutput{
+		// more than max supply
+		[]TXOutput{TXOutput{lib.MaxSupply + 1, []byte{3}, nil}},
+		[]TXOutput{TXOutput{math.MaxInt64, []byte{3}, nil}},
+		// sum wraps to negative
+		[]TXOutput{TXOutput{half, []byte{3}, nil}, TXOutput{half, []byte{4}, nil}},
+		[]TXOutput{TXOutput{math.MaxInt64, []byte{3}, nil}, TXOutput{math.MaxInt64, []byte{4}, nil}, TXOutput{2 * lib.AmountUnit, []byte{5}, nil}},
+		// each is fine, the sum is more than max supply
+		[]TXOutput{TXOutput{lib.MaxSupply, []byte{3}, nil}, TXOutput{lib.MaxSupply, []byte{4}, nil}},
+	}
+
+	for i, vout := range outputs {
+		tx := Transaction{nil, []TXInput{TXInput{prevTX.ID, 0, nil, w.PublicKey, 0}}, vout, 0, 0}
+
+		signData, err := tx.PrepareSignData(prevTXs)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = tx.SignData(w.PrivateKey, w.PublicKey, signData)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = tx.Verify(prevTXs); err == nil {
+			t.Fatalf("Expected verify error for outputs set %d", i)
+		}
+
+		if _, err = tx.GetFee(prevTXs); err == nil {
+			t.Fatalf("Expected fee error for outputs set %d", i)
+		}
+	}
+
+	// inputs can not be summed over max supply too
+	bigTX := &Transaction{[]byte{1, 2, 3}, []TXInput{}, []TXOutput{TXOutput{math.MaxInt64, keyHash, nil}}, 0, 0}
+	tx := Transaction{nil, []TXInput{TXInput{bigTX.ID, 0, nil, w.PublicKey, 0}, TXInput{bigTX.ID, 0, nil, w.PublicKey, 0}},
+		[]TXOutput{TXOutput{lib.AmountUnit, []byte{3}, nil}}, 0, 0}
+
+	if _, err := tx.GetFee(map[int]*Transaction{0: bigTX, 1: bigTX}); err == nil {
+		t.Fatalf("Expected fee error for huge inputs")
+	}
+}
+
+func TestMultiSigVerify(t *testing.T) {
+	wallets := []wallet.Wallet{}
+	pubKeys := [][]byte{}
+
+	for i := 0; i < 3; i++ {
+		w := wallet.Wallet{}
+		w.MakeWallet()
+		wallets = append(wallets, w)
+		pubKeys = append(pubKeys, w.PublicKey)
+	}
+
+	ms, _ := utils.NewMultiSig(2, pubKeys)
+	msHash, _ := utils.HashPubKey(ms.Serialize())
+
+	prevTX := &Transaction{[]byte{1, 2, 3}, []TXInput{}, []TXOutput{TXOutput{5 * lib.AmountUnit, msHash, nil}}, 0, 0}
+	prevTXs := map[int]*Transaction{0: prevTX}
+
+	tx := Transaction{nil, []TXInput{TXInput{prevTX.ID, 0, nil, ms.Serialize(), 0}},
+		[]TXOutput{TXOutput{4 * lib.AmountUnit, []byte{3}, nil}}, 0, 0}
+
+	signData, err := tx.PrepareSignData(prevTXs)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signatures := map[int][]byte{}
+
+	for _, w := range wallets[:2] {
+		s, _ := utils.SignDataSet(w.PublicKey, w.PrivateKey, signData)
+		signatures[ms.KeyIndex(w.PublicKey)] = s[0]
+	}
+
+	joined, err := ms.JoinSignatures(signatures)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx.SetSignatures([][]byte{joined})
+
+	if err = tx.Verify(prevTXs); err != nil {
+		t.Fatalf("Verify Error: %s", err.Error())
+	}
+
+	// same key twice
+	index := ms.KeyIndex(wallets[0].PublicKey)
+	one := append([]byte{byte(index), byte(len(signatures[index]))}, signatures[index]...)
+	tx.SetSignatures([][]byte{append(one, one...)})
+
+	if err = tx.Verify(prevTXs); err == nil {
+		t.Fatal("Expected error for signatures of same key")
+	}
+
+	// not enough signatures
+	tx.SetSignatures([][]byte{one})
+
+	if err = tx.Verify(prevTXs); err == nil {
+		t.Fatal("Expected error for 1 of 2 signatures")
+	}
+	// signature of other data. Inputs are checked with the script engine
+	bad := append([]byte{}, joined...)
+	bad[len(bad)-1]++
+	tx.SetSignatures([][]byte{bad})
+
+	if err = tx.Verify(prevTXs); err == nil {
+		t.Fatal("Expected error for wrong signature")
+	}
+
+	// more signatures than required
+	s, _ := utils.SignDataSet(wallets[2].PublicKey, wallets[2].PrivateKey, signData)
+	signatures[ms.KeyIndex(wallets[2].PublicKey)] = s[0]
+
+	all := []byte{}
+
+	for i := range pubKeys {
+		all = append(all, byte(i), byte(len(signatures[i])))
+		all = append(all, signatures[i]...)
+	}
+	tx.SetSignatures([][]byte{all})
+
+	if err = tx.Verify(prevTXs); err == nil {
+		t.Fatal("Expected error for 3 of 2 signatures")
+	}
+}
+
+func TestScriptVerify(t *testing.T) {
+	w := wallet.Wallet{}
+	w.MakeWallet()
+
+	secret := []byte("secret")
+	secretHash := sha256.Sum256(secret)
+
+	// coins can be spent by the key if the secret is known
+	lock := script.NewBuilder().AddOp(script.OP_SHA256).AddData(secretHash[:]).AddOp(script.OP_EQUALVERIFY).
+		AddData(w.PublicKey).AddOp(script.OP_CHECKSIG).Script()
+	lockHash, _ := utils.HashPubKey(script.ScriptKey(lock))
+	keyHash, _ := utils.HashPubKey(w.PublicKey)
+
+	prevTX := &Transaction{[]byte{1, 2, 3}, []TXInput{},
+		[]TXOutput{TXOutput{5 * lib.AmountUnit, lockHash, nil}, TXOutput{5 * lib.AmountUnit, keyHash, nil}}, 0, 0}
+	prevTXs := map[int]*Transaction{0: prevTX, 1: prevTX}
+
+	tx := Transaction{nil, []TXInput{TXInput{prevTX.ID, 0, nil, script.ScriptKey(lock), 0}, TXInput{prevTX.ID, 1, nil, w.PublicKey, 0}},
+		[]TXOutput{TXOutput{9 * lib.AmountUnit, []byte{3}, nil}}, 0, 0}
+
+	signData, err := tx.PrepareSignData(prevTXs)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signatures, _ := utils.SignDataSet(w.PublicKey, w.PrivateKey, signData)
+
+	unlock := script.NewBuilder().AddData(signatures[0]).AddData(secret).Script()
+	tx.SetSignatures([][]byte{unlock, signatures[1]})
+
+	if err = tx.Verify(prevTXs); err != nil {
+		t.Fatalf("Verify Error: %s", err.Error())
+	}
+
+	unlock = script.NewBuilder().AddData(signatures[0]).AddData([]byte("wrong")).Script()
+	tx.SetSignatures([][]byte{unlock, signatures[1]})
+
+	if err = tx.Verify(prevTXs); err == nil {
+		t.Fatal("Expected error for wrong secret")
+	}
+
+	// signature of other input
+	unlock = script.NewBuilder().AddData(signatures[0]).AddData(secret).Script()
+	tx.SetSignatures([][]byte{unlock, signatures[0]})
+
+	if err = tx.Verify(prevTXs); err == nil {
+		t.Fatal("Expected error for wrong signature")
+	}
+}
+
+func TestTransactionLocks(t *testing.T) {
+	tx := Transaction{nil, []TXInput{TXInput{[]byte{1}, 0, nil, nil, 0}, TXInput{[]byte{2}, 0, nil, nil, 0}}, nil, 0, 0}
+
+	if err := tx.CheckLocks(1, 1000, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tx.LockTime = 10
+
+	if tx.IsFinal(9, 1000) || !tx.IsFinal(10, 1000) {
+		t.Fatal("Lock by height is wrong")
+	}
+
+	tx.LockTime = LockTimeThreshold + 100
+
+	if tx.IsFinal(1000, LockTimeThreshold+99) || !tx.IsFinal(1, LockTimeThreshold+100) {
+		t.Fatal("Lock by time is wrong")
+	}
+
+	tx.LockTime = 0
+	tx.Vin[0].RelativeLock = 5
+	tx.Vin[1].RelativeLock = RelativeLockSeconds(60)
+
+	confirmations := map[int]OutputConfirmation{0: OutputConfirmation{10, 1000}, 1: OutputConfirmation{12, 1030}}
+
+	if err := tx.CheckLocks(14, 1100, confirmations); err == nil {
+		t.Fatal("Expected error for 4 blocks of 5")
+	}
+
+	if err := tx.CheckLocks(15, 1089, confirmations); err == nil {
+		t.Fatal("Expected error for 59 seconds of 60")
+	}
+
+	if err := tx.CheckLocks(15, 1090, confirmations); err != nil {
+		t.Fatal(err)
+	}
+
+	// output is not confirmed yet
+	delete(confirmations, 0)
+
+	if err := tx.CheckLocks(100, 5000, confirmations); err == nil {
+		t.Fatal("Expected error for not confirmed output")
+	}
+}
+
+func TestTransactionLockedLongerThan(t *testing.T) {
+	tx := Transaction{nil, []TXInput{TXInput{[]byte{1}, 0, nil, nil, 0}}, nil, 0, 0}
+
+	tests := []struct {
+		lockTime     int64
+		relativeLock int64
+		result       bool
+	}{
+		{0, 0, false},
+		{110, 0, false},
+		{111, 0, true},
+		{LockTimeThreshold + 1060, 0, false},
+		{LockTimeThreshold + 1061, 0, true},
+		{0, 10, false},
+		{0, 11, true},
+		{0, RelativeLockSeconds(60), false},
+		{0, RelativeLockSeconds(61), true},
+	}
+
+	for _, test := range tests {
+		tx.LockTime = test.lockTime
+		tx.Vin[0].RelativeLock = test.relativeLock
+
+		if tx.IsLockedLongerThan(100, LockTimeThreshold+1000, 10, 60) != test.result {
+			t.Fatalf("Lock %d, relative lock %d: expected %v", test.lockTime, test.relativeLock, test.result)
+		}
+	}
+}
+
+func TestScriptLockTime(t *testing.T) {
+	w := wallet.Wallet{}
+	w.MakeWallet()
+
+	// coins can be spent by the key after block 100
+	lock := script.NewBuilder().AddInt(100).AddOp(script.OP_CHECKLOCKTIMEVERIFY).AddOp(script.OP_DROP).
+		AddData(w.PublicKey).AddOp(script.OP_CHECKSIG).Script()
+	lockHash, _ := utils.HashPubKey(script.ScriptKey(lock))
+
+	prevTX := &Transaction{[]byte{1, 2, 3}, []TXInput{}, []TXOutput{TXOutput{5 * lib.AmountUnit, lockHash, nil}}, 0, 0}
+	prevTXs := map[int]*Transaction{0: prevTX}
+
+	for _, lockTime := range []int64{99, 100} {
+		tx := Transaction{nil, []TXInput{TXInput{prevTX.ID, 0, nil, script.ScriptKey(lock), 0}},
+			[]TXOutput{TXOutput{4 * lib.AmountUnit, []byte{3}, nil}}, 0, lockTime}
+
+		signData, _ := tx.PrepareSignData(prevTXs)
+		signatures, _ := utils.SignDataSet(w.PublicKey, w.PrivateKey, signData)
+
+		tx.SetSignatures([][]byte{script.NewBuilder().AddData(signatures[0]).Script()})
+
+		err := tx.Verify(prevTXs)
+
+		if lockTime < 100 && err == nil {
+			t.Fatal("Expected error for lock time less than in the script")
+		}
+
+		if lockTime == 100 && err != nil {
+			t.Fatalf("Verify Error: %s", err.Error())
+		}
+	}
+}
+
+func TestDataOutput(t *testing.T) {
+	_, err := NewDataOutput([]byte{})
+
+	if err == nil {
+		t.Fatalf("Expected error for empty data")
+	}
+
+	_, err = NewDataOutput(make([]byte, MaxOutputDataSize+1))
+
+	if err == nil {
+		t.Fatalf("Expected error for too long data")
+	}
+
+	w := wallet.Wallet{}
+	w.MakeWallet()
+	keyHash, _ := utils.HashPubKey(w.PublicKey)
+
+	dataOut, err := NewDataOutput([]byte("sensor readings hash"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevTX := &Transaction{[]byte{1, 2, 3}, []TXInput{}, []TXOutput{TXOutput{5 * lib.AmountUnit, keyHash, nil}, *dataOut}, 0, 0}
+	prevTXs := map[int]*Transaction{0: prevTX}
+
+	sign := func(tx *Transaction) {
+		signData, err := tx.PrepareSignData(prevTXs)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = tx.SignData(w.PrivateKey, w.PublicKey, signData)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tx := Transaction{nil, []TXInput{TXInput{prevTX.ID, 0, nil, w.PublicKey, 0}},
+		[]TXOutput{*dataOut, TXOutput{4 * lib.AmountUnit, keyHash, nil}}, 0, 0}
+	sign(&tx)
+
+	err = tx.Verify(prevTXs)
+
+	if err != nil {
+		t.Fatalf("Verify Error: %s", err.Error())
+	}
+
+	// coins in a data output would be lost
+	tx.Vout[0].Value = lib.AmountUnit
+	sign(&tx)
+
+	if tx.Verify(prevTXs) == nil {
+		t.Fatalf("Expected error for data output with a value")
+	}
+
+	tx.Vout = []TXOutput{*dataOut, *dataOut, TXOutput{4 * lib.AmountUnit, keyHash, nil}}
+	sign(&tx)
+
+	if tx.Verify(prevTXs) == nil {
+		t.Fatalf("Expected error for 2 data outputs")
+	}
+
+	// nobody can spend a data output
+	tx.Vin[0].Vout = 1
+	tx.Vout = []TXOutput{TXOutput{lib.SmallestUnit, keyHash, nil}}
+	sign(&tx)
+
+	if tx.Verify(prevTXs) == nil {
+		t.Fatalf("Expected error for spending data output")
+	}
+}
@@ -7,6 +7,7 @@ import (
 )
 
 const TXVerifyErrorNoInput = "noinput"
+const TXVerifyErrorImmature = "immature"
 const TXNotFoundErrorUnspent = "inunspent"
 
 type TXVerifyError struct {
@@ -82,6 +82,12 @@ func (ti *transactionsIndex) BlockAdded(block *structures.Block) error {
 			return err
 		}
 
+		err = ti.dataAdded(txdb, tx)
+
+		if err != nil {
+			return err
+		}
+
 		if tx.IsCoinbase() {
 			continue
 		}
@@ -181,6 +187,13 @@ func (ti *transactionsIndex) BlockRemoved(block *structures.Block) error {
 			}
 		} else {
 			txdb.DeleteTXToBlockLink(tx.ID)
+
+			// the transaction is not in any block now
+			err = ti.dataRemoved(txdb, tx)
+
+			if err != nil {
+				return err
+			}
 		}
 
 		if tx.IsCoinbase() {
@@ -230,6 +243,118 @@ func (ti *transactionsIndex) BlockRemoved(block *structures.Block) error {
 	return nil
 }
 
+// Adds a transaction to the list of transactions with same data output
+func (ti *transactionsIndex) dataAdded(txdb database.TranactionsInterface, tx *structures.Transaction) error {
+	for _, out := range tx.Vout {
+		if !out.IsData() {
+			continue
+		}
+
+		txIDs, err := ti.getDataTransactions(txdb, out.Data)
+
+		if err != nil {
+			return err
+		}
+
+		found := false
+
+		// a transaction can be in blocks of few branches
+		for _, txID := range txIDs {
+			if bytes.Compare(txID, tx.ID) == 0 {
+				found = true
+				break
+			}
+		}
+
+		if found {
+			continue
+		}
+
+		data, err := ti.SerializeHashes(append(txIDs, tx.ID))
+
+		if err != nil {
+			return err
+		}
+
+		err = txdb.PutDataToTXLink(out.Data, data)
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Removes a transaction from the list of transactions with same data output
+func (ti *transactionsIndex) dataRemoved(txdb database.TranactionsInterface, tx *structures.Transaction) error {
+	for _, out := range tx.Vout {
+		if !out.IsData() {
+			continue
+		}
+
+		txIDs, err := ti.getDataTransactions(txdb, out.Data)
+
+		if err != nil {
+			return err
+		}
+
+		newTXIDs := [][]byte{}
+
+		for _, txID := range txIDs {
+			if bytes.Compare(txID, tx.ID) != 0 {
+				newTXIDs = append(newTXIDs, txID)
+			}
+		}
+
+		if len(newTXIDs) == 0 {
+			err = txdb.DeleteDataToTXLink(out.Data)
+
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		data, err := ti.SerializeHashes(newTXIDs)
+
+		if err != nil {
+			return err
+		}
+
+		err = txdb.PutDataToTXLink(out.Data, data)
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ti *transactionsIndex) getDataTransactions(txdb database.TranactionsInterface, data []byte) ([][]byte, error) {
+	txIDsBytes, err := txdb.GetTXForData(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if txIDsBytes == nil {
+		return [][]byte{}, nil
+	}
+
+	return ti.DeserializeHashes(txIDsBytes)
+}
+
+// Returns IDs of transactions with a data output. Transactions can be in any branch
+func (ti *transactionsIndex) GetDataTransactions(data []byte) ([][]byte, error) {
+	txdb, err := ti.DB.GetTransactionsObject()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ti.getDataTransactions(txdb, data)
+}
+
 // Reindex cach of trsnactions pointers to block
 func (ti *transactionsIndex) Reindex() error {
 	ti.Logger.Trace.Println("TXCache.Reindex: Prepare to recreate bucket")
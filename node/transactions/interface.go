@@ -3,12 +3,13 @@ package transactions
 import (
 	"crypto/ecdsa"
 
+	"github.com/taincoin/taincoin/lib"
 	"github.com/taincoin/taincoin/lib/wallet"
 	"github.com/taincoin/taincoin/node/structures"
 )
 
 type UnApprovedTransactionCallbackInterface func(txhash, txstr string) error
-type UnspentTransactionOutputCallbackInterface func(fromaddr string, value float64, txID []byte, output int, isbase bool) error
+type UnspentTransactionOutputCallbackInterface func(fromaddr string, value lib.Amount, txID []byte, output int, isbase bool) error
 
 type TransactionsManagerInterface interface {
 	GetAddressBalance(address string) (wallet.WalletBalance, error)
@@ -19,15 +20,21 @@ type TransactionsManagerInterface interface {
 	GetIfUnapprovedExists(txid []byte) (*structures.Transaction, error)
 
 	VerifyTransaction(tx *structures.Transaction, prevtxs []*structures.Transaction, tip []byte) (bool, error)
+	GetTransactionFee(tx *structures.Transaction, prevtxs []*structures.Transaction, tip []byte) (lib.Amount, error)
 
 	ForEachUnspentOutput(address string, callback UnspentTransactionOutputCallbackInterface) error
 	ForEachUnapprovedTransaction(callback UnApprovedTransactionCallbackInterface) (int, error)
 
 	// Create transaction methods
-	CreateTransaction(PubKey []byte, privKey ecdsa.PrivateKey, to string, amount float64) (*structures.Transaction, error)
+	CreateTransaction(PubKey []byte, privKey ecdsa.PrivateKey, to string, amount lib.Amount, fee lib.Amount) (*structures.Transaction, error)
+	CreateDataTransaction(PubKey []byte, privKey ecdsa.PrivateKey, data []byte, fee lib.Amount) (*structures.Transaction, error)
 	ReceivedNewTransaction(tx *structures.Transaction) error
 	ReceivedNewTransactionData(txBytes []byte, Signatures [][]byte) (*structures.Transaction, error)
-	PrepareNewTransaction(PubKey []byte, to string, amount float64) ([]byte, [][]byte, error)
+	PrepareNewTransaction(PubKey []byte, to string, amount lib.Amount, fee lib.Amount) ([]byte, [][]byte, error)
+	PrepareNewDataTransaction(PubKey []byte, data []byte, fee lib.Amount) ([]byte, [][]byte, error)
+	GetInputTransactions(txBytes []byte) ([][]byte, error)
+
+	FindDataTransaction(data []byte) (*structures.Transaction, *structures.Block, error)
 
 	// new block was created in blockchain DB. It must not be on top of primary blockchain
 	BlockAdded(block *structures.Block, ontopofchain bool) error
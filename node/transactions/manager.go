@@ -5,23 +5,39 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"strconv"
+	"sort"
 
 	"github.com/taincoin/taincoin/lib"
 	"github.com/taincoin/taincoin/lib/utils"
 	"github.com/taincoin/taincoin/lib/wallet"
 	"github.com/taincoin/taincoin/node/blockchain"
 	"github.com/taincoin/taincoin/node/database"
+	"github.com/taincoin/taincoin/node/events"
 	"github.com/taincoin/taincoin/node/structures"
 )
 
+// Transactions locked for longer than this are not accepted to the pool. Locks in blocks are
+// compared with same time of blocks
+const MaxPoolLockTime = 2 * 24 * 60 * 60
+const MaxPoolLockBlocks = MaxPoolLockTime / lib.TargetBlockTime
+
+// Max number of transactions in the pool waiting for their locks. Others with smaller fee rate are removed
+const MaxPoolImmatureTransactions = 1000
+
 type txManager struct {
 	DB     database.DBManager
 	Logger *utils.LoggerMan
+	Events events.Publisher
 }
 
 func NewManager(DB database.DBManager, Logger *utils.LoggerMan) TransactionsManagerInterface {
-	return &txManager{DB, Logger}
+	return &txManager{DB, Logger, nil}
+}
+
+// Creates a manager that publishes events about new blocks and transactions. Events of changes
+// done in a DB batch must go to a queue, it is published after the batch is saved
+func NewManagerWithEvents(DB database.DBManager, Logger *utils.LoggerMan, Events events.Publisher) TransactionsManagerInterface {
+	return &txManager{DB, Logger, Events}
 }
 
 // Create tx index object to use in this package
@@ -100,14 +116,40 @@ func (n *txManager) GetUnspentCount() (int, error) {
 
 // return number of unapproved transactions for new block. detect conflicts
 // if there are less, it returns less than requested
+// Transactions with bigger fee rate are chosen first. Transactions with locks that are not
+// reached yet stay in the pool and are skipped
 func (n *txManager) GetUnapprovedTransactionsForNewBlock(number int) ([]*structures.Transaction, error) {
-	txlist, err := n.getUnapprovedTransactionsManager().GetTransactions(number)
+	total, err := n.getUnapprovedTransactionsManager().GetCount()
+
+	if err != nil {
+		return nil, err
+	}
+
+	txlist, err := n.getUnapprovedTransactionsManager().GetTransactions(total)
+
+	if err != nil {
+		return nil, err
+	}
+
+	txlist = n.sortTransactionsByFeeRate(txlist)
 
 	n.Logger.Trace.Printf("Found %d transaction to mine\n", len(txlist))
 
 	txs := []*structures.Transaction{}
+	// transactions that are not mature yet. Transactions spending their outputs wait too
+	immature := map[string]bool{}
 
 	for _, tx := range txlist {
+		if len(txs) >= number {
+			break
+		}
+
+		if n.dependsOn(tx, immature) {
+			immature[hex.EncodeToString(tx.ID)] = true
+			n.limitImmature(tx, len(immature))
+			continue
+		}
+
 		n.Logger.Trace.Printf("Go to verify: %x\n", tx.ID)
 
 		// we need to verify each transaction
@@ -115,6 +157,14 @@ func (n *txManager) GetUnapprovedTransactionsForNewBlock(number int) ([]*structu
 		// also, a transaction can have input from other transaction from thi block
 		vtx, err := n.VerifyTransaction(tx, txs, []byte{})
 
+		if isImmatureError(err) {
+			// the transaction stays in the pool until it matures
+			n.Logger.Trace.Printf("Skip transaction %x: %s\n", tx.ID, err.Error())
+			immature[hex.EncodeToString(tx.ID)] = true
+			n.limitImmature(tx, len(immature))
+			continue
+		}
+
 		if err != nil {
 			// this can be case when a transaction is based on other unapproved transaction
 			// and that transaction was created in same second
@@ -184,22 +234,20 @@ func (n *txManager) CancelTransaction(txid []byte) error {
 // NOTE Transaction can have outputs of other transactions that are not yet approved.
 // This must be considered as correct case
 func (n *txManager) VerifyTransaction(tx *structures.Transaction, prevtxs []*structures.Transaction, tip []byte) (bool, error) {
-	inputTXs, notFoundInputs, err := n.getInputTransactionsState(tx, tip)
+	inputTXs, err := n.getInputTransactions(tx, prevtxs, tip)
+
 	if err != nil {
 		return false, err
 	}
+	// do final check against inputs
 
-	if len(notFoundInputs) > 0 {
-		// some of inputs can be from other transactions in this pool
-		inputTXs, err = n.getUnapprovedTransactionsManager().CheckInputsWereBefore(notFoundInputs, prevtxs, inputTXs)
+	err = tx.Verify(inputTXs)
 
-		if err != nil {
-			return false, err
-		}
+	if err != nil {
+		return false, err
 	}
-	// do final check against inputs
 
-	err = tx.Verify(inputTXs)
+	err = n.checkTransactionLocks(tx, tip)
 
 	if err != nil {
 		return false, err
@@ -208,6 +256,261 @@ func (n *txManager) VerifyTransaction(tx *structures.Transaction, prevtxs []*str
 	return true, nil
 }
 
+// Checks if lock time and relative locks of a transaction allow to add it to a block after tip.
+// Empty tip is the top of the primary branch. Returns TXVerifyError with TXVerifyErrorImmature kind
+// if the transaction must wait
+func (n *txManager) checkTransactionLocks(tx *structures.Transaction, tip []byte) error {
+	if !tx.HasLocks() {
+		return nil
+	}
+
+	bcMan, err := blockchain.NewBlockchainManager(n.DB, n.Logger)
+
+	if err != nil {
+		return err
+	}
+
+	if len(tip) == 0 {
+		tip, _, err = bcMan.GetState()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	tipBlock, err := bcMan.GetBlock(tip)
+
+	if err != nil {
+		return err
+	}
+
+	medianTime, err := bcMan.GetMedianTimePast(tip)
+
+	if err != nil {
+		return err
+	}
+
+	confirmations := map[int]structures.OutputConfirmation{}
+
+	for inID, vin := range tx.Vin {
+		if vin.RelativeLock == 0 {
+			continue
+		}
+
+		blockHashes, err := n.getIndexManager().GetTranactionBlocks(vin.Txid)
+
+		if err != nil {
+			return err
+		}
+
+		blockHash, err := bcMan.ChooseHashUnderTip(blockHashes, tip)
+
+		if err != nil {
+			return err
+		}
+
+		if blockHash == nil {
+			// output is not yet in blocks
+			continue
+		}
+
+		block, err := bcMan.GetBlock(blockHash)
+
+		if err != nil {
+			return err
+		}
+
+		blockTime, err := bcMan.GetMedianTimePast(block.PrevBlockHash)
+
+		if err != nil {
+			return err
+		}
+		confirmations[inID] = structures.OutputConfirmation{Height: block.Height, Time: blockTime}
+	}
+
+	err = tx.CheckLocks(tipBlock.Height+1, medianTime, confirmations)
+
+	if err != nil {
+		return NewTXVerifyError(err.Error(), TXVerifyErrorImmature, tx.ID)
+	}
+	return nil
+}
+
+// Removes a transaction waiting for its locks if there are too many of them in the pool.
+// Transactions are sorted by fee rate, so ones with smaller fee are removed
+func (n *txManager) limitImmature(tx *structures.Transaction, count int) {
+	if count <= MaxPoolImmatureTransactions {
+		return
+	}
+	n.Logger.Trace.Printf("Delete transaction %x. Too many transactions wait for locks\n", tx.ID)
+	n.CancelTransaction(tx.ID)
+}
+
+// Returns error if a new transaction must wait for locks and the pool already has max number
+// of such transactions. Checked for every received transaction, not only on minting nodes
+func (n *txManager) checkImmatureLimit(tx *structures.Transaction) error {
+	if !tx.HasLocks() {
+		// can wait only if it spends outputs of other transactions in the pool
+		inPool := false
+
+		for _, vin := range tx.Vin {
+			ptx, err := n.GetIfUnapprovedExists(vin.Txid)
+
+			if err != nil {
+				return err
+			}
+
+			if ptx != nil {
+				inPool = true
+				break
+			}
+		}
+
+		if !inPool {
+			return nil
+		}
+	}
+
+	immature, err := n.getImmatureTransactions()
+
+	if err != nil {
+		return err
+	}
+
+	if len(immature) < MaxPoolImmatureTransactions {
+		return nil
+	}
+
+	if !n.dependsOn(tx, immature) {
+		err = n.checkTransactionLocks(tx, []byte{})
+
+		if !isImmatureError(err) {
+			return err
+		}
+	}
+	return errors.New(fmt.Sprintf("Too many transactions wait for locks. Max is %d", MaxPoolImmatureTransactions))
+}
+
+// Returns IDs of transactions in the pool that wait for their locks or spend outputs of such transactions
+func (n *txManager) getImmatureTransactions() (map[string]bool, error) {
+	total, err := n.getUnapprovedTransactionsManager().GetCount()
+
+	if err != nil {
+		return nil, err
+	}
+
+	txlist, err := n.getUnapprovedTransactionsManager().GetTransactions(total)
+
+	if err != nil {
+		return nil, err
+	}
+
+	immature := map[string]bool{}
+
+	for _, tx := range txlist {
+		err = n.checkTransactionLocks(tx, []byte{})
+
+		if isImmatureError(err) {
+			immature[hex.EncodeToString(tx.ID)] = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	// transactions in the pool are not ordered, repeat until no new dependent is found
+	for found := len(immature) > 0; found; {
+		found = false
+
+		for _, tx := range txlist {
+			txid := hex.EncodeToString(tx.ID)
+
+			if !immature[txid] && n.dependsOn(tx, immature) {
+				immature[txid] = true
+				found = true
+			}
+		}
+	}
+	return immature, nil
+}
+
+// Returns error if a transaction can not be added to a block for too long. Such transactions
+// are not accepted to the pool, they would stay there
+func (n *txManager) checkLocksHorizon(tx *structures.Transaction) error {
+	if !tx.HasLocks() {
+		return nil
+	}
+
+	bcMan, err := blockchain.NewBlockchainManager(n.DB, n.Logger)
+
+	if err != nil {
+		return err
+	}
+
+	tip, height, err := bcMan.GetState()
+
+	if err != nil {
+		return err
+	}
+
+	medianTime, err := bcMan.GetMedianTimePast(tip)
+
+	if err != nil {
+		return err
+	}
+
+	if tx.IsLockedLongerThan(height+1, medianTime, MaxPoolLockBlocks, MaxPoolLockTime) {
+		return errors.New(fmt.Sprintf("Transaction is locked for too long. Max is %d blocks or %d seconds", MaxPoolLockBlocks, MaxPoolLockTime))
+	}
+	return nil
+}
+
+// Returns true if a transaction spends outputs of any of transactions
+func (n *txManager) dependsOn(tx *structures.Transaction, txids map[string]bool) bool {
+	for _, vin := range tx.Vin {
+		if txids[hex.EncodeToString(vin.Txid)] {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if an error is about a transaction that can not be added to a block yet
+func isImmatureError(err error) bool {
+	verr, ok := err.(*TXVerifyError)
+
+	return ok && verr.GetKind() == TXVerifyErrorImmature
+}
+
+// Returns a fee of a transaction. Inputs are found same way as in VerifyTransaction
+func (n *txManager) GetTransactionFee(tx *structures.Transaction, prevtxs []*structures.Transaction, tip []byte) (lib.Amount, error) {
+	inputTXs, err := n.getInputTransactions(tx, prevtxs, tip)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return tx.GetFee(inputTXs)
+}
+
+// Finds transactions used as inputs. Agains blockchain and in the list of previous transactions
+func (n *txManager) getInputTransactions(tx *structures.Transaction, prevtxs []*structures.Transaction, tip []byte) (map[int]*structures.Transaction, error) {
+	inputTXs, notFoundInputs, err := n.getInputTransactionsState(tx, tip)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(notFoundInputs) > 0 {
+		// some of inputs can be from other transactions in this pool
+		inputTXs, err = n.getUnapprovedTransactionsManager().CheckInputsWereBefore(notFoundInputs, prevtxs, inputTXs)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+	return inputTXs, nil
+}
+
 // Iterate over unapproved transactions, for example to display them . Accepts callback as argument
 func (n *txManager) ForEachUnapprovedTransaction(callback UnApprovedTransactionCallbackInterface) (int, error) {
 	return n.getUnapprovedTransactionsManager().forEachUnapprovedTransaction(callback)
@@ -226,11 +529,14 @@ func (n *txManager) CleanUnapprovedCache() error {
 // to execute when new block added . the block must not be on top
 func (n *txManager) BlockAdded(block *structures.Block, ontopofchain bool) error {
 	// update caches
-	n.getIndexManager().BlockAdded(block)
+	err := n.getIndexManager().BlockAdded(block)
+
+	if err != nil {
+		return err
+	}
 
 	if ontopofchain {
-		n.getUnapprovedTransactionsManager().DeleteFromBlock(block)
-		n.getUnspentOutputsManager().UpdateOnBlockAdd(block)
+		return n.BlockAddedToPrimaryChain(block)
 	}
 
 	return nil
@@ -238,24 +544,76 @@ func (n *txManager) BlockAdded(block *structures.Block, ontopofchain bool) error
 
 // Block was removed from the top of primary blockchain branch
 func (n *txManager) BlockRemoved(block *structures.Block) error {
-	n.getUnapprovedTransactionsManager().AddFromCanceled(block.Transactions)
-	n.getUnspentOutputsManager().UpdateOnBlockCancel(block)
-	n.getIndexManager().BlockRemoved(block)
-	return nil
+	err := n.BlockRemovedFromPrimaryChain(block)
+
+	if err != nil {
+		return err
+	}
+	return n.getIndexManager().BlockRemoved(block)
 }
 
 // block is now added to primary chain. it existed in DB before
 func (n *txManager) BlockAddedToPrimaryChain(block *structures.Block) error {
-	n.getUnapprovedTransactionsManager().DeleteFromBlock(block)
-	n.getUnspentOutputsManager().UpdateOnBlockAdd(block)
+	err := n.getUnapprovedTransactionsManager().DeleteFromBlock(block)
+
+	if err != nil {
+		return err
+	}
+
+	err = n.getUnspentOutputsManager().UpdateOnBlockAdd(block)
+
+	if err != nil {
+		return err
+	}
+
+	n.publishBlock(block)
 	return nil
 }
 
+// Publishes events about a block added to the main chain and payments in it
+func (n *txManager) publishBlock(block *structures.Block) {
+	if n.Events == nil {
+		return
+	}
+
+	n.Events.Publish(events.Event{Kind: events.KindBlock, BlockHash: block.Hash, Height: block.Height})
+
+	for _, tx := range block.Transactions {
+		n.publishPayments(tx, block)
+	}
+}
+
+// Publishes events about outputs of a transaction. Block is nil for a transaction in the pool
+func (n *txManager) publishPayments(tx *structures.Transaction, block *structures.Block) {
+	for i, out := range tx.Vout {
+		if out.IsData() {
+			continue
+		}
+		address, err := utils.PubKeyHashToAddres(out.PubKeyHash)
+
+		if err != nil {
+			continue
+		}
+
+		e := events.Event{Kind: events.KindPayment, TXID: tx.ID, Vout: i, Address: address, Amount: out.Value}
+
+		if block != nil {
+			e.Confirmed = true
+			e.BlockHash = block.Hash
+			e.Height = block.Height
+		}
+		n.Events.Publish(e)
+	}
+}
+
 // block is removed from primary chain. it continued to be in DB on side branch
 func (n *txManager) BlockRemovedFromPrimaryChain(block *structures.Block) error {
-	n.getUnapprovedTransactionsManager().AddFromCanceled(block.Transactions)
-	n.getUnspentOutputsManager().UpdateOnBlockCancel(block)
-	return nil
+	err := n.getUnapprovedTransactionsManager().AddFromCanceled(block.Transactions)
+
+	if err != nil {
+		return err
+	}
+	return n.getUnspentOutputsManager().UpdateOnBlockCancel(block)
 }
 
 // Send amount of money if a node is not running.
@@ -264,16 +622,19 @@ func (n *txManager) BlockRemovedFromPrimaryChain(block *structures.Block) error
 //
 // Returns new transaction hash. This return can be used to try to send transaction
 // to other nodes or to try mining
-func (n *txManager) CreateTransaction(PubKey []byte, privKey ecdsa.PrivateKey, to string, amount float64) (*structures.Transaction, error) {
+func (n *txManager) CreateTransaction(PubKey []byte, privKey ecdsa.PrivateKey, to string, amount lib.Amount, fee lib.Amount) (*structures.Transaction, error) {
 
 	if amount <= 0 {
 		return nil, errors.New("Amount must be positive value")
 	}
+	if fee < 0 {
+		return nil, errors.New("Fee can not be negative")
+	}
 	if to == "" {
 		return nil, errors.New("Recipient address is not provided")
 	}
 
-	txBytes, DataToSign, err := n.PrepareNewTransaction(PubKey, to, amount)
+	txBytes, DataToSign, err := n.PrepareNewTransaction(PubKey, to, amount, fee)
 
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("Prepare error: %s", err.Error()))
@@ -293,6 +654,23 @@ func (n *txManager) CreateTransaction(PubKey []byte, privKey ecdsa.PrivateKey, t
 	return NewTX, nil
 }
 
+// Makes a transaction with a data output, signs it and adds to the pool
+func (n *txManager) CreateDataTransaction(PubKey []byte, privKey ecdsa.PrivateKey, data []byte, fee lib.Amount) (*structures.Transaction, error) {
+	txBytes, DataToSign, err := n.PrepareNewDataTransaction(PubKey, data, fee)
+
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Prepare error: %s", err.Error()))
+	}
+
+	signatures, err := utils.SignDataSet(PubKey, privKey, DataToSign)
+
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Sign error: %s", err.Error()))
+	}
+
+	return n.ReceivedNewTransactionData(txBytes, signatures)
+}
+
 // New transactions created. It is received in serialysed view and signatures separately
 // This data is ready to be convertd to complete gransaction
 func (n *txManager) ReceivedNewTransactionData(txBytes []byte, Signatures [][]byte) (*structures.Transaction, error) {
@@ -329,33 +707,85 @@ func (n *txManager) ReceivedNewTransaction(tx *structures.Transaction) error {
 	if !good {
 		return errors.New("Transaction verification failed")
 	}
+
+	err = n.checkLocksHorizon(tx)
+
+	if err != nil {
+		return err
+	}
+
+	err = n.checkImmatureLimit(tx)
+
+	if err != nil {
+		return err
+	}
 	// if all is ok, add it to the list of unapproved
-	return n.getUnapprovedTransactionsManager().Add(tx)
+	err = n.getUnapprovedTransactionsManager().Add(tx)
+
+	if err != nil {
+		return err
+	}
+
+	if n.Events != nil {
+		n.Events.Publish(events.Event{Kind: events.KindTransaction, TXID: tx.ID})
+		n.publishPayments(tx, nil)
+	}
+	return nil
 }
 
 // Request to make new transaction and prepare data to sign
 // This function should find good input transactions for this amount
 // Including inputs from unapproved transactions if no good approved transactions yet
-func (n *txManager) PrepareNewTransaction(PubKey []byte, to string, amount float64) ([]byte, [][]byte, error) {
-	amount, err := strconv.ParseFloat(fmt.Sprintf("%.8f", amount), 64)
+// Inputs must cover the amount and a fee. A fee is not sent anywhere, it is what is left for a miner
+func (n *txManager) PrepareNewTransaction(PubKey []byte, to string, amount lib.Amount, fee lib.Amount) ([]byte, [][]byte, error) {
+	if amount < lib.SmallestUnit {
+		return nil, nil, errors.New("Amount must be positive")
+	}
+
+	return n.prepareNewTransaction(PubKey, to, amount, nil, fee)
+}
+
+// Request to make new transaction with a data output and prepare data to sign.
+// Inputs cover only a fee, the rest is sent back to the sender
+func (n *txManager) PrepareNewDataTransaction(PubKey []byte, data []byte, fee lib.Amount) ([]byte, [][]byte, error) {
+	_, err := structures.NewDataOutput(data)
 
 	if err != nil {
 		return nil, nil, err
 	}
+
+	return n.prepareNewTransaction(PubKey, "", 0, data, fee)
+}
+
+func (n *txManager) prepareNewTransaction(PubKey []byte, to string, amount lib.Amount, data []byte,
+	fee lib.Amount) ([]byte, [][]byte, error) {
+
+	if fee < 0 {
+		return nil, nil, errors.New("Fee can not be negative")
+	}
+
+	// amount to find in inputs
+	needed := amount + fee
+
+	if needed < lib.SmallestUnit {
+		// a transaction with data and without a fee still needs an input
+		needed = lib.SmallestUnit
+	}
+
 	PubKeyHash, _ := utils.HashPubKey(PubKey)
 	// get from pending transactions. find outputs used by this pubkey
 	pendinginputs, pendingoutputs, _, err := n.getUnapprovedTransactionsManager().GetPreparedBy(PubKeyHash)
 	n.Logger.Trace.Printf("Pending transactions state: %d- inputs, %d - unspent outputs", len(pendinginputs), len(pendingoutputs))
 
-	inputs, prevTXs, totalamount, err := n.getUnspentOutputsManager().GetNewTransactionInputs(PubKey, to, amount, pendinginputs)
+	inputs, prevTXs, totalamount, err := n.getUnspentOutputsManager().GetNewTransactionInputs(PubKey, to, needed, pendinginputs)
 
 	if err != nil {
 		return nil, nil, err
 	}
 
-	n.Logger.Trace.Printf("First step prepared amount %f of %f", totalamount, amount)
+	n.Logger.Trace.Printf("First step prepared amount %s of %s", totalamount, needed)
 
-	if totalamount < amount {
+	if totalamount < needed {
 		// no anough funds in confirmed transactions
 		// pending must be used
 
@@ -364,7 +794,7 @@ func (n *txManager) PrepareNewTransaction(PubKey []byte, to string, amount float
 			return nil, nil, errors.New("No enough funds for requested transaction")
 		}
 		inputs, prevTXs, totalamount, err =
-			n.getUnspentOutputsManager().ExtendNewTransactionInputs(PubKey, amount, totalamount,
+			n.getUnspentOutputsManager().ExtendNewTransactionInputs(PubKey, needed, totalamount,
 				inputs, prevTXs, pendingoutputs)
 
 		if err != nil {
@@ -372,27 +802,39 @@ func (n *txManager) PrepareNewTransaction(PubKey []byte, to string, amount float
 		}
 	}
 
-	n.Logger.Trace.Printf("Second step prepared amount %f of %f", totalamount, amount)
+	n.Logger.Trace.Printf("Second step prepared amount %s of %s", totalamount, needed)
 
-	if totalamount < amount {
+	if totalamount < needed {
 		return nil, nil, errors.New("No anough funds to make new transaction")
 	}
 
-	return n.prepareNewTransactionComplete(PubKey, to, amount, inputs, totalamount, prevTXs)
+	return n.prepareNewTransactionComplete(PubKey, to, amount, data, fee, inputs, totalamount, prevTXs)
 }
 
 //
-func (n *txManager) prepareNewTransactionComplete(PubKey []byte, to string, amount float64,
-	inputs []structures.TXInput, totalamount float64, prevTXs map[string]structures.Transaction) ([]byte, [][]byte, error) {
+func (n *txManager) prepareNewTransactionComplete(PubKey []byte, to string, amount lib.Amount, data []byte, fee lib.Amount,
+	inputs []structures.TXInput, totalamount lib.Amount, prevTXs map[string]structures.Transaction) ([]byte, [][]byte, error) {
 
 	var outputs []structures.TXOutput
 
 	// Build a list of outputs
 	from, _ := utils.PubKeyToAddres(PubKey)
-	outputs = append(outputs, *structures.NewTXOutput(amount, to))
 
-	if totalamount > amount && totalamount-amount > lib.SmallestUnit {
-		outputs = append(outputs, *structures.NewTXOutput(totalamount-amount, from)) // a change
+	if data != nil {
+		out, err := structures.NewDataOutput(data)
+
+		if err != nil {
+			return nil, nil, err
+		}
+		outputs = append(outputs, *out)
+	} else {
+		outputs = append(outputs, *structures.NewTXOutput(amount, to))
+	}
+
+	change := totalamount - amount - fee
+
+	if change >= lib.SmallestUnit {
+		outputs = append(outputs, *structures.NewTXOutput(change, from)) // a change
 	}
 
 	inputTXs := make(map[int]*structures.Transaction)
@@ -402,7 +844,7 @@ func (n *txManager) prepareNewTransactionComplete(PubKey []byte, to string, amou
 		inputTXs[vinInd] = &tx
 	}
 
-	tx := structures.Transaction{nil, inputs, outputs, 0}
+	tx := structures.Transaction{Vin: inputs, Vout: outputs}
 	tx.TimeNow()
 
 	signdata, err := tx.PrepareSignData(inputTXs)
@@ -420,6 +862,94 @@ func (n *txManager) prepareNewTransactionComplete(PubKey []byte, to string, amou
 	return txBytes, signdata, nil
 }
 
+// Returns transactions spent by inputs of a prepared transaction. Every one is serialized
+// without ID (see SerializeForHash), so a signer offline can check it and know values of inputs
+func (n *txManager) GetInputTransactions(txBytes []byte) ([][]byte, error) {
+	tx := structures.Transaction{}
+
+	err := tx.DeserializeTransaction(txBytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := [][]byte{}
+	added := map[string]bool{}
+
+	for _, vin := range tx.Vin {
+		if added[string(vin.Txid)] {
+			continue
+		}
+
+		prevTX, err := n.GetIfExists(vin.Txid)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if prevTX == nil {
+			return nil, errors.New(fmt.Sprintf("Previous transaction %x is not found", vin.Txid))
+		}
+
+		b, err := prevTX.SerializeForHash()
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, b)
+		added[string(vin.Txid)] = true
+	}
+
+	return result, nil
+}
+
+// Finds a transaction with a data output in the main chain and a block where it is.
+// If same data were added few times, the earliest transaction is returned.
+// Returns nil if the data are not found
+func (n *txManager) FindDataTransaction(data []byte) (*structures.Transaction, *structures.Block, error) {
+	txIDs, err := n.getIndexManager().GetDataTransactions(data)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bcMan, err := blockchain.NewBlockchainManager(n.DB, n.Logger)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var resultTX *structures.Transaction
+	var resultBlock *structures.Block
+
+	for _, txID := range txIDs {
+		// empty top hash means the main chain
+		tx, _, blockHash, err := n.getIndexManager().GetTransactionAllInfo(txID, []byte{})
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if tx == nil {
+			// in other branch
+			continue
+		}
+
+		block, err := bcMan.GetBlock(blockHash)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if resultBlock == nil || block.Height < resultBlock.Height {
+			resultTX = tx
+			resultBlock = &block
+		}
+	}
+	return resultTX, resultBlock, nil
+}
+
 // check if transaction exists. it checks in all places. in approved and pending
 func (n *txManager) GetIfExists(txid []byte) (*structures.Transaction, error) {
 	// check in pending first
@@ -447,7 +977,7 @@ func (n *txManager) GetIfUnapprovedExists(txid []byte) (*structures.Transaction,
 }
 
 // Calculates pending balance of address.
-func (n *txManager) getAddressPendingBalance(address string) (float64, error) {
+func (n *txManager) getAddressPendingBalance(address string) (lib.Amount, error) {
 	PubKeyHash, _ := utils.AddresToPubKeyHash(address)
 
 	// inputs this is what a wallet spent from his real approved balance
@@ -459,7 +989,7 @@ func (n *txManager) getAddressPendingBalance(address string) (float64, error) {
 		return 0, err
 	}
 
-	pendingbalance := float64(0)
+	pendingbalance := lib.Amount(0)
 
 	for _, o := range outputs {
 		// this is amount sent to this wallet and this
@@ -530,6 +1060,99 @@ func (n *txManager) verifyTransactionQuick(tx *structures.Transaction) (bool, er
 	return true, nil
 }
 
+// Returns a fee of a transaction. Inputs are found in the cache of unspent outputs and in the cache of unapproved
+func (n *txManager) getTransactionFeeQuick(tx *structures.Transaction) (lib.Amount, error) {
+	notFoundInputs, inputTXs, err := n.getUnspentOutputsManager().VerifyTransactionsOutputsAreNotSpent(tx.Vin)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if len(notFoundInputs) > 0 {
+		err := n.getUnapprovedTransactionsManager().CheckInputsArePrepared(notFoundInputs, inputTXs)
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return tx.GetFee(inputTXs)
+}
+
+// Sorts transactions by a fee rate (fee per byte of a transaction), bigger first.
+// If a transaction uses outputs of other transaction from the list, it is placed after that transaction
+func (n *txManager) sortTransactionsByFeeRate(txlist []*structures.Transaction) []*structures.Transaction {
+	rates := map[string]float64{}
+	inlist := map[string]bool{}
+
+	for _, tx := range txlist {
+		key := hex.EncodeToString(tx.ID)
+		inlist[key] = true
+		rates[key] = 0
+
+		fee, err := n.getTransactionFeeQuick(tx)
+
+		if err != nil {
+			// this transaction will fail on verification later
+			n.Logger.Trace.Printf("Can not get fee of %x: %s", tx.ID, err.Error())
+			continue
+		}
+
+		txBytes, err := tx.Serialize()
+
+		if err != nil || len(txBytes) == 0 {
+			continue
+		}
+
+		rates[key] = float64(fee) / float64(len(txBytes))
+	}
+
+	// list is sorted by time already. keep this order for same rate
+	sort.SliceStable(txlist, func(i, j int) bool {
+		return rates[hex.EncodeToString(txlist[i].ID)] > rates[hex.EncodeToString(txlist[j].ID)]
+	})
+
+	// move transactions after transactions they depend on
+	result := []*structures.Transaction{}
+	added := map[string]bool{}
+	waiting := map[string][]*structures.Transaction{} // transactions waiting for a parent to be added
+
+	var add func(tx *structures.Transaction)
+
+	add = func(tx *structures.Transaction) {
+		key := hex.EncodeToString(tx.ID)
+
+		if added[key] {
+			return
+		}
+
+		for _, vin := range tx.Vin {
+			inkey := hex.EncodeToString(vin.Txid)
+
+			if inlist[inkey] && !added[inkey] {
+				waiting[inkey] = append(waiting[inkey], tx)
+				return
+			}
+		}
+
+		result = append(result, tx)
+		added[key] = true
+
+		children := waiting[key]
+		delete(waiting, key)
+
+		for _, child := range children {
+			add(child)
+		}
+	}
+
+	for _, tx := range txlist {
+		add(tx)
+	}
+
+	return result
+}
+
 // Verifies transaction inputs. Check if that are real existent transactions. And that outputs are not yet used
 // Is some transaction is not in blockchain, returns nil pointer in map and this input in separate map
 // Missed inputs can be some unconfirmed transactions
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"sort"
 
+	"github.com/taincoin/taincoin/lib"
 	"github.com/taincoin/taincoin/lib/utils"
 	"github.com/taincoin/taincoin/node/database"
 	"github.com/taincoin/taincoin/node/structures"
@@ -204,7 +205,7 @@ func (u *unApprovedTransactions) GetPreparedBy(PubKeyHash []byte) ([]structures.
 }
 
 // Get input value for TX in the cache
-func (u *unApprovedTransactions) GetInputValue(input structures.TXInput) (float64, error) {
+func (u *unApprovedTransactions) GetInputValue(input structures.TXInput) (lib.Amount, error) {
 	u.Logger.Trace.Printf("Find TX %x in unapproved", input.Txid)
 	tx, err := u.GetIfExists(input.Txid)
 
@@ -8,6 +8,7 @@ import (
 	"log"
 	"sort"
 
+	"github.com/taincoin/taincoin/lib"
 	"github.com/taincoin/taincoin/lib/utils"
 	"github.com/taincoin/taincoin/lib/wallet"
 	"github.com/taincoin/taincoin/node/blockchain"
@@ -57,7 +58,7 @@ func (u unspentTransactions) deserializeOutputs(data []byte) ([]structures.TXOut
 /*
 * Calculates address balance using the cache of unspent transactions outputs
  */
-func (u unspentTransactions) GetAddressBalance(address string) (float64, error) {
+func (u unspentTransactions) GetAddressBalance(address string) (lib.Amount, error) {
 	if address == "" {
 		return 0, errors.New("Address is missed")
 	}
@@ -67,7 +68,7 @@ func (u unspentTransactions) GetAddressBalance(address string) (float64, error)
 		return 0, errors.New("Address is not valid")
 	}
 
-	balance := float64(0)
+	balance := lib.Amount(0)
 
 	UnspentTXs, err2 := u.GetunspentTransactionsOutputs(address)
 
@@ -82,7 +83,7 @@ func (u unspentTransactions) GetAddressBalance(address string) (float64, error)
 }
 
 // CGet input value. Input is unspent TX output
-func (u unspentTransactions) GetInputValue(input structures.TXInput) (float64, error) {
+func (u unspentTransactions) GetInputValue(input structures.TXInput) (lib.Amount, error) {
 
 	uodb, err := u.DB.GetUnspentOutputsObject()
 
@@ -117,8 +118,8 @@ func (u unspentTransactions) GetInputValue(input structures.TXInput) (float64, e
 }
 
 // Choose inputs for new transaction
-func (u unspentTransactions) ChooseSpendableOutputs(pubKeyHash []byte, amount float64,
-	pendinguse []structures.TXInput) (float64, []structures.TXOutputIndependent, error) {
+func (u unspentTransactions) ChooseSpendableOutputs(pubKeyHash []byte, amount lib.Amount,
+	pendinguse []structures.TXInput) (lib.Amount, []structures.TXOutputIndependent, error) {
 
 	uodb, err := u.DB.GetUnspentOutputsObject()
 
@@ -127,7 +128,7 @@ func (u unspentTransactions) ChooseSpendableOutputs(pubKeyHash []byte, amount fl
 	}
 
 	unspentOutputs := []structures.TXOutputIndependent{}
-	accumulated := float64(0)
+	accumulated := lib.Amount(0)
 
 	err = uodb.ForEach(func(txID, txData []byte) error {
 		outs, err := u.deserializeOutputs(txData)
@@ -397,6 +398,10 @@ func (u unspentTransactions) FindunspentTransactions() (map[string][]structures.
 			var spent bool
 
 			for outIdx, out := range tx.Vout {
+				if out.IsData() {
+					// data outputs can not be spent
+					continue
+				}
 				// Was the output spent?
 				spent = false
 
@@ -522,11 +527,19 @@ func (u unspentTransactions) UpdateOnBlockAdd(block *structures.Block) error {
 		newOutputs := []structures.TXOutputIndependent{}
 
 		for outInd, out := range tx.Vout {
+			if out.IsData() {
+				// data outputs can not be spent, they are never unspent outputs
+				continue
+			}
 			no := structures.TXOutputIndependent{}
 			no.LoadFromSimple(out, tx.ID, outInd, sender, tx.IsCoinbase(), block.Hash)
 			newOutputs = append(newOutputs, no)
 		}
 
+		if len(newOutputs) == 0 {
+			continue
+		}
+
 		d, err := u.serializeOutputs(newOutputs)
 
 		if err != nil {
@@ -594,6 +607,9 @@ func (u unspentTransactions) UpdateOnBlockCancel(block *structures.Block) error
 			UnspentOuts := []structures.TXOutputIndependent{}
 
 			for outInd, out := range txi.Vout {
+				if out.IsData() {
+					continue
+				}
 				spent := false
 
 				for _, so := range spending {
@@ -637,10 +653,10 @@ func (u unspentTransactions) UpdateOnBlockCancel(block *structures.Block) error
 // not yet confirmed transactions
 // Returns list of inputs prepared. Even if less then requested
 // Returns previous transactions. It later will be used to prepare data to sign
-func (u unspentTransactions) GetNewTransactionInputs(PubKey []byte, to string, amount float64,
-	pendinguse []structures.TXInput) ([]structures.TXInput, map[string]structures.Transaction, float64, error) {
+func (u unspentTransactions) GetNewTransactionInputs(PubKey []byte, to string, amount lib.Amount,
+	pendinguse []structures.TXInput) ([]structures.TXInput, map[string]structures.Transaction, lib.Amount, error) {
 
-	localError := func(err error) ([]structures.TXInput, map[string]structures.Transaction, float64, error) {
+	localError := func(err error) ([]structures.TXInput, map[string]structures.Transaction, lib.Amount, error) {
 		return nil, nil, 0, err
 	}
 
@@ -666,7 +682,7 @@ func (u unspentTransactions) GetNewTransactionInputs(PubKey []byte, to string, a
 
 	// Build a list of inputs
 	for _, out := range validOutputs {
-		input := structures.TXInput{out.TXID, out.OIndex, nil, PubKey}
+		input := structures.TXInput{Txid: out.TXID, Vout: out.OIndex, PubKey: PubKey}
 		inputs = append(inputs, input)
 
 		prevTX, err := bcMan.GetTransactionFromBlock(out.TXID, out.BlockHash)
@@ -680,13 +696,13 @@ func (u unspentTransactions) GetNewTransactionInputs(PubKey []byte, to string, a
 }
 
 // Returns previous transactions. It later will be used to prepare data to sign
-func (u unspentTransactions) ExtendNewTransactionInputs(PubKey []byte, amount, totalamount float64,
+func (u unspentTransactions) ExtendNewTransactionInputs(PubKey []byte, amount, totalamount lib.Amount,
 	inputs []structures.TXInput, prevTXs map[string]structures.Transaction,
-	pendingoutputs []*structures.TXOutputIndependent) ([]structures.TXInput, map[string]structures.Transaction, float64, error) {
+	pendingoutputs []*structures.TXOutputIndependent) ([]structures.TXInput, map[string]structures.Transaction, lib.Amount, error) {
 
 	// Build a list of inputs
 	for _, out := range pendingoutputs {
-		input := structures.TXInput{out.TXID, out.OIndex, nil, PubKey}
+		input := structures.TXInput{Txid: out.TXID, Vout: out.OIndex, PubKey: PubKey}
 		inputs = append(inputs, input)
 
 		prevTX := structures.Transaction{}